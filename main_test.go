@@ -1,1331 +0,0 @@
-package main
-
-import (
-	"encoding/json"
-	"errors"
-	"flag"
-	"os"
-	"path/filepath"
-	"strings"
-	"testing"
-	"time"
-)
-
-// =============================================================================
-// MAIN FUNCTIONALITY TESTS
-// =============================================================================
-// This file contains all tests related to:
-// - Main application execution flow
-// - Symlink creation and management
-// - Directory processing
-// - File operations
-// - Version and flag handling
-// =============================================================================
-
-// TestMain sets up mocking for all tests
-func TestMain(m *testing.M) {
-	// Set up default mock for symlink function to avoid permission issues
-	originalSymlink := symlinkFunc
-	symlinkFunc = mockSymlink
-	
-	// Mock parseFlags to avoid flag redefinition errors
-	originalParseFlags := parseFlags
-	parseFlags = func() (*bool, *bool) {
-		versionFlag := false
-		updateFlag := false
-		return &versionFlag, &updateFlag
-	}
-	
-	code := m.Run()
-	
-	// Restore original functions
-	symlinkFunc = originalSymlink
-	parseFlags = originalParseFlags
-	
-	os.Exit(code)
-}
-
-// Mock symlink function that creates a regular file instead
-func mockSymlink(oldname, newname string) error {
-	content := []byte("SYMLINK:" + oldname)
-	return os.WriteFile(newname, content, 0644)
-}
-
-// Helper function to create test directory
-func setupTestDir(t *testing.T) string {
-	tempDir, err := os.MkdirTemp("", "secret_manager_test")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-	return tempDir
-}
-
-// Helper function to create test file
-func createFile(t *testing.T, path string, content string) {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		t.Fatalf("Failed to create directory %s: %v", dir, err)
-	}
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		t.Fatalf("Failed to create file %s: %v", path, err)
-	}
-}
-
-// Test main function execution
-func TestMainFunction(t *testing.T) {
-	originalExit := exitFunc
-	originalStderr := os.Stderr
-	originalExeDir := executableDir
-	
-	tests := []struct {
-		name        string
-		setup       func(string)
-		expectExit  bool
-		exitCode    int
-		exeDirError bool
-	}{
-		{
-			name: "success_case",
-			setup: func(dir string) {
-				os.MkdirAll(filepath.Join(dir, "my_secret"), 0755)
-				createFile(t, filepath.Join(dir, "my_secret/test.txt"), "content")
-				config := SymlinkConfig{
-					Targets: []Target{{Path: "link.txt", Description: "test"}},
-				}
-				data, _ := json.Marshal(config)
-				createFile(t, filepath.Join(dir, "my_secret/test.txt.symlink.json"), string(data))
-			},
-			expectExit: false,
-		},
-		{
-			name: "error_case",
-			setup: func(dir string) {
-				// No directories with 'secret' in name
-			},
-			expectExit: true,
-			exitCode:   0, // Now exits with 0 when no secret directories found
-		},
-		{
-			name: "find_directories_error",
-			setup: func(dir string) {
-				// Create a file named "secret" instead of directory to cause issues
-				createFile(t, filepath.Join(dir, "secret"), "not a directory")
-			},
-			expectExit: false, // findSecretDirectories handles this gracefully
-		},
-		{
-			name: "process_directory_error",
-			setup: func(dir string) {
-				// Create a secret directory with invalid symlink config
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(secretDir, 0755)
-				// Create a file that will be processed but with read error
-				configPath := filepath.Join(secretDir, "test.txt.symlink.json")
-				createFile(t, configPath, "valid json")
-				// Make the config file unreadable (Windows may ignore this)
-				os.Chmod(configPath, 0000)
-			},
-			expectExit: false, // processSecretDirectory continues on error
-		},
-		{
-			name: "exe_dir_error",
-			setup: func(dir string) {
-				// No setup needed
-			},
-			expectExit:  true,
-			exitCode:    1,
-			exeDirError: true,
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tempDir := setupTestDir(t)
-			defer os.RemoveAll(tempDir)
-			
-			originalWd, _ := os.Getwd()
-			defer os.Chdir(originalWd)
-			
-			// Mock executableDir
-			if tt.exeDirError {
-				executableDir = func() (string, error) {
-					return "", errors.New("mock error")
-				}
-			} else {
-				executableDir = func() (string, error) {
-					return tempDir, nil
-				}
-			}
-			
-			exitCalled := false
-			exitCode := 0
-			exitFunc = func(code int) {
-				exitCalled = true
-				exitCode = code
-			}
-			defer func() { 
-				exitFunc = originalExit
-				executableDir = originalExeDir
-			}()
-			
-			// Capture stderr for error case
-			r, w, _ := os.Pipe()
-			os.Stderr = w
-			
-			tt.setup(tempDir)
-			main()
-			
-			w.Close()
-			os.Stderr = originalStderr
-			output := make([]byte, 1024)
-			n, _ := r.Read(output)
-			output = output[:n]
-			
-			if tt.expectExit && !exitCalled {
-				t.Error("Expected exit to be called")
-			}
-			if tt.expectExit && exitCode != tt.exitCode {
-				t.Errorf("Expected exit code %d, got %d", tt.exitCode, exitCode)
-			}
-			if tt.name == "exe_dir_error" && len(output) == 0 {
-				t.Error("Expected error output for exe_dir_error")
-			}
-		})
-	}
-}
-
-// Test processSecretDirectory function
-func TestProcessSecretDirectory(t *testing.T) {
-	tests := []struct {
-		name    string
-		setup   func(string) string
-		wantErr bool
-	}{
-		{
-			name: "directory_not_found",
-			setup: func(dir string) string {
-				return "/nonexistent/directory"
-			},
-			wantErr: true,
-		},
-		{
-			name: "empty_directory",
-			setup: func(dir string) string {
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(secretDir, 0755)
-				return secretDir
-			},
-			wantErr: false,
-		},
-		{
-			name: "with_subdirectory",
-			setup: func(dir string) string {
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(filepath.Join(secretDir, "subdir"), 0755)
-				return secretDir
-			},
-			wantErr: false,
-		},
-		{
-			name: "missing_source_file",
-			setup: func(dir string) string {
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(secretDir, 0755)
-				config := SymlinkConfig{
-					Targets: []Target{{Path: "link.txt", Description: "test"}},
-				}
-				data, _ := json.Marshal(config)
-				createFile(t, filepath.Join(secretDir, "missing.txt.symlink.json"), string(data))
-				return secretDir
-			},
-			wantErr: false,
-		},
-		{
-			name: "valid_symlink_config",
-			setup: func(dir string) string {
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(secretDir, 0755)
-				createFile(t, filepath.Join(secretDir, "test.txt"), "content")
-				config := SymlinkConfig{
-					Targets: []Target{{Path: filepath.Join(dir, "link.txt"), Description: "test"}},
-				}
-				data, _ := json.Marshal(config)
-				createFile(t, filepath.Join(secretDir, "test.txt.symlink.json"), string(data))
-				return secretDir
-			},
-			wantErr: false,
-		},
-		{
-			name: "invalid_json_config",
-			setup: func(dir string) string {
-				secretDir := filepath.Join(dir, "secret")
-				os.MkdirAll(secretDir, 0755)
-				createFile(t, filepath.Join(secretDir, "test.txt"), "content")
-				createFile(t, filepath.Join(secretDir, "test.txt.symlink.json"), "invalid json")
-				return secretDir
-			},
-			wantErr: false, // processSecretDirectory continues on error
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tempDir := setupTestDir(t)
-			defer os.RemoveAll(tempDir)
-			
-			secretDir := tt.setup(tempDir)
-			err := processSecretDirectory(secretDir)
-			
-			if (err != nil) != tt.wantErr {
-				t.Errorf("processSecretDirectory() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-// Test processSymlinkConfig function
-func TestProcessSymlinkConfig(t *testing.T) {
-	tests := []struct {
-		name       string
-		sourcePath string
-		configPath string
-		setup      func(string)
-		wantErr    bool
-	}{
-		{
-			name: "config_file_not_found",
-			setup: func(dir string) {
-				createFile(t, filepath.Join(dir, "source.txt"), "content")
-			},
-			sourcePath: "source.txt",
-			configPath: "nonexistent.json",
-			wantErr:    true,
-		},
-		{
-			name: "invalid_json",
-			setup: func(dir string) {
-				createFile(t, filepath.Join(dir, "source.txt"), "content")
-				createFile(t, filepath.Join(dir, "config.json"), "invalid json")
-			},
-			sourcePath: "source.txt",
-			configPath: "config.json",
-			wantErr:    true,
-		},
-		{
-			name: "empty_targets",
-			setup: func(dir string) {
-				createFile(t, filepath.Join(dir, "source.txt"), "content")
-				config := SymlinkConfig{Targets: []Target{}}
-				data, _ := json.Marshal(config)
-				createFile(t, filepath.Join(dir, "config.json"), string(data))
-			},
-			sourcePath: "source.txt",
-			configPath: "config.json",
-			wantErr:    false,
-		},
-		{
-			name: "multiple_targets",
-			setup: func(dir string) {
-				createFile(t, filepath.Join(dir, "source.txt"), "content")
-				config := SymlinkConfig{
-					Targets: []Target{
-						{Path: filepath.Join(dir, "link1.txt"), Description: "Link 1"},
-						{Path: filepath.Join(dir, "link2.txt"), Description: "Link 2"},
-					},
-				}
-				data, _ := json.Marshal(config)
-				createFile(t, filepath.Join(dir, "config.json"), string(data))
-			},
-			sourcePath: "source.txt",
-			configPath: "config.json",
-			wantErr:    false,
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tempDir := setupTestDir(t)
-			defer os.RemoveAll(tempDir)
-			
-			originalWd, _ := os.Getwd()
-			os.Chdir(tempDir)
-			defer os.Chdir(originalWd)
-			
-			tt.setup(tempDir)
-			
-			err := processSymlinkConfig(tt.sourcePath, tt.configPath)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("processSymlinkConfig() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-// Test createSymlink function
-func TestCreateSymlink(t *testing.T) {
-	tests := []struct {
-		name      string
-		setup     func() (string, Target)
-		mockSetup func()
-		mockTeardown func()
-		wantErr   bool
-		errMsg    string
-	}{
-		{
-			name: "successful_creation",
-			setup: func() (string, Target) {
-				tempDir := setupTestDir(t)
-				sourcePath := filepath.Join(tempDir, "source.txt")
-				createFile(t, sourcePath, "content")
-				// Create the target directory first
-				os.MkdirAll(filepath.Join(tempDir, "link"), 0755)
-				target := Target{
-					Path:        filepath.Join(tempDir, "link", "target.txt"),
-					Description: "Test link",
-				}
-				return sourcePath, target
-			},
-			wantErr: false,
-		},
-		{
-			name: "directory_not_exist",
-			setup: func() (string, Target) {
-				tempDir := setupTestDir(t)
-				sourcePath := filepath.Join(tempDir, "source.txt")
-				createFile(t, sourcePath, "content")
-				// Target in non-existent directory
-				target := Target{
-					Path:        filepath.Join(tempDir, "nonexistent", "file.txt"),
-					Description: "Test",
-				}
-				return sourcePath, target
-			},
-			wantErr: false, // Now returns nil instead of error
-		},
-		{
-			name: "remove_existing_error",
-			setup: func() (string, Target) {
-				tempDir := setupTestDir(t)
-				sourcePath := filepath.Join(tempDir, "source.txt")
-				createFile(t, sourcePath, "content")
-				target := Target{
-					Path:        filepath.Join(tempDir, "target.txt"),
-					Description: "Test",
-				}
-				return sourcePath, target
-			},
-			mockSetup: func() {
-				originalLstat := lstatFunc
-				originalRemove := removeFunc
-				lstatFunc = func(name string) (os.FileInfo, error) {
-					return nil, nil // File exists
-				}
-				removeFunc = func(name string) error {
-					return errors.New("permission denied")
-				}
-				t.Cleanup(func() {
-					lstatFunc = originalLstat
-					removeFunc = originalRemove
-				})
-			},
-			wantErr: true,
-			errMsg:  "failed to remove existing symlink: permission denied",
-		},
-		{
-			name: "symlink_creation_error",
-			setup: func() (string, Target) {
-				tempDir := setupTestDir(t)
-				sourcePath := filepath.Join(tempDir, "source.txt")
-				createFile(t, sourcePath, "content")
-				target := Target{
-					Path:        filepath.Join(tempDir, "target.txt"),
-					Description: "Test",
-				}
-				return sourcePath, target
-			},
-			mockSetup: func() {
-				originalSymlink := symlinkFunc
-				originalLstat := lstatFunc
-				// Make Lstat return error so Remove is not called
-				lstatFunc = func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
-				}
-				symlinkFunc = func(oldname, newname string) error {
-					return errors.New("symlink failed")
-				}
-				t.Cleanup(func() {
-					symlinkFunc = originalSymlink
-					lstatFunc = originalLstat
-				})
-			},
-			wantErr: true,
-			errMsg:  "failed to create symlink: symlink failed",
-		},
-		{
-			name: "replace_existing_file",
-			setup: func() (string, Target) {
-				tempDir := setupTestDir(t)
-				sourcePath := filepath.Join(tempDir, "source.txt")
-				targetPath := filepath.Join(tempDir, "existing.txt")
-				createFile(t, sourcePath, "source content")
-				createFile(t, targetPath, "existing content")
-				target := Target{Path: targetPath, Description: "Replace"}
-				return sourcePath, target
-			},
-			mockSetup: func() {
-				// Reset to use default mockSymlink
-				originalSymlink := symlinkFunc
-				symlinkFunc = mockSymlink
-				t.Cleanup(func() {
-					symlinkFunc = originalSymlink
-				})
-			},
-			wantErr: false,
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sourcePath, target := tt.setup()
-			defer func() {
-				if dir := filepath.Dir(sourcePath); dir != "" {
-					os.RemoveAll(dir)
-				}
-			}()
-			
-			if tt.mockSetup != nil {
-				tt.mockSetup()
-			}
-			
-			err := createSymlink(sourcePath, target)
-			
-			if (err != nil) != tt.wantErr {
-				t.Errorf("createSymlink() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			
-			if tt.errMsg != "" && err != nil && err.Error() != tt.errMsg {
-				t.Errorf("Expected error %q, got %q", tt.errMsg, err.Error())
-			}
-		})
-	}
-}
-
-// Test error handling with symlink creation continues on error
-func TestSymlinkCreationContinuesOnError(t *testing.T) {
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	sourceFile := filepath.Join(tempDir, "source.txt")
-	createFile(t, sourceFile, "content")
-	
-	errorCount := 0
-	originalSymlink := symlinkFunc
-	symlinkFunc = func(oldname, newname string) error {
-		errorCount++
-		return errors.New("mock error")
-	}
-	defer func() { symlinkFunc = originalSymlink }()
-	
-	config := SymlinkConfig{
-		Targets: []Target{
-			{Path: filepath.Join(tempDir, "link1.txt"), Description: "Link 1"},
-			{Path: filepath.Join(tempDir, "link2.txt"), Description: "Link 2"},
-			{Path: filepath.Join(tempDir, "link3.txt"), Description: "Link 3"},
-		},
-	}
-	
-	configData, _ := json.Marshal(config)
-	configFile := filepath.Join(tempDir, "config.json")
-	createFile(t, configFile, string(configData))
-	
-	err := processSymlinkConfig(sourceFile, configFile)
-	if err != nil {
-		t.Errorf("processSymlinkConfig should not return error: %v", err)
-	}
-	
-	if errorCount != 3 {
-		t.Errorf("Expected 3 symlink attempts, got %d", errorCount)
-	}
-}
-
-// Integration test with multiple files
-func TestFullIntegration(t *testing.T) {
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	secretDir := filepath.Join(tempDir, "secret")
-	os.MkdirAll(secretDir, 0755)
-	
-	// Create target directories
-	os.MkdirAll(filepath.Join(tempDir, "app"), 0755)
-	os.MkdirAll(filepath.Join(tempDir, "backup"), 0755)
-	os.MkdirAll(filepath.Join(tempDir, "keys"), 0755)
-	
-	// Create multiple files with configs
-	files := []struct {
-		name    string
-		content string
-		targets []Target
-	}{
-		{
-			name:    "config.ini",
-			content: "[database]\nhost=localhost",
-			targets: []Target{
-				{Path: filepath.Join(tempDir, "app", "config.ini"), Description: "App config"},
-				{Path: filepath.Join(tempDir, "backup", "config.ini"), Description: "Backup"},
-			},
-		},
-		{
-			name:    "secret.key",
-			content: "supersecretkey123",
-			targets: []Target{
-				{Path: filepath.Join(tempDir, "keys", "app.key"), Description: "App key"},
-			},
-		},
-	}
-	
-	for _, file := range files {
-		filePath := filepath.Join(secretDir, file.name)
-		createFile(t, filePath, file.content)
-		
-		config := SymlinkConfig{Targets: file.targets}
-		configData, _ := json.Marshal(config)
-		configPath := filepath.Join(secretDir, file.name+".symlink.json")
-		createFile(t, configPath, string(configData))
-	}
-	
-	err := processSecretDirectory(secretDir)
-	if err != nil {
-		t.Errorf("processSecretDirectory failed: %v", err)
-	}
-	
-	// Verify all symlinks were created
-	expectedLinks := []string{
-		filepath.Join(tempDir, "app", "config.ini"),
-		filepath.Join(tempDir, "backup", "config.ini"),
-		filepath.Join(tempDir, "keys", "app.key"),
-	}
-	
-	for _, link := range expectedLinks {
-		if _, err := os.Stat(link); err != nil {
-			t.Errorf("Expected symlink not created: %s", link)
-		}
-	}
-}
-
-// Test findSecretDirectories function
-func TestFindSecretDirectories(t *testing.T) {
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	// Create test directory structure
-	os.MkdirAll(filepath.Join(tempDir, "project1", "secret"), 0755)
-	os.MkdirAll(filepath.Join(tempDir, "project2", "my_secrets"), 0755)
-	os.MkdirAll(filepath.Join(tempDir, "no_match", "config"), 0755)
-	os.MkdirAll(filepath.Join(tempDir, "secret_data"), 0755)
-	
-	originalWd, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalWd)
-	
-	dirs, err := findSecretDirectories(".")
-	if err != nil {
-		t.Errorf("findSecretDirectories() error = %v", err)
-	}
-	
-	expected := 3 // "project1/secret", "project2/my_secrets", "secret_data"
-	if len(dirs) != expected {
-		t.Errorf("Expected %d directories, got %d: %v", expected, len(dirs), dirs)
-	}
-}
-
-// Test findSecretDirectories with walk error
-func TestFindSecretDirectoriesWalkError(t *testing.T) {
-	// On Windows, filepath.Walk doesn't return error for non-existent paths
-	// Instead, let's test with an invalid path pattern
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	// Create a file (not directory) to trigger different behavior
-	testFile := filepath.Join(tempDir, "testfile")
-	createFile(t, testFile, "content")
-	
-	// Try to walk a file as if it were a directory
-	dirs, err := findSecretDirectories(testFile)
-	// This might not error on all platforms, but should return empty
-	if err != nil {
-		// Some platforms may error
-		if dirs != nil {
-			t.Error("Expected nil directories on error")
-		}
-	} else {
-		// Other platforms may just return empty
-		if len(dirs) != 0 {
-			t.Error("Expected no directories when walking a file")
-		}
-	}
-}
-
-// Test findSecretDirectories with permission error (tests line 42-44)
-func TestFindSecretDirectoriesPermissionError(t *testing.T) {
-	// Test that Walk callback continues on error (line 42-47)
-	originalWalk := filepathWalk
-	callbackCalled := false
-	errorReturned := false
-	
-	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
-		// First call with valid directory
-		walkFn(".", &mockFileInfo{name: ".", isDir: true}, nil)
-		
-		// Then call with an error to test error handling path
-		result := walkFn("./badfile", nil, errors.New("permission denied"))
-		if result != nil {
-			errorReturned = true
-		}
-		callbackCalled = true
-		
-		// Continue with a secret directory after the error
-		walkFn("./my_secret", &mockFileInfo{name: "my_secret", isDir: true}, nil)
-		
-		return nil
-	}
-	
-	defer func() {
-		filepathWalk = originalWalk
-	}()
-	
-	dirs, err := findSecretDirectories(".")
-	
-	if err != nil {
-		t.Errorf("findSecretDirectories() error = %v", err)
-	}
-	
-	if !callbackCalled {
-		t.Error("Walk callback was not called")
-	}
-	
-	if errorReturned {
-		t.Error("Callback should return nil on error, not propagate it")
-	}
-	
-	// Should find the secret directory despite the error
-	if len(dirs) != 1 || dirs[0] != "./my_secret" {
-		t.Errorf("Expected to find ./my_secret, got %v", dirs)
-	}
-}
-
-// mockFileInfo implements os.FileInfo for testing
-type mockFileInfo struct {
-	name  string
-	isDir bool
-}
-
-func (m *mockFileInfo) Name() string       { return m.name }
-func (m *mockFileInfo) Size() int64        { return 0 }
-func (m *mockFileInfo) Mode() os.FileMode  { return 0755 }
-func (m *mockFileInfo) ModTime() time.Time { return time.Now() }
-func (m *mockFileInfo) IsDir() bool        { return m.isDir }
-func (m *mockFileInfo) Sys() interface{}   { return nil }
-
-// Test findSecretDirectories with filepath.Walk returning error
-func TestFindSecretDirectoriesWalkReturnsError(t *testing.T) {
-	// Mock filepathWalk to return an error
-	originalWalk := filepathWalk
-	mockError := errors.New("walk error")
-	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
-		return mockError
-	}
-	defer func() {
-		filepathWalk = originalWalk
-	}()
-	
-	dirs, err := findSecretDirectories(".")
-	if err == nil {
-		t.Error("Expected error from findSecretDirectories")
-	}
-	if err != mockError {
-		t.Errorf("Expected error %v, got %v", mockError, err)
-	}
-	if dirs != nil {
-		t.Error("Expected nil directories on error")
-	}
-}
-
-// Test main function with no secret directories found
-func TestMainWithNoSecretDirectories(t *testing.T) {
-	originalExit := exitFunc
-	originalExeDir := executableDir
-	originalWalk := filepathWalk
-	
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	// Change to temp dir first
-	originalWd, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalWd)
-	
-	exitCalled := false
-	exitCode := 0
-	exitFunc = func(code int) {
-		exitCalled = true
-		exitCode = code
-	}
-	
-	executableDir = func() (string, error) {
-		return tempDir, nil
-	}
-	
-	// Mock filepathWalk to return empty list without error
-	// This simulates the behavior when Walk completes but finds no directories
-	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
-		// Return nil to simulate successful walk with no results
-		return nil
-	}
-	
-	defer func() {
-		exitFunc = originalExit
-		executableDir = originalExeDir
-		filepathWalk = originalWalk
-	}()
-	
-	// Capture stdout (message goes to stdout, not stderr)
-	r, w, _ := os.Pipe()
-	originalStdout := os.Stdout
-	os.Stdout = w
-	
-	main()
-	
-	w.Close()
-	os.Stdout = originalStdout
-	output := make([]byte, 1024)
-	n, _ := r.Read(output)
-	output = output[:n]
-	
-	if !exitCalled {
-		t.Error("Expected exit to be called")
-	}
-	if exitCode != 0 {
-		t.Errorf("Expected exit code 0, got %d", exitCode)
-	}
-	if !strings.Contains(string(output), "No directories containing 'secret' found") {
-		t.Errorf("Expected message about no secret directories found, got: %s", string(output))
-	}
-}
-
-// Test main function with actual findSecretDirectories error  
-func TestMainWithFindDirectoriesActualError(t *testing.T) {
-	// Save originals
-	originalExitFunc := exitFunc
-	originalExecutableDir := executableDir
-	originalFindSecretDirs := findSecretDirs
-	originalParseFlags := parseFlags
-	defer func() {
-		exitFunc = originalExitFunc
-		executableDir = originalExecutableDir
-		findSecretDirs = originalFindSecretDirs
-		parseFlags = originalParseFlags
-	}()
-
-	// Track exit calls
-	exitCalled := false
-	exitCode := 0
-	exitFunc = func(code int) {
-		exitCalled = true
-		exitCode = code
-		// Don't actually exit, just panic to stop execution
-		panic("test exit")
-	}
-
-	// Mock parseFlags to return no flags
-	parseFlags = func() (*bool, *bool) {
-		versionFlag := false
-		updateFlag := false
-		return &versionFlag, &updateFlag
-	}
-
-	// Mock executable directory
-	executableDir = func() (string, error) {
-		return ".", nil
-	}
-
-	// Mock findSecretDirs to return an error
-	findSecretDirs = func(root string) ([]string, error) {
-		return nil, errors.New("mock find secret dirs error")
-	}
-
-	// Capture stderr
-	oldStderr := os.Stderr
-	r, w, _ := os.Pipe()
-	os.Stderr = w
-
-	// Run main in a goroutine so we can capture the panic
-	done := make(chan bool)
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// Expected panic from exit mock
-				if r == "test exit" {
-					done <- true
-				}
-			}
-		}()
-		main()
-		done <- true
-	}()
-
-	// Read output in background
-	outputChan := make(chan string, 1)
-	go func() {
-		buf := make([]byte, 4096)
-		n, _ := r.Read(buf)
-		if n > 0 {
-			outputChan <- string(buf[:n])
-		}
-		close(outputChan)
-	}()
-
-	// Wait for main to complete
-	<-done
-	
-	// Give a moment for output to be written
-	time.Sleep(10 * time.Millisecond)
-	
-	// Get output
-	var output string
-	select {
-	case out := <-outputChan:
-		output = out
-	default:
-		// Try one more read if nothing yet
-		buf := make([]byte, 4096)
-		n, _ := r.Read(buf)
-		if n > 0 {
-			output = string(buf[:n])
-		}
-	}
-
-	// Close writer and restore stderr
-	w.Close()
-	os.Stderr = oldStderr
-
-	// Verify behavior
-	if !exitCalled {
-		t.Error("Expected exitFunc to be called")
-	}
-	if exitCode != 1 {
-		t.Errorf("Expected exit code 1, got %d", exitCode)
-	}
-	if !strings.Contains(output, "Error finding secret directories") {
-		t.Error("Expected error message about finding secret directories")
-	}
-	if !strings.Contains(output, "mock find secret dirs error") {
-		t.Error("Expected mock error message")
-	}
-}
-
-// Test processSecretDirectory returning error in main
-func TestMainWithProcessDirectoryError(t *testing.T) {
-	originalExit := exitFunc
-	originalExeDir := executableDir
-	originalReadDir := readDirFunc
-	
-	tempDir := setupTestDir(t)
-	defer os.RemoveAll(tempDir)
-	
-	// Create a secret directory
-	secretDir := filepath.Join(tempDir, "my_secret")
-	os.MkdirAll(secretDir, 0755)
-	
-	exitCalled := false
-	exitFunc = func(code int) {
-		exitCalled = true
-	}
-	
-	executableDir = func() (string, error) {
-		return tempDir, nil
-	}
-	
-	// Make ReadDir fail for the secret directory
-	readDirFunc = func(name string) ([]os.DirEntry, error) {
-		if strings.Contains(name, "my_secret") {
-			return nil, errors.New("read error")
-		}
-		return originalReadDir(name)
-	}
-	
-	defer func() {
-		exitFunc = originalExit
-		executableDir = originalExeDir
-		readDirFunc = originalReadDir
-	}()
-	
-	// Capture stderr
-	r, w, _ := os.Pipe()
-	originalStderr := os.Stderr
-	os.Stderr = w
-	
-	main()
-	
-	w.Close()
-	os.Stderr = originalStderr
-	output := make([]byte, 1024)
-	n, _ := r.Read(output)
-	output = output[:n]
-	
-	// Should not exit on process directory error
-	if exitCalled {
-		t.Error("Should not exit on process directory error")
-	}
-	
-	if !strings.Contains(string(output), "Error processing") {
-		t.Error("Expected error message about processing directory")
-	}
-}
-
-// Test getExecutableDir function
-func TestGetExecutableDir(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
-		dir, err := getExecutableDir()
-		if err != nil {
-			t.Errorf("getExecutableDir() error = %v", err)
-		}
-		if dir == "" {
-			t.Error("getExecutableDir() returned empty string")
-		}
-	})
-	
-	t.Run("error", func(t *testing.T) {
-		// Mock os.Executable to return error
-		originalOsExecutable := osExecutable
-		osExecutable = func() (string, error) {
-			return "", errors.New("mock error")
-		}
-		defer func() {
-			osExecutable = originalOsExecutable
-		}()
-		
-		_, err := getExecutableDir()
-		if err == nil {
-			t.Error("Expected error from getExecutableDir")
-		}
-	})
-}
-
-// =============================================================================
-// VERSION AND FLAG TESTS
-// =============================================================================
-// Tests for command line flag handling and version display
-// =============================================================================
-
-// Test version flag
-func TestMainVersionFlag(t *testing.T) {
-	originalExit := exitFunc
-	originalParseFlags := parseFlags
-	
-	exitCalled := false
-	exitCode := 0
-	exitFunc = func(code int) {
-		exitCalled = true
-		exitCode = code
-	}
-	
-	// Mock parseFlags to return version flag
-	parseFlags = func() (*bool, *bool) {
-		versionFlag := true
-		updateFlag := false
-		return &versionFlag, &updateFlag
-	}
-	
-	defer func() {
-		exitFunc = originalExit
-		parseFlags = originalParseFlags
-	}()
-	
-	// Capture stdout
-	r, w, _ := os.Pipe()
-	originalStdout := os.Stdout
-	os.Stdout = w
-	
-	main()
-	
-	w.Close()
-	os.Stdout = originalStdout
-	output := make([]byte, 1024)
-	n, _ := r.Read(output)
-	output = output[:n]
-	
-	if !exitCalled {
-		t.Error("Expected exit to be called")
-	}
-	if exitCode != 0 {
-		t.Errorf("Expected exit code 0, got %d", exitCode)
-	}
-	
-	// Check output contains version info
-	outputStr := string(output)
-	if len(outputStr) == 0 {
-		t.Error("Expected version output")
-	}
-}
-
-// Test update flag
-func TestMainUpdateFlag(t *testing.T) {
-	originalExit := exitFunc
-	originalParseFlags := parseFlags
-	originalCheckAndUpdate := checkAndUpdateFunc
-	
-	exitCalled := false
-	exitCode := 0
-	exitFunc = func(code int) {
-		exitCalled = true
-		exitCode = code
-	}
-	
-	// Mock parseFlags to return update flag
-	parseFlags = func() (*bool, *bool) {
-		versionFlag := false
-		updateFlag := true
-		return &versionFlag, &updateFlag
-	}
-	
-	// Mock checkAndUpdate
-	checkAndUpdateCalled := false
-	checkAndUpdateFunc = func() error {
-		checkAndUpdateCalled = true
-		return nil
-	}
-	
-	defer func() {
-		exitFunc = originalExit
-		parseFlags = originalParseFlags
-		checkAndUpdateFunc = originalCheckAndUpdate
-	}()
-	
-	main()
-	
-	if !exitCalled {
-		t.Error("Expected exit to be called")
-	}
-	if exitCode != 0 {
-		t.Errorf("Expected exit code 0, got %d", exitCode)
-	}
-	if !checkAndUpdateCalled {
-		t.Error("Expected checkAndUpdate to be called")
-	}
-}
-
-// Test update flag with error
-func TestMainUpdateFlagError(t *testing.T) {
-	originalExit := exitFunc
-	originalParseFlags := parseFlags
-	originalCheckAndUpdate := checkAndUpdateFunc
-	originalExeDir := executableDir
-	
-	exitCalled := false
-	exitCode := 0
-	exitFunc = func(code int) {
-		exitCalled = true
-		exitCode = code
-		// Panic to prevent continuing execution
-		panic("exit called")
-	}
-	
-	// Mock parseFlags to return update flag
-	parseFlags = func() (*bool, *bool) {
-		versionFlag := false
-		updateFlag := true
-		return &versionFlag, &updateFlag
-	}
-	
-	// Mock checkAndUpdate to return error
-	checkAndUpdateFunc = func() error {
-		return os.ErrNotExist
-	}
-	
-	// Mock executableDir (in case it continues)
-	executableDir = func() (string, error) {
-		return ".", nil
-	}
-	
-	defer func() {
-		// Recover from panic
-		if r := recover(); r != nil {
-			// Expected panic from exitFunc
-		}
-		exitFunc = originalExit
-		parseFlags = originalParseFlags
-		checkAndUpdateFunc = originalCheckAndUpdate
-		executableDir = originalExeDir
-	}()
-	
-	// Capture stderr
-	r, w, _ := os.Pipe()
-	originalStderr := os.Stderr
-	os.Stderr = w
-	
-	// Wrap main() call to handle panic
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// Expected panic
-			}
-		}()
-		main()
-	}()
-	
-	w.Close()
-	os.Stderr = originalStderr
-	output := make([]byte, 1024)
-	n, _ := r.Read(output)
-	output = output[:n]
-	
-	if !exitCalled {
-		t.Error("Expected exit to be called")
-	}
-	if exitCode != 1 {
-		t.Errorf("Expected exit code 1, got %d", exitCode)
-	}
-	
-	outputStr := string(output)
-	if len(outputStr) == 0 {
-		t.Error("Expected error output")
-	}
-}
-
-// =============================================================================
-// FLAG PARSING TESTS
-// =============================================================================
-
-// Test the actual parseFlags function
-func TestParseFlags(t *testing.T) {
-	// Save original command line args
-	oldArgs := os.Args
-	defer func() { os.Args = oldArgs }()
-	
-	// Reset flag.CommandLine to avoid flag redefined errors
-	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-	
-	tests := []struct {
-		name           string
-		args           []string
-		expectVersion  bool
-		expectUpdate   bool
-	}{
-		{
-			name:          "no flags",
-			args:          []string{"secret_manager"},
-			expectVersion: false,
-			expectUpdate:  false,
-		},
-		{
-			name:          "version flag",
-			args:          []string{"secret_manager", "-version"},
-			expectVersion: true,
-			expectUpdate:  false,
-		},
-		{
-			name:          "update flag",
-			args:          []string{"secret_manager", "-update"},
-			expectVersion: false,
-			expectUpdate:  true,
-		},
-		{
-			name:          "both flags",
-			args:          []string{"secret_manager", "-version", "-update"},
-			expectVersion: true,
-			expectUpdate:  true,
-		},
-	}
-	
-	// Save original parseFlags
-	originalParseFlags := parseFlags
-	defer func() { parseFlags = originalParseFlags }()
-	
-	// Use the real parseFlags implementation
-	parseFlags = func() (*bool, *bool) {
-		// Reset flags for each test
-		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-		versionFlag := flag.Bool("version", false, "Show version information")
-		updateFlag := flag.Bool("update", false, "Check for updates and install if available")
-		flag.Parse()
-		return versionFlag, updateFlag
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Set command line args
-			os.Args = tt.args
-			
-			versionFlag, updateFlag := parseFlags()
-			
-			if *versionFlag != tt.expectVersion {
-				t.Errorf("Expected version flag %v, got %v", tt.expectVersion, *versionFlag)
-			}
-			if *updateFlag != tt.expectUpdate {
-				t.Errorf("Expected update flag %v, got %v", tt.expectUpdate, *updateFlag)
-			}
-		})
-	}
-}
-
-// TestDefaultParseFlags tests the defaultParseFlags function
-func TestDefaultParseFlags(t *testing.T) {
-	// Save original state
-	oldArgs := os.Args
-	oldCommandLine := flag.CommandLine
-	defer func() {
-		os.Args = oldArgs
-		flag.CommandLine = oldCommandLine
-	}()
-
-	tests := []struct {
-		name          string
-		args          []string
-		expectVersion bool
-		expectUpdate  bool
-	}{
-		{
-			name:          "no flags",
-			args:          []string{"secret_manager"},
-			expectVersion: false,
-			expectUpdate:  false,
-		},
-		{
-			name:          "version flag",
-			args:          []string{"secret_manager", "-version"},
-			expectVersion: true,
-			expectUpdate:  false,
-		},
-		{
-			name:          "update flag",
-			args:          []string{"secret_manager", "-update"},
-			expectVersion: false,
-			expectUpdate:  true,
-		},
-		{
-			name:          "both flags",
-			args:          []string{"secret_manager", "-version", "-update"},
-			expectVersion: true,
-			expectUpdate:  true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Set command line args
-			os.Args = tt.args
-			// Reset flag.CommandLine for each test
-			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-
-			versionFlag, updateFlag := defaultParseFlags()
-
-			if *versionFlag != tt.expectVersion {
-				t.Errorf("Expected version flag %v, got %v", tt.expectVersion, *versionFlag)
-			}
-			if *updateFlag != tt.expectUpdate {
-				t.Errorf("Expected update flag %v, got %v", tt.expectUpdate, *updateFlag)
-			}
-		})
-	}
-}
\ No newline at end of file
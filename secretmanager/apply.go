@@ -0,0 +1,233 @@
+// Package secretmanager finds secret directories, reads the manifests beside
+// their source files and links each source to its targets. The
+// secret_manager command is a thin wrapper around Main; programs that embed
+// the link pipeline call Apply instead
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Main runs the secret_manager command with the command line arguments,
+// reporting the given build information with -version and to the updater
+func Main(buildVersion, buildCommit, buildDate string) {
+	version, commit, date = buildVersion, buildCommit, buildDate
+	main()
+}
+
+// Result is the outcome of Apply: what happened to each target, and the totals
+type Result struct {
+	Targets []LinkResult `json:"targets"`
+	Totals  Totals       `json:"totals"`
+}
+
+// result returns the outcome recorded so far
+func (s *RunSummary) result() Result {
+	s.mu.Lock()
+	targets := append([]LinkResult(nil), s.Results...)
+	s.mu.Unlock()
+	return Result{Targets: targets, Totals: s.totals()}
+}
+
+// applyMu serializes Apply, since a run keeps its options and progress in
+// package state
+var applyMu sync.Mutex
+
+// Apply runs the link pipeline with o in place of the command line options,
+// for programs that embed secret_manager instead of running it. o is
+// checked as the command checks its flags, and the same guards apply before
+// anything is linked: target conflicts under -strict and
+// -dry-run-apply-then-verify, while o.ConfirmDestructive refuses any run
+// that would overwrite, as Apply cannot ask. Secret directories are found
+// as the command finds them, but from the working directory. Nothing is
+// printed: the outcome is returned, and only warnings are written, to
+// stderr or the file o.WarningsTo names. Calls are serialized and leave the
+// package options as they found them. An error means the run could not
+// start or was stopped by a guard, not that targets failed
+func Apply(o Options) (Result, error) {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	savedOpts, savedSummary := opts, runSummary
+	defer func() { opts, runSummary, silentOutput = savedOpts, savedSummary, false }()
+
+	opts, silentOutput = o, true
+	if err := validateOptions(); err != nil {
+		return Result{}, err
+	}
+	if err := openWarningsFile(); err != nil {
+		return Result{}, err
+	}
+	defer closeWarningsFile()
+	if err := loadVars(opts.Vars); err != nil {
+		return Result{}, err
+	}
+
+	runSummary = &RunSummary{}
+	if err := loadRunInputs(); err != nil {
+		return Result{}, err
+	}
+
+	secretDirs, cleanup, err := runSecretDirs(opts.Only, opts.ConfigArchive)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
+
+	result, err := runLinks(secretDirs)
+	if err != nil {
+		return result, err
+	}
+	saveRunState()
+	return result, nil
+}
+
+// runError is an error that stops a run, with the -error-json category and
+// message prefix the command reports it under
+type runError struct {
+	category string
+	prefix   string
+	err      error
+}
+
+func (e *runError) Error() string { return e.prefix + ": " + e.err.Error() }
+
+func (e *runError) Unwrap() error { return e.err }
+
+// errApplyMismatch reports that -dry-run-apply-then-verify found targets
+// that were not linked as the dry run predicted
+var errApplyMismatch = fmt.Errorf("applied links do not match the dry run")
+
+// runLinks links the manifests of secretDirs after the guards every run
+// passes: -confirm-destructive asks before overwriting, and manifests that
+// claim one target from different sources are warned about, or refused
+// under -strict. With
+// -dry-run-apply-then-verify the outcome is predicted first and checked
+// afterwards
+func runLinks(secretDirs []string) (Result, error) {
+	out := outputWriter()
+	if opts.ConfirmDestructive && !opts.DryRun {
+		if err := confirmDestructive(out, secretDirs); err != nil {
+			return Result{}, &runError{errorAborted, "Error", err}
+		}
+	}
+
+	// Two sources for one target would leave whichever was linked last
+	var links []effectiveLink
+	quietly(func() { links = planLinks(secretDirs) })
+	if err := checkTargetConflicts(links); err != nil {
+		return Result{}, &runError{errorConfig, "Error", err}
+	}
+
+	var predicted []LinkResult
+	if opts.ApplyThenVerify {
+		predicted = predictOutcomes(secretDirs)
+	}
+
+	fmt.Fprintf(out, "Found %d secret directories\n", len(secretDirs))
+	writeDiffHeader()
+
+	result, err := applySecretDirs(secretDirs)
+	if err != nil {
+		return result, &runError{errorFilesystem, "Error", err}
+	}
+
+	fmt.Fprintln(out, "Symlink creation completed successfully!")
+	fmt.Fprintf(out, "Summary: %s\n", runSummary.message())
+
+	if opts.ApplyThenVerify && !writeApplyVerification(out, predicted) {
+		return result, errApplyMismatch
+	}
+	return result, nil
+}
+
+// loadRunInputs reads what a run needs besides the manifests: the keys of
+// -k8s-secret-dir, and with -hash-verify the state of the previous run
+func loadRunInputs() error {
+	var err error
+	k8sKeys = nil
+	if opts.K8sSecretDir != "" {
+		if k8sKeys, err = readK8sSecretKeys(opts.K8sSecretDir); err != nil {
+			return err
+		}
+	}
+
+	runState = newLinkState()
+	if opts.HashVerify {
+		if runState, err = loadState(opts.StateFile); err != nil {
+			warnf("Warning: %v, starting with an empty state\n", err)
+			runState = newLinkState()
+		}
+	}
+	return nil
+}
+
+// saveRunState writes the state file of a -hash-verify run
+func saveRunState() {
+	if opts.HashVerify && !opts.DryRun {
+		if err := runState.save(opts.StateFile); err != nil {
+			warnf("Warning: failed to write state file: %v\n", err)
+		}
+	}
+}
+
+// runSecretDirs returns the secret directories to process: the extracted
+// -config-archive, the -only directory, or those found by scanning the
+// working directory. A dry run extracts the archive into a temporary
+// directory, leaving -config-archive-dir to the links of the last real run;
+// the returned function removes it
+func runSecretDirs(only, archive string) ([]string, func(), error) {
+	switch {
+	case archive != "":
+		dir := opts.ConfigArchiveDir
+		if dir == "" {
+			dir = defaultArchiveDir
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup := func() {}
+		if opts.DryRun {
+			temp, err := os.MkdirTemp("", "secret_manager_archive_*")
+			if err != nil {
+				return nil, nil, err
+			}
+			dir = filepath.Join(temp, "archive")
+			cleanup = func() { os.RemoveAll(temp) }
+		}
+		if err := extractConfigArchive(archive, dir); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		opts.scanRoot = dir
+		return []string{dir}, cleanup, nil
+	case only != "":
+		secretDirs, err := onlySecretDirectory(only)
+		opts.scanRoot = canonicalPath(only)
+		return secretDirs, func() {}, err
+	default:
+		secretDirs, err := findSecretDirs(".")
+		opts.scanRoot = canonicalPath(".")
+		return secretDirs, func() {}, err
+	}
+}
+
+// applySecretDirs links the manifests of secretDirs, recording progress in
+// the -checkpoint file if one is given, and returns the outcome
+func applySecretDirs(secretDirs []string) (Result, error) {
+	runCheckpoint = nil
+	if opts.Checkpoint != "" && !opts.DryRun {
+		var err error
+		if runCheckpoint, err = openCheckpoint(opts.Checkpoint, opts.Resume); err != nil {
+			return Result{}, err
+		}
+	}
+
+	processSecretDirectories(secretDirs)
+	runCheckpoint.finish()
+	runCheckpoint = nil
+	return runSummary.result(), nil
+}
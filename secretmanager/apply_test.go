@@ -0,0 +1,177 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// APPLY TESTS
+// =============================================================================
+// Tests for running the link pipeline programmatically with Apply
+// =============================================================================
+
+func TestApply(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+
+	writeManifest := func(dir, source string, config SymlinkConfig) {
+		createFile(t, filepath.Join(tempDir, dir, source), "secret")
+		data, _ := json.Marshal(config)
+		createFile(t, filepath.Join(tempDir, dir, source+".symlink.json"), string(data))
+	}
+	etc := filepath.Join(tempDir, "etc")
+	os.MkdirAll(etc, 0755)
+	writeManifest("app_secret", "api.key", SymlinkConfig{
+		Targets: []Target{
+			{Path: filepath.Join(etc, "api.key"), Description: "API key"},
+			{Path: filepath.Join(tempDir, "missing", "api.key"), Description: "no directory", Optional: true},
+		},
+	})
+	writeManifest("db_secret", "db.key", SymlinkConfig{
+		Targets: []Target{{Path: filepath.Join(etc, "db.key")}},
+	})
+	os.Chdir(tempDir)
+
+	var result Result
+	var err error
+	// The zero Options expect target directories owned by the current user
+	output := captureStdout(t, func() {
+		result, err = Apply(Options{WarningsTo: "stdout"})
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if output != "" {
+		t.Errorf("Expected Apply to print nothing, got %q", output)
+	}
+
+	actions := make(map[string]string)
+	for _, target := range result.Targets {
+		actions[filepath.Base(filepath.Dir(target.Target))+"/"+filepath.Base(target.Target)] = target.Action
+	}
+	want := map[string]string{
+		"etc/api.key":     actionCreated,
+		"missing/api.key": actionSkipped,
+		"etc/db.key":      actionCreated,
+	}
+	for target, action := range want {
+		if actions[target] != action {
+			t.Errorf("Target %s: action = %q, want %q (all: %v)", target, actions[target], action, actions)
+		}
+	}
+	totals := Totals{Success: true, Total: 3, Created: 2, Skipped: 1}
+	if result.Totals != totals {
+		t.Errorf("Totals = %+v, want %+v", result.Totals, totals)
+	}
+	if content, _ := os.ReadFile(filepath.Join(etc, "db.key")); !strings.HasPrefix(string(content), "SYMLINK:") {
+		t.Errorf("Expected db.key to be linked, got %q", content)
+	}
+
+	// Each call starts from an empty result
+	captureStdout(t, func() {
+		result, err = Apply(Options{DryRun: true, WarningsTo: "stdout"})
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if result.Totals.Total != 3 || result.Totals.Planned != 2 {
+		t.Errorf("Expected a fresh dry-run result, got %+v", result.Totals)
+	}
+
+	// A run that cannot start is an error
+	if _, err := Apply(Options{Only: filepath.Join(tempDir, "nowhere")}); err == nil {
+		t.Error("Expected an error for a missing -only directory")
+	}
+}
+
+func TestApplyGuards(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	// Two manifests claiming one target
+	target := filepath.Join(tempDir, "etc", "shared.key")
+	os.MkdirAll(filepath.Dir(target), 0755)
+	for _, dir := range []string{"a_secret", "b_secret"} {
+		createFile(t, filepath.Join(tempDir, dir, "shared.key"), dir)
+		data, _ := json.Marshal(SymlinkConfig{Targets: []Target{{Path: target}}})
+		createFile(t, filepath.Join(tempDir, dir, "shared.key.symlink.json"), string(data))
+	}
+	os.Chdir(tempDir)
+
+	commandOpts := opts
+	tests := []struct {
+		name     string
+		options  Options
+		category string
+	}{
+		{"invalid_options", Options{DirPerm: "bad"}, ""},
+		{"strict_conflict", Options{NoOwnerCheck: true, Strict: true, SummaryOnly: true}, errorConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			captureStdout(t, func() { _, err = Apply(tt.options) })
+			if err == nil {
+				t.Fatal("Apply() error = nil, want an error")
+			}
+			var stopped *runError
+			if tt.category != "" && (!errors.As(err, &stopped) || stopped.category != tt.category) {
+				t.Errorf("Apply() error = %v, want a %s error", err, tt.category)
+			}
+			if _, statErr := os.Lstat(target); !os.IsNotExist(statErr) {
+				t.Errorf("Expected %s to be left alone, got %v", target, statErr)
+			}
+			if !reflect.DeepEqual(opts, commandOpts) {
+				t.Errorf("Expected Apply to leave the command options alone, got %+v", opts)
+			}
+		})
+	}
+}
+
+func TestApplyConfirmDestructive(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	originalTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return true }
+	defer func() { stdinIsTerminal = originalTerminal }()
+
+	target := filepath.Join(tempDir, "etc", "api.key")
+	createFile(t, target, "existing")
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key"), "secret")
+	data, _ := json.Marshal(SymlinkConfig{Targets: []Target{{Path: target}}})
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key.symlink.json"), string(data))
+	os.Chdir(tempDir)
+
+	// Apply cannot ask, even at a terminal, so the overwrite is refused
+	var err error
+	output := captureStdout(t, func() { _, err = Apply(Options{ConfirmDestructive: true}) })
+	var stopped *runError
+	if !errors.As(err, &stopped) || stopped.category != errorAborted {
+		t.Errorf("Apply() error = %v, want an aborted run", err)
+	}
+	if output != "" {
+		t.Errorf("Expected Apply to print nothing, got %q", output)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "existing" {
+		t.Errorf("Expected %s to be left alone, got %q", target, content)
+	}
+}
@@ -0,0 +1,196 @@
+package secretmanager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// CONFIG ARCHIVE TESTS
+// =============================================================================
+// Tests for processing sources and manifests bundled in a tarball
+// =============================================================================
+
+// writeTestTarGz writes a gzipped tarball of the given files; names ending in
+// "/" become directories
+func writeTestTarGz(t *testing.T, path string, files map[string]string, order []string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gzw := gzip.NewWriter(f)
+	defer gzw.Close()
+	tw := tar.NewWriter(gzw)
+	defer tw.Close()
+
+	for _, name := range order {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			header = &tar.Header{Name: name, Mode: 0700, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(files[name]))
+		}
+	}
+}
+
+func TestMainConfigArchive(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	apiTarget := filepath.Join(tempDir, "api.key")
+	dbTarget := filepath.Join(tempDir, "db.key")
+	archivePath := filepath.Join(tempDir, "bundle.tar.gz")
+	files := map[string]string{
+		"api.key":              "api secret",
+		"api.key.symlink.json": fmt.Sprintf(`{"targets":[{"path":%q,"description":"API"}]}`, apiTarget),
+		"nested/":              "",
+		"db.key":               "db secret",
+		"db.key.symlink.json":  fmt.Sprintf(`{"targets":[{"path":%q}]}`, dbTarget),
+		"nested/ignored.txt":   "not a manifest",
+	}
+	writeTestTarGz(t, archivePath, files, []string{"api.key", "api.key.symlink.json", "nested/", "nested/ignored.txt", "db.key", "db.key.symlink.json"})
+
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+
+	originalExit := exitFunc
+	originalOpts := opts
+	originalExeDir := executableDir
+	originalFindSecretDirs := findSecretDirs
+	defer func() {
+		exitFunc = originalExit
+		opts = originalOpts
+		executableDir = originalExeDir
+		findSecretDirs = originalFindSecretDirs
+	}()
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	exeDir := t.TempDir()
+	executableDir = func() (string, error) { return exeDir, nil }
+	findSecretDirs = func(root string) ([]string, error) {
+		t.Error("Scan should not run with -config-archive")
+		return nil, nil
+	}
+	opts.NoOwnerCheck = true
+	opts.ConfigArchive = "bundle.tar.gz"
+	opts.ConfigArchiveDir = defaultArchiveDir
+
+	output := captureStdout(t, main)
+
+	if exitCode != -1 {
+		t.Errorf("Expected no exit, got %d: %s", exitCode, output)
+	}
+
+	// The links point into -config-archive-dir, which outlives the run
+	extractDir := filepath.Join(exeDir, defaultArchiveDir)
+	wantContent := map[string]string{apiTarget: "api secret", dbTarget: "db secret"}
+	for target, want := range wantContent {
+		source, err := mockReadlink(target)
+		if err != nil {
+			t.Fatalf("Expected %s to be linked: %v", target, err)
+		}
+		if filepath.Dir(source) != extractDir {
+			t.Errorf("Expected %s to link into %s, got %s", target, extractDir, source)
+		}
+		if content, err := os.ReadFile(source); err != nil || string(content) != want {
+			t.Errorf("Expected %s to still resolve after the run, got %q, %v", target, content, err)
+		}
+	}
+
+	// A second run replaces the extraction, leaving no staging directories
+	files["api.key"] = "rotated"
+	writeTestTarGz(t, archivePath, files, []string{"api.key", "api.key.symlink.json", "nested/", "nested/ignored.txt", "db.key", "db.key.symlink.json"})
+	os.Chdir(tempDir)
+	captureStdout(t, main)
+	if content, _ := os.ReadFile(filepath.Join(extractDir, "api.key")); string(content) != "rotated" {
+		t.Errorf("Expected the extraction to be replaced, got %q", content)
+	}
+	entries, _ := os.ReadDir(exeDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only %s in %s, got %d entries", defaultArchiveDir, exeDir, len(entries))
+	}
+}
+
+func TestExtractConfigArchiveReplacesOnlyExtractions(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	archivePath := filepath.Join(tempDir, "bundle.tar.gz")
+	writeTestTarGz(t, archivePath, map[string]string{"api.key": "api secret"}, []string{"api.key"})
+
+	tests := []struct {
+		name    string
+		setup   func(dir string)
+		wantErr string
+	}{
+		{"missing", func(dir string) {}, ""},
+		{"empty", func(dir string) { os.MkdirAll(dir, 0700) }, ""},
+		{"earlier_extraction", func(dir string) {
+			if err := extractConfigArchive(archivePath, dir); err != nil {
+				t.Fatal(err)
+			}
+			createFile(t, filepath.Join(dir, "stale.key"), "stale")
+		}, ""},
+		{"unrelated_directory", func(dir string) { createFile(t, filepath.Join(dir, "notes.txt"), "keep me") }, "refusing to replace"},
+		{"regular_file", func(dir string) { createFile(t, dir, "keep me") }, "refusing to replace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(tempDir, tt.name, "extracted")
+			tt.setup(dir)
+
+			err := extractConfigArchive(archivePath, dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				kept := filepath.Join(dir, "notes.txt")
+				if tt.name == "regular_file" {
+					kept = dir
+				}
+				if content, _ := os.ReadFile(kept); string(content) != "keep me" {
+					t.Errorf("Expected %s to be left alone, got %q", kept, content)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractConfigArchive() error = %v", err)
+			}
+			if content, _ := os.ReadFile(filepath.Join(dir, "api.key")); string(content) != "api secret" {
+				t.Errorf("Expected the bundle in %s, got %q", dir, content)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "stale.key")); !os.IsNotExist(err) {
+				t.Errorf("Expected the previous extraction to be replaced, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractTarGzAllRejectsTraversal(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "evil.tar.gz")
+	writeTestTarGz(t, archivePath, map[string]string{"../evil.key": "x"}, []string{"../evil.key"})
+
+	destDir := filepath.Join(tempDir, "dest")
+	os.Mkdir(destDir, 0700)
+
+	err := extractTarGzAll(archivePath, destDir)
+	if err == nil || !strings.Contains(err.Error(), "outside the extraction directory") {
+		t.Errorf("Expected traversal to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "evil.key")); !os.IsNotExist(err) {
+		t.Error("Expected no file written outside the extraction directory")
+	}
+}
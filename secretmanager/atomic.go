@@ -0,0 +1,317 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Suffixes of the temporary names used while linking a manifest atomically
+const (
+	atomicTempSuffix   = ".sm-tmp"
+	atomicBackupSuffix = ".sm-bak"
+)
+
+// atomicLink tracks one target of an atomic manifest through the transaction
+type atomicLink struct {
+	target   Target
+	origin   string // the source as declared, reported in the results
+	source   string // the source linked to, after -resolve-source
+	tempPath string
+	mode     string // the link mode: symlink, copy or junction
+	backup   string // where the previous entry was moved, empty if there was none
+	done     bool   // whether the new link has been renamed into place
+}
+
+// linkTargetsAtomically links every target to sourcePath, or none of them.
+// Each link is first created under a temporary name next to its target, then
+// all of them are renamed into place. Any failure restores what was there
+// before, and every target is recorded as failed
+func linkTargetsAtomically(sourcePath string, targets []Target) error {
+	items := make([]atomicItem, len(targets))
+	for i, target := range targets {
+		items[i] = atomicItem{source: sourcePath, target: target}
+	}
+	return linkAtomically(items)
+}
+
+// linkAtomically creates every link of items, which may have different
+// sources, or none of them
+func linkAtomically(items []atomicItem) error {
+	// Targets that need no change are not part of the transaction
+	var pending []atomicItem
+	var err error
+	for i, item := range items {
+		join, screenErr := screenAtomicItem(item)
+		if screenErr != nil {
+			err = fmt.Errorf("%s: %w", item.target.Path, screenErr)
+			pending = append(pending, items[i:]...)
+			break
+		}
+		if join {
+			pending = append(pending, item)
+		}
+	}
+	items = pending
+
+	links := make([]*atomicLink, 0, len(items))
+	if err == nil {
+		err = func() error {
+			for _, item := range items {
+				link, err := prepareAtomicLink(item.source, item.target)
+				if link != nil {
+					links = append(links, link)
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %w", item.target.Path, err)
+				}
+				if err := finishStagedLink(link); err != nil {
+					return fmt.Errorf("%s: %w", item.target.Path, err)
+				}
+			}
+			for _, link := range links {
+				if err := commitAtomicLink(link); err != nil {
+					return fmt.Errorf("%s: %w", link.target.Path, err)
+				}
+			}
+			return nil
+		}()
+	}
+
+	if err != nil {
+		rollbackAtomicLinks(links)
+		for _, item := range items {
+			runSummary.record(LinkResult{
+				Source:      item.source,
+				Target:      item.target.Path,
+				Description: item.target.Description,
+				Action:      actionFailed,
+				Message:     "rolled back: " + err.Error(),
+				Optional:    item.target.Optional,
+			})
+		}
+		return err
+	}
+
+	for _, link := range links {
+		action := actionCreated
+		if link.backup != "" {
+			removeFunc(link.backup)
+			action = actionReplaced
+		}
+		if opts.HashVerify {
+			if hash, err := hashFile(link.source); err == nil {
+				runState.setHash(link.target.Path, hash)
+			}
+		}
+		var message string
+		switch link.mode {
+		case linkModeCopy:
+			printTarget("Copied: %s -> %s (%s)\n", link.source, link.target.Path, link.target.Description)
+			message = "copied"
+		case linkModeJunction:
+			printTarget("Created junction: %s -> %s (%s)\n", link.target.Path, link.source, link.target.Description)
+			message = "junction"
+		default:
+			printTarget("Created symlink: %s -> %s (%s)\n", link.target.Path, link.source, link.target.Description)
+		}
+		runCheckpoint.markDone(link.origin, link.target.Path)
+		runSummary.record(LinkResult{
+			Source:      link.origin,
+			Target:      link.target.Path,
+			Description: link.target.Description,
+			Action:      action,
+			Message:     message,
+			Optional:    link.target.Optional,
+		})
+	}
+	return nil
+}
+
+// screenAtomicItem settles an item before the transaction, as linkTarget
+// would, when it needs no change: the run being resumed already linked it,
+// the link mode rules skip it, its directory is missing but it is optional,
+// or -hash-verify or an identical copy shows it is up to date. It reports
+// whether the item still has to be linked
+func screenAtomicItem(item atomicItem) (bool, error) {
+	source, target := item.source, item.target
+	if skipCheckpointed(source, target) {
+		return false, nil
+	}
+	record := func(action, message string) {
+		runSummary.record(LinkResult{
+			Source:      item.source,
+			Target:      target.Path,
+			Description: target.Description,
+			Action:      action,
+			Message:     message,
+			Optional:    target.Optional,
+		})
+	}
+
+	mode := targetLinkMode(source)
+	if mode == linkModeSkip {
+		target.out.printTarget("Skipping %s: %s is not linked by the link mode rules\n", target.Path, source)
+		record(actionSkipped, "skipped by link mode rule")
+		return false, nil
+	}
+	if opts.ResolveSource || target.ResolveSource {
+		resolved, err := evalSymlinks(source)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve source: %w", err)
+		}
+		source = resolved
+	}
+
+	// Only an optional target may be left out; a required one fails the
+	// transaction when it is prepared
+	targetDir := filepath.Dir(target.Path)
+	if _, err := statFunc(targetDir); os.IsNotExist(err) && !opts.Mkdir && target.Optional {
+		target.out.warnTarget("Error: Target directory does not exist: %s\n", targetDir)
+		record(actionSkipped, "target directory does not exist")
+		return false, nil
+	}
+
+	if opts.HashVerify {
+		_, upToDate, err := checkSourceHash(source, target.Path)
+		if err != nil {
+			return false, err
+		}
+		if upToDate {
+			target.out.printTarget("Symlink up to date: %s -> %s (%s)\n", target.Path, source, target.Description)
+			record(actionSkipped, "link is up to date")
+			return false, nil
+		}
+	}
+
+	// A copy is left alone while it matches, as copyTarget leaves it
+	copyUpToDate := fallbackCopyUpToDate(source, target)
+	if mode == linkModeCopy {
+		same, err := sameContent(source, target.Path)
+		copyUpToDate = err == nil && same
+	}
+	if copyUpToDate {
+		target.out.printTarget("Copy up to date: %s (%s)\n", target.Path, target.Description)
+		runCheckpoint.markDone(item.source, target.Path)
+		record(actionUnchanged, "content unchanged")
+		return false, nil
+	}
+	return true, nil
+}
+
+// finishStagedLink applies the target's perm (junctions have none of their
+// own) and owner under its temporary name and checks that it is readable,
+// so that a failure rolls back the whole transaction before anything has
+// been renamed into place
+func finishStagedLink(link *atomicLink) error {
+	staged := link.target
+	staged.Path = link.tempPath
+	if link.mode != linkModeJunction {
+		if err := applyTargetPerm(staged); err != nil {
+			return err
+		}
+	}
+	if err := applyTargetOwner(staged); err != nil {
+		return err
+	}
+	return verifyTargetReadable(staged)
+}
+
+// prepareAtomicLink creates the link for target, or its copy or junction as
+// the link mode rules say, under a temporary name. With -copy-fallback a
+// symlink that cannot be created is staged as a copy instead. The returned
+// link is non-nil once there is something to roll back
+func prepareAtomicLink(sourcePath string, target Target) (*atomicLink, error) {
+	origin := sourcePath
+	if opts.ResolveSource || target.ResolveSource {
+		resolved, err := evalSymlinks(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve source: %w", err)
+		}
+		sourcePath = resolved
+	}
+
+	targetDir := filepath.Dir(target.Path)
+	if !checkMountReady(targetDir) {
+		return nil, fmt.Errorf("mount not ready: %s", targetDir)
+	}
+	if _, err := statFunc(targetDir); os.IsNotExist(err) {
+		if !opts.Mkdir {
+			return nil, fmt.Errorf("target directory does not exist: %s", targetDir)
+		}
+		if err := createTargetDir(target.out, targetDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDirOwner(targetDir); err != nil {
+		return nil, err
+	}
+	if opts.CheckOpenFiles {
+		if pids := targetOpenBy(target); len(pids) > 0 {
+			return nil, fmt.Errorf("%s is open by process %s", target.Path, formatPIDs(pids))
+		}
+	}
+
+	tempPath := filepath.Join(targetDir, "."+filepath.Base(target.Path)+atomicTempSuffix)
+	removeFunc(tempPath) // left over from an interrupted run
+	mode := targetLinkMode(origin)
+	switch mode {
+	case linkModeCopy:
+		link := &atomicLink{target: target, origin: origin, source: sourcePath, tempPath: tempPath, mode: mode}
+		return link, copySource(sourcePath, tempPath)
+	case linkModeJunction:
+		if err := junctionFunc(sourcePath, tempPath); err != nil {
+			return nil, fmt.Errorf("failed to create junction: %w", err)
+		}
+	default:
+		if err := symlinkFunc(sourcePath, tempPath); err != nil {
+			if !opts.CopyFallback || isTransientLinkError(err) {
+				return nil, fmt.Errorf("failed to create symlink: %w", err)
+			}
+			target.out.logf(verboseDecisions, "Falling back to copying %s\n", sourcePath)
+			link := &atomicLink{target: target, origin: origin, source: sourcePath, tempPath: tempPath, mode: linkModeCopy}
+			return link, copySource(sourcePath, tempPath)
+		}
+	}
+
+	link := &atomicLink{target: target, origin: origin, source: sourcePath, tempPath: tempPath, mode: mode}
+	if err := verifySymlink(sourcePath, tempPath); err != nil {
+		return link, err
+	}
+	return link, nil
+}
+
+// commitAtomicLink moves any existing entry aside and renames the new link
+// into place
+func commitAtomicLink(link *atomicLink) error {
+	if _, err := lstatFunc(link.target.Path); err == nil {
+		backup := link.target.Path + atomicBackupSuffix
+		if err := renameFunc(link.target.Path, backup); err != nil {
+			return fmt.Errorf("failed to move existing entry aside: %w", err)
+		}
+		link.backup = backup
+	}
+	if err := renameFunc(link.tempPath, link.target.Path); err != nil {
+		return fmt.Errorf("failed to rename symlink into place: %w", err)
+	}
+	link.done = true
+	return nil
+}
+
+// rollbackAtomicLinks undoes prepared and committed links in reverse order,
+// restoring the entries that were moved aside
+func rollbackAtomicLinks(links []*atomicLink) {
+	for i := len(links) - 1; i >= 0; i-- {
+		link := links[i]
+		if link.done {
+			removeFunc(link.target.Path)
+		} else {
+			removeFunc(link.tempPath)
+		}
+		if link.backup != "" {
+			if err := renameFunc(link.backup, link.target.Path); err != nil {
+				warnf("Error: failed to restore %s from %s: %v\n", link.target.Path, link.backup, err)
+			}
+		}
+	}
+}
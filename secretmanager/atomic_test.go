@@ -0,0 +1,505 @@
+package secretmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// =============================================================================
+// ATOMIC MANIFEST TESTS
+// =============================================================================
+// Tests for linking all targets of a manifest together with "atomic": true
+// =============================================================================
+
+// atomicFixture writes a source with an atomic manifest for the given targets
+func atomicFixture(t *testing.T, tempDir string, targets ...string) (string, string) {
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	var quoted []string
+	for _, target := range targets {
+		quoted = append(quoted, fmt.Sprintf(`{"path":%q}`, target))
+	}
+	configPath := sourcePath + ".symlink.json"
+	createFile(t, configPath, `{"atomic":true,"targets":[`+strings.Join(quoted, ",")+`]}`)
+	return sourcePath, configPath
+}
+
+// assertNoTempFiles fails if a temporary or backup name was left behind
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), atomicTempSuffix) || strings.HasSuffix(entry.Name(), atomicBackupSuffix) {
+			t.Errorf("Leftover temporary file %s", entry.Name())
+		}
+	}
+}
+
+func TestProcessSymlinkConfigAtomicCommit(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	createFile(t, second, "old content")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+
+	captureStdout(t, func() {
+		if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+			t.Fatalf("processSymlinkConfig() error = %v", err)
+		}
+	})
+
+	for _, target := range []string{first, second} {
+		data, err := os.ReadFile(target)
+		if err != nil || string(data) != "SYMLINK:"+sourcePath {
+			t.Errorf("Expected %s to link to the source, got %q (%v)", target, data, err)
+		}
+	}
+	if runSummary.count(actionCreated) != 1 || runSummary.count(actionReplaced) != 1 {
+		t.Errorf("Expected one created and one replaced result, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSymlinkConfigAtomicRollback(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalRename := renameFunc
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		renameFunc = originalRename
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	third := filepath.Join(tempDir, "third.key")
+	createFile(t, second, "original")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second, third)
+
+	// The first two targets are committed before the third fails
+	renameFunc = func(oldpath, newpath string) error {
+		if newpath == third {
+			return errors.New("mock rename failure")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+
+	output := captureStdout(t, func() {
+		if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+			t.Fatalf("processSymlinkConfig() error = %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "no targets were changed") {
+		t.Errorf("Expected rollback warning, got: %s", output)
+	}
+	for _, target := range []string{first, third} {
+		if _, err := os.Lstat(target); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be rolled back, got %v", target, err)
+		}
+	}
+	if data, err := os.ReadFile(second); err != nil || string(data) != "original" {
+		t.Errorf("Expected the original entry to be restored, got %q (%v)", data, err)
+	}
+	if runSummary.count(actionFailed) != 3 || len(runSummary.Results) != 3 {
+		t.Errorf("Expected all three targets to fail, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSymlinkConfigAtomicPrepareFailure(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	missing := filepath.Join(tempDir, "missing", "second.key")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, missing)
+
+	captureStdout(t, func() {
+		processSymlinkConfig(sourcePath, configPath)
+	})
+
+	if _, err := os.Lstat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected no link when another target cannot be prepared, got %v", err)
+	}
+	if runSummary.count(actionFailed) != 2 {
+		t.Errorf("Expected both targets to fail, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSymlinkConfigAtomicFinishFailure(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalOpen := openFunc
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		openFunc = originalOpen
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	createFile(t, second, "original")
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	configPath := sourcePath + ".symlink.json"
+	createFile(t, configPath, fmt.Sprintf(`{"atomic":true,"targets":[{"path":%q},{"path":%q,"verify_readable":true}]}`, first, second))
+
+	// The second target cannot be read once linked
+	openFunc = func(name string) (*os.File, error) {
+		if strings.Contains(name, "second.key") {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		return os.Open(name)
+	}
+
+	output := captureStdout(t, func() {
+		processSymlinkConfig(sourcePath, configPath)
+	})
+
+	if !strings.Contains(output, "no targets were changed") {
+		t.Errorf("Expected rollback warning, got: %s", output)
+	}
+	if _, err := os.Lstat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected no link when another target fails verification, got %v", err)
+	}
+	if data, err := os.ReadFile(second); err != nil || string(data) != "original" {
+		t.Errorf("Expected the original entry to stay in place, got %q (%v)", data, err)
+	}
+	if runSummary.count(actionFailed) != 2 {
+		t.Errorf("Expected both targets to fail, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSymlinkConfigAtomicOptionalMissingDir(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	missing := filepath.Join(tempDir, "missing", "second.key")
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	configPath := sourcePath + ".symlink.json"
+	createFile(t, configPath, fmt.Sprintf(`{"atomic":true,"targets":[{"path":%q},{"path":%q,"optional":true}]}`, first, missing))
+
+	captureStdout(t, func() {
+		processSymlinkConfig(sourcePath, configPath)
+	})
+
+	// An optional target is left out rather than failing the transaction
+	if data, err := os.ReadFile(first); err != nil || string(data) != "SYMLINK:"+sourcePath {
+		t.Errorf("Expected %s to link to the source, got %q (%v)", first, data, err)
+	}
+	if runSummary.count(actionCreated) != 1 || runSummary.count(actionSkipped) != 1 || len(runSummary.Results) != 2 {
+		t.Errorf("Expected one created and one skipped result, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSymlinkConfigAtomicCheckpoint(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		runCheckpoint = nil
+	}()
+	opts.NoOwnerCheck = true
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+
+	// The interrupted run already linked the first target
+	cp, err := openCheckpoint(filepath.Join(tempDir, "run.checkpoint"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCheckpoint = cp
+	defer cp.finish()
+	cp.markDone(sourcePath, first)
+
+	captureStdout(t, func() {
+		processSymlinkConfig(sourcePath, configPath)
+	})
+
+	if _, err := os.Lstat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be left to the checkpoint, got %v", first, err)
+	}
+	if data, err := os.ReadFile(second); err != nil || string(data) != "SYMLINK:"+sourcePath {
+		t.Errorf("Expected %s to link to the source, got %q (%v)", second, data, err)
+	}
+	if runSummary.count(actionSkipped) != 1 || runSummary.count(actionCreated) != 1 {
+		t.Errorf("Expected one skipped and one created result, got %+v", runSummary.Results)
+	}
+	if !cp.isDone(sourcePath, second) {
+		t.Error("Expected the committed target to be marked done in the checkpoint")
+	}
+}
+
+func TestProcessSymlinkConfigAtomicHashVerify(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalState := runState
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		runState = originalState
+	}()
+	opts.NoOwnerCheck = true
+	opts.HashVerify = true
+	runState = newLinkState()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+
+	for run, want := range []string{actionCreated, actionSkipped} {
+		runSummary = &RunSummary{}
+		captureStdout(t, func() {
+			processSymlinkConfig(sourcePath, configPath)
+		})
+		if runSummary.count(want) != 2 {
+			t.Errorf("Run %d: expected both targets %s, got %+v", run+1, want, runSummary.Results)
+		}
+	}
+	if _, known := runState.hash(first); !known {
+		t.Errorf("Expected the hash of %s to be recorded", first)
+	}
+}
+
+func TestProcessSymlinkConfigAtomicCopyFallback(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalSymlink := symlinkFunc
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		symlinkFunc = originalSymlink
+	}()
+	opts.NoOwnerCheck = true
+	opts.CopyFallback = true
+	symlinkFunc = func(oldname, newname string) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+
+	// Copies are made while links fail, then left alone while they match
+	for run, want := range []string{actionCreated, actionUnchanged} {
+		runSummary = &RunSummary{}
+		captureStdout(t, func() {
+			processSymlinkConfig(sourcePath, configPath)
+		})
+		if runSummary.count(want) != 2 {
+			t.Errorf("Run %d: expected both targets %s, got %+v", run+1, want, runSummary.Results)
+		}
+		for _, target := range []string{first, second} {
+			if data, err := os.ReadFile(target); err != nil || string(data) != "key" {
+				t.Errorf("Run %d: expected %s to be a copy of the source, got %q (%v)", run+1, target, data, err)
+			}
+		}
+	}
+	assertNoTempFiles(t, tempDir)
+}
+
+func TestProcessSecretDirectoryLinkGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		sources   []string
+		existing  string // created in the target directory beforehand
+		failKey   bool   // whether renaming tls.key into place fails
+		wantLinks bool
+		wantFail  string
+	}{
+		{name: "all_sources_linked", sources: []string{"tls.crt", "tls.key"}, wantLinks: true},
+		{name: "missing_source_links_nothing", sources: []string{"tls.crt"}, existing: "tls.key", wantFail: "tls.key does not exist"},
+		{name: "rename_failure_rolls_back", sources: []string{"tls.crt", "tls.key"}, failKey: true, wantFail: "no targets were changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalSummary := runSummary
+			originalRename := renameFunc
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+				renameFunc = originalRename
+			}()
+			opts.NoOwnerCheck = true
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			secretDir := filepath.Join(tempDir, "secret")
+			targetDir := filepath.Join(tempDir, "tls")
+			os.MkdirAll(targetDir, 0755)
+			for _, source := range tt.sources {
+				createFile(t, filepath.Join(secretDir, source), source)
+			}
+			if tt.existing != "" {
+				createFile(t, filepath.Join(targetDir, tt.existing), "original")
+			}
+			createFile(t, filepath.Join(secretDir, directoryManifest), fmt.Sprintf(
+				`{"groups":[{"name":"tls","sources":["tls.crt","tls.key"],"target_dir":%q}]}`, targetDir))
+
+			if tt.failKey {
+				renameFunc = func(oldpath, newpath string) error {
+					if newpath == filepath.Join(targetDir, "tls.key") {
+						return errors.New("mock rename failure")
+					}
+					return os.Rename(oldpath, newpath)
+				}
+			}
+
+			output := captureStdout(t, func() {
+				if err := processSecretDirectory(secretDir); err != nil {
+					t.Fatalf("processSecretDirectory() error = %v", err)
+				}
+			})
+
+			for _, name := range []string{"tls.crt", "tls.key"} {
+				data, err := os.ReadFile(filepath.Join(targetDir, name))
+				linked := err == nil && string(data) == "SYMLINK:"+filepath.Join(secretDir, name)
+				if linked != tt.wantLinks {
+					t.Errorf("Expected %s linked = %v, got %q (%v)", name, tt.wantLinks, data, err)
+				}
+			}
+			if tt.existing != "" {
+				if data, _ := os.ReadFile(filepath.Join(targetDir, tt.existing)); string(data) != "original" {
+					t.Errorf("Expected the existing %s to be left alone, got %q", tt.existing, data)
+				}
+			}
+			if tt.wantFail == "" {
+				if runSummary.count(actionCreated) != 2 {
+					t.Errorf("Expected both targets to be created, got %+v", runSummary.Results)
+				}
+			} else {
+				if runSummary.count(actionFailed) != 2 || len(runSummary.Results) != 2 {
+					t.Errorf("Expected both targets to fail, got %+v", runSummary.Results)
+				}
+				if !strings.Contains(output, tt.wantFail) {
+					t.Errorf("Expected output containing %q, got:\n%s", tt.wantFail, output)
+				}
+			}
+			assertNoTempFiles(t, targetDir)
+		})
+	}
+}
+
+func TestLinkGroupSourceChecksAndPlan(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	targetDir := filepath.Join(tempDir, "tls")
+	os.MkdirAll(targetDir, 0755)
+	createFile(t, filepath.Join(secretDir, "tls.crt"), "crt")
+	createFile(t, filepath.Join(tempDir, "outside.key"), "outside")
+	createFile(t, filepath.Join(secretDir, directoryManifest), fmt.Sprintf(
+		`{"groups":[{"name":"tls","sources":["tls.crt","../outside.key"],"target_dir":%q}]}`, targetDir))
+
+	// Group sources are planned like any other manifest's
+	var links []effectiveLink
+	quietly(func() { links = planLinks([]string{secretDir}) })
+	if len(links) != 2 {
+		t.Fatalf("Expected both group sources to be planned, got %+v", links)
+	}
+	for _, link := range links {
+		if link.LinkGroup != "tls" || !link.Atomic || filepath.Dir(link.Target) != targetDir {
+			t.Errorf("Unexpected planned group link %+v", link)
+		}
+	}
+
+	// A source outside -source-allow-root keeps the whole group from linking
+	opts.SourceAllowRoot = secretDir
+	output := captureStdout(t, func() {
+		if err := processSecretDirectory(secretDir); err != nil {
+			t.Fatalf("processSecretDirectory() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "is outside -source-allow-root") {
+		t.Errorf("Expected the source to be refused, got:\n%s", output)
+	}
+	if runSummary.count(actionFailed) != 2 || len(runSummary.Results) != 2 {
+		t.Errorf("Expected both targets to fail, got %+v", runSummary.Results)
+	}
+	if entries, _ := os.ReadDir(targetDir); len(entries) != 0 {
+		t.Errorf("Expected nothing linked, got %d entries", len(entries))
+	}
+}
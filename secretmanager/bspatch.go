@@ -0,0 +1,117 @@
+package secretmanager
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bsdiffMagic identifies a patch in the BSDIFF40 format produced by bsdiff
+// and compatible tools such as github.com/gabstv/go-bsdiff
+const bsdiffMagic = "BSDIFF40"
+
+// maxPatchedSize bounds the size a patch may declare for the new content
+const maxPatchedSize = 1 << 30
+
+// patchBufferSize is the most that is allocated for the new content before
+// the patch has produced it, whatever size the header declares
+const patchBufferSize = 1 << 20
+
+// maxStalledControls bounds how many control entries in a row may neither
+// add nor copy anything, so that a compressed run of empty entries cannot
+// keep a patch spinning
+const maxStalledControls = 1 << 10
+
+// errCorruptPatch is returned when a patch cannot be applied
+var errCorruptPatch = errors.New("corrupt patch")
+
+// bspatch applies a BSDIFF40 patch to old and returns the new content
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return nil, fmt.Errorf("%w: bad header", errCorruptPatch)
+	}
+
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	// Each length is checked on its own first so that their sum cannot overflow
+	bodyLen := int64(len(patch) - 32)
+	if ctrlLen < 0 || diffLen < 0 || ctrlLen > bodyLen || diffLen > bodyLen || ctrlLen+diffLen > bodyLen {
+		return nil, fmt.Errorf("%w: bad header lengths", errCorruptPatch)
+	}
+	if newSize < 0 || newSize > maxPatchedSize {
+		return nil, fmt.Errorf("%w: bad new size %d", errCorruptPatch, newSize)
+	}
+
+	body := patch[32:]
+	return applyControls(old, newSize,
+		bzip2.NewReader(bytes.NewReader(body[:ctrlLen])),
+		bzip2.NewReader(bytes.NewReader(body[ctrlLen:ctrlLen+diffLen])),
+		bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:])))
+}
+
+// applyControls builds newSize bytes of new content from old by following
+// the control entries read from ctrlReader, each of which adds bytes from
+// diffReader to old, copies bytes from extraReader and moves within old
+func applyControls(old []byte, newSize int64, ctrlReader, diffReader, extraReader io.Reader) ([]byte, error) {
+	var newData bytes.Buffer
+	newData.Grow(int(min(newSize, patchBufferSize)))
+	var oldPos, newPos int64
+	ctrl := make([]byte, 24)
+	stalled := 0
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrlReader, ctrl); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		addLen := offtin(ctrl[0:8])
+		copyLen := offtin(ctrl[8:16])
+		seek := offtin(ctrl[16:24])
+		if addLen == 0 && copyLen == 0 {
+			if stalled++; stalled > maxStalledControls {
+				return nil, fmt.Errorf("%w: too many empty control entries", errCorruptPatch)
+			}
+		} else {
+			stalled = 0
+		}
+
+		// Add the diff bytes to the old content
+		if addLen < 0 || addLen > newSize-newPos {
+			return nil, fmt.Errorf("%w: bad diff length", errCorruptPatch)
+		}
+		if _, err := io.CopyN(&newData, diffReader, addLen); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		added := newData.Bytes()[newPos:]
+		for i := range added {
+			if at := oldPos + int64(i); at >= 0 && at < int64(len(old)) {
+				added[i] += old[at]
+			}
+		}
+		newPos += addLen
+		oldPos += addLen
+
+		// Copy the extra bytes verbatim
+		if copyLen < 0 || copyLen > newSize-newPos {
+			return nil, fmt.Errorf("%w: bad extra length", errCorruptPatch)
+		}
+		if _, err := io.CopyN(&newData, extraReader, copyLen); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+
+	return newData.Bytes(), nil
+}
+
+// offtin decodes the sign-magnitude little-endian integers used by bsdiff
+func offtin(buf []byte) int64 {
+	y := int64(binary.LittleEndian.Uint64(buf) &^ (1 << 63))
+	if buf[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
@@ -0,0 +1,256 @@
+package secretmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// =============================================================================
+// BINARY PATCH TESTS
+// =============================================================================
+// Tests for applying BSDIFF40 patches
+// =============================================================================
+
+// Patch fixture generated with bsdiff-compatible tooling, transforming
+// testPatchOld into testPatchNew
+const (
+	testPatchOld = "secret_manager v1.0.0 binary"
+	testPatchNew = "secret_manager v1.1.0 binary!!"
+	testPatchHex = "42534449464634302b0000000000000029000000000000001e00000000000000" +
+		"425a6839314159265359c481f07b000005e00058080004200030cd00901a4156" +
+		"6e2ee48a70a1218903e0f6425a6839314159265359177eae66000000c0006201" +
+		"200030cd341268369327177245385090177eae66425a68393141592653599110" +
+		"c72f000000900020002000211846c2ee48a70a12122218e5e0"
+)
+
+func testPatch(t *testing.T) []byte {
+	patch, err := hex.DecodeString(testPatchHex)
+	if err != nil {
+		t.Fatalf("Invalid patch fixture: %v", err)
+	}
+	return patch
+}
+
+func TestBspatch(t *testing.T) {
+	patched, err := bspatch([]byte(testPatchOld), testPatch(t))
+	if err != nil {
+		t.Fatalf("bspatch() error = %v", err)
+	}
+	if string(patched) != testPatchNew {
+		t.Errorf("Expected %q, got %q", testPatchNew, string(patched))
+	}
+}
+
+func TestBspatchErrors(t *testing.T) {
+	patch := testPatch(t)
+
+	tests := []struct {
+		name  string
+		patch []byte
+	}{
+		{
+			name:  "too short",
+			patch: []byte("BSDIFF40"),
+		},
+		{
+			name:  "bad magic",
+			patch: append([]byte("NOTDIFF0"), patch[8:]...),
+		},
+		{
+			name:  "truncated body",
+			patch: patch[:40],
+		},
+		{
+			name:  "overflowing lengths",
+			patch: withPatchHeader(patch, 1<<62, 1<<62, 10),
+		},
+		{
+			name:  "negative length",
+			patch: withPatchHeader(patch, -1, 0, 10),
+		},
+		{
+			name:  "huge new size",
+			patch: withPatchHeader(patch, 0, 0, 1<<62),
+		},
+		{
+			// The declared size is not allocated before the patch produces it
+			name:  "new size beyond the patch",
+			patch: withPatchHeader(patch, offtin(patch[8:16]), offtin(patch[16:24]), maxPatchedSize),
+		},
+		{
+			name:  "corrupt block",
+			patch: append(append([]byte{}, patch[:32]...), make([]byte, len(patch)-32)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bspatch([]byte(testPatchOld), tt.patch)
+			if !errors.Is(err, errCorruptPatch) {
+				t.Errorf("Expected corrupt patch error, got %v", err)
+			}
+		})
+	}
+}
+
+// withPatchHeader returns a copy of patch with its header lengths replaced
+func withPatchHeader(patch []byte, ctrlLen, diffLen, newSize int64) []byte {
+	out := append([]byte{}, patch...)
+	for i, v := range []int64{ctrlLen, diffLen, newSize} {
+		field := out[8+8*i : 16+8*i]
+		magnitude := v
+		if v < 0 {
+			magnitude = -v
+		}
+		binary.LittleEndian.PutUint64(field, uint64(magnitude))
+		if v < 0 {
+			field[7] |= 0x80
+		}
+	}
+	return out
+}
+
+func TestOfftin(t *testing.T) {
+	if got := offtin([]byte{5, 0, 0, 0, 0, 0, 0, 0}); got != 5 {
+		t.Errorf("Expected 5, got %d", got)
+	}
+	if got := offtin([]byte{5, 0, 0, 0, 0, 0, 0, 0x80}); got != -5 {
+		t.Errorf("Expected -5, got %d", got)
+	}
+}
+
+// controlEntry encodes one bsdiff control entry
+func controlEntry(addLen, copyLen, seek int64) []byte {
+	entry := withPatchHeader(make([]byte, 32), addLen, copyLen, seek)
+	return entry[8:]
+}
+
+func TestApplyControls(t *testing.T) {
+	old := []byte("abcdef")
+	tests := []struct {
+		name    string
+		newSize int64
+		ctrl    []byte
+		diff    []byte
+		extra   []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "add_copy_and_seek",
+			newSize: 7,
+			ctrl:    append(controlEntry(2, 3, 2), controlEntry(2, 0, 0)...),
+			diff:    []byte{1, 1, 0, 0},
+			extra:   []byte("XYZ"),
+			want:    "bcXYZef",
+		},
+		{
+			name:    "seek_before_old",
+			newSize: 2,
+			ctrl:    append(controlEntry(0, 0, -10), controlEntry(2, 0, 0)...),
+			diff:    []byte("hi"),
+			want:    "hi",
+		},
+		{
+			name:    "truncated_control",
+			newSize: 4,
+			ctrl:    controlEntry(2, 0, 0)[:20],
+			diff:    []byte{0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "diff_past_new_size",
+			newSize: 2,
+			ctrl:    controlEntry(3, 0, 0),
+			diff:    []byte{0, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "negative_extra",
+			newSize: 2,
+			ctrl:    controlEntry(0, -1, 0),
+			wantErr: true,
+		},
+		{
+			name:    "missing_extra_bytes",
+			newSize: 3,
+			ctrl:    controlEntry(0, 3, 0),
+			extra:   []byte("X"),
+			wantErr: true,
+		},
+		{
+			name:    "endless_empty_entries",
+			newSize: 1,
+			ctrl:    bytes.Repeat(controlEntry(0, 0, 1), maxStalledControls+1),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyControls(old, tt.newSize, bytes.NewReader(tt.ctrl), bytes.NewReader(tt.diff), bytes.NewReader(tt.extra))
+			if tt.wantErr {
+				if !errors.Is(err, errCorruptPatch) {
+					t.Errorf("Expected corrupt patch error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyControls() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// checkPatchResult fails unless a patch either applied to exactly newSize
+// bytes or was rejected as corrupt
+func checkPatchResult(t *testing.T, got []byte, err error, newSize int64) {
+	if err != nil {
+		if !errors.Is(err, errCorruptPatch) {
+			t.Errorf("Expected corrupt patch error, got %v", err)
+		}
+		return
+	}
+	if int64(len(got)) != newSize {
+		t.Errorf("Expected %d bytes, got %d", newSize, len(got))
+	}
+}
+
+func FuzzBspatch(f *testing.F) {
+	patch, _ := hex.DecodeString(testPatchHex)
+	f.Add([]byte(testPatchOld), patch)
+	for _, n := range []int{0, 8, 31, 32, 40, 64, len(patch) - 1} {
+		f.Add([]byte(testPatchOld), patch[:n])
+	}
+	f.Add([]byte(testPatchOld), withPatchHeader(patch, 1<<62, 1<<62, 10))
+	f.Add([]byte{}, withPatchHeader(patch, 0, 0, 64))
+
+	f.Fuzz(func(t *testing.T, old, patch []byte) {
+		got, err := bspatch(old, patch)
+		var newSize int64
+		if len(patch) >= 32 {
+			newSize = offtin(patch[24:32])
+		}
+		checkPatchResult(t, got, err, newSize)
+	})
+}
+
+// FuzzApplyControls feeds hostile control entries straight to the decoder,
+// as the compressed blocks of a real patch are hard to mutate usefully
+func FuzzApplyControls(f *testing.F) {
+	f.Add([]byte("abcdef"), uint16(7), append(controlEntry(2, 3, 2), controlEntry(2, 0, 0)...), []byte{1, 1, 0, 0}, []byte("XYZ"))
+	f.Add([]byte("abcdef"), uint16(2), controlEntry(0, 0, -1<<62), []byte{}, []byte{})
+	f.Add([]byte{}, uint16(4), controlEntry(1<<62, 0, 0), []byte{0}, []byte{})
+	f.Add([]byte("abc"), uint16(3), controlEntry(0, -3, 1<<62)[:17], []byte{}, []byte("xyz"))
+
+	f.Fuzz(func(t *testing.T, old []byte, newSize uint16, ctrl, diff, extra []byte) {
+		got, err := applyControls(old, int64(newSize), bytes.NewReader(ctrl), bytes.NewReader(diff), bytes.NewReader(extra))
+		checkPatchResult(t, got, err, int64(newSize))
+	})
+}
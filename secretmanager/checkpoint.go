@@ -0,0 +1,133 @@
+package secretmanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// checkpointEntry is one line of the checkpoint file, written as soon as a
+// target has been applied
+type checkpointEntry struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// checkpoint records the targets applied so far, so that a run interrupted
+// part way can be resumed with -resume without redoing them
+type checkpoint struct {
+	file *os.File
+	done map[checkpointEntry]bool
+
+	// mu guards file and done while targets are linked concurrently
+	mu sync.Mutex
+}
+
+// runCheckpoint is the checkpoint of the current run, nil without -checkpoint
+var runCheckpoint *checkpoint
+
+// openCheckpoint opens the checkpoint file at path. With resume, the targets
+// it already lists are treated as done and new ones are appended; otherwise
+// it is started afresh
+func openCheckpoint(path string, resume bool) (*checkpoint, error) {
+	cp := &checkpoint{done: make(map[checkpointEntry]bool)}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		if err := cp.load(path); err != nil {
+			return nil, err
+		}
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	cp.file = file
+	return cp, nil
+}
+
+// load reads the targets done in an earlier run. A line cut short by the
+// interruption is ignored, so that target is simply applied again
+func (cp *checkpoint) load(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			cp.done[entry] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// isDone reports whether the link from sourcePath to targetPath was applied
+// by the run being resumed
+func (cp *checkpoint) isDone(sourcePath, targetPath string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.done[checkpointEntry{Source: sourcePath, Target: targetPath}]
+}
+
+// skipCheckpointed records target as skipped if the run being resumed
+// already linked it to sourcePath, and reports whether it did
+func skipCheckpointed(sourcePath string, target Target) bool {
+	if !runCheckpoint.isDone(sourcePath, target.Path) {
+		return false
+	}
+	target.out.printTarget("Already applied before the interruption: %s -> %s (%s)\n", target.Path, sourcePath, target.Description)
+	runSummary.record(LinkResult{
+		Source:      sourcePath,
+		Target:      target.Path,
+		Description: target.Description,
+		Action:      actionSkipped,
+		Message:     "done in checkpoint",
+		Optional:    target.Optional,
+	})
+	return true
+}
+
+// markDone appends a target to the checkpoint and syncs it to disk before
+// the next target is applied
+func (cp *checkpoint) markDone(sourcePath, targetPath string) {
+	if cp == nil {
+		return
+	}
+	entry := checkpointEntry{Source: sourcePath, Target: targetPath}
+	data, _ := json.Marshal(entry)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.done[entry] = true
+	if _, err := cp.file.Write(append(data, '\n')); err != nil {
+		warnf("Warning: failed to write checkpoint: %v\n", err)
+		return
+	}
+	if err := cp.file.Sync(); err != nil {
+		warnf("Warning: failed to write checkpoint: %v\n", err)
+	}
+}
+
+// finish closes the checkpoint and removes it, since a completed run has
+// nothing left to resume
+func (cp *checkpoint) finish() {
+	if cp == nil {
+		return
+	}
+	cp.file.Close()
+	if err := os.Remove(cp.file.Name()); err != nil {
+		warnf("Warning: failed to remove checkpoint: %v\n", err)
+	}
+}
@@ -0,0 +1,108 @@
+package secretmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// CHECKPOINT TESTS
+// =============================================================================
+// Tests for resuming an interrupted run with -checkpoint and -resume
+// =============================================================================
+
+func TestCheckpointResume(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	targets := []string{filepath.Join(tempDir, "a.key"), filepath.Join(tempDir, "b.key"), filepath.Join(tempDir, "c.key")}
+	configPath := sourcePath + ".symlink.json"
+	createFile(t, configPath, fmt.Sprintf(`{"targets":[{"path":%q},{"path":%q},{"path":%q}]}`, targets[0], targets[1], targets[2]))
+	checkpointPath := filepath.Join(tempDir, "run.checkpoint")
+
+	originalOpts := opts
+	originalSummary := runSummary
+	originalSymlink := symlinkFunc
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		symlinkFunc = originalSymlink
+		runCheckpoint = nil
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+
+	// The first run is interrupted after linking the first target: nothing
+	// after it is applied
+	var linked []string
+	interrupted := false
+	symlinkFunc = func(oldname, newname string) error {
+		if interrupted {
+			return errors.New("interrupted")
+		}
+		linked = append(linked, filepath.Base(newname))
+		interrupted = newname == targets[0]
+		return mockSymlink(oldname, newname)
+	}
+	run := func(resume bool) {
+		var err error
+		if runCheckpoint, err = openCheckpoint(checkpointPath, resume); err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		runSummary = &RunSummary{}
+		captureStdout(t, func() {
+			processSymlinkConfig(sourcePath, configPath)
+		})
+	}
+	run(false)
+	runCheckpoint.file.Close()
+
+	// A crash can also leave half a line behind
+	f, _ := os.OpenFile(checkpointPath, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"source":"` + sourcePath)
+	f.Close()
+	data, _ := os.ReadFile(checkpointPath)
+	if !strings.Contains(string(data), targets[0]) || strings.Contains(string(data), targets[1]) {
+		t.Fatalf("Expected only the first target in the checkpoint, got:\n%s", data)
+	}
+
+	// The resumed run skips the first target and applies the rest
+	linked, interrupted = nil, false
+	os.Remove(targets[0])
+	run(true)
+	if strings.Join(linked, ",") != "b.key,c.key" {
+		t.Errorf("Expected only b.key and c.key to be linked on resume, got %v", linked)
+	}
+	if runSummary.count(actionSkipped) != 1 || runSummary.count(actionCreated) != 2 {
+		t.Errorf("Expected one skipped and two created targets, got %+v", runSummary.Results)
+	}
+
+	// Once the run completes there is nothing left to resume
+	runCheckpoint.finish()
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestCheckpointWithoutResumeStartsAfresh(t *testing.T) {
+	checkpointPath := filepath.Join(t.TempDir(), "run.checkpoint")
+	os.WriteFile(checkpointPath, []byte(`{"source":"/s","target":"/t"}`+"\n"), 0600)
+
+	cp, err := openCheckpoint(checkpointPath, false)
+	if err != nil {
+		t.Fatalf("openCheckpoint() error = %v", err)
+	}
+	defer cp.file.Close()
+	if cp.isDone("/s", "/t") {
+		t.Error("Expected a run without -resume to ignore the old checkpoint")
+	}
+	if data, _ := os.ReadFile(checkpointPath); len(data) != 0 {
+		t.Errorf("Expected the old checkpoint to be cleared, got %q", data)
+	}
+}
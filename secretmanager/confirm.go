@@ -0,0 +1,133 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// destructiveCounts is the number of existing entries a plan would remove
+type destructiveCounts struct {
+	overwrites int // regular files or directories replaced by a link
+	relinks    int // links that would point somewhere else
+}
+
+// countDestructive counts the planned links that would replace something
+// other than an identical link. New links are not destructive
+func countDestructive(edges []graphEdge) destructiveCounts {
+	var counts destructiveCounts
+	for _, e := range edges {
+		info, err := lstatFunc(e.target)
+		if err != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			counts.overwrites++
+			continue
+		}
+		if verifySymlink(e.source, e.target) != nil {
+			counts.relinks++
+		}
+	}
+	return counts
+}
+
+// planQuietly builds the plan without recording results or printing the
+// per-target messages that the real run will print again
+func planQuietly(secretDirs []string) []graphEdge {
+	var edges []graphEdge
+	quietly(func() { edges = planGraph(secretDirs) })
+	return edges
+}
+
+// confirmDestructive shows how many existing entries the run would remove and
+// asks before going ahead. Purely additive runs are not prompted
+func confirmDestructive(w io.Writer, secretDirs []string) error {
+	counts := countDestructive(planQuietly(secretDirs))
+	if counts.overwrites == 0 && counts.relinks == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "This run would overwrite %d existing file(s) and replace %d existing link(s).\n", counts.overwrites, counts.relinks)
+	if silentOutput {
+		return fmt.Errorf("refusing to overwrite %d file(s) and replace %d link(s) that Apply cannot confirm", counts.overwrites, counts.relinks)
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("refusing destructive changes without a terminal to confirm them")
+	}
+	if !askConfirmation(w, "Proceed?") {
+		return fmt.Errorf("cancelled")
+	}
+	return nil
+}
+
+// confirmScanBreadth warns when the scan found more than -warn-threshold
+// directories, which usually means it matched vendored or cache folders.
+// Under -strict the run only continues if confirmed at a terminal
+func confirmScanBreadth(w io.Writer, count int) error {
+	if opts.WarnThreshold <= 0 || count <= opts.WarnThreshold {
+		return nil
+	}
+
+	warnf("WARNING: found %d secret directories, more than -warn-threshold %d.\n", count, opts.WarnThreshold)
+	warnf("WARNING: the scan may be matching vendored or cache folders; narrow it with -skip-hidden, -scan-keyword or -only.\n")
+	if !opts.Strict {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("refusing to process %d directories under -strict without a terminal to confirm them", count)
+	}
+	if !askConfirmation(w, fmt.Sprintf("Process all %d directories?", count)) {
+		return fmt.Errorf("cancelled")
+	}
+	return nil
+}
+
+// userHomeDir is mockable so that tests can place the home directory
+var userHomeDir = os.UserHomeDir
+
+// broadScanRoot names the well-known broad location that dir is: the home
+// directory, the filesystem root or a drive root. Any other directory is ""
+func broadScanRoot(dir string) string {
+	dir = filepath.Clean(dir)
+	volume := filepath.VolumeName(dir)
+	if dir == volume+string(filepath.Separator) {
+		if volume != "" {
+			return "drive root"
+		}
+		return "filesystem root"
+	}
+	if home, err := userHomeDir(); err == nil && home != "" {
+		if filepath.Clean(home) == dir {
+			return "home directory"
+		}
+		if resolved, err := evalSymlinks(home); err == nil && resolved == dir {
+			return "home directory"
+		}
+	}
+	return ""
+}
+
+// confirmBroadScan asks before recursively scanning the working directory
+// when it is a broad location, where the scan is slow and likely to match
+// unintended directories. -confirm-broad-scan skips the question
+func confirmBroadScan(w io.Writer) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	kind := broadScanRoot(dir)
+	if kind == "" || opts.ConfirmBroadScan {
+		return nil
+	}
+
+	fmt.Fprintf(w, "The scan root %s is the %s; scanning it can be slow and match unintended directories.\n", dir, kind)
+	if !stdinIsTerminal() {
+		return fmt.Errorf("refusing to scan the %s %s without a terminal to confirm it; choose a directory with -root or pass -confirm-broad-scan", kind, dir)
+	}
+	if !askConfirmation(w, fmt.Sprintf("Scan %s?", dir)) {
+		return fmt.Errorf("cancelled")
+	}
+	return nil
+}
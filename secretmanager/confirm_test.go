@@ -0,0 +1,271 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// DESTRUCTIVE CHANGE CONFIRMATION TESTS
+// =============================================================================
+// Tests for asking before overwriting files with -confirm-destructive
+// =============================================================================
+
+// failingReader fails the test if a prompt answer is read
+type failingReader struct{ t *testing.T }
+
+func (r failingReader) Read(p []byte) (int, error) {
+	r.t.Error("Expected no confirmation prompt")
+	return 0, io.EOF
+}
+
+func TestMainConfirmDestructive(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    bool
+		terminal    bool
+		answer      string
+		wantPrompt  bool
+		wantExit    int
+		wantReplace bool
+	}{
+		{name: "additive_not_prompted", wantExit: -1, wantReplace: true},
+		{name: "destructive_confirmed", existing: true, terminal: true, answer: "y\n", wantPrompt: true, wantExit: -1, wantReplace: true},
+		{name: "destructive_declined", existing: true, terminal: true, answer: "n\n", wantPrompt: true, wantExit: 1},
+		{name: "destructive_without_terminal", existing: true, wantExit: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalExit := exitFunc
+			originalExeDir := executableDir
+			originalTerminal := stdinIsTerminal
+			originalInput := promptInput
+			originalOpts := opts
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd)
+
+			source := filepath.Join(tempDir, "app_secret", "api.key")
+			target := filepath.Join(tempDir, "api.link")
+			createFile(t, source, "secret")
+			createFile(t, source+".symlink.json", fmt.Sprintf(`{"targets":[{"path":%q}]}`, target))
+			if tt.existing {
+				createFile(t, target, "original")
+			}
+
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+			executableDir = func() (string, error) { return tempDir, nil }
+			stdinIsTerminal = func() bool { return tt.terminal }
+			if tt.answer != "" {
+				promptInput = strings.NewReader(tt.answer)
+			} else {
+				promptInput = failingReader{t}
+			}
+			opts.NoOwnerCheck = true
+			opts.ConfirmDestructive = true
+
+			defer func() {
+				exitFunc = originalExit
+				executableDir = originalExeDir
+				stdinIsTerminal = originalTerminal
+				promptInput = originalInput
+				opts = originalOpts
+			}()
+
+			output := captureStdout(t, main)
+
+			if exitCode != tt.wantExit {
+				t.Errorf("Expected exit code %d, got %d\n%s", tt.wantExit, exitCode, output)
+			}
+			if got := strings.Contains(output, "Proceed? [y/N]"); got != tt.wantPrompt {
+				t.Errorf("Expected prompt = %v, got output:\n%s", tt.wantPrompt, output)
+			}
+			if tt.existing && !strings.Contains(output, "would overwrite 1 existing file(s)") {
+				t.Errorf("Expected the destructive operations to be counted, got:\n%s", output)
+			}
+
+			data, _ := os.ReadFile(target)
+			if replaced := string(data) == "SYMLINK:"+filepath.Join("app_secret", "api.key"); replaced != tt.wantReplace {
+				t.Errorf("Expected link created = %v, target contains %q", tt.wantReplace, data)
+			}
+		})
+	}
+}
+
+func TestConfirmScanBreadth(t *testing.T) {
+	tests := []struct {
+		name        string
+		count       int
+		strict      bool
+		terminal    bool
+		answer      string
+		wantWarning bool
+		wantErr     string
+	}{
+		{name: "below threshold", count: 3, strict: true},
+		{name: "at threshold", count: 5, strict: true},
+		{name: "above threshold warns", count: 6, wantWarning: true},
+		{name: "strict without terminal aborts", count: 6, strict: true, wantWarning: true, wantErr: "without a terminal"},
+		{name: "strict confirmed", count: 6, strict: true, terminal: true, answer: "y\n", wantWarning: true},
+		{name: "strict declined", count: 6, strict: true, terminal: true, answer: "n\n", wantWarning: true, wantErr: "cancelled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalTerminal := stdinIsTerminal
+			originalInput := promptInput
+			defer func() {
+				opts = originalOpts
+				stdinIsTerminal = originalTerminal
+				promptInput = originalInput
+			}()
+
+			opts.WarnThreshold = 5
+			opts.Strict = tt.strict
+			opts.WarningsTo = "stdout"
+			stdinIsTerminal = func() bool { return tt.terminal }
+			if tt.answer != "" {
+				promptInput = strings.NewReader(tt.answer)
+			} else {
+				promptInput = failingReader{t}
+			}
+
+			var err error
+			output := captureStdout(t, func() {
+				err = confirmScanBreadth(os.Stdout, tt.count)
+			})
+
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if got := strings.Contains(output, "more than -warn-threshold 5"); got != tt.wantWarning {
+				t.Errorf("Expected warning = %v, got output:\n%s", tt.wantWarning, output)
+			}
+		})
+	}
+}
+
+func TestBroadScanRoot(t *testing.T) {
+	home := t.TempDir()
+	originalHome := userHomeDir
+	userHomeDir = func() (string, error) { return home, nil }
+	defer func() { userHomeDir = originalHome }()
+
+	root := string(filepath.Separator)
+	if volume := filepath.VolumeName(home); volume != "" {
+		root = volume + root
+	}
+	wantRoot := "filesystem root"
+	if filepath.VolumeName(root) != "" {
+		wantRoot = "drive root"
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{name: "root", dir: root, want: wantRoot},
+		{name: "home", dir: home, want: "home directory"},
+		{name: "home_unclean", dir: home + string(filepath.Separator), want: "home directory"},
+		{name: "below_home", dir: filepath.Join(home, "projects"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := broadScanRoot(tt.dir); got != tt.want {
+				t.Errorf("broadScanRoot(%s) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMainBroadScanRoot(t *testing.T) {
+	tests := []struct {
+		name       string
+		root       bool
+		confirm    bool
+		terminal   bool
+		answer     string
+		wantPrompt bool
+		wantExit   int
+		wantLinked bool
+	}{
+		{name: "refused_without_terminal", wantExit: 1},
+		{name: "confirmed", terminal: true, answer: "y\n", wantPrompt: true, wantExit: -1, wantLinked: true},
+		{name: "declined", terminal: true, answer: "n\n", wantPrompt: true, wantExit: 1},
+		{name: "confirm_flag", confirm: true, wantExit: -1, wantLinked: true},
+		{name: "explicit_root", root: true, wantExit: -1, wantLinked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalExit := exitFunc
+			originalExeDir := executableDir
+			originalHome := userHomeDir
+			originalTerminal := stdinIsTerminal
+			originalInput := promptInput
+			originalOpts := opts
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd)
+
+			source := filepath.Join(tempDir, "app_secret", "api.key")
+			target := filepath.Join(tempDir, "api.link")
+			createFile(t, source, "secret")
+			createFile(t, source+".symlink.json", fmt.Sprintf(`{"targets":[{"path":%q}]}`, target))
+
+			// The executable is started from the home directory
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+			executableDir = func() (string, error) { return tempDir, nil }
+			userHomeDir = func() (string, error) { return tempDir, nil }
+			stdinIsTerminal = func() bool { return tt.terminal }
+			if tt.answer != "" {
+				promptInput = strings.NewReader(tt.answer)
+			} else {
+				promptInput = failingReader{t}
+			}
+			opts.NoOwnerCheck = true
+			opts.ConfirmBroadScan = tt.confirm
+			if tt.root {
+				opts.Root = tempDir
+			}
+
+			defer func() {
+				exitFunc = originalExit
+				executableDir = originalExeDir
+				userHomeDir = originalHome
+				stdinIsTerminal = originalTerminal
+				promptInput = originalInput
+				opts = originalOpts
+			}()
+
+			output := captureStdout(t, main)
+
+			if exitCode != tt.wantExit {
+				t.Errorf("Expected exit code %d, got %d\n%s", tt.wantExit, exitCode, output)
+			}
+			if got := strings.Contains(output, "Scan "+tempDir+"? [y/N]"); got != tt.wantPrompt {
+				t.Errorf("Expected prompt = %v, got output:\n%s", tt.wantPrompt, output)
+			}
+			if _, err := os.Lstat(target); (err == nil) != tt.wantLinked {
+				t.Errorf("Expected link created = %v, got %v", tt.wantLinked, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package secretmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// targetClaim is one declaration of a target: the manifest, its source and
+// the target's position in the manifest's targets, from 1
+type targetClaim struct {
+	manifest string
+	source   string
+	index    int
+	priority int
+}
+
+func (c targetClaim) String() string {
+	return fmt.Sprintf("%s (source %s, target #%d)", c.manifest, c.source, c.index)
+}
+
+// targetConflict is a target that more than one source claims
+type targetConflict struct {
+	target string
+	claims []targetClaim
+}
+
+// targetConflictError reports every conflicting target of a plan at once,
+// so that all of them can be fixed in one pass
+type targetConflictError struct {
+	conflicts []targetConflict
+}
+
+func (e *targetConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d targets are claimed by more than one source", len(e.conflicts))
+	for _, c := range e.conflicts {
+		fmt.Fprintf(&b, "\n  %s:", c.target)
+		for _, claim := range c.claims {
+			fmt.Fprintf(&b, "\n    %s", claim)
+		}
+	}
+	return b.String()
+}
+
+// findTargetConflicts returns the targets of links that are declared with
+// different sources, each with every declaration that claims it, in target
+// order. Only one of the sources could end up linked, depending on the
+// processing order; targets whose winner priority decides are left out
+func findTargetConflicts(links []effectiveLink) []targetConflict {
+	absolute := func(path string) string {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+
+	claims := make(map[string][]targetClaim)
+	sources := make(map[string]map[string]bool)
+	for _, link := range links {
+		target, source := absolute(link.Target), absolute(link.Source)
+		claims[target] = append(claims[target], targetClaim{manifest: absolute(link.Manifest), source: source, index: link.index, priority: link.Priority})
+		if sources[target] == nil {
+			sources[target] = make(map[string]bool)
+		}
+		sources[target][source] = true
+	}
+
+	var conflicts []targetConflict
+	for target, c := range claims {
+		if len(sources[target]) > 1 && !resolvedByPriority(c) {
+			conflicts = append(conflicts, targetConflict{target: target, claims: c})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].target < conflicts[j].target })
+	return conflicts
+}
+
+// resolvedByPriority reports whether priority decides which of claims wins:
+// they are all in one secret directory, whose manifests are processed in
+// priority order, and a single source has the highest priority
+func resolvedByPriority(claims []targetClaim) bool {
+	top := claims[0].priority
+	for _, claim := range claims {
+		if filepath.Dir(claim.manifest) != filepath.Dir(claims[0].manifest) {
+			return false
+		}
+		if claim.priority > top {
+			top = claim.priority
+		}
+	}
+	var winner string
+	for _, claim := range claims {
+		if claim.priority != top {
+			continue
+		}
+		if winner != "" && claim.source != winner {
+			return false
+		}
+		winner = claim.source
+	}
+	return true
+}
+
+// checkTargetConflicts reports the conflicting targets of links. Under
+// -strict they are one error; otherwise each is a warning
+func checkTargetConflicts(links []effectiveLink) error {
+	conflicts := findTargetConflicts(links)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if opts.Strict {
+		return &targetConflictError{conflicts: conflicts}
+	}
+	for _, c := range conflicts {
+		claims := make([]string, len(c.claims))
+		for i, claim := range c.claims {
+			claims[i] = claim.String()
+		}
+		warnf("Warning: %s is claimed by more than one source: %s\n", c.target, strings.Join(claims, "; "))
+	}
+	return nil
+}
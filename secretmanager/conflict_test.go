@@ -0,0 +1,206 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// TARGET CONFLICT TESTS
+// =============================================================================
+// Tests for reporting targets that more than one source claims
+// =============================================================================
+
+// writeConflictTree writes three manifests, two of which claim etc/shared.key,
+// and returns the scan root and the two conflicting manifest paths
+func writeConflictTree(t *testing.T) (string, string, string) {
+	tempDir := setupTestDir(t)
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	etc := filepath.Join(tempDir, "etc")
+	os.MkdirAll(etc, 0755)
+	shared := filepath.Join(etc, "shared.key")
+	writeManifest := func(dir, source string, targets ...string) string {
+		createFile(t, filepath.Join(tempDir, dir, source), "secret")
+		config := SymlinkConfig{}
+		for _, target := range targets {
+			config.Targets = append(config.Targets, Target{Path: target})
+		}
+		data, _ := json.Marshal(config)
+		path := filepath.Join(tempDir, dir, source+".symlink.json")
+		createFile(t, path, string(data))
+		return path
+	}
+	first := writeManifest("app_secret", "api.key", shared)
+	second := writeManifest("db_secret", "db.key", filepath.Join(etc, "db.key"), shared)
+	writeManifest("tls_secret", "cert.pem", filepath.Join(etc, "cert.pem"))
+	return tempDir, first, second
+}
+
+func TestFindTargetConflicts(t *testing.T) {
+	root, first, second := writeConflictTree(t)
+	secretDirs, err := findSecretDirectories(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links []effectiveLink
+	quietly(func() { links = planLinks(secretDirs) })
+	conflicts := findTargetConflicts(links)
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %+v", conflicts)
+	}
+	if want := filepath.Join(root, "etc", "shared.key"); conflicts[0].target != want {
+		t.Errorf("Conflicting target = %s, want %s", conflicts[0].target, want)
+	}
+
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.Strict = true
+	err = checkTargetConflicts(links)
+	if err == nil {
+		t.Fatal("Expected an error under -strict")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		"1 targets are claimed by more than one source",
+		first + " (source " + filepath.Join(root, "app_secret", "api.key") + ", target #1)",
+		second + " (source " + filepath.Join(root, "db_secret", "db.key") + ", target #2)",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected %q in error:\n%s", want, message)
+		}
+	}
+	if strings.Contains(message, "cert.pem") {
+		t.Errorf("Did not expect the unrelated manifest in error:\n%s", message)
+	}
+
+	// Without -strict each conflict is a warning
+	opts.Strict = false
+	opts.WarningsTo = "stdout"
+	output := captureStdout(t, func() {
+		if err := checkTargetConflicts(links); err != nil {
+			t.Errorf("Expected no error without -strict, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "Warning: "+conflicts[0].target+" is claimed by more than one source") {
+		t.Errorf("Expected a warning, got %q", output)
+	}
+}
+
+func TestFindTargetConflictsPriority(t *testing.T) {
+	link := func(manifest, source string, priority int) effectiveLink {
+		return effectiveLink{Source: source, Target: "/etc/shared.key", Manifest: manifest, Priority: priority, index: 1}
+	}
+	tests := []struct {
+		name         string
+		links        []effectiveLink
+		wantConflict bool
+	}{
+		{
+			name:  "distinct_priorities",
+			links: []effectiveLink{link("/s/a.symlink.json", "/s/a", 0), link("/s/b.symlink.json", "/s/b", 10)},
+		},
+		{
+			name:         "tied_highest_priority",
+			links:        []effectiveLink{link("/s/a.symlink.json", "/s/a", 10), link("/s/b.symlink.json", "/s/b", 10), link("/s/c.symlink.json", "/s/c", 0)},
+			wantConflict: true,
+		},
+		{
+			name:         "different_secret_directories",
+			links:        []effectiveLink{link("/s/a.symlink.json", "/s/a", 0), link("/t/b.symlink.json", "/t/b", 10)},
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(findTargetConflicts(tt.links)) > 0; got != tt.wantConflict {
+				t.Errorf("conflict = %v, want %v", got, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestMainStrictTargetConflict(t *testing.T) {
+	root, first, second := writeConflictTree(t)
+
+	originalOpts := opts
+	originalExeDir := executableDir
+	originalWd, _ := os.Getwd()
+	defer func() {
+		opts = originalOpts
+		executableDir = originalExeDir
+		os.Chdir(originalWd)
+	}()
+	executableDir = func() (string, error) { return root, nil }
+	opts.Strict = true
+	opts.ErrorJSON = true
+	opts.NoOwnerCheck = true
+
+	var code int
+	var last string
+	captureStdout(t, func() {
+		code, last = runMainForError(t)
+	})
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+
+	var result errorJSON
+	if err := json.Unmarshal([]byte(last), &result); err != nil {
+		t.Fatalf("Expected an error object, got %q: %v", last, err)
+	}
+	if result.Category != errorConfig {
+		t.Errorf("Category = %q, want %q", result.Category, errorConfig)
+	}
+	if !strings.Contains(result.Message, first) || !strings.Contains(result.Message, second) {
+		t.Errorf("Expected both manifests in the message, got %q", result.Message)
+	}
+
+	// Nothing is linked when the plan conflicts
+	for _, name := range []string{"shared.key", "db.key", "cert.pem"} {
+		if _, err := os.Lstat(filepath.Join(root, "etc", name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be linked, got %v", name, err)
+		}
+	}
+}
+
+func TestMainTargetConflictWarning(t *testing.T) {
+	root, _, _ := writeConflictTree(t)
+
+	originalOpts := opts
+	originalExeDir := executableDir
+	originalExit := exitFunc
+	originalWd, _ := os.Getwd()
+	defer func() {
+		opts = originalOpts
+		executableDir = originalExeDir
+		exitFunc = originalExit
+		os.Chdir(originalWd)
+	}()
+	executableDir = func() (string, error) { return root, nil }
+	exitCode := 0
+	exitFunc = func(code int) { exitCode = code }
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+
+	output := captureStdout(t, main)
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0 without -strict, got %d", exitCode)
+	}
+	warning := "Warning: " + filepath.Join(root, "etc", "shared.key") + " is claimed by more than one source"
+	if n := strings.Count(output, warning); n != 1 {
+		t.Errorf("Expected the conflict warning once, got %d in:\n%s", n, output)
+	}
+
+	// The run goes ahead
+	for _, name := range []string{"shared.key", "db.key", "cert.pem"} {
+		if _, err := os.Lstat(filepath.Join(root, "etc", name)); err != nil {
+			t.Errorf("Expected %s to be linked, got %v", name, err)
+		}
+	}
+}
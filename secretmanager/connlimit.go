@@ -0,0 +1,72 @@
+package secretmanager
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// defaultMaxConnections is how many update requests may be in flight at once
+const defaultMaxConnections = 4
+
+// limitedTransport lets at most cap(slots) requests be in flight at once. A
+// slot is held until the response body is closed, since that is when the
+// connection is released
+type limitedTransport struct {
+	base  http.RoundTripper
+	slots chan struct{}
+}
+
+// newLimitedTransport wraps base, or http.DefaultTransport if it is nil
+func newLimitedTransport(base http.RoundTripper, max int) *limitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &limitedTransport{base: base, slots: make(chan struct{}, max)}
+}
+
+// RoundTrip waits for a free slot, or for the request to be cancelled
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-t.slots
+		return nil, err
+	}
+	resp.Body = &slotBody{ReadCloser: resp.Body, release: func() { <-t.slots }}
+	return resp, nil
+}
+
+// inFlight returns the number of requests currently holding a slot
+func (t *limitedTransport) inFlight() int {
+	return len(t.slots)
+}
+
+// slotBody releases its transport slot the first time it is closed
+type slotBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// withConnectionLimit returns a copy of client whose requests share a limit
+// of max concurrent connections. A max of 0 or less leaves client unlimited
+func withConnectionLimit(client *http.Client, max int) *http.Client {
+	if max <= 0 {
+		return client
+	}
+	limited := *client
+	limited.Transport = newLimitedTransport(client.Transport, max)
+	return &limited
+}
@@ -0,0 +1,91 @@
+package secretmanager
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// CONNECTION LIMIT TESTS
+// =============================================================================
+// Tests for capping concurrent update requests with -max-connections
+// =============================================================================
+
+func TestWithConnectionLimit(t *testing.T) {
+	const limit = 2
+
+	var mu sync.Mutex
+	active, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := withConnectionLimit(&http.Client{}, limit)
+	transport := client.Transport.(*limitedTransport)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+				return
+			}
+			if n := transport.inFlight(); n > limit {
+				t.Errorf("Expected at most %d requests in flight, got %d", limit, n)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak == 0 || peak > limit {
+		t.Errorf("Expected between 1 and %d concurrent requests at the server, got %d", limit, peak)
+	}
+	if n := transport.inFlight(); n != 0 {
+		t.Errorf("Expected every slot to be released, %d still held", n)
+	}
+}
+
+func TestWithConnectionLimitDisabled(t *testing.T) {
+	client := &http.Client{}
+	if got := withConnectionLimit(client, 0); got != client {
+		t.Error("Expected a limit of 0 to leave the client unchanged")
+	}
+}
+
+func TestLimitedTransportReleasesOnError(t *testing.T) {
+	transport := newLimitedTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	}), 1)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("http://example.com/"); err == nil {
+			t.Fatal("Expected the transport error")
+		}
+	}
+	if n := transport.inFlight(); n != 0 {
+		t.Errorf("Expected the slot to be released after an error, %d still held", n)
+	}
+}
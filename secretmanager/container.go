@@ -0,0 +1,46 @@
+package secretmanager
+
+import (
+	"os"
+	"strings"
+)
+
+// containerSignals are the hints used to tell whether we run in a container
+type containerSignals struct {
+	DockerEnv    bool   // /.dockerenv exists
+	ContainerEnv bool   // /run/.containerenv exists (Podman)
+	Cgroup       string // Contents of /proc/1/cgroup
+	EnvContainer string // $container, set by systemd-nspawn, LXC and Podman
+}
+
+// cgroupContainerHints are substrings of /proc/1/cgroup found in containers
+var cgroupContainerHints = []string{"docker", "kubepods", "containerd", "libpod", "lxc", "ecs"}
+
+// readContainerSignals is a variable to allow mocking in tests
+var readContainerSignals = func() containerSignals {
+	var s containerSignals
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		s.DockerEnv = true
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		s.ContainerEnv = true
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		s.Cgroup = string(data)
+	}
+	s.EnvContainer = os.Getenv("container")
+	return s
+}
+
+// isContainer reports whether the signals indicate a containerized environment
+func isContainer(s containerSignals) bool {
+	if s.DockerEnv || s.ContainerEnv || s.EnvContainer != "" {
+		return true
+	}
+	for _, hint := range cgroupContainerHints {
+		if strings.Contains(s.Cgroup, hint) {
+			return true
+		}
+	}
+	return false
+}
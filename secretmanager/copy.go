@@ -0,0 +1,71 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+)
+
+// hashFileFunc is a variable to allow mocking in tests
+var hashFileFunc = hashFile
+
+// sameContent reports whether targetPath is a regular file with the same
+// content as sourcePath. Sizes are compared first so that only files that
+// could match are hashed
+func sameContent(sourcePath, targetPath string) (bool, error) {
+	targetInfo, err := lstatFunc(targetPath)
+	if err != nil || !targetInfo.Mode().IsRegular() {
+		return false, err
+	}
+	sourceInfo, err := statFunc(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	if sourceInfo.Size() != targetInfo.Size() {
+		return false, nil
+	}
+
+	sourceHash, err := hashFileFunc(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	targetHash, err := hashFileFunc(targetPath)
+	if err != nil {
+		return false, err
+	}
+	return sourceHash == targetHash, nil
+}
+
+// fallbackCopyUpToDate reports whether the target is a copy left by
+// -copy-fallback that still matches sourcePath while links keep failing
+func fallbackCopyUpToDate(sourcePath string, target Target) bool {
+	if !opts.CopyFallback {
+		return false
+	}
+	if info, err := lstatFunc(target.Path); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	err := probeLink(sourcePath, target.Path)
+	if err == nil || isTransientLinkError(err) {
+		return false
+	}
+	target.out.logf(verboseDecisions, "Link probe for %s failed: %v\n", target.Path, err)
+	same, err := sameContent(sourcePath, target.Path)
+	return err == nil && same
+}
+
+// copySource writes the content of sourcePath to targetPath with the source's
+// permissions, for -copy-fallback when a symlink cannot be created
+func copySource(sourcePath, targetPath string) error {
+	info, err := statFunc(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to copy source: %w", err)
+	}
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to copy source: %w", err)
+	}
+	if err := os.WriteFile(targetPath, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to copy source: %w", err)
+	}
+	return nil
+}
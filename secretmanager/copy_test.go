@@ -0,0 +1,124 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// =============================================================================
+// COPY FALLBACK TESTS
+// =============================================================================
+// Tests for copying sources when symlinks cannot be created
+// =============================================================================
+
+func TestCopyFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		existing      string
+		canLink       bool
+		wantAction    string
+		wantSymlinked bool
+		wantHashes    int
+	}{
+		{name: "identical copy is left untouched", existing: "secret", wantAction: actionUnchanged, wantSymlinked: true, wantHashes: 2},
+		{name: "identical copy is replaced once links work", existing: "secret", canLink: true, wantAction: actionReplaced, wantSymlinked: true},
+		{name: "different content of the same size is copied", existing: "SECRET", wantAction: actionReplaced, wantSymlinked: true, wantHashes: 2},
+		{name: "different size is copied without hashing", existing: "old secret", wantAction: actionReplaced, wantSymlinked: true},
+		{name: "missing target is copied", wantAction: actionCreated, wantSymlinked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalSummary := runSummary
+			originalSymlink := symlinkFunc
+			originalHash := hashFileFunc
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+				symlinkFunc = originalSymlink
+				hashFileFunc = originalHash
+			}()
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			sourcePath := filepath.Join(tempDir, "secret", "api.key")
+			createFile(t, sourcePath, "secret")
+			targetPath := filepath.Join(tempDir, "api.key")
+			if tt.existing != "" {
+				createFile(t, targetPath, tt.existing)
+			}
+
+			opts.NoOwnerCheck = true
+			opts.CopyFallback = true
+			runSummary = &RunSummary{}
+			symlinked := false
+			symlinkFunc = func(oldname, newname string) error {
+				symlinked = true
+				if tt.canLink {
+					return originalSymlink(oldname, newname)
+				}
+				return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EPERM}
+			}
+			hashes := 0
+			hashFileFunc = func(path string) (string, error) {
+				hashes++
+				return hashFile(path)
+			}
+
+			captureStdout(t, func() {
+				if err := createSymlink(sourcePath, Target{Path: targetPath}); err != nil {
+					t.Fatalf("createSymlink() error = %v", err)
+				}
+			})
+
+			if got := runSummary.Results[0].Action; got != tt.wantAction {
+				t.Errorf("Expected action %q, got %q", tt.wantAction, got)
+			}
+			if symlinked != tt.wantSymlinked {
+				t.Errorf("Expected symlink attempted = %v, got %v", tt.wantSymlinked, symlinked)
+			}
+			if hashes != tt.wantHashes {
+				t.Errorf("Expected %d hashes, got %d", tt.wantHashes, hashes)
+			}
+			want := "secret"
+			if tt.canLink {
+				want = "SYMLINK:" + sourcePath
+			}
+			if data, err := os.ReadFile(targetPath); err != nil || string(data) != want {
+				t.Errorf("Expected the target to hold %q, got %q (%v)", want, data, err)
+			}
+		})
+	}
+}
+
+func TestCopyFallbackDisabled(t *testing.T) {
+	originalOpts := opts
+	originalSymlink := symlinkFunc
+	defer func() {
+		opts = originalOpts
+		symlinkFunc = originalSymlink
+	}()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "secret")
+	targetPath := filepath.Join(tempDir, "api.key")
+
+	opts.NoOwnerCheck = true
+	symlinkFunc = func(oldname, newname string) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+
+	if err := createSymlink(sourcePath, Target{Path: targetPath}); err == nil {
+		t.Error("Expected the symlink error without -copy-fallback")
+	}
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no copy without -copy-fallback, got %v", err)
+	}
+}
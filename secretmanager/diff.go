@@ -0,0 +1,83 @@
+package secretmanager
+
+import "fmt"
+
+// diffFormatUnified is the -diff-format printing dry-run changes as
+// unified-diff style lines
+const diffFormatUnified = "unified"
+
+// linkChange is the difference between what is at a target now and the link
+// a run would create there
+type linkChange struct {
+	target    string
+	oldSource string // current link destination, empty if there is none
+	exists    bool   // whether anything is at the target
+	isLink    bool   // whether the existing entry is a symlink
+	newSource string
+}
+
+// computeChange inspects targetPath to describe the change that linking it to
+// sourcePath would make
+func computeChange(sourcePath, targetPath string) linkChange {
+	change := linkChange{target: targetPath, newSource: sourcePath}
+	if _, err := lstatFunc(targetPath); err != nil {
+		return change
+	}
+	change.exists = true
+	if old, err := readlinkFunc(targetPath); err == nil {
+		change.oldSource = old
+		change.isLink = true
+	}
+	return change
+}
+
+// formatUnifiedDiff renders a change as unified-diff style lines. An unchanged
+// link is printed as a context line
+func formatUnifiedDiff(change linkChange) string {
+	added := fmt.Sprintf("+ %s -> %s\n", change.target, change.newSource)
+	switch {
+	case !change.exists:
+		return added
+	case !change.isLink:
+		return fmt.Sprintf("- %s (not a symlink)\n", change.target) + added
+	case change.oldSource == change.newSource:
+		return fmt.Sprintf("  %s -> %s\n", change.target, change.newSource)
+	default:
+		return fmt.Sprintf("- %s -> %s\n", change.target, change.oldSource) + added
+	}
+}
+
+// printPlannedLink reports a link that -dry-run would create, in the
+// -diff-format selected
+func printPlannedLink(sourcePath string, target Target) {
+	if opts.DiffFormat == diffFormatUnified {
+		target.out.printTarget("%s", formatUnifiedDiff(computeChange(sourcePath, target.Path)))
+		return
+	}
+	target.out.printTarget("Would create symlink: %s -> %s (%s)\n", target.Path, sourcePath, target.Description)
+}
+
+// validateDiffFormat checks the -diff-format option
+func validateDiffFormat() error {
+	switch opts.DiffFormat {
+	case "":
+		return nil
+	case diffFormatUnified:
+		if !opts.DryRun {
+			return fmt.Errorf("-diff-format requires -dry-run")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown -diff-format %q (supported: %s)", opts.DiffFormat, diffFormatUnified)
+	}
+}
+
+// writeDiffHeader prints the file header that review tools expect before the
+// change lines
+func writeDiffHeader() {
+	if opts.DiffFormat != diffFormatUnified {
+		return
+	}
+	fmt.Fprintln(outputWriter(), "--- current")
+	fmt.Fprintln(outputWriter(), "+++ planned")
+}
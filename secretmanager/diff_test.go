@@ -0,0 +1,112 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// DIFF FORMAT TESTS
+// =============================================================================
+// Tests for printing dry-run changes with -diff-format
+// =============================================================================
+
+func TestFormatUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change linkChange
+		want   string
+	}{
+		{
+			name:   "new_link",
+			change: linkChange{target: "/app/api.key", newSource: "/secrets/api.key"},
+			want:   "+ /app/api.key -> /secrets/api.key\n",
+		},
+		{
+			name:   "retargeted_link",
+			change: linkChange{target: "/app/api.key", exists: true, isLink: true, oldSource: "/old/api.key", newSource: "/secrets/api.key"},
+			want:   "- /app/api.key -> /old/api.key\n+ /app/api.key -> /secrets/api.key\n",
+		},
+		{
+			name:   "unchanged_link",
+			change: linkChange{target: "/app/api.key", exists: true, isLink: true, oldSource: "/secrets/api.key", newSource: "/secrets/api.key"},
+			want:   "  /app/api.key -> /secrets/api.key\n",
+		},
+		{
+			name:   "replaced_file",
+			change: linkChange{target: "/app/api.key", exists: true, newSource: "/secrets/api.key"},
+			want:   "- /app/api.key (not a symlink)\n+ /app/api.key -> /secrets/api.key\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatUnifiedDiff(tt.change); got != tt.want {
+				t.Errorf("formatUnifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunUnifiedDiff(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	newTarget := filepath.Join(tempDir, "new.key")
+	movedTarget := filepath.Join(tempDir, "moved.key")
+	// The mocked symlink is a file recording its destination
+	createFile(t, movedTarget, "SYMLINK:/old/api.key")
+
+	opts.NoOwnerCheck = true
+	opts.DryRun = true
+	opts.DiffFormat = diffFormatUnified
+
+	output := captureStdout(t, func() {
+		for _, path := range []string{newTarget, movedTarget} {
+			if err := createSymlink(sourcePath, Target{Path: path}); err != nil {
+				t.Fatalf("createSymlink() error = %v", err)
+			}
+		}
+	})
+
+	want := "+ " + newTarget + " -> " + sourcePath + "\n" +
+		"- " + movedTarget + " -> /old/api.key\n" +
+		"+ " + movedTarget + " -> " + sourcePath + "\n"
+	if output != want {
+		t.Errorf("Unexpected diff output:\n%s\nwant:\n%s", output, want)
+	}
+	if strings.Contains(output, "Would create symlink") {
+		t.Error("Unified diff output should replace the plain dry-run lines")
+	}
+}
+
+func TestValidateDiffFormat(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	tests := []struct {
+		format  string
+		dryRun  bool
+		wantErr bool
+	}{
+		{format: "", wantErr: false},
+		{format: diffFormatUnified, dryRun: true, wantErr: false},
+		{format: diffFormatUnified, dryRun: false, wantErr: true},
+		{format: "context", dryRun: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		opts.DiffFormat = tt.format
+		opts.DryRun = tt.dryRun
+		if err := validateDiffFormat(); (err != nil) != tt.wantErr {
+			t.Errorf("validateDiffFormat(%q, dry-run=%v) error = %v, wantErr %v", tt.format, tt.dryRun, err, tt.wantErr)
+		}
+	}
+}
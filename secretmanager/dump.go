@@ -0,0 +1,116 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// effectiveLink is a single source -> target operation after every manifest
+// setting, variable, prefix, root and filter has been applied
+type effectiveLink struct {
+	Source         string `json:"source"`
+	Target         string `json:"target"`
+	Description    string `json:"description,omitempty"`
+	Manifest       string `json:"manifest"`
+	ResolveSource  bool   `json:"resolve_source,omitempty"`
+	Retries        int    `json:"retries,omitempty"`
+	SourcePerm     string `json:"source_perm,omitempty"`
+	Atomic         bool   `json:"atomic,omitempty"`
+	Owner          string `json:"owner,omitempty"`
+	Group          string `json:"group,omitempty"`
+	Optional       bool   `json:"optional,omitempty"`
+	Perm           string `json:"perm,omitempty"`
+	LinkGroup      string `json:"link_group,omitempty"`
+	Priority       int    `json:"priority,omitempty"`
+	VerifyReadable bool   `json:"verify_readable,omitempty"`
+
+	// index is the target's position in its manifest, from 1
+	index int
+}
+
+// planLinks resolves the links the manifests in secretDirs would create,
+// without touching the filesystem, in the order a run would create them
+func planLinks(secretDirs []string) []effectiveLink {
+	var links []effectiveLink
+	for _, secretDir := range secretDirs {
+		manifests, err := listManifests(secretDir)
+		if err != nil {
+			warnf("Warning: %v\n", err)
+			continue
+		}
+
+		for _, m := range orderManifests(manifests) {
+			config, err := m.load()
+			if err != nil {
+				warnf("Warning: %s: %v\n", m.configPath, err)
+				continue
+			}
+			for _, target := range expandTargets(m.sourcePath, config) {
+				retries := opts.LinkRetries
+				if target.Retries > 0 {
+					retries = target.Retries
+				}
+				links = append(links, effectiveLink{
+					Source:         m.sourcePath,
+					Target:         target.Path,
+					Description:    target.Description,
+					Manifest:       m.configPath,
+					ResolveSource:  opts.ResolveSource || target.ResolveSource,
+					Retries:        retries,
+					SourcePerm:     config.SourcePerm,
+					Atomic:         config.Atomic,
+					Owner:          target.Owner,
+					Group:          target.Group,
+					Optional:       target.Optional,
+					Perm:           targetPerm(config, target),
+					LinkGroup:      m.group,
+					Priority:       config.Priority,
+					VerifyReadable: target.VerifyReadable,
+					index:          target.index,
+				})
+			}
+		}
+	}
+	return links
+}
+
+// effectiveManifest is the document written with -dump-effective
+type effectiveManifest struct {
+	Links []effectiveLink `json:"links"`
+}
+
+// writeEffectiveManifest atomically writes the planned links for secretDirs
+// to path as one JSON document. With portable set, as for -dump-effective,
+// paths are written relative to -report-base and links are sorted by source
+// and target so that equivalent trees produce identical output. Otherwise
+// the links stay in processing order, so that applying the plan picks the
+// same winner for an overlapping target as a direct run
+func writeEffectiveManifest(path string, secretDirs []string, portable bool) error {
+	var links []effectiveLink
+	quietly(func() { links = planLinks(secretDirs) })
+	if err := checkTargetConflicts(links); err != nil {
+		return err
+	}
+	if links == nil {
+		links = []effectiveLink{}
+	}
+	if portable {
+		for i := range links {
+			links[i].Source = reportPath(links[i].Source)
+			links[i].Target = reportPath(links[i].Target)
+			links[i].Manifest = reportPath(links[i].Manifest)
+		}
+		sort.SliceStable(links, func(i, j int) bool {
+			if links[i].Source != links[j].Source {
+				return links[i].Source < links[j].Source
+			}
+			return links[i].Target < links[j].Target
+		})
+	}
+
+	data, err := json.MarshalIndent(effectiveManifest{Links: links}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
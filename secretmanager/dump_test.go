@@ -0,0 +1,134 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// =============================================================================
+// EFFECTIVE MANIFEST TESTS
+// =============================================================================
+// Tests for writing the fully resolved link plan with -dump-effective
+// =============================================================================
+
+func TestMainDumpEffective(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	writeManifest := func(dir, source string, config SymlinkConfig) {
+		createFile(t, filepath.Join(tempDir, dir, source), "secret")
+		data, _ := json.Marshal(config)
+		createFile(t, filepath.Join(tempDir, dir, source+".symlink.json"), string(data))
+	}
+	root := filepath.Join(tempDir, "root")
+	etc := filepath.Join(tempDir, "etc")
+	writeManifest("tls_secret", "cert.pem", SymlinkConfig{
+		SourcePerm: "0600",
+		Targets: []Target{
+			{Path: filepath.Join(etc, "nginx", "cert.pem"), Description: "nginx cert", Retries: 3},
+			{Path: "cert.pem", Tags: []string{"dev"}},
+			{Path: filepath.Join(etc, "skipped.pem")},
+		},
+	})
+	writeManifest("app_secret", "api.key", SymlinkConfig{
+		TargetPrefix: "app",
+		Atomic:       true,
+		Targets: []Target{
+			{Path: "${env}/api.key", Description: "API key for ${env}", ResolveSource: true},
+			{Path: "shared/api.key", Tags: []string{"prod"}},
+		},
+	})
+	createFile(t, filepath.Join(tempDir, "vars.json"), `{"env":"staging"}`)
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	opts.DryRun = true
+	opts.NoOwnerCheck = true
+	opts.Vars = filepath.Join(tempDir, "vars.json")
+	opts.TargetRoot = root
+	opts.Tags = []string{"dev"}
+	opts.SkipTargets = []string{filepath.Join(etc, "skipped.pem")}
+	opts.LinkRetries = 1
+	opts.DumpEffective = filepath.Join(tempDir, "effective.json")
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		opts = originalOpts
+		loadVars("")
+	}()
+
+	output := captureStdout(t, main)
+
+	if exitCode != -1 {
+		t.Fatalf("Expected no exit, got %d:\n%s", exitCode, output)
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, "effective.json"))
+	if err != nil {
+		t.Fatalf("Expected the effective manifest to be written: %v", err)
+	}
+
+	path := func(elem ...string) string {
+		quoted, _ := json.Marshal(filepath.Join(elem...))
+		return string(quoted)
+	}
+	expected := fmt.Sprintf(`{
+  "links": [
+    {
+      "source": %s,
+      "target": %s,
+      "description": "API key for staging",
+      "manifest": %s,
+      "resolve_source": true,
+      "retries": 1,
+      "atomic": true
+    },
+    {
+      "source": %s,
+      "target": %s,
+      "description": "nginx cert",
+      "manifest": %s,
+      "retries": 3,
+      "source_perm": "0600"
+    },
+    {
+      "source": %s,
+      "target": %s,
+      "manifest": %s,
+      "retries": 1,
+      "source_perm": "0600"
+    }
+  ]
+}
+`,
+		path("app_secret", "api.key"), path(root, "app", "staging", "api.key"), path("app_secret", "api.key.symlink.json"),
+		path("tls_secret", "cert.pem"), path(etc, "nginx", "cert.pem"), path("tls_secret", "cert.pem.symlink.json"),
+		path("tls_secret", "cert.pem"), path(root, "cert.pem"), path("tls_secret", "cert.pem.symlink.json"))
+	if string(data) != expected {
+		t.Errorf("Unexpected effective manifest:\n%s\nwant:\n%s", data, expected)
+	}
+}
+
+func TestWriteEffectiveManifestEmpty(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "effective.json")
+	if err := writeEffectiveManifest(path, nil, true); err != nil {
+		t.Fatalf("writeEffectiveManifest() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "{\n  \"links\": []\n}\n" {
+		t.Errorf("Expected an empty link list, got %q", data)
+	}
+}
@@ -0,0 +1,53 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// Categories of failures reported by -error-json
+const (
+	errorUsage      = "usage"
+	errorConfig     = "config"
+	errorFilesystem = "filesystem"
+	errorNetwork    = "network"
+	errorAborted    = "aborted"
+	errorTargets    = "targets"
+)
+
+// errorJSON is the object -error-json writes to stderr when a run fails
+type errorJSON struct {
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	Path     string `json:"path,omitempty"`
+}
+
+// fatal prints err to stderr after prefix, followed under -error-json by a
+// JSON description of it, and returns the exit code to use
+func fatal(category, prefix string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", prefix, err)
+	writeErrorJSON(1, category, err)
+	return 1
+}
+
+// writeErrorJSON writes the -error-json object as the last line on stderr.
+// The path is taken from the file or URL error err wraps, if any
+func writeErrorJSON(code int, category string, err error) {
+	if !opts.ErrorJSON {
+		return
+	}
+	result := errorJSON{Code: code, Category: category, Message: err.Error()}
+	var pathErr *os.PathError
+	var urlErr *url.Error
+	if errors.As(err, &pathErr) {
+		result.Path = pathErr.Path
+	} else if errors.As(err, &urlErr) {
+		result.Path = urlErr.URL
+	}
+	data, _ := json.Marshal(result)
+	fmt.Fprintln(os.Stderr, string(data))
+}
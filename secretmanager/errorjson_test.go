@@ -0,0 +1,122 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// ERROR JSON TESTS
+// =============================================================================
+// Tests for the machine-readable failure written by -error-json
+// =============================================================================
+
+// runMainForError runs main until its first exit and returns the exit code
+// and the last line written to stderr
+func runMainForError(t *testing.T) (int, string) {
+	t.Helper()
+	originalExit := exitFunc
+	exitCode := -1
+	exitFunc = func(code int) {
+		if exitCode == -1 {
+			exitCode = code
+		}
+		panic("exit called")
+	}
+	defer func() { exitFunc = originalExit }()
+
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	func() {
+		defer func() { recover() }()
+		main()
+	}()
+	w.Close()
+	os.Stderr = originalStderr
+	output, _ := io.ReadAll(r)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return exitCode, lines[len(lines)-1]
+}
+
+func TestMainErrorJSON(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	varsPath := filepath.Join(tempDir, "missing-vars.json")
+
+	tests := []struct {
+		name   string
+		setup  func()
+		update error
+		want   errorJSON
+	}{
+		{
+			name:  "config error",
+			setup: func() { opts.Vars = varsPath },
+			want:  errorJSON{Code: 1, Category: errorConfig, Path: varsPath},
+		},
+		{
+			name:  "network error",
+			setup: func() { opts.UpdateBackground = true },
+			update: &url.Error{Op: "Get", URL: "https://api.github.com/repos/owner/name/releases/latest",
+				Err: errors.New("connection refused")},
+			want: errorJSON{Code: 1, Category: errorNetwork, Path: "https://api.github.com/repos/owner/name/releases/latest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalCheckAndUpdate := checkAndUpdateFunc
+			defer func() {
+				opts = originalOpts
+				checkAndUpdateFunc = originalCheckAndUpdate
+			}()
+			opts.ErrorJSON = true
+			tt.setup()
+			checkAndUpdateFunc = func() error { return tt.update }
+
+			code, last := runMainForError(t)
+			if code != 1 {
+				t.Errorf("Expected exit code 1, got %d", code)
+			}
+
+			var got errorJSON
+			if err := json.Unmarshal([]byte(last), &got); err != nil {
+				t.Fatalf("Expected the last stderr line to be JSON, got %q: %v", last, err)
+			}
+			if got.Message == "" {
+				t.Error("Expected a message")
+			}
+			got.Message = ""
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteErrorJSONDisabled(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.ErrorJSON = false
+
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	writeErrorJSON(1, errorConfig, errors.New("broken"))
+	w.Close()
+	os.Stderr = originalStderr
+	output, _ := io.ReadAll(r)
+
+	if len(output) != 0 {
+		t.Errorf("Expected no output without -error-json, got %q", output)
+	}
+}
@@ -0,0 +1,141 @@
+package secretmanager
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"strings"
+)
+
+// elfArchs, machoArchs and peArchs map the machine types of executable
+// headers to GOARCH names. Machines not listed are not checked
+var (
+	elfArchs = map[elf.Machine]string{
+		elf.EM_386:       "386",
+		elf.EM_X86_64:    "amd64",
+		elf.EM_ARM:       "arm",
+		elf.EM_AARCH64:   "arm64",
+		elf.EM_RISCV:     "riscv64",
+		elf.EM_S390:      "s390x",
+		elf.EM_LOONGARCH: "loong64",
+	}
+	machoArchs = map[macho.Cpu]string{
+		macho.Cpu386:   "386",
+		macho.CpuAmd64: "amd64",
+		macho.CpuArm:   "arm",
+		macho.CpuArm64: "arm64",
+	}
+	peArchs = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386:  "386",
+		pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+		pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}
+)
+
+// executableArchs returns the architectures the executable at path is built
+// for: one, or several for a universal Mach-O binary. Machines without a
+// GOARCH name are left out
+func executableArchs(path, format string) ([]string, error) {
+	var archs []string
+	add := func(arch string, known bool) {
+		if known {
+			archs = append(archs, arch)
+		}
+	}
+
+	switch format {
+	case "ELF":
+		f, err := elf.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		arch, ok := elfArchs[f.Machine]
+		add(arch, ok)
+	case "Mach-O":
+		if fat, err := macho.OpenFat(path); err == nil {
+			defer fat.Close()
+			for _, a := range fat.Arches {
+				arch, ok := machoArchs[a.Cpu]
+				add(arch, ok)
+			}
+			break
+		}
+		f, err := macho.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		arch, ok := machoArchs[f.Cpu]
+		add(arch, ok)
+	case "PE":
+		f, err := pe.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		arch, ok := peArchs[f.Machine]
+		add(arch, ok)
+	}
+	return archs, nil
+}
+
+// hostArchs returns the architectures an update may be built for: the one
+// given with -target-arch or the running one, and under Rosetta also the
+// native arm64 that findAssetURL prefers
+func hostArchs() []string {
+	arch := goArch()
+	if opts.TargetArch != "" {
+		arch = opts.TargetArch
+	}
+	// Asset names may carry the ARM version (armv6, armv7); headers do not
+	if strings.HasPrefix(arch, "armv") {
+		arch = "arm"
+	}
+
+	archs := []string{arch}
+	if !crossPlatform() && isRosettaTranslated() {
+		archs = append(archs, "arm64")
+	}
+	return archs
+}
+
+// validateExecutableArch reads the header of the executable at path and
+// refuses it when its machine type does not match the host, so that a wrong
+// asset choice is caught before it replaces a working binary
+func validateExecutableArch(path string) error {
+	format := executableFormat(assetOS())
+	if format == "" {
+		return nil
+	}
+
+	archs, err := executableArchs(path, format)
+	if err != nil {
+		return fmt.Errorf("failed to read the %s header of the downloaded executable: %w", format, err)
+	}
+	if len(archs) == 0 {
+		return nil // Not a machine we can name
+	}
+
+	host := hostArchs()
+	for _, arch := range archs {
+		for _, want := range host {
+			if arch == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("downloaded update is built for %s, not %s; refusing to install it",
+		strings.Join(archs, ", "), host[0])
+}
+
+// validateExecutableFile checks both the format and the architecture of a
+// downloaded executable
+func validateExecutableFile(path string) error {
+	if err := validateExecutableFormat(path); err != nil {
+		return err
+	}
+	return validateExecutableArch(path)
+}
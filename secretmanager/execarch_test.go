@@ -0,0 +1,104 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// EXECUTABLE ARCHITECTURE TESTS
+// =============================================================================
+// Tests for refusing a downloaded executable built for another architecture
+// =============================================================================
+
+func TestValidateExecutableArch(t *testing.T) {
+	// The test binary is a real executable for this platform
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skipf("cannot locate the test binary: %v", err)
+	}
+	if executableFormat(runtime.GOOS) == "" {
+		t.Skipf("no executable format known for %s", runtime.GOOS)
+	}
+	if !map[string]bool{"386": true, "amd64": true, "arm": true, "arm64": true}[runtime.GOARCH] {
+		t.Skipf("no header check for %s", runtime.GOARCH)
+	}
+	other := "arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "amd64"
+	}
+
+	originalGOArch := goArch
+	originalRosetta := isRosettaTranslated
+	originalOpts := opts
+	defer func() {
+		goArch = originalGOArch
+		isRosettaTranslated = originalRosetta
+		opts = originalOpts
+	}()
+	isRosettaTranslated = func() bool { return false }
+
+	tests := []struct {
+		name       string
+		hostArch   string
+		targetArch string
+		wantErr    string
+	}{
+		{name: "matching", hostArch: runtime.GOARCH},
+		{name: "mismatched", hostArch: other, wantErr: "built for " + runtime.GOARCH + ", not " + other},
+		{name: "target_arch_override", hostArch: other, targetArch: runtime.GOARCH},
+		{name: "target_arch_mismatched", hostArch: runtime.GOARCH, targetArch: other, wantErr: "not " + other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goArch = func() string { return tt.hostArch }
+			opts.TargetArch = tt.targetArch
+
+			err := validateExecutableArch(exe)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateExecutableArch() unexpected error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("unreadable_header", func(t *testing.T) {
+		goArch = func() string { return runtime.GOARCH }
+		opts.TargetArch = ""
+		path := filepath.Join(t.TempDir(), "secret_manager")
+		if err := os.WriteFile(path, []byte("\x7fELF truncated"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateExecutableArch(path); err == nil || !strings.Contains(err.Error(), "failed to read the") {
+			t.Errorf("Expected a header read error, got %v", err)
+		}
+	})
+}
+
+func TestHostArchs(t *testing.T) {
+	originalGOArch := goArch
+	originalRosetta := isRosettaTranslated
+	originalOpts := opts
+	defer func() {
+		goArch = originalGOArch
+		isRosettaTranslated = originalRosetta
+		opts = originalOpts
+	}()
+
+	goArch = func() string { return "amd64" }
+	isRosettaTranslated = func() bool { return true }
+	if got := strings.Join(hostArchs(), ","); got != "amd64,arm64" {
+		t.Errorf("hostArchs() under Rosetta = %q, want amd64,arm64", got)
+	}
+
+	opts.TargetArch = "armv7"
+	if got := strings.Join(hostArchs(), ","); got != "arm" {
+		t.Errorf("hostArchs() with -target-arch armv7 = %q, want arm", got)
+	}
+}
@@ -0,0 +1,116 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+)
+
+// explainConfig returns a note for each field of a manifest and its targets
+// that was left at its default, describing the effective behavior
+func explainConfig(config SymlinkConfig) []string {
+	var notes []string
+	if config.SchemaVersion == 0 {
+		notes = append(notes, fmt.Sprintf("schema_version defaulted to %d", supportedSchemaVersion))
+	}
+	if config.SourcePerm == "" {
+		notes = append(notes, "source_perm not set -> source permissions left unchanged")
+	}
+	if config.TargetPrefix == "" {
+		notes = append(notes, "target_prefix not set -> relative targets used as written")
+	}
+	if config.PreHook == "" {
+		notes = append(notes, "pre_hook not set -> no command run before linking")
+	}
+	if !config.Atomic {
+		notes = append(notes, "atomic defaulted to false -> targets linked independently")
+	}
+	if config.DefaultPerm == "" {
+		if opts.DefaultPerm != "" {
+			notes = append(notes, fmt.Sprintf("default_perm defaulted to -default-perm (%s)", opts.DefaultPerm))
+		} else {
+			notes = append(notes, "default_perm not set -> targets without perm keep their permissions")
+		}
+	}
+	if config.Priority == 0 {
+		notes = append(notes, "priority defaulted to 0 -> ordered by file name among manifests of equal priority")
+	}
+	if config.LinkPrefix == "" {
+		if opts.LinkPrefix != "" {
+			notes = append(notes, fmt.Sprintf("link_prefix defaulted to -link-prefix (%s)", opts.LinkPrefix))
+		} else {
+			notes = append(notes, "link_prefix not set -> links in directory targets named after the source")
+		}
+	}
+
+	for _, target := range config.Targets {
+		var targetNotes []string
+		if target.Description == "" {
+			targetNotes = append(targetNotes, "description empty -> none shown")
+		}
+		if !target.ResolveSource {
+			targetNotes = append(targetNotes, fmt.Sprintf("resolve_source defaulted to -resolve-source (%t)", opts.ResolveSource))
+		}
+		if len(target.Tags) == 0 {
+			targetNotes = append(targetNotes, "tags empty -> selected regardless of -tags")
+		}
+		if target.Retries == 0 {
+			targetNotes = append(targetNotes, fmt.Sprintf("retries defaulted to -link-retries (%d)", opts.LinkRetries))
+		}
+		if target.Perm == "" {
+			switch perm := targetPerm(config, target); {
+			case config.DefaultPerm != "":
+				targetNotes = append(targetNotes, fmt.Sprintf("perm defaulted to default_perm (%s)", perm))
+			case perm != "":
+				targetNotes = append(targetNotes, fmt.Sprintf("perm defaulted to -default-perm (%s)", perm))
+			default:
+				targetNotes = append(targetNotes, "perm not set -> permissions left unchanged")
+			}
+		}
+		if target.Owner == "" {
+			targetNotes = append(targetNotes, "owner not set -> owner left unchanged")
+		}
+		if target.Group == "" {
+			targetNotes = append(targetNotes, "group not set -> group left unchanged")
+		}
+		if !target.Optional {
+			targetNotes = append(targetNotes, "optional defaulted to false -> a failure fails the run")
+		}
+		if !target.VerifyReadable {
+			targetNotes = append(targetNotes, "verify_readable defaulted to false -> target not opened after linking")
+		}
+
+		notes = append(notes, fmt.Sprintf("target %s:", target.Path))
+		for _, note := range targetNotes {
+			notes = append(notes, "  "+note)
+		}
+	}
+	return notes
+}
+
+// writeConfigExplanation prints, for each manifest in secretDirs, which
+// fields took their default values
+func writeConfigExplanation(w io.Writer, secretDirs []string) {
+	for _, secretDir := range secretDirs {
+		manifests, err := listManifests(secretDir)
+		if err != nil {
+			warnf("Warning: %v\n", err)
+			continue
+		}
+
+		for _, m := range manifests {
+			// A group's sources take no manifest fields of their own
+			if m.group != "" {
+				continue
+			}
+			config, err := m.load()
+			if err != nil {
+				warnf("Warning: %s: %v\n", m.configPath, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s (source %s)\n", m.configPath, m.sourcePath)
+			for _, note := range explainConfig(config) {
+				fmt.Fprintf(w, "  %s\n", note)
+			}
+		}
+	}
+}
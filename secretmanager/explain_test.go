@@ -0,0 +1,88 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// CONFIG EXPLANATION TESTS
+// =============================================================================
+// Tests for reporting defaulted manifest fields with -explain-config
+// =============================================================================
+
+func TestExplainConfigMinimalManifest(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.LinkRetries = 2
+	opts.DefaultPerm = "0640"
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	configPath := filepath.Join(secretDir, "api.key.symlink.json")
+	createFile(t, configPath, `{"targets":[{"path":"../app/api.key"}]}`)
+
+	output := captureStdout(t, func() {
+		writeConfigExplanation(os.Stdout, []string{secretDir})
+	})
+
+	for _, want := range []string{
+		configPath + " (source " + filepath.Join(secretDir, "api.key") + ")",
+		"  schema_version defaulted to 1",
+		"  source_perm not set -> source permissions left unchanged",
+		"  target_prefix not set -> relative targets used as written",
+		"  atomic defaulted to false -> targets linked independently",
+		"  default_perm defaulted to -default-perm (0640)",
+		"  priority defaulted to 0 -> ordered by file name among manifests of equal priority",
+		"  link_prefix not set -> links in directory targets named after the source",
+		"  target ../app/api.key:",
+		"    description empty -> none shown",
+		"    resolve_source defaulted to -resolve-source (false)",
+		"    tags empty -> selected regardless of -tags",
+		"    retries defaulted to -link-retries (2)",
+		"    perm defaulted to -default-perm (0640)",
+		"    owner not set -> owner left unchanged",
+		"    group not set -> group left unchanged",
+		"    optional defaulted to false -> a failure fails the run",
+		"    verify_readable defaulted to false -> target not opened after linking",
+	} {
+		if !strings.Contains(output, want+"\n") {
+			t.Errorf("Expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestExplainConfigSpecifiedFields(t *testing.T) {
+	config := SymlinkConfig{
+		SchemaVersion: 1,
+		SourcePerm:    "0600",
+		TargetPrefix:  "/etc/app",
+		PreHook:       "true",
+		Atomic:        true,
+		DefaultPerm:   "0600",
+		Priority:      1,
+		LinkPrefix:    "app-",
+		Targets: []Target{{
+			Path:           "api.key",
+			Description:    "API key",
+			ResolveSource:  true,
+			Tags:           []string{"tls"},
+			Retries:        3,
+			Perm:           "0600",
+			Owner:          "app",
+			Group:          "app",
+			Optional:       true,
+			VerifyReadable: true,
+		}},
+	}
+
+	notes := explainConfig(config)
+	if len(notes) != 1 || notes[0] != "target api.key:" {
+		t.Errorf("Expected no defaulted fields, got %q", notes)
+	}
+}
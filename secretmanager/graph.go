@@ -0,0 +1,98 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+)
+
+// graphEdge is a planned link from a target to its source
+type graphEdge struct {
+	source        string
+	target        string
+	description   string
+	resolveSource bool
+}
+
+// planGraph resolves the links the manifests in secretDirs would create,
+// without touching the filesystem
+func planGraph(secretDirs []string) []graphEdge {
+	var edges []graphEdge
+	for _, link := range planLinks(secretDirs) {
+		edges = append(edges, graphEdge{
+			source:        link.Source,
+			target:        link.Target,
+			description:   link.Description,
+			resolveSource: link.ResolveSource,
+		})
+	}
+	return edges
+}
+
+// sourceGroup is a unique source with every planned link that points at it
+type sourceGroup struct {
+	source string
+	exists bool
+	edges  []graphEdge
+}
+
+// groupBySource groups planned links by canonical source, so a source shared
+// by several manifests (or reached through a symlink) appears once and is
+// checked for existence once. Groups keep the order sources were first seen
+func groupBySource(edges []graphEdge) []sourceGroup {
+	var groups []sourceGroup
+	index := make(map[string]int)
+	for _, e := range edges {
+		key := canonicalPath(e.source)
+		if resolved, err := evalSymlinks(key); err == nil {
+			key = resolved
+		}
+
+		i, ok := index[key]
+		if !ok {
+			_, err := statFunc(e.source)
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, sourceGroup{source: e.source, exists: err == nil})
+		}
+		groups[i].edges = append(groups[i].edges, e)
+	}
+	return groups
+}
+
+// writeGraph writes the planned links as a Graphviz DOT digraph. Sources are
+// boxes (dashed when missing), targets are ellipses, and each edge follows
+// the symlink from target to source, labeled with the target's description
+func writeGraph(w io.Writer, edges []graphEdge) error {
+	if _, err := fmt.Fprintln(w, "digraph secrets {\n\trankdir=LR;"); err != nil {
+		return err
+	}
+
+	groups := groupBySource(edges)
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		if g.exists {
+			fmt.Fprintf(w, "\t%q [shape=box];\n", g.source)
+		} else {
+			fmt.Fprintf(w, "\t%q [shape=box, style=dashed];\n", g.source)
+		}
+		for _, e := range g.edges {
+			if !seen[e.target] {
+				seen[e.target] = true
+				fmt.Fprintf(w, "\t%q [shape=ellipse];\n", e.target)
+			}
+		}
+	}
+
+	for _, g := range groups {
+		for _, e := range g.edges {
+			if e.description != "" {
+				fmt.Fprintf(w, "\t%q -> %q [label=%q];\n", e.target, g.source, e.description)
+			} else {
+				fmt.Fprintf(w, "\t%q -> %q;\n", e.target, g.source)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,145 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// GRAPH TESTS
+// =============================================================================
+// Tests for printing the planned symlinks as Graphviz DOT
+// =============================================================================
+
+func TestMainGraph(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	writeManifest := func(dir, source string, config SymlinkConfig) {
+		createFile(t, filepath.Join(tempDir, dir, source), "secret")
+		data, _ := json.Marshal(config)
+		createFile(t, filepath.Join(tempDir, dir, source+".symlink.json"), string(data))
+	}
+	writeManifest("app_secret", "api.key", SymlinkConfig{
+		TargetPrefix: "/etc/app",
+		Targets: []Target{
+			{Path: "api.key", Description: "API key"},
+			{Path: "/srv/worker/api.key"},
+		},
+	})
+	writeManifest("tls_secret", "cert.pem", SymlinkConfig{
+		Targets: []Target{{Path: "/etc/nginx/cert.pem", Description: "nginx \"main\" cert"}},
+	})
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	symlinks := 0
+	originalSymlink := symlinkFunc
+	symlinkFunc = func(oldname, newname string) error {
+		symlinks++
+		return nil
+	}
+	opts.Graph = true
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		symlinkFunc = originalSymlink
+		opts = originalOpts
+	}()
+
+	output := captureStdout(t, main)
+
+	if exitCode != -1 {
+		t.Errorf("Expected no exit, got %d", exitCode)
+	}
+	if symlinks != 0 {
+		t.Errorf("Expected -graph to create no links, got %d", symlinks)
+	}
+	if !strings.HasPrefix(output, "digraph secrets {") || !strings.HasSuffix(output, "}\n") {
+		t.Errorf("Expected a DOT digraph, got:\n%s", output)
+	}
+
+	apiKey := filepath.Join("app_secret", "api.key")
+	cert := filepath.Join("tls_secret", "cert.pem")
+	for _, want := range []string{
+		fmt.Sprintf("%q [shape=box];", apiKey),
+		fmt.Sprintf("%q [shape=box];", cert),
+		`"/etc/app/api.key" [shape=ellipse];`,
+		fmt.Sprintf(`"/etc/app/api.key" -> %q [label="API key"];`, apiKey),
+		fmt.Sprintf(`"/srv/worker/api.key" -> %q;`, apiKey),
+		fmt.Sprintf(`"/etc/nginx/cert.pem" -> %q [label="nginx \"main\" cert"];`, cert),
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %s in graph, got:\n%s", want, output)
+		}
+	}
+	if n := strings.Count(output, fmt.Sprintf("%q [shape=box]", apiKey)); n != 1 {
+		t.Errorf("Expected one node per source, got %d", n)
+	}
+	if strings.Contains(output, "Found") {
+		t.Errorf("Expected progress output to stay off stdout, got:\n%s", output)
+	}
+}
+
+func TestGroupBySourceSharedSource(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// Two manifests, one of them reaching the source through a symlink
+	source := filepath.Join(tempDir, "app_secret", "api.key")
+	createFile(t, source, "secret")
+	createFile(t, source+".symlink.json", `{"targets":[{"path":"/etc/app/api.key"}]}`)
+	alias := filepath.Join(tempDir, "worker_secret", "api.key")
+	os.MkdirAll(filepath.Dir(alias), 0755)
+	if err := os.Symlink(source, alias); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	createFile(t, alias+".symlink.json", `{"targets":[{"path":"/srv/worker/api.key"}]}`)
+	createFile(t, filepath.Join(tempDir, "app_secret", "gone.key.symlink.json"), `{"targets":[{"path":"/etc/app/gone.key"}]}`)
+
+	var edges []graphEdge
+	captureStdout(t, func() {
+		edges = planGraph([]string{filepath.Join(tempDir, "app_secret"), filepath.Join(tempDir, "worker_secret")})
+	})
+	groups := groupBySource(edges)
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 unique sources, got %d: %+v", len(groups), groups)
+	}
+	shared := groups[0]
+	if shared.source != source || len(shared.edges) != 2 || !shared.exists {
+		t.Errorf("Expected one existing entry for %s with both targets, got %+v", source, shared)
+	}
+	if groups[1].exists {
+		t.Errorf("Expected the missing source to be reported, got %+v", groups[1])
+	}
+
+	var buf strings.Builder
+	if err := writeGraph(&buf, edges); err != nil {
+		t.Fatalf("writeGraph() error = %v", err)
+	}
+	output := buf.String()
+	if n := strings.Count(output, "[shape=box"); n != 2 {
+		t.Errorf("Expected one node per unique source, got %d:\n%s", n, output)
+	}
+	for _, want := range []string{
+		fmt.Sprintf(`"/srv/worker/api.key" -> %q;`, source),
+		fmt.Sprintf(`%q [shape=box, style=dashed];`, filepath.Join(tempDir, "app_secret", "gone.key")),
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %s in graph, got:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// linkGroup is a set of sources in a directory manifest that are linked into
+// one target directory together or not at all, such as a certificate and its
+// key
+type linkGroup struct {
+	Name        string   `json:"name"`
+	Sources     []string `json:"sources"`
+	TargetDir   string   `json:"target_dir"`
+	Description string   `json:"description,omitempty"`
+	LinkPrefix  string   `json:"link_prefix,omitempty"`
+}
+
+// atomicItem is one source -> target link of an atomic transaction
+type atomicItem struct {
+	source string
+	target Target
+}
+
+// groupName returns the name of the i'th group of a directory manifest, its
+// position from 1 when it has none
+func groupName(group linkGroup, i int) string {
+	if group.Name != "" {
+		return group.Name
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+// config returns the manifest that links one source of the group: into the
+// target directory under the source's own name, atomically. The group's
+// sources are then planned and checked like those of any manifest
+func (g linkGroup) config() *SymlinkConfig {
+	dir := g.TargetDir
+	if !isDirTarget(dir) {
+		dir += string(filepath.Separator)
+	}
+	return &SymlinkConfig{
+		Atomic:     true,
+		LinkPrefix: g.LinkPrefix,
+		Targets:    []Target{{Path: dir, Description: g.Description}},
+	}
+}
+
+// processLinkGroup links the targets of every member of a group, atomically.
+// If any source is missing or refused, nothing is changed and every target
+// is recorded as failed
+func processLinkGroup(name string, members []manifest) {
+	var items []atomicItem
+	var incomplete error
+	for _, m := range members {
+		if incomplete == nil {
+			if _, err := statFunc(m.sourcePath); os.IsNotExist(err) {
+				incomplete = fmt.Errorf("source %s does not exist", m.sourcePath)
+			} else if opts.CheckFilePerms {
+				incomplete = checkFilePerms(m.configPath, m.sourcePath)
+			}
+		}
+
+		// A source refused by expandTargets, such as one outside
+		// -source-allow-root, has already been reported and recorded
+		targets := expandTargets(m.sourcePath, *m.config)
+		if len(targets) == 0 && incomplete == nil {
+			incomplete = fmt.Errorf("source %s was not linked", m.sourcePath)
+		}
+		for _, target := range targets {
+			items = append(items, atomicItem{source: m.sourcePath, target: target})
+		}
+	}
+	linkGroupItems(name, items, incomplete)
+}
+
+// linkGroupItems links the items of a group atomically, or records all of
+// them as failed when the group is incomplete
+func linkGroupItems(name string, items []atomicItem, incomplete error) {
+	if incomplete != nil {
+		warnTarget("Error: group %s: %v, no targets were changed\n", name, incomplete)
+		for _, item := range items {
+			runSummary.record(LinkResult{
+				Source:      item.source,
+				Target:      item.target.Path,
+				Description: item.target.Description,
+				Action:      actionFailed,
+				Message:     "group not linked: " + incomplete.Error(),
+				Optional:    item.target.Optional,
+			})
+		}
+		return
+	}
+
+	if opts.DryRun {
+		for _, item := range items {
+			linkTargets(item.source, []Target{item.target})
+		}
+		return
+	}
+	if err := linkAtomically(items); err != nil {
+		warnTarget("Failed to link group %s, no targets were changed: %v\n", name, err)
+	}
+}
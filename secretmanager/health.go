@@ -0,0 +1,74 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkLinkHealth reports why the link described by e is not in place, or
+// nil if the target is a symlink that resolves to its source. With
+// resolve-source the link is expected to point at the resolved source, and a
+// target that may be a copy (-copy-fallback or the copy link mode) is
+// healthy when it is a regular file with the source's content
+func checkLinkHealth(e graphEdge) error {
+	info, err := lstatFunc(e.target)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("missing")
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		if info.Mode().IsRegular() && (opts.CopyFallback || targetLinkMode(e.source) == linkModeCopy) {
+			same, err := sameContent(e.source, e.target)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return fmt.Errorf("copy differs from source")
+			}
+			return nil
+		}
+		return fmt.Errorf("not a symlink")
+	}
+	source := e.source
+	if opts.ResolveSource || e.resolveSource {
+		if resolved, err := evalSymlinks(source); err == nil {
+			source = resolved
+		}
+	}
+	if err := verifySymlink(source, e.target); err != nil {
+		return err
+	}
+	if _, err := statFunc(e.target); err != nil {
+		return fmt.Errorf("dangling: %v", err)
+	}
+	return nil
+}
+
+// writeHealthReport checks every planned link and writes a PASS/FAIL line
+// for each, followed by the totals. Links are grouped by source, so a source
+// shared by several manifests is checked for existence once and the links
+// to a missing source fail without being inspected. It reports whether all
+// links are healthy
+func writeHealthReport(w io.Writer, edges []graphEdge) bool {
+	failed := 0
+	for _, g := range groupBySource(edges) {
+		for _, e := range g.edges {
+			err := fmt.Errorf("source %s is missing", g.source)
+			if g.exists {
+				err = checkLinkHealth(e)
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(w, "FAIL %s: %v\n", e.target, err)
+				continue
+			}
+			fmt.Fprintf(w, "PASS %s -> %s\n", e.target, e.source)
+		}
+	}
+
+	fmt.Fprintf(w, "Health: %d passed, %d failed\n", len(edges)-failed, failed)
+	return failed == 0
+}
@@ -0,0 +1,177 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// HEALTH CHECK TESTS
+// =============================================================================
+// Tests for verifying that managed links are in place with -health
+// =============================================================================
+
+// healthFixture writes a secret directory whose manifests target the given
+// link names, returning the secret directory and the target paths
+func healthFixture(t *testing.T, tempDir string, names ...string) (string, []string) {
+	secretDir := filepath.Join(tempDir, "secret")
+	linkDir := filepath.Join(tempDir, "links")
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var targets []string
+	for _, name := range names {
+		target := filepath.Join(linkDir, name)
+		targets = append(targets, target)
+		createFile(t, filepath.Join(secretDir, name), "secret")
+		createFile(t, filepath.Join(secretDir, name+".symlink.json"), fmt.Sprintf(`{"targets":[{"path":%q}]}`, target))
+	}
+	return secretDir, targets
+}
+
+func TestMainHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		breakFn  func(t *testing.T, secretDir string, targets []string)
+		wantFail []string
+	}{
+		{
+			name: "all_healthy",
+		},
+		{
+			name: "missing_source_and_target",
+			breakFn: func(t *testing.T, secretDir string, targets []string) {
+				os.Remove(filepath.Join(secretDir, "db.key"))
+				os.Remove(targets[2])
+			},
+			wantFail: []string{"db.key: source ", "tls.pem: missing"},
+		},
+		{
+			name: "not_a_symlink",
+			breakFn: func(t *testing.T, secretDir string, targets []string) {
+				os.Remove(targets[0])
+				createFile(t, targets[0], "copied secret")
+			},
+			wantFail: []string{"api.key: not a symlink"},
+		},
+		{
+			name: "resolved_source",
+			breakFn: func(t *testing.T, secretDir string, targets []string) {
+				source := filepath.Join(secretDir, "api.key")
+				real := filepath.Join(secretDir, "api.real")
+				os.Rename(source, real)
+				os.Symlink(real, source)
+				os.Remove(targets[0])
+				os.Symlink(real, targets[0])
+				opts.ResolveSource = true
+			},
+		},
+		{
+			name: "copy_fallback_copies",
+			breakFn: func(t *testing.T, secretDir string, targets []string) {
+				os.Remove(targets[0])
+				createFile(t, targets[0], "secret")
+				os.Remove(targets[1])
+				createFile(t, targets[1], "stale secret")
+				opts.CopyFallback = true
+			},
+			wantFail: []string{"db.key: copy differs from source"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalExit := exitFunc
+			originalExeDir := executableDir
+			originalReadlink := readlinkFunc
+			originalOpts := opts
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd)
+
+			secretDir, targets := healthFixture(t, tempDir, "api.key", "db.key", "tls.pem")
+			for _, target := range targets {
+				source := filepath.Join(secretDir, filepath.Base(target))
+				if err := os.Symlink(source, target); err != nil {
+					t.Skipf("symlinks not supported: %v", err)
+				}
+			}
+			if tt.breakFn != nil {
+				tt.breakFn(t, secretDir, targets)
+			}
+
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+			executableDir = func() (string, error) { return tempDir, nil }
+			readlinkFunc = os.Readlink
+			opts.Health = true
+			opts.Only = secretDir
+
+			defer func() {
+				exitFunc = originalExit
+				executableDir = originalExeDir
+				readlinkFunc = originalReadlink
+				opts = originalOpts
+			}()
+
+			output := captureStdout(t, main)
+
+			wantCode := -1
+			if len(tt.wantFail) > 0 {
+				wantCode = 1
+			}
+			if exitCode != wantCode {
+				t.Errorf("Expected exit code %d, got %d\n%s", wantCode, exitCode, output)
+			}
+			for _, want := range tt.wantFail {
+				if !strings.Contains(output, "FAIL ") || !strings.Contains(output, want) {
+					t.Errorf("Expected FAIL line containing %q, got:\n%s", want, output)
+				}
+			}
+			passed := len(targets) - len(tt.wantFail)
+			if got := strings.Count(output, "PASS "); got != passed {
+				t.Errorf("Expected %d PASS lines, got %d:\n%s", passed, got, output)
+			}
+			if want := fmt.Sprintf("Health: %d passed, %d failed", passed, len(tt.wantFail)); !strings.Contains(output, want) {
+				t.Errorf("Expected %q in output, got:\n%s", want, output)
+			}
+			// The check reports drift without repairing it
+			if tt.name == "missing_source_and_target" {
+				if _, err := os.Lstat(targets[2]); !os.IsNotExist(err) {
+					t.Errorf("Expected the missing link to stay missing, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteHealthReportSharedSource(t *testing.T) {
+	originalStat := statFunc
+	stats := 0
+	statFunc = func(name string) (os.FileInfo, error) {
+		stats++
+		return os.Stat(name)
+	}
+	defer func() { statFunc = originalStat }()
+
+	edges := []graphEdge{
+		{source: "/nonexistent/api.key", target: "/etc/app/api.key"},
+		{source: "/nonexistent/api.key", target: "/srv/worker/api.key"},
+	}
+	var buf strings.Builder
+	if writeHealthReport(&buf, edges) {
+		t.Errorf("Expected links to a missing source to fail, got:\n%s", buf.String())
+	}
+	if n := strings.Count(buf.String(), "source /nonexistent/api.key is missing"); n != 2 {
+		t.Errorf("Expected both links to report the missing source, got:\n%s", buf.String())
+	}
+	if stats != 1 {
+		t.Errorf("Expected the shared source to be checked once, got %d checks", stats)
+	}
+}
@@ -0,0 +1,38 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// sourceEnvVar is the environment variable through which hooks receive the source path
+const sourceEnvVar = "SECRET_MANAGER_SOURCE"
+
+// runCommand is a variable to allow mocking in tests
+var runCommand = func(command string, env []string) error {
+	var cmd *exec.Cmd
+	if isWindows() {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = outputWriter()
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runPreHook runs a manifest's pre-hook before any of its targets are processed
+func runPreHook(command, sourcePath string) error {
+	if opts.DryRun {
+		printTarget("Would run pre-hook: %s\n", command)
+		return nil
+	}
+
+	printTarget("Running pre-hook: %s\n", command)
+	if err := runCommand(command, []string{sourceEnvVar + "=" + sourcePath}); err != nil {
+		return fmt.Errorf("pre-hook failed: %w", err)
+	}
+	return nil
+}
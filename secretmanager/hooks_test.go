@@ -0,0 +1,179 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// HOOK TESTS
+// =============================================================================
+// Tests for commands run around manifest processing
+// =============================================================================
+
+func TestProcessSymlinkConfigPreHook(t *testing.T) {
+	tests := []struct {
+		name         string
+		hookErr      error
+		strict       bool
+		createSource bool
+		wantErr      bool
+		wantLinks    int
+	}{
+		{
+			name:         "succeeding hook creates source",
+			createSource: true,
+			wantLinks:    1,
+		},
+		{
+			name:      "failing hook warns",
+			hookErr:   errors.New("exit status 1"),
+			wantLinks: 1,
+		},
+		{
+			name:      "failing hook aborts under strict",
+			hookErr:   errors.New("exit status 1"),
+			strict:    true,
+			wantErr:   true,
+			wantLinks: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			sourcePath := filepath.Join(tempDir, "vault.key")
+			if !tt.createSource {
+				createFile(t, sourcePath, "existing")
+			}
+			config := SymlinkConfig{
+				PreHook: "fetch-secret",
+				Targets: []Target{{Path: filepath.Join(tempDir, "link.key")}},
+			}
+			data, _ := json.Marshal(config)
+			configPath := filepath.Join(tempDir, "vault.key.symlink.json")
+			createFile(t, configPath, string(data))
+
+			originalRun := runCommand
+			originalOpts := opts
+			var gotCommand string
+			var gotEnv []string
+			runCommand = func(command string, env []string) error {
+				gotCommand = command
+				gotEnv = env
+				if tt.createSource {
+					createFile(t, sourcePath, "fetched")
+				}
+				return tt.hookErr
+			}
+			opts.Strict = tt.strict
+			defer func() {
+				runCommand = originalRun
+				opts = originalOpts
+			}()
+
+			links := 0
+			originalSymlink := symlinkFunc
+			symlinkFunc = func(oldname, newname string) error {
+				links++
+				return mockSymlink(oldname, newname)
+			}
+			defer func() { symlinkFunc = originalSymlink }()
+
+			err := processSymlinkConfig(sourcePath, configPath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processSymlinkConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "pre-hook failed") {
+				t.Errorf("Expected pre-hook error, got %v", err)
+			}
+			if gotCommand != "fetch-secret" {
+				t.Errorf("Expected pre-hook to run, got %q", gotCommand)
+			}
+			if len(gotEnv) != 1 || gotEnv[0] != sourceEnvVar+"="+sourcePath {
+				t.Errorf("Expected source path in environment, got %v", gotEnv)
+			}
+			if links != tt.wantLinks {
+				t.Errorf("Expected %d links, got %d", tt.wantLinks, links)
+			}
+		})
+	}
+}
+
+func TestRunPreHookDryRun(t *testing.T) {
+	originalRun := runCommand
+	originalOpts := opts
+	runCommand = func(command string, env []string) error {
+		t.Error("Hook should not run in dry-run")
+		return nil
+	}
+	opts.DryRun = true
+	defer func() {
+		runCommand = originalRun
+		opts = originalOpts
+	}()
+
+	output := captureStdout(t, func() {
+		if err := runPreHook("fetch-secret", "source"); err != nil {
+			t.Errorf("runPreHook() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Would run pre-hook: fetch-secret") {
+		t.Errorf("Expected dry-run message, got: %s", output)
+	}
+	// The dry run that predicts outcomes stays silent
+	output = captureStdout(t, func() {
+		quietly(func() { runPreHook("fetch-secret", "source") })
+	})
+	if output != "" {
+		t.Errorf("Expected no output from a quiet dry run, got: %s", output)
+	}
+}
+
+func TestRunPreHookSummaryOnly(t *testing.T) {
+	originalRun := runCommand
+	originalOpts := opts
+	ran := false
+	runCommand = func(command string, env []string) error {
+		ran = true
+		return nil
+	}
+	defer func() {
+		runCommand = originalRun
+		opts = originalOpts
+	}()
+
+	output := captureStdout(t, func() { runPreHook("fetch-secret", "source") })
+	if !strings.Contains(output, "Running pre-hook: fetch-secret") {
+		t.Errorf("Expected the pre-hook line, got: %s", output)
+	}
+
+	// The line is per-target output, which -summary-only leaves out
+	opts.SummaryOnly = true
+	output = captureStdout(t, func() {
+		if err := runPreHook("fetch-secret", "source"); err != nil {
+			t.Errorf("runPreHook() error = %v", err)
+		}
+	})
+	if output != "" {
+		t.Errorf("Expected no output with -summary-only, got: %s", output)
+	}
+	if !ran {
+		t.Error("Expected the hook to run")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	if err := runCommand("exit 0", nil); err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+	if err := runCommand("exit 3", nil); err == nil {
+		t.Error("Expected error for nonzero exit")
+	}
+}
@@ -0,0 +1,92 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// traceWriter is where -http-trace logs, a variable to allow mocking in tests
+var traceWriter io.Writer = os.Stderr
+
+// Headers worth seeing when diagnosing proxies and rate limits
+var (
+	tracedRequestHeaders  = []string{"User-Agent", "Accept", "Range", "Authorization", "Proxy-Authorization"}
+	tracedResponseHeaders = []string{"Content-Type", "Content-Length", "Location", "Via", "X-RateLimit-Remaining"}
+)
+
+// tracingTransport logs each request and its response to traceWriter
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := redactURL(req.URL)
+	fmt.Fprintf(traceWriter, "HTTP > %s %s%s\n", req.Method, target, traceHeaders(req.Header, tracedRequestHeaders))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(traceWriter, "HTTP < %s %s: %v\n", req.Method, target, err)
+		return nil, err
+	}
+	fmt.Fprintf(traceWriter, "HTTP < %s %s%s\n", resp.Status, target, traceHeaders(resp.Header, tracedResponseHeaders))
+	return resp, nil
+}
+
+// redactURL formats u without its user info, query string or fragment,
+// which for signed asset downloads carry short-lived credentials
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.ForceQuery = false
+	redacted.Fragment = ""
+	redacted.RawFragment = ""
+	if u.RawQuery != "" {
+		return redacted.String() + "?[REDACTED]"
+	}
+	return redacted.String()
+}
+
+// traceHeaders formats the named headers that are set, hiding credentials
+// and the query string of a redirect
+func traceHeaders(header http.Header, names []string) string {
+	var fields []string
+	for _, name := range names {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		if strings.HasSuffix(name, "Authorization") {
+			value = "[REDACTED]"
+		}
+		if name == "Location" {
+			if u, err := url.Parse(value); err == nil {
+				value = redactURL(u)
+			} else {
+				value = "[REDACTED]"
+			}
+		}
+		fields = append(fields, name+": "+value)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(fields, ", ") + ")"
+}
+
+// withHTTPTrace returns a copy of client that logs its requests when enabled
+func withHTTPTrace(client *http.Client, enabled bool) *http.Client {
+	if !enabled {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	traced := *client
+	traced.Transport = &tracingTransport{base: base}
+	return &traced
+}
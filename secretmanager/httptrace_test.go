@@ -0,0 +1,74 @@
+package secretmanager
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// HTTP TRACE TESTS
+// =============================================================================
+// Tests for logging update requests with -http-trace
+// =============================================================================
+
+func TestWithHTTPTrace(t *testing.T) {
+	var buf bytes.Buffer
+	originalWriter := traceWriter
+	traceWriter = &buf
+	defer func() { traceWriter = originalWriter }()
+
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/unreachable" {
+			return nil, errors.New("proxy refused connection")
+		}
+		header := make(http.Header)
+		if req.URL.Path == "/download" {
+			header.Set("Location", "https://objects.example.com/asset?X-Amz-Signature=signed_secret")
+			return &http.Response{Status: "302 Found", StatusCode: http.StatusFound, Header: header, Body: http.NoBody}, nil
+		}
+		header.Set("Content-Type", "application/json")
+		header.Set("X-RateLimit-Remaining", "59")
+		return &http.Response{Status: "200 OK", StatusCode: http.StatusOK, Header: header, Body: http.NoBody}, nil
+	})
+	client := withHTTPTrace(&http.Client{Transport: base}, true)
+
+	req, _ := http.NewRequest("GET", "https://api.github.com/repos/owner/name/releases/latest", nil)
+	req.Header.Set("User-Agent", "secret_manager/1.0")
+	req.Header.Set("Authorization", "Bearer ghp_secret_token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	client.Get("https://example.com/unreachable")
+	redirect, _ := http.NewRequest("GET", "https://example.com/download?token=query_secret", nil)
+	client.Transport.RoundTrip(redirect)
+
+	trace := buf.String()
+	for _, want := range []string{
+		"HTTP > GET https://api.github.com/repos/owner/name/releases/latest (User-Agent: secret_manager/1.0, Authorization: [REDACTED])",
+		"HTTP < 200 OK https://api.github.com/repos/owner/name/releases/latest (Content-Type: application/json, X-RateLimit-Remaining: 59)",
+		"HTTP < GET https://example.com/unreachable: proxy refused connection",
+		"HTTP > GET https://example.com/download?[REDACTED]",
+		"HTTP < 302 Found https://example.com/download?[REDACTED] (Location: https://objects.example.com/asset?[REDACTED])",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("Expected trace containing %q, got:\n%s", want, trace)
+		}
+	}
+	for _, secret := range []string{"ghp_secret_token", "query_secret", "signed_secret"} {
+		if strings.Contains(trace, secret) {
+			t.Errorf("Expected %s to be redacted, got:\n%s", secret, trace)
+		}
+	}
+}
+
+func TestWithHTTPTraceDisabled(t *testing.T) {
+	client := &http.Client{}
+	if got := withHTTPTrace(client, false); got != client {
+		t.Error("Expected the client to be unchanged without -http-trace")
+	}
+}
@@ -0,0 +1,117 @@
+package secretmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idleTimer is the part of *time.Timer the idle timeout needs
+type idleTimer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// afterFunc is a variable to allow mocking the clock in tests
+var afterFunc = func(d time.Duration, f func()) idleTimer {
+	return time.AfterFunc(d, f)
+}
+
+// idleTransport aborts a request once no bytes have arrived for idle,
+// however long the request has been running in total
+type idleTransport struct {
+	base http.RoundTripper
+	idle time.Duration
+}
+
+// RoundTrip starts the idle timer before sending, so a server that never
+// answers is caught too, and resets it on every read of the body
+func (t *idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	watch := &idleWatch{idle: t.idle, cancel: cancel}
+	watch.timer = afterFunc(t.idle, watch.expire)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		watch.stop()
+		return nil, watch.wrap(err)
+	}
+	watch.timer.Reset(t.idle)
+	resp.Body = &idleBody{ReadCloser: resp.Body, watch: watch}
+	return resp, nil
+}
+
+// idleWatch cancels its request when the timer fires
+type idleWatch struct {
+	idle   time.Duration
+	cancel context.CancelFunc
+	timer  idleTimer
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (w *idleWatch) expire() {
+	w.mu.Lock()
+	w.expired = true
+	w.mu.Unlock()
+	w.cancel()
+}
+
+func (w *idleWatch) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+// wrap replaces the cancellation error with one naming the idle timeout
+func (w *idleWatch) wrap(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return fmt.Errorf("no data received for %s: %w", w.idle, err)
+	}
+	return err
+}
+
+// idleBody resets the idle timer whenever bytes arrive
+type idleBody struct {
+	io.ReadCloser
+	watch *idleWatch
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watch.timer.Reset(b.watch.idle)
+	}
+	if err != nil && err != io.EOF {
+		err = b.watch.wrap(err)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.watch.stop()
+	return err
+}
+
+// withIdleTimeout returns a copy of client that aborts a request after idle
+// without data. The total timeout is dropped, since the point is to let slow
+// but progressing downloads finish. An idle of 0 or less leaves client as is
+func withIdleTimeout(client *http.Client, idle time.Duration) *http.Client {
+	if idle <= 0 {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	watched := *client
+	watched.Timeout = 0
+	watched.Transport = &idleTransport{base: base, idle: idle}
+	return &watched
+}
@@ -0,0 +1,160 @@
+package secretmanager
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// IDLE TIMEOUT TESTS
+// =============================================================================
+// Tests for aborting update requests only when no data arrives for a while
+// =============================================================================
+
+// fakeClock fires timers when advanced past their deadline
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Duration
+	active   bool
+	fire     func()
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) idleTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, deadline: c.now + d, active: true, fire: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	var due []func()
+	for _, timer := range c.timers {
+		if timer.active && timer.deadline <= c.now {
+			timer.active = false
+			due = append(due, timer.fire)
+		}
+	}
+	c.mu.Unlock()
+	for _, fire := range due {
+		fire()
+	}
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := t.active
+	t.deadline, t.active = t.clock.now+d, true
+	return was
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := t.active
+	t.active = false
+	return was
+}
+
+// trickleBody returns one byte per read, advancing the clock by gap before
+// each; after stallAfter bytes it advances by stall and waits to be cancelled
+type trickleBody struct {
+	ctx        context.Context
+	clock      *fakeClock
+	gap        time.Duration
+	remaining  int
+	stallAfter int
+	stall      time.Duration
+}
+
+func (b *trickleBody) Read(p []byte) (int, error) {
+	if b.remaining == 0 {
+		return 0, io.EOF
+	}
+	if b.stallAfter == 0 {
+		b.clock.advance(b.stall)
+		<-b.ctx.Done()
+		return 0, b.ctx.Err()
+	}
+	b.clock.advance(b.gap)
+	b.stallAfter--
+	b.remaining--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func (b *trickleBody) Close() error { return nil }
+
+func TestWithIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		stallAfter int
+		wantErr    string
+	}{
+		// 100 bytes a second apart take far longer than the idle window in total
+		{name: "slow but steady stream completes", stallAfter: -1},
+		{name: "stalled stream aborts", stallAfter: 10, wantErr: "no data received for 5s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			originalAfterFunc := afterFunc
+			afterFunc = clock.afterFunc
+			defer func() { afterFunc = originalAfterFunc }()
+
+			base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				body := &trickleBody{ctx: req.Context(), clock: clock, gap: time.Second, remaining: 100, stallAfter: tt.stallAfter, stall: time.Minute}
+				return &http.Response{StatusCode: http.StatusOK, Body: body, Header: make(http.Header)}, nil
+			})
+			client := withIdleTimeout(&http.Client{Transport: base, Timeout: 30 * time.Second}, 5*time.Second)
+			if client.Timeout != 0 {
+				t.Errorf("Expected the total timeout to be dropped, got %s", client.Timeout)
+			}
+
+			resp, err := client.Get("http://example.invalid/asset")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if tt.wantErr == "" {
+				if err != nil || len(data) != 100 {
+					t.Errorf("Expected 100 bytes without error, got %d bytes (%v)", len(data), err)
+				}
+				if clock.now <= 5*time.Second {
+					t.Errorf("Expected the download to outlast the idle window, took %s", clock.now)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if len(data) != tt.stallAfter {
+				t.Errorf("Expected %d bytes before the stall, got %d", tt.stallAfter, len(data))
+			}
+		})
+	}
+}
+
+func TestWithIdleTimeoutDisabled(t *testing.T) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if got := withIdleTimeout(client, 0); got != client {
+		t.Errorf("Expected an idle timeout of 0 to leave the client unchanged")
+	}
+}
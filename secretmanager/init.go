@@ -0,0 +1,64 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// scaffoldConfig returns the template manifest -init writes for sourcePath.
+// The description stands in for a comment, since manifests are plain JSON
+func scaffoldConfig(sourcePath string) SymlinkConfig {
+	name := filepath.Base(sourcePath)
+	return SymlinkConfig{
+		SchemaVersion: supportedSchemaVersion,
+		Targets: []Target{
+			{
+				Path:        "/path/to/app/" + name,
+				Description: fmt.Sprintf("Example target: replace path with where the application reads %s", name),
+			},
+		},
+	}
+}
+
+// initManifest writes a template manifest next to sourcePath. An existing
+// manifest is only replaced with -force
+func initManifest(sourcePath string) error {
+	info, err := statFunc(sourcePath)
+	if err != nil {
+		return fmt.Errorf("cannot scaffold a manifest: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot scaffold a manifest: %s is a directory", sourcePath)
+	}
+
+	data, err := json.MarshalIndent(scaffoldConfig(sourcePath), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	configPath := sourcePath + ".symlink.json"
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.Force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(configPath, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists; use -force to overwrite it", configPath)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s; edit its targets, then run secret_manager\n", configPath)
+	return nil
+}
@@ -0,0 +1,75 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// INIT TESTS
+// =============================================================================
+// Tests for scaffolding a template manifest with -init
+// =============================================================================
+
+func TestInitManifest(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "app_secret", "api.key")
+	createFile(t, sourcePath, "secret")
+	configPath := sourcePath + ".symlink.json"
+
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	captureStdout(t, func() {
+		if err := initManifest(sourcePath); err != nil {
+			t.Fatalf("initManifest() error = %v", err)
+		}
+	})
+
+	config, err := loadSymlinkConfig(configPath)
+	if err != nil {
+		t.Fatalf("Expected the scaffolded manifest to parse, got %v", err)
+	}
+	if config.SchemaVersion != supportedSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", supportedSchemaVersion, config.SchemaVersion)
+	}
+	if len(config.Targets) != 1 || !strings.HasSuffix(config.Targets[0].Path, "api.key") || config.Targets[0].Description == "" {
+		t.Errorf("Expected one described example target for api.key, got %+v", config.Targets)
+	}
+
+	// An edited manifest is not overwritten without -force
+	createFile(t, configPath, `{"targets":[{"path":"/etc/app/api.key"}]}`)
+	if err := initManifest(sourcePath); err == nil || !strings.Contains(err.Error(), "use -force") {
+		t.Errorf("Expected an overwrite refusal, got %v", err)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "/etc/app/api.key") {
+		t.Errorf("Expected the existing manifest to be kept, got %s", data)
+	}
+
+	opts.Force = true
+	captureStdout(t, func() {
+		if err := initManifest(sourcePath); err != nil {
+			t.Errorf("initManifest() with -force error = %v", err)
+		}
+	})
+	if data, _ := os.ReadFile(configPath); strings.Contains(string(data), "/etc/app/api.key") {
+		t.Errorf("Expected -force to replace the manifest, got %s", data)
+	}
+}
+
+func TestInitManifestMissingSource(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "missing.key")
+	if err := initManifest(sourcePath); err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+	if _, err := os.Stat(sourcePath + ".symlink.json"); !os.IsNotExist(err) {
+		t.Errorf("Expected no manifest to be written, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package secretmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// json5ToJSON rewrites the JSON5 features hand-edited manifests use into
+// standard JSON: // and /* */ comments, trailing commas, unquoted object keys,
+// single-quoted strings, and hexadecimal numbers, numbers with a leading +
+// and numbers with a leading or trailing decimal point. Infinity and NaN are
+// rejected, since no manifest field can hold them. Anything else is passed
+// through for encoding/json to validate
+func json5ToJSON(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"' || c == '\'':
+			n, err := copyJSON5String(&out, data, i)
+			if err != nil {
+				return nil, err
+			}
+			i = n
+
+		case c == '/':
+			n, err := skipJSON5Comment(data, i)
+			if err != nil {
+				return nil, err
+			}
+			if n == i {
+				out.WriteByte(c)
+				i++
+				continue
+			}
+			// Blank the comment out rather than drop it, so that the offset
+			// of a parse error still points into the manifest as written
+			for _, b := range data[i:n] {
+				if b == '\n' {
+					out.WriteByte('\n')
+				} else {
+					out.WriteByte(' ')
+				}
+			}
+			i = n
+
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			n, err := copyJSON5Number(&out, data, i)
+			if err != nil {
+				return nil, err
+			}
+			i = n
+
+		case c == ',':
+			n, err := skipJSON5Space(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			if n < len(data) && (data[n] == '}' || data[n] == ']') {
+				i++ // Trailing comma
+				continue
+			}
+			out.WriteByte(c)
+			i++
+
+		case isJSON5IdentStart(c):
+			j := i + 1
+			for j < len(data) && isJSON5IdentPart(data[j]) {
+				j++
+			}
+			n, err := skipJSON5Space(data, j)
+			if err != nil {
+				return nil, err
+			}
+			if n < len(data) && data[n] == ':' {
+				out.WriteByte('"')
+				out.Write(data[i:j])
+				out.WriteByte('"')
+			} else if word := string(data[i:j]); word == "Infinity" || word == "NaN" {
+				return nil, fmt.Errorf("json5: %s is not supported in manifests", word)
+			} else {
+				out.Write(data[i:j]) // true, false, null
+			}
+			i = j
+
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// copyJSON5String writes the string starting at data[i] as a double-quoted
+// JSON string and returns the index just past it
+func copyJSON5String(out *bytes.Buffer, data []byte, i int) (int, error) {
+	quote := data[i]
+	out.WriteByte('"')
+	for i++; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == quote:
+			out.WriteByte('"')
+			return i + 1, nil
+		case c == '\\':
+			if i+1 >= len(data) {
+				return 0, errors.New("json5: unterminated string")
+			}
+			i++
+			switch data[i] {
+			case '\'':
+				out.WriteByte('\'')
+			case '\n':
+				// Line continuation
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(data[i])
+			}
+		case c == '"':
+			out.WriteString(`\"`)
+		case c == '\n':
+			return 0, errors.New("json5: newline in string")
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return 0, errors.New("json5: unterminated string")
+}
+
+// copyJSON5Number writes the number starting at data[i] as a JSON number
+// and returns the index just past it. Hexadecimal numbers are converted to
+// decimal, a leading + is dropped and a bare decimal point gets its zero
+func copyJSON5Number(out *bytes.Buffer, data []byte, i int) (int, error) {
+	j := i
+	if data[j] == '+' || data[j] == '-' {
+		if data[j] == '-' {
+			out.WriteByte('-')
+		}
+		j++
+	}
+	start := j
+	hex := j+1 < len(data) && data[j] == '0' && (data[j+1] == 'x' || data[j+1] == 'X')
+	for j < len(data) {
+		c := data[j]
+		exponentSign := (c == '+' || c == '-') && !hex && j > start && (data[j-1] == 'e' || data[j-1] == 'E')
+		if !isJSON5IdentPart(c) && c != '.' && !exponentSign {
+			break
+		}
+		j++
+	}
+
+	number := string(data[start:j])
+	switch {
+	case number == "Infinity" || number == "NaN":
+		return 0, fmt.Errorf("json5: %s is not supported in manifests", number)
+	case hex:
+		v, err := strconv.ParseUint(number[2:], 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("json5: invalid hexadecimal number %s", number)
+		}
+		out.WriteString(strconv.FormatUint(v, 10))
+	default:
+		if strings.HasPrefix(number, ".") {
+			number = "0" + number
+		}
+		number = strings.Replace(number, ".e", "e", 1)
+		number = strings.Replace(number, ".E", "E", 1)
+		out.WriteString(strings.TrimSuffix(number, "."))
+	}
+	return j, nil
+}
+
+// skipJSON5Comment returns the index after a comment starting at data[i], or
+// i itself if there is no comment there
+func skipJSON5Comment(data []byte, i int) (int, error) {
+	if i+1 >= len(data) {
+		return i, nil
+	}
+	switch data[i+1] {
+	case '/':
+		if end := bytes.IndexByte(data[i:], '\n'); end >= 0 {
+			return i + end, nil
+		}
+		return len(data), nil
+	case '*':
+		if end := bytes.Index(data[i+2:], []byte("*/")); end >= 0 {
+			return i + 2 + end + 2, nil
+		}
+		return 0, errors.New("json5: unterminated comment")
+	}
+	return i, nil
+}
+
+// skipJSON5Space returns the index of the next character that is neither
+// whitespace nor part of a comment
+func skipJSON5Space(data []byte, i int) (int, error) {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '/':
+			n, err := skipJSON5Comment(data, i)
+			if err != nil || n == i {
+				return n, err
+			}
+			i = n
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+func isJSON5IdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isJSON5IdentPart(c byte) bool {
+	return isJSON5IdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,185 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// JSON5 TESTS
+// =============================================================================
+// Tests for hand-edited *.symlink.json5 manifests
+// =============================================================================
+
+func TestJSON5ToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    SymlinkConfig
+		wantErr bool
+	}{
+		{
+			name: "comments",
+			input: `// Database credentials
+{
+	/* Both services read the same key */
+	"targets": [
+		{"path": "/etc/app/db.key", "description": "app"}, // primary
+		{"path": "/etc/worker/db.key", "description": "worker // not a comment"}
+	]
+}`,
+			want: SymlinkConfig{Targets: []Target{
+				{Path: "/etc/app/db.key", Description: "app"},
+				{Path: "/etc/worker/db.key", Description: "worker // not a comment"},
+			}},
+		},
+		{
+			name: "trailing_commas",
+			input: `{
+	"targets": [
+		{"path": "/etc/app/db.key", "tags": ["db", "prod",],},
+	],
+}`,
+			want: SymlinkConfig{Targets: []Target{
+				{Path: "/etc/app/db.key", Tags: []string{"db", "prod"}},
+			}},
+		},
+		{
+			name:  "unquoted_keys_and_single_quotes",
+			input: `{targets: [{path: '/etc/app/"db".key', description: 'it\'s the key', resolve_source: true}]}`,
+			want: SymlinkConfig{Targets: []Target{
+				{Path: `/etc/app/"db".key`, Description: "it's the key", ResolveSource: true},
+			}},
+		},
+		{
+			name:  "numbers",
+			input: `{schema_version: 0x1, priority: +2, targets: [{path: "a", retries: 0X0a}]}`,
+			want: SymlinkConfig{SchemaVersion: 1, Priority: 2, Targets: []Target{
+				{Path: "a", Retries: 10},
+			}},
+		},
+		{
+			name:    "infinity",
+			input:   `{priority: Infinity, targets: []}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative_infinity",
+			input:   `{priority: -Infinity, targets: []}`,
+			wantErr: true,
+		},
+		{
+			name:    "nan",
+			input:   `{priority: NaN, targets: []}`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated_comment",
+			input:   `{"targets": [] /* oops`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated_string",
+			input:   `{"targets": [{"path": '/etc/app}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json5ToJSON([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("json5ToJSON() error = %v", err)
+			}
+
+			var got SymlinkConfig
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Converted manifest is not valid JSON: %v\n%s", err, data)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("Expected %s, got %s", wantJSON, gotJSON)
+			}
+		})
+	}
+}
+
+func TestJSON5NumberForms(t *testing.T) {
+	for input, want := range map[string]string{
+		"0x1F":   "31",
+		"-0xff":  "-255",
+		"+1":     "1",
+		".5":     "0.5",
+		"5.":     "5",
+		"-.5e-3": "-0.5e-3",
+		"1.e+2":  "1e+2",
+		"1e-5":   "1e-5",
+	} {
+		got, err := json5ToJSON([]byte(input))
+		if err != nil || string(got) != want {
+			t.Errorf("json5ToJSON(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+}
+
+func TestJSON5CommentOffsets(t *testing.T) {
+	input := "{\n// note\n/* a\nb */ \"targets\": []}"
+	got, err := json5ToJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("json5ToJSON() error = %v", err)
+	}
+	if len(got) != len(input) || strings.Count(string(got), "\n") != strings.Count(input, "\n") {
+		t.Errorf("Expected comments to be blanked in place, got %q", got)
+	}
+}
+
+func TestProcessSecretDirectoryJSON5(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	os.MkdirAll(secretDir, 0755)
+	json5Target := filepath.Join(tempDir, "json5.key")
+	jsonTarget := filepath.Join(tempDir, "json.key")
+
+	createFile(t, filepath.Join(secretDir, "a.key"), "a")
+	createFile(t, filepath.Join(secretDir, "a.key.symlink.json5"), `{
+	// Trailing commas are fine here
+	"targets": [{"path": "`+json5Target+`",},],
+}`)
+
+	// Standard JSON manifests stay strict
+	createFile(t, filepath.Join(secretDir, "b.key"), "b")
+	createFile(t, filepath.Join(secretDir, "b.key.symlink.json"), `{"targets": [{"path": "`+jsonTarget+`",},],}`)
+
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+
+	output := captureStdout(t, func() {
+		if err := processSecretDirectory(secretDir); err != nil {
+			t.Errorf("processSecretDirectory() error = %v", err)
+		}
+	})
+
+	if _, err := os.Stat(json5Target); err != nil {
+		t.Errorf("Expected JSON5 manifest target to be created: %v", err)
+	}
+	if _, err := os.Stat(jsonTarget); !os.IsNotExist(err) {
+		t.Errorf("Expected JSON manifest with trailing commas to be rejected")
+	}
+	if !strings.Contains(output, "failed to parse JSON") {
+		t.Errorf("Expected parse error for the JSON manifest, got: %s", output)
+	}
+}
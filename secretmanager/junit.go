@@ -0,0 +1,79 @@
+package secretmanager
+
+import (
+	"encoding/xml"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite groups the targets of one manifest, named after its source
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single target
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// buildJUnitReport converts the run results into JUnit test suites, one per
+// manifest in the order they were processed. Created, replaced and planned
+// links pass
+func buildJUnitReport(s *RunSummary) junitTestSuites {
+	report := junitTestSuites{Name: "secret_manager"}
+	index := make(map[string]int)
+	for _, result := range s.Results {
+		source := reportPath(result.Source)
+		i, ok := index[source]
+		if !ok {
+			i = len(report.Suites)
+			index[source] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: source})
+		}
+		suite := &report.Suites[i]
+
+		tc := junitTestCase{ClassName: source, Name: reportPath(result.Target)}
+		switch result.Action {
+		case actionFailed:
+			tc.Failure = &junitMessage{Message: result.Message}
+			suite.Failures++
+			report.Failures++
+		case actionSkipped:
+			tc.Skipped = &junitMessage{Message: result.Message}
+			suite.Skipped++
+			report.Skipped++
+		}
+		suite.Tests++
+		report.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return report
+}
+
+// writeJUnitFile atomically writes the run results to path as JUnit XML
+func writeJUnitFile(path string, s *RunSummary) error {
+	data, err := xml.MarshalIndent(buildJUnitReport(s), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append([]byte(xml.Header), append(data, '\n')...))
+}
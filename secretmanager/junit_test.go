@@ -0,0 +1,87 @@
+package secretmanager
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// JUNIT REPORT TESTS
+// =============================================================================
+// Tests for writing the run results as JUnit XML with -junit
+// =============================================================================
+
+func TestWriteJUnitFile(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "junit.xml")
+
+	summary := &RunSummary{}
+	summary.record(LinkResult{Source: "app_secret/api.key", Target: "/etc/app/api.key", Action: actionCreated})
+	summary.record(LinkResult{Source: "app_secret/api.key", Target: "/srv/app/api.key", Action: actionFailed, Message: `failed to create symlink: "<busy> & locked"`})
+	summary.record(LinkResult{Source: "tls_secret/cert.pem", Target: "/etc/nginx/cert.pem", Action: actionSkipped, Message: "skipped by flag"})
+	summary.record(LinkResult{Source: "app_secret/api.key", Target: "/opt/app/api.key", Action: actionReplaced})
+
+	if err := writeJUnitFile(path, summary); err != nil {
+		t.Fatalf("writeJUnitFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "<?xml") {
+		t.Errorf("Expected an XML declaration, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "&lt;busy&gt; &amp; locked") {
+		t.Errorf("Expected the failure message to be escaped, got:\n%s", data)
+	}
+
+	var report struct {
+		XMLName  xml.Name `xml:"testsuites"`
+		Tests    int      `xml:"tests,attr"`
+		Failures int      `xml:"failures,attr"`
+		Skipped  int      `xml:"skipped,attr"`
+		Suites   []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Skipped *struct{} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Report is not valid XML: %v\n%s", err, data)
+	}
+
+	if report.Tests != 4 || report.Failures != 1 || report.Skipped != 1 {
+		t.Errorf("Unexpected totals: tests=%d failures=%d skipped=%d", report.Tests, report.Failures, report.Skipped)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("Expected one suite per manifest, got %d", len(report.Suites))
+	}
+
+	app := report.Suites[0]
+	if app.Name != "app_secret/api.key" || app.Tests != 3 || app.Failures != 1 {
+		t.Errorf("Unexpected suite %s: tests=%d failures=%d", app.Name, app.Tests, app.Failures)
+	}
+	for _, tc := range app.Cases {
+		failed := tc.Name == "/srv/app/api.key"
+		if (tc.Failure != nil) != failed {
+			t.Errorf("Expected failure element for %s = %v", tc.Name, failed)
+		}
+		if failed && tc.Failure.Message != `failed to create symlink: "<busy> & locked"` {
+			t.Errorf("Unexpected failure message %q", tc.Failure.Message)
+		}
+	}
+	if tls := report.Suites[1]; len(tls.Cases) != 1 || tls.Cases[0].Skipped == nil {
+		t.Errorf("Expected a skipped test case for the nginx cert, got %+v", tls.Cases)
+	}
+}
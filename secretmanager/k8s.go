@@ -0,0 +1,51 @@
+package secretmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// k8sDataLink is the symlink in a Kubernetes secret volume that points at the
+// directory holding the current version of every key. Kubernetes swaps it
+// atomically when the secret is updated
+const k8sDataLink = "..data"
+
+// k8sKeys holds the keys of the -k8s-secret-dir volume for the current run
+var k8sKeys map[string]bool
+
+// readK8sSecretKeys returns the keys of a Kubernetes secret volume by
+// following its ..data link to the current data directory
+func readK8sSecretKeys(dir string) (map[string]bool, error) {
+	dataDir, err := readlinkFunc(filepath.Join(dir, k8sDataLink))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a Kubernetes secret volume: %w", dir, err)
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(dir, dataDir)
+	}
+
+	entries, err := readDirFunc(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret data in %s: %w", dir, err)
+	}
+
+	keys := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		keys[entry.Name()] = true
+	}
+	return keys, nil
+}
+
+// k8sSourcePath returns the path to link for a key of the -k8s-secret-dir
+// volume. The key's entry at the top of the volume goes through ..data, so
+// links keep following the secret across updates
+func k8sSourcePath(key string) (string, bool) {
+	if !k8sKeys[key] {
+		return "", false
+	}
+	return filepath.Join(opts.K8sSecretDir, key), true
+}
@@ -0,0 +1,118 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// KUBERNETES SECRET VOLUME TESTS
+// =============================================================================
+// Tests for using the keys of a Kubernetes secret volume as sources
+// =============================================================================
+
+// fakeK8sSecretDir lays out a secret volume the way the kubelet does: the keys
+// live in a timestamped directory, ..data points at it, and each key at the
+// top of the volume points through ..data
+func fakeK8sSecretDir(t *testing.T, dir string, keys map[string]string) {
+	dataDir := "..2024_06_01_12_00_00.123456789"
+	for key, value := range keys {
+		createFile(t, filepath.Join(dir, dataDir, key), value)
+	}
+	if err := os.Symlink(dataDir, filepath.Join(dir, k8sDataLink)); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	for key := range keys {
+		if err := os.Symlink(filepath.Join(k8sDataLink, key), filepath.Join(dir, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadK8sSecretKeys(t *testing.T) {
+	originalReadlink := readlinkFunc
+	readlinkFunc = os.Readlink
+	defer func() { readlinkFunc = originalReadlink }()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	volume := filepath.Join(tempDir, "volume")
+	fakeK8sSecretDir(t, volume, map[string]string{"api.key": "key", "db.password": "pw"})
+
+	keys, err := readK8sSecretKeys(volume)
+	if err != nil {
+		t.Fatalf("readK8sSecretKeys() error = %v", err)
+	}
+	if len(keys) != 2 || !keys["api.key"] || !keys["db.password"] {
+		t.Errorf("Expected the two keys, got %v", keys)
+	}
+
+	if _, err := readK8sSecretKeys(tempDir); err == nil || !strings.Contains(err.Error(), "not a Kubernetes secret volume") {
+		t.Errorf("Expected an error for a directory without ..data, got %v", err)
+	}
+}
+
+func TestMainK8sSecretDir(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalReadlink := readlinkFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	volume := filepath.Join(tempDir, "volume")
+	fakeK8sSecretDir(t, volume, map[string]string{"api.key": "key", "db.password": "pw"})
+
+	manifests := filepath.Join(tempDir, "app_secret")
+	for _, key := range []string{"api.key", "db.password"} {
+		config := fmt.Sprintf(`{"targets":[{"path":%q}]}`, filepath.Join(tempDir, key+".link"))
+		createFile(t, filepath.Join(manifests, key+".symlink.json"), config)
+	}
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	readlinkFunc = os.Readlink
+	opts.NoOwnerCheck = true
+	opts.Only = manifests
+	opts.K8sSecretDir = volume
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		readlinkFunc = originalReadlink
+		opts = originalOpts
+		k8sKeys = nil
+	}()
+
+	// Links are created through the mock, which records their destination
+	symlinks := make(map[string]string)
+	originalSymlink := symlinkFunc
+	symlinkFunc = func(oldname, newname string) error {
+		symlinks[newname] = oldname
+		return os.Symlink(oldname, newname)
+	}
+	defer func() { symlinkFunc = originalSymlink }()
+
+	output := captureStdout(t, main)
+
+	if exitCode != -1 {
+		t.Fatalf("Expected no exit, got %d:\n%s", exitCode, output)
+	}
+	for key, value := range map[string]string{"api.key": "key", "db.password": "pw"} {
+		target := filepath.Join(tempDir, key+".link")
+		if got := symlinks[target]; got != filepath.Join(volume, key) {
+			t.Errorf("Expected %s to link to the volume's %s, got %q", target, key, got)
+		}
+		if data, err := os.ReadFile(target); err != nil || string(data) != value {
+			t.Errorf("Expected %s to resolve to the secret, got %q (%v)", target, data, err)
+		}
+	}
+}
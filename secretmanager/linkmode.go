@@ -0,0 +1,190 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Link modes. With -link-mode auto the mode of each target is chosen from
+// its source by the -link-mode-rule mapping
+const (
+	linkModeSymlink  = "symlink"
+	linkModeCopy     = "copy"
+	linkModeSkip     = "skip"
+	linkModeJunction = "junction"
+	linkModeAuto     = "auto"
+)
+
+// Rule keys for source types, checked before extensions
+const (
+	ruleDir     = "dir"
+	ruleSocket  = "socket"
+	ruleDefault = "*"
+)
+
+// junctionFunc is a variable to allow mocking in tests
+var junctionFunc = createJunction
+
+// defaultLinkModeRules are the -link-mode auto rules that -link-mode-rule
+// adds to or overrides: directories get a junction on Windows, sockets
+// cannot be linked usefully, everything else is symlinked
+var defaultLinkModeRules = map[string]string{
+	ruleDir:     defaultDirLinkMode,
+	ruleSocket:  linkModeSkip,
+	".sock":     linkModeSkip,
+	ruleDefault: linkModeSymlink,
+}
+
+// parseLinkModeRule splits a -link-mode-rule of the form key=mode, where key
+// is dir, socket, an extension such as .pem, or * for any other source
+func parseLinkModeRule(rule string) (string, string, error) {
+	key, mode, ok := strings.Cut(rule, "=")
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("%q is not of the form key=mode", rule)
+	}
+	if key != ruleDir && key != ruleSocket && key != ruleDefault && !strings.HasPrefix(key, ".") {
+		return "", "", fmt.Errorf("unknown key %q (use dir, socket, * or an extension such as .pem)", key)
+	}
+	switch mode {
+	case linkModeSymlink, linkModeSkip:
+		return strings.ToLower(key), mode, nil
+	case linkModeCopy:
+		// Only single files are copied
+		if key == ruleDir {
+			return "", "", fmt.Errorf("directories cannot be copied (use symlink, junction or skip)")
+		}
+		return strings.ToLower(key), mode, nil
+	case linkModeJunction:
+		if key != ruleDir {
+			return "", "", fmt.Errorf("junction is only for directories (use it with the dir key)")
+		}
+		return key, mode, nil
+	}
+	return "", "", fmt.Errorf("unknown mode %q (use symlink, copy, junction or skip)", mode)
+}
+
+// validateLinkMode checks -link-mode and its rules
+func validateLinkMode() error {
+	switch opts.LinkMode {
+	case "", linkModeSymlink, linkModeAuto:
+	default:
+		return fmt.Errorf("invalid -link-mode %q (use symlink or auto)", opts.LinkMode)
+	}
+	for _, rule := range opts.LinkModeRules {
+		if _, _, err := parseLinkModeRule(rule); err != nil {
+			return fmt.Errorf("invalid -link-mode-rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// linkModeRules returns the default rules with -link-mode-rule applied
+func linkModeRules() map[string]string {
+	rules := make(map[string]string, len(defaultLinkModeRules)+len(opts.LinkModeRules))
+	for key, mode := range defaultLinkModeRules {
+		rules[key] = mode
+	}
+	for _, rule := range opts.LinkModeRules {
+		if key, mode, err := parseLinkModeRule(rule); err == nil {
+			rules[key] = mode
+		}
+	}
+	return rules
+}
+
+// targetLinkMode returns how to link sourcePath: symlink, unless -link-mode
+// auto picks a mode by the source's type, then its extension, then the *
+// rule. A source that cannot be inspected is symlinked, leaving the error to
+// the link itself
+func targetLinkMode(sourcePath string) string {
+	if opts.LinkMode != linkModeAuto {
+		return linkModeSymlink
+	}
+
+	rules := linkModeRules()
+	if info, err := statFunc(sourcePath); err == nil {
+		switch {
+		case info.IsDir():
+			if mode, ok := rules[ruleDir]; ok {
+				return mode
+			}
+		case info.Mode()&os.ModeSocket != 0:
+			if mode, ok := rules[ruleSocket]; ok {
+				return mode
+			}
+		}
+	}
+	if ext := strings.ToLower(filepath.Ext(sourcePath)); ext != "" {
+		if mode, ok := rules[ext]; ok {
+			return mode
+		}
+	}
+	if mode, ok := rules[ruleDefault]; ok {
+		return mode
+	}
+	return linkModeSymlink
+}
+
+// copyTarget writes a copy of sourcePath to the target for the copy link
+// mode, leaving an identical copy untouched. A symlink left at the target
+// is removed first so that the copy does not write through it
+func copyTarget(sourcePath string, target Target) (string, string, error) {
+	if same, err := sameContent(sourcePath, target.Path); err == nil && same {
+		target.out.printTarget("Copy up to date: %s (%s)\n", target.Path, target.Description)
+		return actionUnchanged, "content unchanged", nil
+	}
+
+	action := actionCreated
+	if info, err := lstatFunc(target.Path); err == nil {
+		action = actionReplaced
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err := removeFunc(target.Path); err != nil {
+				return "", "", fmt.Errorf("failed to remove existing symlink: %w", err)
+			}
+		}
+	}
+	if err := copySource(sourcePath, target.Path); err != nil {
+		return "", "", err
+	}
+	target.out.printTarget("Copied: %s -> %s (%s)\n", sourcePath, target.Path, target.Description)
+
+	if err := applyTargetPerm(target); err != nil {
+		return "", "", err
+	}
+	if err := applyTargetOwner(target); err != nil {
+		return "", "", err
+	}
+	if err := verifyTargetReadable(target); err != nil {
+		return "", "", err
+	}
+	return action, "copied", nil
+}
+
+// junctionTarget links a directory target to sourcePath with a junction for
+// the junction link mode, replacing any existing link
+func junctionTarget(sourcePath string, target Target) (string, string, error) {
+	action := actionCreated
+	if _, err := lstatFunc(target.Path); err == nil {
+		if err := removeFunc(target.Path); err != nil {
+			return "", "", fmt.Errorf("failed to remove existing link: %w", err)
+		}
+		action = actionReplaced
+	}
+	if err := junctionFunc(sourcePath, target.Path); err != nil {
+		return "", "", fmt.Errorf("failed to create junction: %w", err)
+	}
+	if err := verifySymlink(sourcePath, target.Path); err != nil {
+		return "", "", err
+	}
+	target.out.printTarget("Created junction: %s -> %s (%s)\n", target.Path, sourcePath, target.Description)
+
+	if err := applyTargetOwner(target); err != nil {
+		return "", "", err
+	}
+	if err := verifyTargetReadable(target); err != nil {
+		return "", "", err
+	}
+	return action, "junction", nil
+}
@@ -0,0 +1,224 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// LINK MODE TESTS
+// =============================================================================
+// Tests for choosing the link mode of each target from its source with -link-mode auto
+// =============================================================================
+
+func TestTargetLinkMode(t *testing.T) {
+	tempDir := t.TempDir()
+	dir := filepath.Join(tempDir, "certs")
+	os.MkdirAll(dir, 0755)
+	file := filepath.Join(tempDir, "api.key")
+	pem := filepath.Join(tempDir, "tls.PEM")
+	sock := filepath.Join(tempDir, "agent.sock")
+	for _, path := range []string{file, pem, sock} {
+		createFile(t, path, "content")
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		rules  []string
+		source string
+		want   string
+	}{
+		{name: "directory", mode: linkModeAuto, source: dir, want: linkModeSymlink},
+		{name: "regular_file", mode: linkModeAuto, source: file, want: linkModeSymlink},
+		{name: "skipped_extension", mode: linkModeAuto, source: sock, want: linkModeSkip},
+		{name: "extension_rule", mode: linkModeAuto, rules: []string{".pem=copy"}, source: pem, want: linkModeCopy},
+		{name: "directory_rule", mode: linkModeAuto, rules: []string{"dir=skip"}, source: dir, want: linkModeSkip},
+		{name: "default_rule", mode: linkModeAuto, rules: []string{"*=copy"}, source: file, want: linkModeCopy},
+		{name: "overridden_default", mode: linkModeAuto, rules: []string{".sock=symlink"}, source: sock, want: linkModeSymlink},
+		{name: "not_auto", mode: linkModeSymlink, rules: []string{"*=copy"}, source: sock, want: linkModeSymlink},
+	}
+
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.LinkMode = tt.mode
+			opts.LinkModeRules = tt.rules
+			if got := targetLinkMode(tt.source); got != tt.want {
+				t.Errorf("targetLinkMode(%s) = %q, want %q", filepath.Base(tt.source), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinkModeRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{rule: ".pem=copy"},
+		{rule: "dir=symlink"},
+		{rule: "pem=copy", wantErr: "unknown key"},
+		{rule: ".pem=hardlink", wantErr: "unknown mode"},
+		{rule: ".pem", wantErr: "not of the form key=mode"},
+		{rule: "dir=junction"},
+		{rule: "dir=copy", wantErr: "directories cannot be copied"},
+		{rule: ".pem=junction", wantErr: "only for directories"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, _, err := parseLinkModeRule(tt.rule)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("parseLinkModeRule() unexpected error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateSymlinkAutoLinkMode(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	appDir := filepath.Join(tempDir, "app")
+	os.MkdirAll(appDir, 0755)
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	createFile(t, filepath.Join(secretDir, "tls.pem"), "cert")
+	createFile(t, filepath.Join(secretDir, "agent.sock"), "socket")
+
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	opts.LinkMode = linkModeAuto
+	opts.LinkModeRules = []string{".pem=copy"}
+	runSummary = &RunSummary{}
+
+	captureStdout(t, func() {
+		for _, name := range []string{"api.key", "tls.pem", "agent.sock"} {
+			if err := createSymlink(filepath.Join(secretDir, name), Target{Path: filepath.Join(appDir, name)}); err != nil {
+				t.Errorf("createSymlink(%s) error = %v", name, err)
+			}
+		}
+	})
+
+	wantActions := []string{actionCreated, actionCreated, actionSkipped}
+	for i, result := range runSummary.Results {
+		if result.Action != wantActions[i] {
+			t.Errorf("%s: action = %q, want %q", filepath.Base(result.Target), result.Action, wantActions[i])
+		}
+	}
+	if content, _ := os.ReadFile(filepath.Join(appDir, "api.key")); !strings.HasPrefix(string(content), "SYMLINK:") {
+		t.Errorf("Expected api.key to be symlinked, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(appDir, "tls.pem")); string(content) != "cert" {
+		t.Errorf("Expected tls.pem to be copied, got %q", content)
+	}
+	if _, err := os.Lstat(filepath.Join(appDir, "agent.sock")); !os.IsNotExist(err) {
+		t.Errorf("Expected agent.sock not to be linked, got %v", err)
+	}
+}
+
+func TestCreateSymlinkJunctionLinkMode(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := filepath.Join(tempDir, "secret", "certs")
+	os.MkdirAll(sourceDir, 0755)
+	targetPath := filepath.Join(tempDir, "certs")
+
+	originalOpts := opts
+	originalSummary := runSummary
+	originalJunction := junctionFunc
+	var junctions []string
+	junctionFunc = func(source, target string) error {
+		junctions = append(junctions, target)
+		return mockSymlink(source, target)
+	}
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		junctionFunc = originalJunction
+	}()
+	opts.NoOwnerCheck = true
+	opts.LinkMode = linkModeAuto
+	opts.LinkModeRules = []string{"dir=junction"}
+	runSummary = &RunSummary{}
+
+	output := captureStdout(t, func() {
+		if err := createSymlink(sourceDir, Target{Path: targetPath}); err != nil {
+			t.Fatalf("createSymlink() error = %v", err)
+		}
+	})
+
+	if len(junctions) != 1 || junctions[0] != targetPath {
+		t.Errorf("Expected a junction at %s, got %v", targetPath, junctions)
+	}
+	if !strings.Contains(output, "Created junction") {
+		t.Errorf("Expected the junction to be reported, got %q", output)
+	}
+	if got := runSummary.Results[0].Action; got != actionCreated {
+		t.Errorf("Action = %q, want %q", got, actionCreated)
+	}
+}
+
+func TestProcessSymlinkConfigAtomicLinkMode(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	opts.LinkMode = linkModeAuto
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	createFile(t, second, "old content")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+
+	for _, tt := range []struct {
+		rule       string
+		wantAction string
+		wantFirst  string
+	}{
+		{rule: ".key=copy", wantAction: actionCreated, wantFirst: "key"},
+		{rule: ".key=skip", wantAction: actionSkipped},
+	} {
+		opts.LinkModeRules = []string{tt.rule}
+		runSummary = &RunSummary{}
+		os.Remove(first)
+
+		captureStdout(t, func() {
+			if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+				t.Fatalf("%s: processSymlinkConfig() error = %v", tt.rule, err)
+			}
+		})
+
+		if got := runSummary.Results[0].Action; got != tt.wantAction {
+			t.Errorf("%s: action = %q, want %q", tt.rule, got, tt.wantAction)
+		}
+		data, err := os.ReadFile(first)
+		if tt.wantFirst == "" && !os.IsNotExist(err) {
+			t.Errorf("%s: expected no link, got %q (%v)", tt.rule, data, err)
+		}
+		if tt.wantFirst != "" && string(data) != tt.wantFirst {
+			t.Errorf("%s: expected a copy of the source, got %q (%v)", tt.rule, data, err)
+		}
+		assertNoTempFiles(t, tempDir)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package secretmanager
+
+// defaultDirLinkMode symlinks directories
+const defaultDirLinkMode = linkModeSymlink
+
+// createJunction creates a symlink instead, since junctions only exist on
+// Windows
+func createJunction(source, target string) error {
+	return symlinkFunc(source, target)
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package secretmanager
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDirLinkMode links directories with a junction, which unlike a
+// directory symlink needs neither administrator rights nor developer mode
+const defaultDirLinkMode = linkModeJunction
+
+// createJunction links target to the directory source with a junction. The
+// standard library cannot create one, so this goes through mklink. A
+// junction stores an absolute path, so source is made absolute first
+func createJunction(source, target string) error {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("cmd", "/c", "mklink", "/J", target, abs).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mklink /J: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,1828 @@
+package secretmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// supportedSchemaVersion is the newest manifest schema_version this build understands
+const supportedSchemaVersion = 1
+
+type SymlinkConfig struct {
+	SchemaVersion int      `json:"schema_version,omitempty"`
+	Targets       []Target `json:"targets"`
+	SourcePerm    string   `json:"source_perm,omitempty"`
+	TargetPrefix  string   `json:"target_prefix,omitempty"`
+	PreHook       string   `json:"pre_hook,omitempty"`
+	Atomic        bool     `json:"atomic,omitempty"`
+	DefaultPerm   string   `json:"default_perm,omitempty"`
+	Priority      int      `json:"priority,omitempty"`
+	LinkPrefix    string   `json:"link_prefix,omitempty"`
+}
+
+type Target struct {
+	Path           string   `json:"path"`
+	Description    string   `json:"description"`
+	ResolveSource  bool     `json:"resolve_source,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Retries        int      `json:"retries,omitempty"`
+	Owner          string   `json:"owner,omitempty"`
+	Group          string   `json:"group,omitempty"`
+	Optional       bool     `json:"optional,omitempty"`
+	Perm           string   `json:"perm,omitempty"`
+	VerifyReadable bool     `json:"verify_readable,omitempty"`
+
+	// out is where output about the target goes while targets are linked
+	// concurrently; nil writes directly
+	out *outputUnit
+
+	// index is the target's position in its manifest, from 1
+	index int
+}
+
+// Options holds the command line options that affect symlink processing
+type Options struct {
+	NoOwnerCheck          bool
+	OwnerUID              *int // nil expects the current user
+	Repo                  string
+	APIBase               string
+	DryRun                bool
+	Mkdir                 bool
+	DirPerm               string
+	Strict                bool
+	Ansible               bool
+	ResolveSource         bool
+	SkipHidden            bool
+	UpdateBackground      bool
+	Tags                  []string
+	ExcludeTags           []string
+	RequireTags           bool
+	SkipTargets           []string
+	SkipTargetGlobs       []string
+	ScanCache             string
+	HashVerify            bool
+	RelinkOnChange        bool
+	StateFile             string
+	CompressState         bool
+	Only                  string
+	AllowDowngrade        bool
+	SummaryOnly           bool
+	WaitForTarget         time.Duration
+	Graph                 bool
+	AllowContainerUpdate  bool
+	LinkRetries           int
+	SourceRoot            string
+	TargetRoot            string
+	ManifestGlobs         []string
+	WarningsTo            string
+	RequireNonemptySource bool
+	ConfigArchive         string
+	ConfigArchiveDir      string
+	Vars                  string
+	AllowUndefinedVars    bool
+	SummaryJSONFile       string
+	Verbosity             int
+	DiffFormat            string
+	Thaw                  bool
+	PrintDownloadURL      bool
+	AssumeYesForDowngrade bool
+	Health                bool
+	EnforcePerms          bool
+	AuditOrphans          bool
+	Init                  string
+	Force                 bool
+	CheckFilePerms        bool
+	CheckOpenFiles        bool
+	ReconcileInterval     time.Duration
+	Checkpoint            string
+	Resume                bool
+	JobsPerMount          int
+	UnorderedOutput       bool
+	JUnit                 string
+	SourceAllowRoot       string
+	ExplainConfig         bool
+	NoChdir               bool
+	Root                  string
+	ConfirmBroadScan      bool
+	K8sSecretDir          string
+	ConfirmDestructive    bool
+	ScanKeywords          []string
+	DumpEffective         string
+	CopyFallback          bool
+	ApplyThenVerify       bool
+	ReleaseFile           string
+	AssetRegex            string
+	TargetOS              string
+	TargetArch            string
+	DownloadTo            string
+	ErrorJSON             bool
+	WarnThreshold         int
+	PlanFile              string
+	ApplyPlan             string
+	CheckMountReady       bool
+	MountReadyTimeout     time.Duration
+	ReportBase            string
+	DryRunProbe           bool
+	MaxConnections        int
+	IdleTimeout           time.Duration
+	HTTPTrace             bool
+	DefaultPerm           string
+	LinkPrefix            string
+	LinkMode              string
+	LinkModeRules         []string
+
+	// scanRoot is where the secret directories of the run came from: the
+	// scanned directory, the -only directory or the extracted -config-archive
+	scanRoot string
+}
+
+// stringList is a flag.Value collecting the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// verbosityFlag is a boolean flag.Value that raises the verbosity level by
+// step each time it is given, so -v -v and -vv are equivalent
+type verbosityFlag struct {
+	level *int
+	step  int
+}
+
+func (f verbosityFlag) String() string {
+	if f.level == nil {
+		return "0"
+	}
+	return strconv.Itoa(*f.level)
+}
+
+func (f verbosityFlag) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if on {
+		*f.level += f.step
+	}
+	return nil
+}
+
+func (f verbosityFlag) IsBoolFlag() bool {
+	return true
+}
+
+// ownerUIDFlag is a flag.Value setting an optional uid; a negative uid
+// leaves it unset
+type ownerUIDFlag struct {
+	uid **int
+}
+
+func (f ownerUIDFlag) String() string {
+	if f.uid == nil || *f.uid == nil {
+		return "-1"
+	}
+	return strconv.Itoa(**f.uid)
+}
+
+func (f ownerUIDFlag) Set(value string) error {
+	uid, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*f.uid = nil
+	if uid >= 0 {
+		*f.uid = &uid
+	}
+	return nil
+}
+
+// commaList is a flag.Value collecting comma-separated values from one or
+// more occurrences of a flag
+type commaList []string
+
+func (l *commaList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// opts holds the options parsed from the command line
+var opts Options
+
+// exitFunc is a variable to allow mocking in tests
+var exitFunc = os.Exit
+
+// executableDir is a variable to allow mocking in tests
+var executableDir = getExecutableDir
+
+// Version information (set at build time)
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// osExecutable is a variable to allow mocking in tests
+var osExecutable = os.Executable
+
+// filepathWalk is a variable to allow mocking in tests
+var filepathWalk = filepath.Walk
+
+// findSecretDirs is a variable to allow mocking in tests
+var findSecretDirs = findSecretDirectories
+
+// checkAndUpdateFunc is a variable to allow mocking in tests
+var checkAndUpdateFunc = checkAndUpdate
+
+func getExecutableDir() (string, error) {
+	exe, err := osExecutable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
+}
+
+// findSecretDirectories recursively finds all directories whose name contains
+// a scan keyword ("secret" by default)
+func findSecretDirectories(root string) ([]string, error) {
+	if opts.ScanCache != "" {
+		if secretDirs, ok := loadScanCache(opts.ScanCache, root); ok {
+			sort.Strings(secretDirs)
+			return secretDirs, nil
+		}
+	}
+	
+	secretDirs, allDirs, err := walkSecretDirectories(root)
+	if err != nil {
+		return nil, err
+	}
+	
+	if opts.ScanCache != "" {
+		if err := saveScanCache(opts.ScanCache, root, secretDirs, allDirs); err != nil {
+			warnf("Warning: failed to write scan cache: %v\n", err)
+		}
+	}
+	
+	// Processed in path order, so overlapping targets resolve the same way
+	// on every run and filesystem
+	sort.Strings(secretDirs)
+	return secretDirs, nil
+}
+
+// walkSecretDirectories walks root, returning the secret directories found
+// along with every directory visited
+func walkSecretDirectories(root string) ([]string, []string, error) {
+	var secretDirs, allDirs []string
+	
+	err := filepathWalk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip directories that can't be accessed
+		}
+		
+		if opts.SkipHidden && info.IsDir() && path != root && strings.HasPrefix(info.Name(), ".") {
+			logf(verboseSkips, "Skipping hidden directory %s\n", path)
+			return filepath.SkipDir
+		}
+		
+		if info.IsDir() {
+			allDirs = append(allDirs, path)
+		}
+		
+		if info.IsDir() && matchesScanKeyword(info.Name()) {
+			secretDirs = append(secretDirs, path)
+		}
+		
+		return nil
+	})
+	
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	return secretDirs, allDirs, nil
+}
+
+// scanKeywords returns the words a directory name is matched against
+func scanKeywords() []string {
+	if len(opts.ScanKeywords) == 0 {
+		return []string{"secret"}
+	}
+	return opts.ScanKeywords
+}
+
+// matchesScanKeyword reports whether a directory name contains one of the
+// scan keywords, ignoring case
+func matchesScanKeyword(name string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range scanKeywords() {
+		if strings.Contains(name, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags is a variable to allow mocking in tests
+var parseFlags func() (*bool, *bool)
+
+// defaultParseFlags is the default implementation of parseFlags
+func defaultParseFlags() (*bool, *bool) {
+	versionFlag := flag.Bool("version", false, "Show version information")
+	updateFlag := flag.Bool("update", false, "Check for updates and install if available")
+	flag.BoolVar(&opts.NoOwnerCheck, "no-owner-check", false, "Skip verifying ownership and permissions of target directories")
+	flag.Var(ownerUIDFlag{&opts.OwnerUID}, "owner-uid", "Expected owner uid of target directories (default: current user)")
+	flag.StringVar(&opts.Repo, "repo", "", "GitHub repository (owner/name) to update from")
+	flag.StringVar(&opts.ReleaseFile, "release-file", "", "Read the latest release from this JSON file instead of the GitHub API (assets may use file:// URLs)")
+	flag.StringVar(&opts.AssetRegex, "asset-regex", "", "Update from the one release asset whose name matches this regular expression, instead of guessing by platform")
+	flag.StringVar(&opts.TargetOS, "target-os", "", "Select the update asset for this operating system instead of the running one (requires -download-to)")
+	flag.StringVar(&opts.TargetArch, "target-arch", "", "Select the update asset for this architecture instead of the running one (requires -download-to)")
+	flag.StringVar(&opts.DownloadTo, "download-to", "", "Save the selected update asset to this path instead of replacing the running executable")
+	flag.StringVar(&opts.APIBase, "api-base", "", "Base URL of the GitHub API (default: "+defaultAPIBase+")")
+	flag.BoolVar(&opts.DryRunProbe, "dry-run-probe", false, "With -dry-run, create and remove a throwaway link next to each target to confirm it would succeed")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "Show what would be done without making any changes")
+	flag.BoolVar(&opts.Mkdir, "mkdir", false, "Create missing target directories")
+	flag.StringVar(&opts.DefaultPerm, "default-perm", "", "Permissions (octal) applied to the file behind each link whose manifest sets neither perm nor default_perm")
+	flag.StringVar(&opts.LinkMode, "link-mode", "symlink", "How to link targets: symlink, or auto to choose per source with -link-mode-rule")
+	flag.Var((*stringList)(&opts.LinkModeRules), "link-mode-rule", "With -link-mode auto, link sources matching key with mode, as key=mode; key is dir, socket, an extension such as .pem, or *; mode is symlink, copy, skip, or junction for dir (repeatable, default: dir=symlink, or dir=junction on Windows, socket=skip .sock=skip *=symlink)")
+	flag.StringVar(&opts.LinkPrefix, "link-prefix", "", "Prepend this to the file name of links whose target is a directory (a path ending in '/'), for manifests without link_prefix")
+	flag.StringVar(&opts.DirPerm, "dir-perm", "0755", "Permissions (octal) for directories created by -mkdir")
+	flag.BoolVar(&opts.ConfirmDestructive, "confirm-destructive", false, "Ask before a run that would overwrite files or replace existing links")
+	flag.BoolVar(&opts.Strict, "strict", false, "Exit with a nonzero status if any target fails")
+	flag.BoolVar(&opts.ErrorJSON, "error-json", false, "On failure, end stderr with a JSON object giving the exit code, category, message and path")
+	flag.BoolVar(&opts.Ansible, "ansible", false, "Print the result as Ansible-compatible JSON")
+	flag.BoolVar(&opts.ResolveSource, "resolve-source", false, "Resolve symlinked sources so targets point at the real file")
+	flag.Var((*stringList)(&opts.ScanKeywords), "scan-keyword", "Scan for directories whose name contains this word, case-insensitively (repeatable, default: secret)")
+	flag.IntVar(&opts.WarnThreshold, "warn-threshold", 100, "Warn when the scan finds more than this many secret directories (0 disables); under -strict, confirm or abort")
+	flag.BoolVar(&opts.SkipHidden, "skip-hidden", false, "Do not scan hidden directories (names starting with '.')")
+	flag.IntVar(&opts.MaxConnections, "max-connections", defaultMaxConnections, "Most concurrent connections to GitHub while updating (0 for no limit)")
+	flag.DurationVar(&opts.IdleTimeout, "idle-timeout", 0, "Abort an update request only after this long without receiving data, instead of after 30s in total (0 keeps the total timeout)")
+	flag.BoolVar(&opts.HTTPTrace, "http-trace", false, "Log every update request and response (method, URL, status, selected headers) to stderr, with credentials and query strings redacted")
+	flag.BoolVar(&opts.UpdateBackground, "update-background", false, "Download and stage an update to be installed on the next start")
+	flag.Var((*commaList)(&opts.Tags), "tags", "Only process targets carrying one of these comma-separated tags")
+	flag.Var((*commaList)(&opts.ExcludeTags), "exclude-tags", "Skip targets carrying any of these comma-separated tags")
+	flag.BoolVar(&opts.RequireTags, "require-tags", false, "Skip targets without tags when -tags is given")
+	flag.Var((*stringList)(&opts.SkipTargets), "skip-target", "Never create this target path (repeatable)")
+	flag.Var((*stringList)(&opts.SkipTargetGlobs), "skip-target-glob", "Never create target paths matching this glob (repeatable)")
+	flag.StringVar(&opts.ScanCache, "scan-cache", "", "Cache the secret directory scan in this file and reuse it while the tree is unchanged")
+	flag.BoolVar(&opts.HashVerify, "hash-verify", false, "Record source hashes and warn when a linked source changes")
+	flag.BoolVar(&opts.RelinkOnChange, "relink-on-change", false, "Recreate links whose source changed (requires -hash-verify)")
+	flag.BoolVar(&opts.NoChdir, "no-chdir", false, "Scan the current directory instead of the executable directory")
+	flag.StringVar(&opts.Root, "root", "", "Scan this directory instead of the executable directory; relative paths such as -state-file are then relative to it")
+	flag.BoolVar(&opts.ConfirmBroadScan, "confirm-broad-scan", false, "Scan a home directory, filesystem root or drive root without asking for confirmation")
+	flag.StringVar(&opts.StateFile, "state-file", defaultStateFile, "State file, relative to the executable directory (or the current directory with -no-chdir)")
+	flag.BoolVar(&opts.CompressState, "compress-state", false, "Write the state file gzip-compressed (plain and compressed state files are both read)")
+	flag.StringVar(&opts.Only, "only", "", "Process only this secret directory instead of scanning")
+	flag.BoolVar(&opts.AllowDowngrade, "allow-downgrade", false, "Allow -update to install a release published before the installed one")
+	flag.BoolVar(&opts.AssumeYesForDowngrade, "assume-yes-for-downgrade", false, "Confirm installing an older release without prompting")
+	flag.BoolVar(&opts.SummaryOnly, "summary-only", false, "Print only the final summary instead of a line per target")
+	flag.BoolVar(&opts.CheckMountReady, "check-mount-ready", false, "Skip targets whose directory does not respond to a stat within -mount-ready-timeout, instead of hanging")
+	flag.DurationVar(&opts.MountReadyTimeout, "mount-ready-timeout", defaultMountReadyTimeout, "How long -check-mount-ready waits for a target directory to respond")
+	flag.DurationVar(&opts.WaitForTarget, "wait-for-target", 0, "Wait up to this long for a missing target directory to appear (e.g. 30s)")
+	flag.StringVar(&opts.PlanFile, "plan-file", "", "Save the resolved links to this JSON file for a later -apply-plan, without creating them")
+	flag.StringVar(&opts.ApplyPlan, "apply-plan", "", "Create exactly the links saved in this -plan-file, without reading the manifests")
+	flag.StringVar(&opts.DumpEffective, "dump-effective", "", "Write every source -> target link after all manifest transformations to this JSON file")
+	flag.BoolVar(&opts.ExplainConfig, "explain-config", false, "Print which manifest fields took their default values instead of creating links")
+	flag.BoolVar(&opts.ApplyThenVerify, "dry-run-apply-then-verify", false, "Dry-run first, then apply, then report any target whose link does not match the dry-run prediction")
+	flag.StringVar(&opts.Init, "init", "", "Write a template manifest next to this source file and exit")
+	flag.BoolVar(&opts.Force, "force", false, "Let -init overwrite an existing manifest")
+	flag.DurationVar(&opts.ReconcileInterval, "reconcile-interval", 0, "After linking, keep running and re-apply every manifest at this interval until interrupted (0 runs once)")
+	flag.StringVar(&opts.Checkpoint, "checkpoint", "", "Record each applied target in this file as the run goes, so an interrupted run can be continued with -resume")
+	flag.BoolVar(&opts.Resume, "resume", false, "Skip targets that -checkpoint recorded as applied by an interrupted run")
+	flag.BoolVar(&opts.Health, "health", false, "Check that every managed link exists and resolves to its source, and exit 1 if any does not")
+	flag.BoolVar(&opts.EnforcePerms, "enforce-perms", false, "Re-apply source_perm and perm to managed sources and targets whose permissions drifted, instead of creating links")
+	flag.BoolVar(&opts.AuditOrphans, "audit-orphans", false, "Report symlinks in target directories that point into a managed source but are not declared by any manifest, and exit 1 if any are found")
+	flag.BoolVar(&opts.Graph, "graph", false, "Print the planned symlinks as a Graphviz DOT graph instead of creating them")
+	flag.BoolVar(&opts.AllowContainerUpdate, "allow-container-update", false, "Allow -update inside a container")
+	flag.IntVar(&opts.JobsPerMount, "jobs-per-mount", 1, "Link up to this many targets of a manifest at once on each filesystem")
+	flag.BoolVar(&opts.UnorderedOutput, "unordered-output", false, "With -jobs-per-mount, print each line as soon as it is written instead of keeping the output of each target together in manifest order")
+	flag.BoolVar(&opts.CopyFallback, "copy-fallback", false, "Copy the source to the target when a symlink cannot be created; identical copies are left untouched")
+	flag.IntVar(&opts.LinkRetries, "link-retries", 0, "Retry creating a link this many times on transient errors (EAGAIN, EBUSY)")
+	flag.StringVar(&opts.K8sSecretDir, "k8s-secret-dir", "", "Kubernetes secret volume whose keys are used as the sources of manifests with the same name")
+	flag.StringVar(&opts.SourceRoot, "source-root", "", "Directory that relative source paths resolve against (default: the executable directory)")
+	flag.StringVar(&opts.SourceAllowRoot, "source-allow-root", "", "Refuse sources that are not under this directory once symlinks and '..' are resolved")
+	flag.StringVar(&opts.TargetRoot, "target-root", "", "Directory that relative target paths resolve against")
+	flag.Var((*stringList)(&opts.ManifestGlobs), "manifest-glob", "Treat files matching this pattern as manifests; '*' is the source name (repeatable, default: *.symlink.json, *.symlink.json5)")
+	flag.StringVar(&opts.WarningsTo, "warnings-to", "stderr", "Where to write warnings: stdout, stderr, or a file path to append to")
+	flag.BoolVar(&opts.RequireNonemptySource, "require-nonempty-source", false, "Treat an empty source file as an error and do not link it")
+	flag.BoolVar(&opts.CheckOpenFiles, "check-open-files", false, "Skip replacing an existing target that a running process has open (Linux, via /proc)")
+	flag.BoolVar(&opts.CheckFilePerms, "check-file-perms", false, "Refuse manifests and sources that the group or others can write (ignored on Windows)")
+	flag.StringVar(&opts.ConfigArchive, "config-archive", "", "Process the sources and manifests in this .tar.gz instead of scanning")
+	flag.StringVar(&opts.ConfigArchiveDir, "config-archive-dir", defaultArchiveDir, "Directory the -config-archive is extracted into and its links point into, replaced on each run; relative to the executable directory (or the current directory with -no-chdir)")
+	flag.StringVar(&opts.Vars, "vars", "", "JSON file of key/value pairs substituted for ${key} in target paths and descriptions")
+	flag.BoolVar(&opts.AllowUndefinedVars, "allow-undefined-vars", false, "Warn about undefined ${key} placeholders instead of failing the target")
+	flag.StringVar(&opts.ReportBase, "report-base", "", "Write source and target paths in -junit, -ansible and -dump-effective output relative to this directory when they are inside it")
+	flag.StringVar(&opts.JUnit, "junit", "", "Write a JUnit XML report with a test case for each target to this file")
+	flag.StringVar(&opts.SummaryJSONFile, "summary-json-file", "", "Write the aggregate counts and overall status of the run to this JSON file")
+	flag.StringVar(&opts.DiffFormat, "diff-format", "", "With -dry-run, print planned changes in this format instead (supported: unified)")
+	flag.BoolVar(&opts.PrintDownloadURL, "print-download-url", false, "Print the URL of the update asset (and its checksum) instead of downloading it")
+	flag.BoolVar(&opts.Thaw, "thaw", false, "Process secret directories even if they contain a "+frozenMarker+" marker")
+	flag.Var(verbosityFlag{&opts.Verbosity, 1}, "v", "Verbose output: skipped files (repeatable)")
+	flag.Var(verbosityFlag{&opts.Verbosity, 2}, "vv", "More verbose output: also resolved paths")
+	flag.Var(verbosityFlag{&opts.Verbosity, 3}, "vvv", "Most verbose output: also internal decisions such as readlink results and retries")
+	flag.Parse()
+	return versionFlag, updateFlag
+}
+
+func init() {
+	parseFlags = defaultParseFlags
+}
+
+// validateOptions checks option values that cannot be validated by the flag package
+func validateOptions() error {
+	if _, err := dirMode(); err != nil {
+		return fmt.Errorf("invalid -dir-perm: %w", err)
+	}
+	if opts.DefaultPerm != "" {
+		if _, err := parsePerm(opts.DefaultPerm); err != nil {
+			return fmt.Errorf("invalid -default-perm: %w", err)
+		}
+	}
+	if err := validateLinkMode(); err != nil {
+		return err
+	}
+	if opts.AssetRegex != "" {
+		if _, err := regexp.Compile(opts.AssetRegex); err != nil {
+			return fmt.Errorf("invalid -asset-regex: %w", err)
+		}
+	}
+	if (opts.TargetOS != "" || opts.TargetArch != "") && opts.DownloadTo == "" {
+		return fmt.Errorf("-target-os and -target-arch require -download-to")
+	}
+	if opts.Root != "" && opts.NoChdir {
+		return fmt.Errorf("-root cannot be combined with -no-chdir")
+	}
+	if opts.RelinkOnChange && !opts.HashVerify {
+		return fmt.Errorf("-relink-on-change requires -hash-verify")
+	}
+	for _, pattern := range opts.ManifestGlobs {
+		if err := validateManifestGlob(pattern); err != nil {
+			return fmt.Errorf("invalid -manifest-glob: %w", err)
+		}
+	}
+	if err := validateDiffFormat(); err != nil {
+		return err
+	}
+	if err := validateDryRunProbe(); err != nil {
+		return err
+	}
+	if opts.ApplyThenVerify && opts.DryRun {
+		return fmt.Errorf("-dry-run-apply-then-verify cannot be combined with -dry-run")
+	}
+	if opts.Resume && opts.Checkpoint == "" {
+		return fmt.Errorf("-resume requires -checkpoint")
+	}
+	if opts.PlanFile != "" && opts.ApplyPlan != "" {
+		return fmt.Errorf("-plan-file cannot be combined with -apply-plan")
+	}
+	return nil
+}
+
+// dirMode returns the permissions used for directories created by -mkdir
+func dirMode() (os.FileMode, error) {
+	if opts.DirPerm == "" {
+		return 0755, nil
+	}
+	return parsePerm(opts.DirPerm)
+}
+
+func main() {
+	// Parse command line flags
+	versionFlag, updateFlag := parseFlags()
+
+	if err := validateOptions(); err != nil {
+		exitFunc(fatal(errorUsage, "Error", err))
+		return
+	}
+
+	// Opened before changing to the executable directory, so a relative
+	// -warnings-to path is relative to where the command was run
+	if err := openWarningsFile(); err != nil {
+		exitFunc(fatal(errorFilesystem, "Error", err))
+		return
+	}
+	defer closeWarningsFile()
+	
+	if err := loadVars(opts.Vars); err != nil {
+		exitFunc(fatal(errorConfig, "Error", err))
+		return
+	}
+
+	// Install an update staged by a previous -update-background run
+	if err := applyStagedUpdate(); err != nil {
+		warnf("Warning: %v\n", err)
+	}
+
+	// Handle version flag
+	if *versionFlag {
+		fmt.Printf("secret_manager version %s (commit: %s, built: %s)\n", version, commit, date)
+		exitFunc(0)
+	}
+
+	// Relative to where the command was run, so it runs before the chdir
+	if opts.Init != "" {
+		if err := initManifest(opts.Init); err != nil {
+			exitFunc(fatal(errorConfig, "Error", err))
+		}
+		return
+	}
+
+	// Handle update flag
+	if *updateFlag || opts.UpdateBackground || opts.PrintDownloadURL {
+		if err := checkAndUpdateFunc(); err != nil {
+			exitFunc(fatal(errorNetwork, "Error checking for updates", err))
+		}
+		exitFunc(0)
+	}
+
+	// In Ansible and graph modes stdout is reserved for the result
+	stdout := os.Stdout
+	if opts.Ansible || opts.Graph {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+	runSummary = &RunSummary{}
+
+	// -only, -config-archive and the report files are relative to where the
+	// command was run, not the executable
+	only := opts.Only
+	if only != "" {
+		if abs, err := filepath.Abs(only); err == nil {
+			only = abs
+		}
+	}
+	archive := opts.ConfigArchive
+	if archive != "" {
+		if abs, err := filepath.Abs(archive); err == nil {
+			archive = abs
+		}
+	}
+	for _, path := range []*string{&opts.SummaryJSONFile, &opts.JUnit, &opts.SourceAllowRoot, &opts.K8sSecretDir, &opts.DumpEffective, &opts.PlanFile, &opts.ApplyPlan, &opts.ReportBase, &opts.Checkpoint, &opts.DownloadTo} {
+		if *path != "" {
+			if abs, err := filepath.Abs(*path); err == nil {
+				*path = abs
+			}
+		}
+	}
+	
+	// Scan from the directory where the executable is located, unless
+	// -root names the directory or -no-chdir asks to scan the working
+	// directory instead
+	if opts.Root != "" {
+		if err := os.Chdir(opts.Root); err != nil {
+			exitFunc(fatal(errorFilesystem, "Error changing directory", err))
+			return
+		}
+	} else if !opts.NoChdir {
+		exeDir, err := executableDir()
+		if err != nil {
+			exitFunc(fatal(errorFilesystem, "Error getting executable directory", err))
+			return
+		}
+		
+		// Change to executable directory
+		err = os.Chdir(exeDir)
+		if err != nil {
+			exitFunc(fatal(errorFilesystem, "Error changing directory", err))
+			return
+		}
+	}
+	
+	if err := loadRunInputs(); err != nil {
+		exitFunc(fatal(errorConfig, "Error", err))
+		return
+	}
+	
+	if opts.ApplyPlan != "" {
+		if err := applyPlanFile(opts.ApplyPlan); err != nil {
+			exitFunc(fatal(errorConfig, "Error", err))
+			return
+		}
+		fmt.Println("Symlink creation completed successfully!")
+		fmt.Printf("Summary: %s\n", runSummary.message())
+		if code := finishRun(stdout); code != 0 {
+			exitFunc(code)
+		}
+		return
+	}
+	
+	// A scan root that was not chosen with -root may be a home directory
+	// or filesystem root that the executable was merely started from
+	if archive == "" && only == "" && opts.Root == "" {
+		if err := confirmBroadScan(os.Stdout); err != nil {
+			exitFunc(fatal(errorAborted, "Error", err))
+			return
+		}
+	}
+	
+	// Find all directories containing "secret" in their name
+	secretDirs, cleanup, err := runSecretDirs(only, archive)
+	if err != nil {
+		if archive != "" || only != "" {
+			exitFunc(fatal(errorConfig, "Error", err))
+		} else {
+			exitFunc(fatal(errorFilesystem, "Error finding secret directories", err))
+		}
+		return
+	}
+	defer cleanup()
+	if archive == "" && only == "" {
+		if err := confirmScanBreadth(os.Stdout, len(secretDirs)); err != nil {
+			exitFunc(fatal(errorAborted, "Error", err))
+			return
+		}
+	}
+	
+	if opts.DumpEffective != "" {
+		if err := writeEffectiveManifest(opts.DumpEffective, secretDirs, true); err != nil {
+			warnf("Warning: failed to write effective manifest: %v\n", err)
+		}
+	}
+	
+	if opts.PlanFile != "" {
+		if err := writeEffectiveManifest(opts.PlanFile, secretDirs, false); err != nil {
+			exitFunc(fatal(errorFilesystem, "Error writing plan", err))
+			return
+		}
+		fmt.Printf("Plan written to %s; apply it with -apply-plan\n", opts.PlanFile)
+		return
+	}
+	
+	if opts.Graph {
+		if err := writeGraph(stdout, planGraph(secretDirs)); err != nil {
+			exitFunc(fatal(errorFilesystem, "Error writing graph", err))
+		}
+		return
+	}
+	
+	if opts.ExplainConfig {
+		writeConfigExplanation(os.Stdout, secretDirs)
+		return
+	}
+	
+	if opts.Health {
+		if !writeHealthReport(os.Stdout, planGraph(secretDirs)) {
+			exitFunc(1)
+		}
+		return
+	}
+	
+	if opts.EnforcePerms {
+		var links []effectiveLink
+		quietly(func() { links = planLinks(secretDirs) })
+		if !writePermEnforcement(os.Stdout, links) {
+			exitFunc(1)
+		}
+		return
+	}
+	
+	if opts.AuditOrphans {
+		var links []effectiveLink
+		quietly(func() { links = planLinks(secretDirs) })
+		if !writeOrphanAudit(os.Stdout, secretDirs, links) {
+			exitFunc(1)
+		}
+		return
+	}
+	
+	if len(secretDirs) == 0 {
+		fmt.Printf("No directories containing '%s' found\n", strings.Join(scanKeywords(), "' or '"))
+		exitFunc(finishRun(stdout))
+	}
+	
+	// The same pipeline as Apply, guards included
+	_, err = runLinks(secretDirs)
+	var stopped *runError
+	if errors.As(err, &stopped) {
+		exitFunc(fatal(stopped.category, stopped.prefix, stopped.err))
+		return
+	}
+	verified := err == nil
+	
+	if opts.ReconcileInterval > 0 {
+		reconcile(opts.ReconcileInterval, secretDirs, archive == "" && only == "")
+	}
+	
+	if code := finishRun(stdout); code != 0 {
+		exitFunc(code)
+	} else if !verified {
+		exitFunc(1)
+	}
+}
+
+// finishRun writes the end-of-run output and returns the process exit code
+func finishRun(stdout *os.File) int {
+	saveRunState()
+	
+	if opts.SummaryJSONFile != "" {
+		if err := writeSummaryJSONFile(opts.SummaryJSONFile, runSummary); err != nil {
+			warnf("Warning: failed to write summary file: %v\n", err)
+		}
+	}
+	
+	if opts.JUnit != "" {
+		if err := writeJUnitFile(opts.JUnit, runSummary); err != nil {
+			warnf("Warning: failed to write JUnit report: %v\n", err)
+		}
+	}
+	
+	if opts.Ansible {
+		if err := writeAnsibleResult(stdout, runSummary); err != nil {
+			return fatal(errorFilesystem, "Error writing Ansible result", err)
+		}
+		// Ansible reads the failed field instead of the exit code
+		return 0
+	}
+	
+	if opts.Strict && runSummary.failed() {
+		writeErrorJSON(1, errorTargets, runSummary.failure())
+		return 1
+	}
+	return 0
+}
+
+// defaultArchiveDir is resolved against the executable directory, like the
+// state file
+const defaultArchiveDir = "secret_manager.archive"
+
+// archiveMarker is written into each -config-archive extraction, so that a
+// directory is only ever replaced if an earlier run extracted it
+const archiveMarker = ".secret_manager-archive"
+
+// extractConfigArchive extracts a bundle of sources and manifests into dir to
+// be processed as a secret directory. The links point into dir, so it
+// outlives the run: the bundle is extracted beside it first and replaces the
+// previous extraction only once complete. A non-empty dir without the
+// archive marker is refused rather than deleted, as -config-archive-dir may
+// name any directory by mistake
+func extractConfigArchive(archivePath, dir string) error {
+	if err := checkArchiveDir(dir); err != nil {
+		return err
+	}
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return fmt.Errorf("failed to create config archive directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+"-*")
+	if err != nil {
+		return err
+	}
+	
+	if err := extractTarGzAll(archivePath, staging); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to extract config archive: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, archiveMarker), nil, 0600); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to mark config archive extraction: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to replace the previous config archive extraction: %w", err)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to replace the previous config archive extraction: %w", err)
+	}
+	
+	return nil
+}
+
+// checkArchiveDir reports whether dir may be replaced by a new extraction:
+// it does not exist, is empty, or holds the marker of an earlier one
+func checkArchiveDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("refusing to replace %s: %w", dir, err)
+	case len(entries) == 0:
+		return nil
+	}
+	if _, err := os.Lstat(filepath.Join(dir, archiveMarker)); err != nil {
+		return fmt.Errorf("refusing to replace %s: it has no %s, so -config-archive did not extract it", dir, archiveMarker)
+	}
+	return nil
+}
+
+// onlySecretDirectory validates the directory given with -only and returns it
+// as the single directory to process
+func onlySecretDirectory(dir string) ([]string, error) {
+	info, err := statFunc(dir)
+	if err != nil {
+		return nil, fmt.Errorf("-only directory %s does not exist", dir)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("-only path %s is not a directory", dir)
+	}
+	
+	files, err := readDirFunc(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read -only directory: %w", err)
+	}
+	for _, file := range files {
+		if _, ok := manifestSource(file.Name()); (ok || file.Name() == directoryManifest) && !file.IsDir() {
+			return []string{dir}, nil
+		}
+	}
+	
+	return nil, fmt.Errorf("-only directory %s contains no %s manifests", dir, strings.Join(append(manifestGlobs(), directoryManifest), " or "))
+}
+
+// defaultManifestGlobs are the manifest patterns used without -manifest-glob
+var defaultManifestGlobs = []string{"*.symlink.json", "*.symlink.json5"}
+
+// manifestGlobs returns the patterns of files treated as manifests
+func manifestGlobs() []string {
+	if len(opts.ManifestGlobs) > 0 {
+		return opts.ManifestGlobs
+	}
+	return defaultManifestGlobs
+}
+
+// validateManifestGlob checks that a manifest pattern is well formed. The
+// single '*' marks the part of the file name that names the source
+func validateManifestGlob(pattern string) error {
+	if strings.Count(pattern, "*") != 1 {
+		return fmt.Errorf("%q must contain exactly one '*' marking the source name", pattern)
+	}
+	if strings.ContainsAny(pattern, "[\\/") {
+		return fmt.Errorf("%q may only use '*' and '?' wildcards", pattern)
+	}
+	return nil
+}
+
+// manifestSource returns the source file name a manifest belongs to: the
+// part of the name matched by the '*' of the first matching pattern
+func manifestSource(name string) (string, bool) {
+	for _, pattern := range manifestGlobs() {
+		if ok, _ := filepath.Match(pattern, name); !ok {
+			continue
+		}
+		star := strings.Index(pattern, "*")
+		prefix, suffix := len(pattern[:star]), len(pattern[star+1:])
+		if source := name[prefix : len(name)-suffix]; source != "" {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// frozenMarker is the file that marks a secret directory as frozen. Links in
+// a frozen directory are left alone unless -thaw is given
+const frozenMarker = ".sm-frozen"
+
+func processSecretDirectory(secretDir string) error {
+	if !opts.Thaw {
+		if _, err := statFunc(filepath.Join(secretDir, frozenMarker)); err == nil {
+			fmt.Fprintf(outputWriter(), "%s is frozen, skipping (remove %s or use -thaw)\n", secretDir, frozenMarker)
+			return nil
+		}
+	}
+	
+	manifests, err := listManifests(secretDir)
+	if err != nil {
+		return err
+	}
+	
+	// The sources of a group are linked together once all are known
+	groups := make(map[string][]manifest)
+	var groupOrder []string
+	for _, m := range orderManifests(manifests) {
+		if m.group != "" {
+			if _, ok := groups[m.group]; !ok {
+				groupOrder = append(groupOrder, m.group)
+			}
+			groups[m.group] = append(groups[m.group], m)
+			continue
+		}
+		config, err := m.load()
+		if err == nil {
+			err = processConfig(m.sourcePath, m.configPath, config)
+		}
+		if err != nil {
+			runSummary.recordManifestError(m.configPath, err)
+		}
+	}
+	
+	for _, name := range groupOrder {
+		processLinkGroup(name, groups[name])
+	}
+	
+	return nil
+}
+
+// manifest is a symlink manifest and the source file it belongs to
+type manifest struct {
+	sourcePath string
+	configPath string
+
+	// config is already parsed for an entry of a directory manifest, where
+	// several sources share one file
+	config *SymlinkConfig
+
+	// group names the group of a directory manifest the source belongs to,
+	// whose sources are linked together or not at all
+	group string
+}
+
+// load returns the manifest's configuration, reading it from configPath
+// unless it came from a directory manifest
+func (m manifest) load() (SymlinkConfig, error) {
+	if m.config != nil {
+		return *m.config, nil
+	}
+	return loadSymlinkConfig(m.configPath)
+}
+
+// orderManifests returns manifests in the order they are processed: by
+// ascending priority, then by manifest file name, entries of a directory
+// manifest in declaration order. The sources of groups come last, as groups
+// are linked once every other manifest is done. A later manifest replaces
+// the links of an earlier one, so the highest priority wins an overlapping
+// target
+func orderManifests(manifests []manifest) []manifest {
+	type ranked struct {
+		manifest
+		priority int
+	}
+	ordered := make([]ranked, len(manifests))
+	for i, m := range manifests {
+		// Parsed once here; a manifest that fails to load reports its
+		// error when it is processed, as does one that -check-file-perms
+		// refuses, whose priority is not trusted
+		if opts.CheckFilePerms && checkFilePerms(m.configPath) != nil {
+			ordered[i].manifest = m
+			continue
+		}
+		if config, err := m.load(); err == nil {
+			m.config = &config
+			ordered[i].priority = config.Priority
+		}
+		ordered[i].manifest = m
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		if grouped := ordered[a].group != ""; grouped != (ordered[b].group != "") {
+			return !grouped
+		}
+		if ordered[a].priority != ordered[b].priority {
+			return ordered[a].priority < ordered[b].priority
+		}
+		return ordered[a].configPath < ordered[b].configPath
+	})
+
+	result := make([]manifest, len(ordered))
+	for i, r := range ordered {
+		result[i] = r.manifest
+	}
+	return result
+}
+
+// directoryManifest is the file name of a manifest that declares the links of
+// several sources in its secret directory at once
+const directoryManifest = "secret_manager.json"
+
+// directoryEntry is one source of a directory manifest, with the same fields
+// as a per-source manifest
+type directoryEntry struct {
+	Source string `json:"source"`
+	SymlinkConfig
+}
+
+// directoryManifestFile is the content of a directory manifest
+type directoryManifestFile struct {
+	Entries []directoryEntry `json:"entries"`
+	Groups  []linkGroup      `json:"groups,omitempty"`
+}
+
+// loadDirectoryManifest reads a directory manifest
+func loadDirectoryManifest(path string) (directoryManifestFile, error) {
+	var dm directoryManifestFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dm, fmt.Errorf("failed to read config file: %w", err)
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	
+	if err := json.Unmarshal(data, &dm); err != nil {
+		return dm, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return dm, nil
+}
+
+// newManifest builds the manifest of sourceFile in secretDir
+func newManifest(secretDir, sourceFile, configPath string) manifest {
+	m := manifest{
+		sourcePath: resolveAgainst(opts.SourceRoot, filepath.Join(secretDir, sourceFile)),
+		configPath: configPath,
+	}
+	if key, ok := k8sSourcePath(sourceFile); ok {
+		m.sourcePath = key
+	}
+	logf(verbosePaths, "Manifest %s: source %s\n", m.configPath, m.sourcePath)
+	return m
+}
+
+// listManifests returns the manifests in a secret directory
+func listManifests(secretDir string) ([]manifest, error) {
+	files, err := readDirFunc(secretDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret directory: %w", err)
+	}
+	
+	var manifests []manifest
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		
+		configPath := filepath.Join(secretDir, file.Name())
+		if file.Name() == directoryManifest {
+			dm, err := loadDirectoryManifest(configPath)
+			if err != nil {
+				runSummary.recordManifestError(configPath, err)
+				continue
+			}
+			for i := range dm.Entries {
+				if dm.Entries[i].Source == "" {
+					warnf("Warning: %s: entry %d has no source, skipping\n", configPath, i+1)
+					continue
+				}
+				m := newManifest(secretDir, dm.Entries[i].Source, configPath)
+				m.config = &dm.Entries[i].SymlinkConfig
+				manifests = append(manifests, m)
+			}
+			seen := make(map[string]bool)
+			for i, group := range dm.Groups {
+				name := groupName(group, i)
+				switch {
+				case group.TargetDir == "" || len(group.Sources) == 0:
+					runSummary.recordManifestError(fmt.Sprintf("group %s in %s", name, configPath), fmt.Errorf("a group needs sources and a target_dir"))
+					continue
+				case seen[name]:
+					runSummary.recordManifestError(fmt.Sprintf("group %s in %s", name, configPath), fmt.Errorf("another group has the same name"))
+					continue
+				}
+				seen[name] = true
+				for _, source := range group.Sources {
+					m := newManifest(secretDir, source, configPath)
+					m.config = group.config()
+					m.group = name
+					manifests = append(manifests, m)
+				}
+			}
+			continue
+		}
+		
+		sourceFile, ok := manifestSource(file.Name())
+		if !ok {
+			logf(verboseSkips, "Skipping %s: not a manifest\n", configPath)
+			continue
+		}
+		manifests = append(manifests, newManifest(secretDir, sourceFile, configPath))
+	}
+	
+	return manifests, nil
+}
+
+// resolveAgainst joins a relative path onto root. Absolute paths, and any
+// path when root is empty, are returned unchanged
+func resolveAgainst(root, path string) string {
+	if root == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// isDirTarget reports whether a target path names a directory to link into,
+// by ending in a path separator, rather than the link itself
+func isDirTarget(path string) bool {
+	return strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator))
+}
+
+// linkPrefix returns the prefix for link names in directory targets: the
+// manifest's link_prefix, else -link-prefix
+func linkPrefix(config SymlinkConfig) string {
+	if config.LinkPrefix != "" {
+		return config.LinkPrefix
+	}
+	return opts.LinkPrefix
+}
+
+// applyTargetPerm sets the permissions of the file a target resolves to, as
+// set by its perm field or the manifest and command line defaults
+func applyTargetPerm(target Target) error {
+	if target.Perm == "" {
+		return nil
+	}
+	mode, err := parsePerm(target.Perm)
+	if err != nil {
+		return fmt.Errorf("invalid perm: %w", err)
+	}
+	if err := chmodFunc(target.Path, mode); err != nil {
+		return fmt.Errorf("failed to set target permissions: %w", err)
+	}
+	target.out.logf(verboseDecisions, "Set permissions of %s to %s\n", target.Path, mode)
+	return nil
+}
+
+// targetPerm returns the permissions for target: its own perm, else the
+// manifest's default_perm, else -default-perm
+func targetPerm(config SymlinkConfig, target Target) string {
+	if target.Perm != "" {
+		return target.Perm
+	}
+	if config.DefaultPerm != "" {
+		return config.DefaultPerm
+	}
+	return opts.DefaultPerm
+}
+
+// verifyTargetReadable opens a target with verify_readable set, as the current
+// user, to catch permissions along the path that creating the link does not
+func verifyTargetReadable(target Target) error {
+	if !target.VerifyReadable {
+		return nil
+	}
+	f, err := openFunc(target.Path)
+	if err != nil {
+		return fmt.Errorf("target is not readable: %w", err)
+	}
+	f.Close()
+	target.out.logf(verboseDecisions, "Verified %s is readable\n", target.Path)
+	return nil
+}
+
+// utf8BOM is the byte order mark some editors write at the start of a file
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// loadSymlinkConfig reads and parses a manifest
+func loadSymlinkConfig(configPath string) (SymlinkConfig, error) {
+	var config SymlinkConfig
+	
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	
+	// Notepad on Windows may save a UTF-8 byte order mark
+	data = bytes.TrimPrefix(data, utf8BOM)
+	
+	if strings.HasSuffix(configPath, ".json5") {
+		data, err = json5ToJSON(data)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse JSON5: %w", err)
+		}
+	}
+	
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	
+	return config, nil
+}
+
+func processSymlinkConfig(sourcePath, configPath string) error {
+	config, err := loadSymlinkConfig(configPath)
+	if err != nil {
+		return err
+	}
+	return processConfig(sourcePath, configPath, config)
+}
+
+// processConfig links sourcePath to the targets of its manifest, already
+// parsed from configPath
+func processConfig(sourcePath, configPath string, config SymlinkConfig) error {
+	// Checked before anything in the manifest is acted on, its pre_hook
+	// included. A source the pre-hook puts in place is checked after it
+	sourceChecked := false
+	if opts.CheckFilePerms {
+		paths := []string{configPath}
+		if _, err := lstatFunc(sourcePath); err == nil {
+			paths = append(paths, sourcePath)
+			sourceChecked = true
+		}
+		if err := checkFilePerms(paths...); err != nil {
+			refuseFilePerms(sourcePath, config, err)
+			return nil
+		}
+	}
+	
+	// A refused source is neither prepared by the pre_hook nor given its
+	// source_perm; expandTargets reports it and fails its targets
+	if checkSourceAllowed(sourcePath) != nil {
+		expandTargets(sourcePath, config)
+		return nil
+	}
+	
+	// Fields added in a newer schema would otherwise be silently ignored
+	if config.SchemaVersion > supportedSchemaVersion {
+		err := fmt.Errorf("manifest requires schema version %d, but this secret_manager supports up to %d; update secret_manager",
+			config.SchemaVersion, supportedSchemaVersion)
+		if opts.Strict {
+			return err
+		}
+		warnTarget("Warning: %s: %v\n", configPath, err)
+	}
+	
+	var sourcePerm os.FileMode
+	var err error
+	if config.SourcePerm != "" {
+		sourcePerm, err = parsePerm(config.SourcePerm)
+		if err != nil {
+			return fmt.Errorf("invalid source_perm: %w", err)
+		}
+	}
+	
+	// The pre-hook may be what puts the source in place, so run it first
+	if config.PreHook != "" {
+		if err := runPreHook(config.PreHook, sourcePath); err != nil {
+			if opts.Strict {
+				return err
+			}
+			warnTarget("Warning: %v\n", err)
+		}
+	}
+	
+	info, err := os.Stat(sourcePath)
+	if os.IsNotExist(err) {
+		warnTarget("Warning: Source file %s does not exist, skipping\n", sourcePath)
+		return nil
+	}
+	
+	// An empty secret usually means the step generating it failed
+	if opts.RequireNonemptySource && err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		warnTarget("Error: Source file %s is empty, skipping\n", sourcePath)
+		for _, target := range expandTargets(sourcePath, config) {
+			runSummary.record(LinkResult{
+				Source:      sourcePath,
+				Target:      target.Path,
+				Description: target.Description,
+				Action:      actionFailed,
+				Message:     "source file is empty",
+				Optional:    target.Optional,
+			})
+		}
+		return nil
+	}
+	
+	if opts.CheckFilePerms && !sourceChecked && err == nil {
+		if err := checkFilePerms(sourcePath); err != nil {
+			refuseFilePerms(sourcePath, config, err)
+			return nil
+		}
+	}
+	
+	if config.Atomic && !opts.DryRun {
+		if err := linkTargetsAtomically(sourcePath, expandTargets(sourcePath, config)); err != nil {
+			warnTarget("Failed to link %s, no targets were changed: %v\n", sourcePath, err)
+		}
+	} else {
+		linkTargets(sourcePath, expandTargets(sourcePath, config))
+	}
+	
+	if config.SourcePerm != "" && !opts.DryRun {
+		if err := chmodFunc(sourcePath, sourcePerm); err != nil {
+			return fmt.Errorf("failed to set source permissions: %w", err)
+		}
+		printTarget("Set permissions of %s to %s\n", sourcePath, sourcePerm)
+	}
+	
+	return nil
+}
+
+// refuseFilePerms records every target of a manifest that -check-file-perms
+// refused as failed
+func refuseFilePerms(sourcePath string, config SymlinkConfig, err error) {
+	warnTarget("Error: %v, skipping\n", err)
+	for _, target := range expandTargets(sourcePath, config) {
+		runSummary.record(LinkResult{
+			Source:      sourcePath,
+			Target:      target.Path,
+			Description: target.Description,
+			Action:      actionFailed,
+			Message:     err.Error(),
+			Optional:    target.Optional,
+		})
+	}
+}
+
+// expandTargets applies manifest-level settings and runtime filters to each
+// target of the manifest for sourcePath, producing the targets that are actually linked
+func expandTargets(sourcePath string, config SymlinkConfig) []Target {
+	sourceErr := checkSourceAllowed(sourcePath)
+	if sourceErr != nil {
+		warnTarget("Error: %v\n", sourceErr)
+	}
+	
+	targets := make([]Target, 0, len(config.Targets))
+	for i, target := range config.Targets {
+		target.index = i + 1
+		if !matchesTags(target.Tags) {
+			logf(verboseSkips, "Skipping %s: tags do not match\n", target.Path)
+			continue
+		}
+		if err := substituteTargetVars(&target); err != nil {
+			warnTarget("Error: %s: %v\n", target.Path, err)
+			runSummary.record(LinkResult{
+				Source:      sourcePath,
+				Target:      target.Path,
+				Description: target.Description,
+				Action:      actionFailed,
+				Message:     err.Error(),
+				Optional:    target.Optional,
+			})
+			continue
+		}
+		target.Perm = targetPerm(config, target)
+		dirTarget := isDirTarget(target.Path)
+		if config.TargetPrefix != "" && !filepath.IsAbs(target.Path) {
+			target.Path = filepath.Join(config.TargetPrefix, target.Path)
+		}
+		target.Path = resolveAgainst(opts.TargetRoot, target.Path)
+		if dirTarget {
+			// The link is named after the source, so that several sources
+			// can share one directory
+			target.Path = filepath.Join(target.Path, linkPrefix(config)+filepath.Base(sourcePath))
+		}
+		logf(verbosePaths, "Resolved target %s\n", target.Path)
+		if sourceErr != nil {
+			runSummary.record(LinkResult{
+				Source:      sourcePath,
+				Target:      target.Path,
+				Description: target.Description,
+				Action:      actionFailed,
+				Message:     sourceErr.Error(),
+				Optional:    target.Optional,
+			})
+			continue
+		}
+		if isSkippedTarget(target.Path) {
+			printTarget("Skipping %s: skipped by flag\n", target.Path)
+			runSummary.record(LinkResult{
+				Source:      sourcePath,
+				Target:      target.Path,
+				Description: target.Description,
+				Action:      actionSkipped,
+				Message:     "skipped by flag",
+			})
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// sourceAllowRoots returns the directories sources must lie under:
+// -source-allow-root, else the scan root along with the -source-root and
+// -k8s-secret-dir that sources are explicitly taken from. With none, sources
+// are not restricted
+func sourceAllowRoots() []string {
+	if opts.SourceAllowRoot != "" {
+		return []string{opts.SourceAllowRoot}
+	}
+	if opts.scanRoot == "" {
+		return nil
+	}
+	roots := []string{opts.scanRoot}
+	for _, root := range []string{opts.SourceRoot, opts.K8sSecretDir} {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// checkSourceAllowed refuses a source outside -source-allow-root, or by
+// default the scan root, comparing canonical paths so that neither '..' nor
+// a symlinked source can escape it
+func checkSourceAllowed(sourcePath string) error {
+	roots := sourceAllowRoots()
+	if len(roots) == 0 {
+		return nil
+	}
+	
+	source := canonicalPath(sourcePath)
+	if resolved, err := evalSymlinks(source); err == nil {
+		source = resolved
+	}
+	for _, root := range roots {
+		root = canonicalPath(root)
+		if resolved, err := evalSymlinks(root); err == nil {
+			root = resolved
+		}
+		rel, err := filepath.Rel(root, source)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	
+	if opts.SourceAllowRoot != "" {
+		return fmt.Errorf("source %s is outside -source-allow-root %s", sourcePath, opts.SourceAllowRoot)
+	}
+	return fmt.Errorf("source %s is outside the scanned directory %s; allow it with -source-allow-root", sourcePath, opts.scanRoot)
+}
+
+// isSkippedTarget reports whether a target path is excluded by -skip-target
+// or -skip-target-glob. Paths are compared in absolute, cleaned form
+func isSkippedTarget(path string) bool {
+	canonical := canonicalPath(path)
+	for _, skip := range opts.SkipTargets {
+		if canonicalPath(skip) == canonical {
+			return true
+		}
+	}
+	for _, pattern := range opts.SkipTargetGlobs {
+		if matched, _ := filepath.Match(canonicalPath(pattern), canonical); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath returns the absolute, cleaned form of path
+func canonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// matchesTags reports whether a target with the given tags passes the
+// -tags, -exclude-tags and -require-tags filters
+func matchesTags(tags []string) bool {
+	for _, tag := range tags {
+		if containsString(opts.ExcludeTags, tag) {
+			return false
+		}
+	}
+	
+	if len(opts.Tags) == 0 {
+		return true
+	}
+	if len(tags) == 0 {
+		return !opts.RequireTags
+	}
+	for _, tag := range tags {
+		if containsString(opts.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePerm parses an octal permission string such as "0600"
+func parsePerm(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q is not a valid octal permission", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Functions that can be mocked in tests
+var (
+	symlinkFunc  = os.Symlink
+	removeFunc   = os.Remove
+	lstatFunc    = os.Lstat
+	readDirFunc  = os.ReadDir
+	statFunc     = os.Stat
+	chmodFunc    = os.Chmod
+	mkdirAllFunc = os.MkdirAll
+	evalSymlinks = filepath.EvalSymlinks
+	readlinkFunc = os.Readlink
+	sleepFunc    = time.Sleep
+	renameFunc   = os.Rename
+	openFunc     = os.Open
+)
+
+// linkRetryBackoff is the delay before the first retry of a transient link
+// failure; it doubles with each further attempt
+const linkRetryBackoff = 100 * time.Millisecond
+
+// replaceLink removes whatever is at targetPath and links it to sourcePath,
+// reporting whether an existing entry was removed
+func replaceLink(sourcePath, targetPath string) (bool, error) {
+	replaced := false
+	if _, err := lstatFunc(targetPath); err == nil {
+		logf(verboseDecisions, "Removing existing entry at %s\n", targetPath)
+		err = removeFunc(targetPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to remove existing symlink: %w", err)
+		}
+		replaced = true
+	}
+	
+	err := symlinkFunc(sourcePath, targetPath)
+	if err != nil {
+		return replaced, fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return replaced, nil
+}
+
+// isTransientLinkError reports whether a link failure may succeed on retry,
+// as on network filesystems that are briefly busy
+func isTransientLinkError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY)
+}
+
+// targetPollInterval is how often -wait-for-target checks for the directory
+const targetPollInterval = 250 * time.Millisecond
+
+// waitForDir polls until dir exists or timeout elapses, reporting whether it
+// appeared. Mount points in init containers may show up shortly after start
+func waitForDir(dir string, timeout time.Duration) bool {
+	deadline := nowFunc().Add(timeout)
+	for {
+		if _, err := statFunc(dir); err == nil {
+			return true
+		}
+		if !nowFunc().Before(deadline) {
+			return false
+		}
+		sleepFunc(targetPollInterval)
+	}
+}
+
+// createTargetDir creates a missing target directory with the -dir-perm mode
+func createTargetDir(out *outputUnit, dir string) error {
+	mode, err := dirMode()
+	if err != nil {
+		return fmt.Errorf("invalid -dir-perm: %w", err)
+	}
+	
+	if opts.DryRun {
+		out.printTarget("Would create directory: %s (%s)\n", dir, mode)
+		return nil
+	}
+	
+	if err := mkdirAllFunc(dir, mode); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+	// MkdirAll is subject to the umask, so apply the requested mode explicitly
+	if err := chmodFunc(dir, mode); err != nil {
+		return fmt.Errorf("failed to set target directory permissions: %w", err)
+	}
+	
+	out.printTarget("Created directory: %s (%s)\n", dir, mode)
+	return nil
+}
+
+// verifySymlink reads back a newly created link and confirms it points at the
+// intended source, catching filesystems that silently create something else
+func verifySymlink(sourcePath, targetPath string) error {
+	got, err := readlinkFunc(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to verify symlink: %w", err)
+	}
+	logf(verboseDecisions, "Readlink %s: %s\n", targetPath, got)
+	
+	if filepath.Clean(got) == filepath.Clean(sourcePath) {
+		return nil
+	}
+	
+	// A relative link is resolved against the directory containing it
+	gotAbs := got
+	if !filepath.IsAbs(gotAbs) {
+		gotAbs = filepath.Join(filepath.Dir(targetPath), gotAbs)
+	}
+	wantAbs, err := filepath.Abs(sourcePath)
+	if err == nil && filepath.Clean(gotAbs) == wantAbs {
+		return nil
+	}
+	
+	return fmt.Errorf("symlink verification failed: %s points to %s, expected %s", targetPath, got, sourcePath)
+}
+
+// createSymlink links target to sourcePath and records the outcome in the run summary
+func createSymlink(sourcePath string, target Target) error {
+	if skipCheckpointed(sourcePath, target) {
+		return nil
+	}
+	
+	action, message, err := linkTarget(sourcePath, target)
+	if err != nil {
+		action = actionFailed
+		message = err.Error()
+	}
+	switch action {
+	case actionCreated, actionReplaced, actionUnchanged:
+		runCheckpoint.markDone(sourcePath, target.Path)
+	}
+	
+	runSummary.record(LinkResult{
+		Source:      sourcePath,
+		Target:      target.Path,
+		Description: target.Description,
+		Action:      action,
+		Message:     message,
+		Optional:    target.Optional,
+	})
+	
+	return err
+}
+
+// reportLinkError warns about a target that could not be linked, noting
+// when the target is optional and so does not fail the run
+func reportLinkError(target Target, err error) {
+	if target.Optional {
+		target.out.warnTarget("Warning: failed to create optional symlink for %s: %v\n", target.Path, err)
+		return
+	}
+	target.out.warnTarget("Failed to create symlink for %s: %v\n", target.Path, err)
+}
+
+// linkTarget performs the link and returns the action taken with an optional detail message
+func linkTarget(sourcePath string, target Target) (string, string, error) {
+	targetPath := target.Path
+	
+	mode := targetLinkMode(sourcePath)
+	if mode == linkModeSkip {
+		target.out.printTarget("Skipping %s: %s is not linked by the link mode rules\n", targetPath, sourcePath)
+		return actionSkipped, "skipped by link mode rule", nil
+	}
+	
+	// Link to the real file instead of building a chain of symlinks
+	if opts.ResolveSource || target.ResolveSource {
+		resolved, err := evalSymlinks(sourcePath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to resolve source: %w", err)
+		}
+		target.out.logf(verbosePaths, "Resolved source %s to %s\n", sourcePath, resolved)
+		sourcePath = resolved
+	}
+	
+	// Check if target directory exists
+	targetDir := filepath.Dir(targetPath)
+	if !checkMountReady(targetDir) {
+		return actionSkipped, "mount not ready", nil
+	}
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) && opts.WaitForTarget > 0 && !opts.DryRun {
+		target.out.printTarget("Waiting up to %s for target directory: %s\n", opts.WaitForTarget, targetDir)
+		waitForDir(targetDir, opts.WaitForTarget)
+	}
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		if !opts.Mkdir {
+			target.out.warnTarget("Error: Target directory does not exist: %s\n", targetDir)
+			// Only an optional target may be left out; a required one fails
+			if target.Optional {
+				return actionSkipped, "target directory does not exist", nil
+			}
+			return actionFailed, "target directory does not exist", nil // Continue with next target
+		}
+		if err := createTargetDir(target.out, targetDir); err != nil {
+			return "", "", err
+		}
+		if opts.DryRun {
+			printPlannedLink(sourcePath, target)
+			return actionPlanned, "", nil
+		}
+	}
+	
+	if err := checkDirOwner(targetDir); err != nil {
+		return "", "", err
+	}
+	
+	var hash string
+	if opts.HashVerify {
+		h, upToDate, err := checkSourceHash(sourcePath, targetPath)
+		if err != nil {
+			return "", "", err
+		}
+		hash = h
+		if upToDate {
+			target.out.printTarget("Symlink up to date: %s -> %s (%s)\n", targetPath, sourcePath, target.Description)
+			return actionSkipped, "link is up to date", nil
+		}
+	}
+	
+	if opts.DryRun {
+		printPlannedLink(sourcePath, target)
+		if opts.DryRunProbe {
+			if err := probeLink(sourcePath, targetPath); err != nil {
+				target.out.warnTarget("Probe: %s would fail (%v)\n", targetPath, err)
+				return actionFailed, "probe failed: " + err.Error(), nil
+			}
+			target.out.printTarget("Probe: %s would succeed\n", targetPath)
+		}
+		return actionPlanned, "", nil
+	}
+	
+	// A copy left by an earlier fallback is replaced by a link once links
+	// work; while they still fail it is only rewritten when it differs, so
+	// that file watchers on the target are not triggered needlessly
+	if fallbackCopyUpToDate(sourcePath, target) {
+		target.out.printTarget("Copy up to date: %s (%s)\n", targetPath, target.Description)
+		return actionUnchanged, "content unchanged", nil
+	}
+	
+	if opts.CheckOpenFiles {
+		if pids := targetOpenBy(target); len(pids) > 0 {
+			target.out.warnTarget("Warning: %s is open by process %s, skipping\n", targetPath, formatPIDs(pids))
+			return actionSkipped, "target is open by process " + formatPIDs(pids), nil
+		}
+	}
+	
+	if mode == linkModeCopy {
+		return copyTarget(sourcePath, target)
+	}
+	if mode == linkModeJunction {
+		return junctionTarget(sourcePath, target)
+	}
+	
+	retries := opts.LinkRetries
+	if target.Retries > 0 {
+		retries = target.Retries
+	}
+	
+	action := actionCreated
+	for attempt := 0; ; attempt++ {
+		replaced, err := replaceLink(sourcePath, targetPath)
+		if replaced {
+			action = actionReplaced
+		}
+		if err == nil {
+			break
+		}
+		target.out.logf(verboseDecisions, "Link attempt %d for %s failed: %v\n", attempt+1, targetPath, err)
+		if opts.CopyFallback && !isTransientLinkError(err) {
+			target.out.logf(verboseDecisions, "Falling back to copying %s\n", sourcePath)
+			if err := copySource(sourcePath, targetPath); err != nil {
+				return "", "", err
+			}
+			target.out.printTarget("Copied: %s -> %s (%s)\n", sourcePath, targetPath, target.Description)
+			if err := applyTargetPerm(target); err != nil {
+				return "", "", err
+			}
+			if err := applyTargetOwner(target); err != nil {
+				return "", "", err
+			}
+			if err := verifyTargetReadable(target); err != nil {
+				return "", "", err
+			}
+			return action, "copied", nil
+		}
+		if attempt >= retries || !isTransientLinkError(err) {
+			return "", "", err
+		}
+		target.out.printTarget("Retrying %s after transient error: %v\n", targetPath, err)
+		sleepFunc(linkRetryBackoff << attempt)
+	}
+	
+	if err := verifySymlink(sourcePath, targetPath); err != nil {
+		return "", "", err
+	}
+	
+	target.out.printTarget("Created symlink: %s -> %s (%s)\n", targetPath, sourcePath, target.Description)
+	
+	if err := applyTargetPerm(target); err != nil {
+		return "", "", err
+	}
+	if err := applyTargetOwner(target); err != nil {
+		return "", "", err
+	}
+	if err := verifyTargetReadable(target); err != nil {
+		return "", "", err
+	}
+	
+	if opts.HashVerify {
+		runState.setHash(targetPath, hash)
+	}
+	
+	return action, "", nil
+}
\ No newline at end of file
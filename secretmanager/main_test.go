@@ -0,0 +1,3116 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// MAIN FUNCTIONALITY TESTS
+// =============================================================================
+// This file contains all tests related to:
+// - Main application execution flow
+// - Symlink creation and management
+// - Directory processing
+// - File operations
+// - Version and flag handling
+// =============================================================================
+
+// TestMain sets up mocking for all tests
+func TestMain(m *testing.M) {
+	// Set up default mock for symlink function to avoid permission issues
+	originalSymlink := symlinkFunc
+	symlinkFunc = mockSymlink
+	
+	// Read back links created by mockSymlink
+	originalReadlink := readlinkFunc
+	readlinkFunc = mockReadlink
+	
+	// Update tests must not depend on whether they run in a container
+	originalContainerSignals := readContainerSignals
+	readContainerSignals = func() containerSignals { return containerSignals{} }
+	
+	// Update tests download placeholder content rather than real executables
+	originalValidateExecutable := validateExecutable
+	validateExecutable = func(path string) error { return nil }
+	
+	// Downgrade confirmations must not wait for input
+	originalStdinIsTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return false }
+	
+	// Mock parseFlags to avoid flag redefinition errors
+	originalParseFlags := parseFlags
+	parseFlags = func() (*bool, *bool) {
+		versionFlag := false
+		updateFlag := false
+		return &versionFlag, &updateFlag
+	}
+	
+	code := m.Run()
+	
+	// Restore original functions
+	symlinkFunc = originalSymlink
+	readlinkFunc = originalReadlink
+	readContainerSignals = originalContainerSignals
+	stdinIsTerminal = originalStdinIsTerminal
+	validateExecutable = originalValidateExecutable
+	parseFlags = originalParseFlags
+	
+	os.Exit(code)
+}
+
+// Mock symlink function that creates a regular file instead
+func mockSymlink(oldname, newname string) error {
+	content := []byte("SYMLINK:" + oldname)
+	return os.WriteFile(newname, content, 0644)
+}
+
+// Mock readlink function that reads back a link created by mockSymlink
+func mockReadlink(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(data), "SYMLINK:"), nil
+}
+
+// Helper function to create test directory
+func setupTestDir(t *testing.T) string {
+	tempDir, err := os.MkdirTemp("", "secret_manager_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	return tempDir
+}
+
+// Helper function to create test file
+func createFile(t *testing.T, path string, content string) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory %s: %v", dir, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file %s: %v", path, err)
+	}
+}
+
+// Helper function to capture everything written to stdout while f runs
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = w
+
+	outputChan := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		outputChan <- string(data)
+	}()
+
+	defer func() {
+		w.Close()
+		os.Stdout = originalStdout
+	}()
+	f()
+	w.Close()
+	os.Stdout = originalStdout
+	return <-outputChan
+}
+
+// Test main function execution
+func TestMainFunction(t *testing.T) {
+	originalExit := exitFunc
+	originalStderr := os.Stderr
+	originalExeDir := executableDir
+	originalOpts := opts
+	
+	tests := []struct {
+		name        string
+		setup       func(string)
+		expectExit  bool
+		exitCode    int
+		exeDirError bool
+	}{
+		{
+			name: "success_case",
+			setup: func(dir string) {
+				os.MkdirAll(filepath.Join(dir, "my_secret"), 0755)
+				createFile(t, filepath.Join(dir, "my_secret/test.txt"), "content")
+				config := SymlinkConfig{
+					Targets: []Target{{Path: "link.txt", Description: "test"}},
+				}
+				data, _ := json.Marshal(config)
+				createFile(t, filepath.Join(dir, "my_secret/test.txt.symlink.json"), string(data))
+			},
+			expectExit: false,
+		},
+		{
+			name: "error_case",
+			setup: func(dir string) {
+				// No directories with 'secret' in name
+			},
+			expectExit: true,
+			exitCode:   0, // Now exits with 0 when no secret directories found
+		},
+		{
+			name: "find_directories_error",
+			setup: func(dir string) {
+				// Create a file named "secret" instead of directory to cause issues
+				createFile(t, filepath.Join(dir, "secret"), "not a directory")
+			},
+			expectExit: false, // findSecretDirectories handles this gracefully
+		},
+		{
+			name: "process_directory_error",
+			setup: func(dir string) {
+				// Create a secret directory with invalid symlink config
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(secretDir, 0755)
+				// Create a file that will be processed but with read error
+				configPath := filepath.Join(secretDir, "test.txt.symlink.json")
+				createFile(t, configPath, "valid json")
+				// Make the config file unreadable (Windows may ignore this)
+				os.Chmod(configPath, 0000)
+			},
+			expectExit: false, // processSecretDirectory continues on error
+		},
+		{
+			name: "exe_dir_error",
+			setup: func(dir string) {
+				// No setup needed
+			},
+			expectExit:  true,
+			exitCode:    1,
+			exeDirError: true,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd)
+			
+			// Mock executableDir
+			if tt.exeDirError {
+				executableDir = func() (string, error) {
+					return "", errors.New("mock error")
+				}
+			} else {
+				executableDir = func() (string, error) {
+					return tempDir, nil
+				}
+			}
+			
+			exitCalled := false
+			exitCode := 0
+			exitFunc = func(code int) {
+				exitCalled = true
+				exitCode = code
+			}
+			defer func() { 
+				exitFunc = originalExit
+				executableDir = originalExeDir
+				opts = originalOpts
+			}()
+			
+			// Capture stderr for error case
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			
+			tt.setup(tempDir)
+			main()
+			
+			w.Close()
+			os.Stderr = originalStderr
+			output := make([]byte, 1024)
+			n, _ := r.Read(output)
+			output = output[:n]
+			
+			if tt.expectExit && !exitCalled {
+				t.Error("Expected exit to be called")
+			}
+			if tt.expectExit && exitCode != tt.exitCode {
+				t.Errorf("Expected exit code %d, got %d", tt.exitCode, exitCode)
+			}
+			if tt.name == "exe_dir_error" && len(output) == 0 {
+				t.Error("Expected error output for exe_dir_error")
+			}
+		})
+	}
+}
+
+// Test processSecretDirectory function
+func TestProcessSecretDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(string) string
+		wantErr bool
+	}{
+		{
+			name: "directory_not_found",
+			setup: func(dir string) string {
+				return "/nonexistent/directory"
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty_directory",
+			setup: func(dir string) string {
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(secretDir, 0755)
+				return secretDir
+			},
+			wantErr: false,
+		},
+		{
+			name: "with_subdirectory",
+			setup: func(dir string) string {
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(filepath.Join(secretDir, "subdir"), 0755)
+				return secretDir
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing_source_file",
+			setup: func(dir string) string {
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(secretDir, 0755)
+				config := SymlinkConfig{
+					Targets: []Target{{Path: "link.txt", Description: "test"}},
+				}
+				data, _ := json.Marshal(config)
+				createFile(t, filepath.Join(secretDir, "missing.txt.symlink.json"), string(data))
+				return secretDir
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid_symlink_config",
+			setup: func(dir string) string {
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(secretDir, 0755)
+				createFile(t, filepath.Join(secretDir, "test.txt"), "content")
+				config := SymlinkConfig{
+					Targets: []Target{{Path: filepath.Join(dir, "link.txt"), Description: "test"}},
+				}
+				data, _ := json.Marshal(config)
+				createFile(t, filepath.Join(secretDir, "test.txt.symlink.json"), string(data))
+				return secretDir
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid_json_config",
+			setup: func(dir string) string {
+				secretDir := filepath.Join(dir, "secret")
+				os.MkdirAll(secretDir, 0755)
+				createFile(t, filepath.Join(secretDir, "test.txt"), "content")
+				createFile(t, filepath.Join(secretDir, "test.txt.symlink.json"), "invalid json")
+				return secretDir
+			},
+			wantErr: false, // processSecretDirectory continues on error
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			secretDir := tt.setup(tempDir)
+			err := processSecretDirectory(secretDir)
+			
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processSecretDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test custom manifest patterns given with -manifest-glob
+func TestProcessSecretDirectoryManifestGlob(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "db.key"), "db")
+	createFile(t, filepath.Join(secretDir, "api.key"), "api")
+	createFile(t, filepath.Join(secretDir, "old.key"), "old")
+	
+	manifest := func(name string) string {
+		return fmt.Sprintf(`{"targets":[{"path":%q}]}`, filepath.Join(tempDir, name))
+	}
+	createFile(t, filepath.Join(secretDir, "db.key.links"), manifest("db.link"))
+	createFile(t, filepath.Join(secretDir, "links-api.key.json"), manifest("api.link"))
+	createFile(t, filepath.Join(secretDir, "old.key.symlink.json"), manifest("old.link"))
+	
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.NoOwnerCheck = true
+	opts.ManifestGlobs = []string{"*.links", "links-*.json"}
+	if err := validateOptions(); err != nil {
+		t.Fatalf("validateOptions() error = %v", err)
+	}
+	
+	captureStdout(t, func() {
+		if err := processSecretDirectory(secretDir); err != nil {
+			t.Errorf("processSecretDirectory() error = %v", err)
+		}
+	})
+	
+	for _, link := range []string{"db.link", "api.link"} {
+		if _, err := os.Stat(filepath.Join(tempDir, link)); err != nil {
+			t.Errorf("Expected %s from a custom manifest: %v", link, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "old.link")); !os.IsNotExist(err) {
+		t.Error("Expected the default pattern to be replaced by -manifest-glob")
+	}
+	
+	for _, pattern := range []string{"*.*.links", "manifest.json", "[ab]*.json"} {
+		opts.ManifestGlobs = []string{pattern}
+		if err := validateOptions(); err == nil {
+			t.Errorf("Expected invalid -manifest-glob for %q", pattern)
+		}
+	}
+}
+
+// Test that a secret_manager.json directory manifest links each of its entries
+func TestProcessSecretDirectoryDirectoryManifest(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "db.key"), "db")
+	createFile(t, filepath.Join(secretDir, "api.key"), "api")
+	createFile(t, filepath.Join(secretDir, "tls.pem"), "tls")
+	
+	createFile(t, filepath.Join(secretDir, directoryManifest), fmt.Sprintf(`{
+  "entries": [
+    {"source": "db.key", "targets": [{"path": %q}, {"path": %q}]},
+    {"source": "api.key", "target_prefix": %q, "targets": [{"path": "api.link"}]},
+    {"source": "missing.key", "targets": [{"path": %q}]},
+    {"targets": [{"path": %q}]}
+  ]
+}`, filepath.Join(tempDir, "db.link"), filepath.Join(tempDir, "db2.link"), tempDir,
+		filepath.Join(tempDir, "missing.link"), filepath.Join(tempDir, "nosource.link")))
+	// Per-source manifests still work alongside it
+	createFile(t, filepath.Join(secretDir, "tls.pem.symlink.json"), fmt.Sprintf(`{"targets":[{"path":%q}]}`, filepath.Join(tempDir, "tls.link")))
+	
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	
+	output := captureStdout(t, func() {
+		if err := processSecretDirectory(secretDir); err != nil {
+			t.Errorf("processSecretDirectory() error = %v", err)
+		}
+	})
+	
+	for link, source := range map[string]string{"db.link": "db.key", "db2.link": "db.key", "api.link": "api.key", "tls.link": "tls.pem"} {
+		data, err := os.ReadFile(filepath.Join(tempDir, link))
+		if err != nil || string(data) != "SYMLINK:"+filepath.Join(secretDir, source) {
+			t.Errorf("Expected %s to link to %s, got %q (%v)", link, source, data, err)
+		}
+	}
+	for _, link := range []string{"missing.link", "nosource.link"} {
+		if _, err := os.Stat(filepath.Join(tempDir, link)); !os.IsNotExist(err) {
+			t.Errorf("Expected no %s, got %v", link, err)
+		}
+	}
+	if !strings.Contains(output, "Source file "+filepath.Join(secretDir, "missing.key")+" does not exist, skipping") {
+		t.Errorf("Expected a warning for the missing source, got:\n%s", output)
+	}
+	if !strings.Contains(output, "entry 4 has no source, skipping") {
+		t.Errorf("Expected a warning for the entry without a source, got:\n%s", output)
+	}
+}
+
+// Test that overlapping targets resolve by manifest name and priority, not by
+// the order the filesystem lists manifests in
+func TestProcessSecretDirectoryManifestOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests map[string]string
+		want      string
+	}{
+		{
+			name: "later_file_name_wins",
+			manifests: map[string]string{
+				"a.key.symlink.json": `{"targets":[{"path":%q}]}`,
+				"b.key.symlink.json": `{"targets":[{"path":%q}]}`,
+			},
+			want: "b.key",
+		},
+		{
+			name: "higher_priority_wins",
+			manifests: map[string]string{
+				"a.key.symlink.json": `{"priority":10,"targets":[{"path":%q}]}`,
+				"b.key.symlink.json": `{"targets":[{"path":%q}]}`,
+			},
+			want: "a.key",
+		},
+		{
+			name: "directory_manifest_declaration_order",
+			manifests: map[string]string{
+				directoryManifest: `{"entries":[{"source":"b.key","targets":[{"path":%[1]q}]},{"source":"a.key","targets":[{"path":%[1]q}]}]}`,
+			},
+			want: "a.key",
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+	
+			secretDir := filepath.Join(tempDir, "secret")
+			linkPath := filepath.Join(tempDir, "shared.link")
+			createFile(t, filepath.Join(secretDir, "a.key"), "a")
+			createFile(t, filepath.Join(secretDir, "b.key"), "b")
+			for name, config := range tt.manifests {
+				createFile(t, filepath.Join(secretDir, name), fmt.Sprintf(config, linkPath))
+			}
+	
+			originalOpts := opts
+			originalReadDir := readDirFunc
+			// List entries in reverse to show the order does not come from the filesystem
+			readDirFunc = func(name string) ([]os.DirEntry, error) {
+				entries, err := os.ReadDir(name)
+				sort.Slice(entries, func(i, j int) bool { return entries[i].Name() > entries[j].Name() })
+				return entries, err
+			}
+			defer func() {
+				opts = originalOpts
+				readDirFunc = originalReadDir
+			}()
+			opts.NoOwnerCheck = true
+	
+			captureStdout(t, func() {
+				if err := processSecretDirectory(secretDir); err != nil {
+					t.Errorf("processSecretDirectory() error = %v", err)
+				}
+			})
+	
+			data, err := os.ReadFile(linkPath)
+			if err != nil || string(data) != "SYMLINK:"+filepath.Join(secretDir, tt.want) {
+				t.Errorf("Expected the link to point at %s, got %q (%v)", tt.want, data, err)
+			}
+		})
+	}
+}
+
+// Test that secret directories are returned in path order whatever order the
+// walk finds them in
+func TestFindSecretDirectoriesSorted(t *testing.T) {
+	originalWalk := filepathWalk
+	originalOpts := opts
+	defer func() {
+		filepathWalk = originalWalk
+		opts = originalOpts
+	}()
+	opts.ScanCache = ""
+	
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		for _, name := range []string{"zeta_secret", "alpha_secret", "mid_secret"} {
+			walkFn(filepath.Join(root, name), &mockFileInfo{name: name, isDir: true}, nil)
+		}
+		return nil
+	}
+	
+	dirs, err := findSecretDirectories(".")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	want := []string{"alpha_secret", "mid_secret", "zeta_secret"}
+	if strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, dirs)
+	}
+}
+
+// Test that a .sm-frozen marker blocks changes unless -thaw is given
+func TestProcessSecretDirectoryFrozen(t *testing.T) {
+	tests := []struct {
+		name     string
+		thaw     bool
+		wantLink bool
+	}{
+		{"frozen_skipped", false, false},
+		{"thaw_overrides", true, true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			secretDir := filepath.Join(tempDir, "secret")
+			targetPath := filepath.Join(tempDir, "api.link")
+			createFile(t, filepath.Join(secretDir, "api.key"), "api")
+			createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), fmt.Sprintf(`{"targets":[{"path":%q}]}`, targetPath))
+			createFile(t, filepath.Join(secretDir, frozenMarker), "")
+			
+			originalOpts := opts
+			defer func() { opts = originalOpts }()
+			opts.NoOwnerCheck = true
+			opts.Thaw = tt.thaw
+			
+			output := captureStdout(t, func() {
+				if err := processSecretDirectory(secretDir); err != nil {
+					t.Errorf("processSecretDirectory() error = %v", err)
+				}
+			})
+			
+			if _, err := os.Stat(targetPath); (err == nil) != tt.wantLink {
+				t.Errorf("Expected link = %v, got stat error %v", tt.wantLink, err)
+			}
+			if got := strings.Contains(output, "frozen, skipping"); got == tt.wantLink {
+				t.Errorf("Expected frozen message = %v, output:\n%s", !tt.wantLink, output)
+			}
+		})
+	}
+}
+
+// Test processSymlinkConfig function
+func TestProcessSymlinkConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourcePath string
+		configPath string
+		setup      func(string)
+		wantErr    bool
+	}{
+		{
+			name: "config_file_not_found",
+			setup: func(dir string) {
+				createFile(t, filepath.Join(dir, "source.txt"), "content")
+			},
+			sourcePath: "source.txt",
+			configPath: "nonexistent.json",
+			wantErr:    true,
+		},
+		{
+			name: "invalid_json",
+			setup: func(dir string) {
+				createFile(t, filepath.Join(dir, "source.txt"), "content")
+				createFile(t, filepath.Join(dir, "config.json"), "invalid json")
+			},
+			sourcePath: "source.txt",
+			configPath: "config.json",
+			wantErr:    true,
+		},
+		{
+			name: "empty_targets",
+			setup: func(dir string) {
+				createFile(t, filepath.Join(dir, "source.txt"), "content")
+				config := SymlinkConfig{Targets: []Target{}}
+				data, _ := json.Marshal(config)
+				createFile(t, filepath.Join(dir, "config.json"), string(data))
+			},
+			sourcePath: "source.txt",
+			configPath: "config.json",
+			wantErr:    false,
+		},
+		{
+			name: "multiple_targets",
+			setup: func(dir string) {
+				createFile(t, filepath.Join(dir, "source.txt"), "content")
+				config := SymlinkConfig{
+					Targets: []Target{
+						{Path: filepath.Join(dir, "link1.txt"), Description: "Link 1"},
+						{Path: filepath.Join(dir, "link2.txt"), Description: "Link 2"},
+					},
+				}
+				data, _ := json.Marshal(config)
+				createFile(t, filepath.Join(dir, "config.json"), string(data))
+			},
+			sourcePath: "source.txt",
+			configPath: "config.json",
+			wantErr:    false,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			originalWd, _ := os.Getwd()
+			os.Chdir(tempDir)
+			defer os.Chdir(originalWd)
+			
+			tt.setup(tempDir)
+			
+			err := processSymlinkConfig(tt.sourcePath, tt.configPath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processSymlinkConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test refusing to link an empty source with -require-nonempty-source
+func TestProcessSymlinkConfigRequireNonemptySource(t *testing.T) {
+	tests := []struct {
+		name       string
+		require    bool
+		content    string
+		wantLink   bool
+		wantAction string
+	}{
+		{"empty_linked_by_default", false, "", true, actionCreated},
+		{"empty_skipped_with_flag", true, "", false, actionFailed},
+		{"nonempty_linked_with_flag", true, "secret", true, actionCreated},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "api.key")
+			targetPath := filepath.Join(tempDir, "link.key")
+			configPath := sourcePath + ".symlink.json"
+			createFile(t, sourcePath, tt.content)
+			createFile(t, configPath, fmt.Sprintf(`{"targets":[{"path":%q}]}`, targetPath))
+			
+			originalOpts := opts
+			originalSummary := runSummary
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+			}()
+			opts.NoOwnerCheck = true
+			opts.RequireNonemptySource = tt.require
+			runSummary = &RunSummary{}
+			
+			captureStdout(t, func() {
+				if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+					t.Errorf("processSymlinkConfig() error = %v", err)
+				}
+			})
+			
+			if _, err := os.Lstat(targetPath); (err == nil) != tt.wantLink {
+				t.Errorf("Expected link = %v, got stat error %v", tt.wantLink, err)
+			}
+			if len(runSummary.Results) != 1 || runSummary.Results[0].Action != tt.wantAction {
+				t.Errorf("Expected one %s result, got %+v", tt.wantAction, runSummary.Results)
+			}
+			if got := runSummary.failed(); got != (tt.wantAction == actionFailed) {
+				t.Errorf("Expected failed() = %v for -strict, got %v", !got, got)
+			}
+		})
+	}
+}
+
+// Test the manifest schema_version compatibility check
+func TestProcessSymlinkConfigSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		strict      bool
+		wantLink    bool
+		wantWarning bool
+		wantErr     bool
+	}{
+		{"unversioned", 0, false, true, false, false},
+		{"compatible", supportedSchemaVersion, true, true, false, false},
+		{"too_new_warns", supportedSchemaVersion + 1, false, true, true, false},
+		{"too_new_strict", supportedSchemaVersion + 1, true, false, false, true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "api.key")
+			targetPath := filepath.Join(tempDir, "link.key")
+			configPath := sourcePath + ".symlink.json"
+			createFile(t, sourcePath, "secret")
+			createFile(t, configPath, fmt.Sprintf(`{"schema_version":%d,"targets":[{"path":%q}]}`, tt.version, targetPath))
+			
+			originalOpts := opts
+			defer func() { opts = originalOpts }()
+			opts.NoOwnerCheck = true
+			opts.Strict = tt.strict
+			opts.WarningsTo = "stdout"
+			
+			var err error
+			output := captureStdout(t, func() {
+				err = processSymlinkConfig(sourcePath, configPath)
+			})
+			
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processSymlinkConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "requires schema version") {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if got := strings.Contains(output, "update secret_manager"); got != tt.wantWarning {
+				t.Errorf("Expected warning = %v, output:\n%s", tt.wantWarning, output)
+			}
+			if _, err := os.Lstat(targetPath); (err == nil) != tt.wantLink {
+				t.Errorf("Expected link = %v, got stat error %v", tt.wantLink, err)
+			}
+		})
+	}
+}
+
+// Test refusing sources outside -source-allow-root
+func TestLoadSymlinkConfigBOM(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	manifest := `{"targets": [{"path": "/etc/app/api.key", "description": "API key"}]}`
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{name: "with BOM", file: "bom.symlink.json", data: "\xEF\xBB\xBF" + manifest},
+		{name: "without BOM", file: "plain.symlink.json", data: manifest},
+		{name: "JSON5 with BOM", file: "bom.symlink.json5", data: "\xEF\xBB\xBF// comment\n" + manifest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.file)
+			createFile(t, path, tt.data)
+
+			config, err := loadSymlinkConfig(path)
+			if err != nil {
+				t.Fatalf("loadSymlinkConfig() error = %v", err)
+			}
+			if len(config.Targets) != 1 || config.Targets[0].Path != "/etc/app/api.key" || config.Targets[0].Description != "API key" {
+				t.Errorf("Unexpected config %+v", config)
+			}
+		})
+	}
+}
+
+func TestProcessSymlinkConfigSourceAllowRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   func(root, outside string) string
+		wantLink bool
+	}{
+		{"in_root", func(root, outside string) string {
+			return filepath.Join(root, "secret", "api.key")
+		}, true},
+		{"dotdot_escape", func(root, outside string) string {
+			return filepath.Join(root, "secret", "..", "..", "outside", "api.key")
+		}, false},
+		{"symlink_escape", func(root, outside string) string {
+			link := filepath.Join(root, "secret", "shadow")
+			if err := os.Symlink(filepath.Join(outside, "api.key"), link); err != nil {
+				t.Skipf("symlinks not supported: %v", err)
+			}
+			return link
+		}, false},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			root := filepath.Join(tempDir, "root")
+			outside := filepath.Join(tempDir, "outside")
+			createFile(t, filepath.Join(root, "secret", "api.key"), "in root")
+			createFile(t, filepath.Join(outside, "api.key"), "outside")
+			
+			sourcePath := tt.source(root, outside)
+			targetPath := filepath.Join(tempDir, "link.key")
+			configPath := filepath.Join(root, "secret", "manifest.symlink.json")
+			createFile(t, configPath, fmt.Sprintf(`{"targets":[{"path":%q}]}`, targetPath))
+			
+			originalOpts := opts
+			originalSummary := runSummary
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+			}()
+			opts.NoOwnerCheck = true
+			opts.SourceAllowRoot = root
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+			
+			output := captureStdout(t, func() {
+				if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+					t.Errorf("processSymlinkConfig() error = %v", err)
+				}
+			})
+			
+			if _, err := os.Lstat(targetPath); (err == nil) != tt.wantLink {
+				t.Errorf("Expected link = %v, got stat error %v", tt.wantLink, err)
+			}
+			if !tt.wantLink {
+				if !strings.Contains(output, "outside -source-allow-root") {
+					t.Errorf("Expected refusal message, got:\n%s", output)
+				}
+				if runSummary.count(actionFailed) != 1 {
+					t.Errorf("Expected the target to be recorded as failed, got %+v", runSummary.Results)
+				}
+			}
+		})
+	}
+}
+
+// Test that without -source-allow-root a source escaping the scanned
+// directory is refused, before its pre_hook or source_perm touch anything
+func TestMainDefaultSourceAllowRoot(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	outsideDir := t.TempDir()
+	outside := filepath.Join(outsideDir, "outside.key")
+	createFile(t, outside, "outside")
+	os.Chmod(outside, 0644)
+	
+	rel, err := filepath.Rel(filepath.Join(tempDir, "app_secret"), outside)
+	if err != nil {
+		t.Skipf("no relative path to %s: %v", outside, err)
+	}
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key"), "inside")
+	createFile(t, filepath.Join(tempDir, "app_secret", directoryManifest), fmt.Sprintf(
+		`{"entries":[{"source":%q,"pre_hook":"fetch","source_perm":"0600","targets":[{"path":%q}]},{"source":"api.key","targets":[{"path":%q}]}]}`,
+		rel, filepath.Join(tempDir, "outside.link"), filepath.Join(tempDir, "api.link")))
+	
+	originalOpts := opts
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalRun := runCommand
+	originalWd, _ := os.Getwd()
+	defer func() {
+		opts = originalOpts
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		runCommand = originalRun
+		os.Chdir(originalWd)
+	}()
+	exitFunc = func(code int) {}
+	executableDir = func() (string, error) { return tempDir, nil }
+	runCommand = func(command string, env []string) error {
+		t.Errorf("Expected the pre-hook of a refused source not to run, got %q", command)
+		return nil
+	}
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	
+	output := captureStdout(t, main)
+	
+	if !strings.Contains(output, "is outside the scanned directory") {
+		t.Errorf("Expected the source to be refused, got:\n%s", output)
+	}
+	if _, err := os.Lstat(filepath.Join(tempDir, "outside.link")); !os.IsNotExist(err) {
+		t.Errorf("Expected no link to the refused source, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(tempDir, "api.link")); err != nil {
+		t.Errorf("Expected the source inside the scanned directory to be linked, got %v", err)
+	}
+	if info, _ := os.Stat(outside); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the refused source's permissions to be left alone, got %s", info.Mode().Perm())
+	}
+}
+
+// Test source permission enforcement in processSymlinkConfig
+func TestProcessSymlinkConfigSourcePerm(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourcePerm string
+		chmodErr   error
+		wantErr    string
+		wantMode   os.FileMode
+		wantChmod  bool
+	}{
+		{
+			name:       "valid_mode",
+			sourcePerm: "0600",
+			wantMode:   0600,
+			wantChmod:  true,
+		},
+		{
+			name:       "malformed_mode",
+			sourcePerm: "rw-------",
+			wantErr:    "invalid source_perm",
+		},
+		{
+			name:       "out_of_range_mode",
+			sourcePerm: "1777",
+			wantErr:    "invalid source_perm",
+		},
+		{
+			name:       "chmod_error",
+			sourcePerm: "0640",
+			chmodErr:   errors.New("operation not permitted"),
+			wantErr:    "failed to set source permissions",
+			wantChmod:  true,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.key")
+			createFile(t, sourcePath, "key")
+			config := SymlinkConfig{
+				Targets:    []Target{{Path: filepath.Join(tempDir, "link.key"), Description: "key"}},
+				SourcePerm: tt.sourcePerm,
+			}
+			data, _ := json.Marshal(config)
+			configPath := filepath.Join(tempDir, "source.key.symlink.json")
+			createFile(t, configPath, string(data))
+			
+			chmodCalled := false
+			var chmodMode os.FileMode
+			originalChmod := chmodFunc
+			chmodFunc = func(name string, mode os.FileMode) error {
+				chmodCalled = true
+				chmodMode = mode
+				if name != sourcePath {
+					t.Errorf("Expected chmod on %s, got %s", sourcePath, name)
+				}
+				return tt.chmodErr
+			}
+			defer func() { chmodFunc = originalChmod }()
+			
+			err := processSymlinkConfig(sourcePath, configPath)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("processSymlinkConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if chmodCalled != tt.wantChmod {
+				t.Errorf("Expected chmod called = %v, got %v", tt.wantChmod, chmodCalled)
+			}
+			if tt.wantMode != 0 && chmodMode != tt.wantMode {
+				t.Errorf("Expected mode %o, got %o", tt.wantMode, chmodMode)
+			}
+		})
+	}
+}
+
+func TestProcessSymlinkConfigTargetPerm(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultPerm string
+		flagPerm    string
+		targetPerm  string
+		// Modes expected for the target without and with its own perm, 0 for no chmod
+		wantDefaulted  os.FileMode
+		wantOverridden os.FileMode
+	}{
+		{name: "manifest_default", defaultPerm: "0600", wantDefaulted: 0600, wantOverridden: 0600},
+		{name: "target_overrides_default", defaultPerm: "0600", targetPerm: "0640", wantDefaulted: 0600, wantOverridden: 0640},
+		{name: "flag_default", flagPerm: "0400", wantDefaulted: 0400, wantOverridden: 0400},
+		{name: "manifest_overrides_flag", defaultPerm: "0600", flagPerm: "0400", wantDefaulted: 0600, wantOverridden: 0600},
+		{name: "target_perm_only", targetPerm: "0640", wantOverridden: 0640},
+		{name: "no_perm"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.key")
+			createFile(t, sourcePath, "key")
+			defaulted := filepath.Join(tempDir, "defaulted.key")
+			overridden := filepath.Join(tempDir, "overridden.key")
+			config := SymlinkConfig{
+				Targets: []Target{
+					{Path: defaulted},
+					{Path: overridden, Perm: tt.targetPerm},
+				},
+				DefaultPerm: tt.defaultPerm,
+			}
+			data, _ := json.Marshal(config)
+			configPath := filepath.Join(tempDir, "source.key.symlink.json")
+			createFile(t, configPath, string(data))
+			
+			originalOpts := opts
+			originalChmod := chmodFunc
+			opts.DefaultPerm = tt.flagPerm
+			modes := make(map[string]os.FileMode)
+			chmodFunc = func(name string, mode os.FileMode) error {
+				modes[name] = mode
+				return nil
+			}
+			defer func() {
+				opts = originalOpts
+				chmodFunc = originalChmod
+			}()
+			
+			captureStdout(t, func() {
+				if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+					t.Errorf("processSymlinkConfig() error = %v", err)
+				}
+			})
+			
+			for path, want := range map[string]os.FileMode{defaulted: tt.wantDefaulted, overridden: tt.wantOverridden} {
+				if got := modes[path]; got != want {
+					t.Errorf("Expected %s to get mode %o, got %o", path, want, got)
+				}
+			}
+		})
+	}
+}
+
+// Test that verify_readable fails a target the current user cannot open
+func TestProcessSymlinkConfigVerifyReadable(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	sourcePath := filepath.Join(tempDir, "source.key")
+	createFile(t, sourcePath, "key")
+	readable := filepath.Join(tempDir, "readable.key")
+	unreadable := filepath.Join(tempDir, "unreadable.key")
+	unchecked := filepath.Join(tempDir, "unchecked.key")
+	config := SymlinkConfig{
+		Targets: []Target{
+			{Path: readable, VerifyReadable: true},
+			{Path: unreadable, VerifyReadable: true},
+			{Path: unchecked},
+		},
+	}
+	data, _ := json.Marshal(config)
+	configPath := filepath.Join(tempDir, "source.key.symlink.json")
+	createFile(t, configPath, string(data))
+	
+	originalOpts := opts
+	originalSummary := runSummary
+	originalOpen := openFunc
+	var opened []string
+	openFunc = func(name string) (*os.File, error) {
+		opened = append(opened, name)
+		if name == unreadable {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		return os.Open(name)
+	}
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		openFunc = originalOpen
+	}()
+	opts.NoOwnerCheck = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+	
+	output := captureStdout(t, func() {
+		if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+			t.Errorf("processSymlinkConfig() error = %v", err)
+		}
+	})
+	
+	if len(opened) != 2 {
+		t.Errorf("Expected only the two verify_readable targets to be opened, got %v", opened)
+	}
+	want := map[string]string{readable: actionCreated, unreadable: actionFailed, unchecked: actionCreated}
+	if len(runSummary.Results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), runSummary.Results)
+	}
+	for _, result := range runSummary.Results {
+		if result.Action != want[result.Target] {
+			t.Errorf("Expected %s to be %s, got %s", result.Target, want[result.Target], result.Action)
+		}
+	}
+	if !strings.Contains(output, "target is not readable") {
+		t.Errorf("Expected a readability failure, got:\n%s", output)
+	}
+}
+
+// Test the manifest-level target prefix in expandTargets
+func TestExpandTargetsPrefix(t *testing.T) {
+	absTarget := filepath.Join(os.TempDir(), "abs", "app.key")
+	config := SymlinkConfig{
+		TargetPrefix: filepath.Join("config", "app"),
+		Targets: []Target{
+			{Path: "app.key", Description: "relative"},
+			{Path: filepath.Join("nested", "app.key"), Description: "nested"},
+			{Path: absTarget, Description: "absolute"},
+		},
+	}
+	
+	targets := expandTargets("source", config)
+	expected := []string{
+		filepath.Join("config", "app", "app.key"),
+		filepath.Join("config", "app", "nested", "app.key"),
+		absTarget,
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %d targets, got %d", len(expected), len(targets))
+	}
+	for i, want := range expected {
+		if targets[i].Path != want {
+			t.Errorf("Target %d: expected %s, got %s", i, want, targets[i].Path)
+		}
+	}
+	if config.Targets[0].Path != "app.key" {
+		t.Error("expandTargets should not modify the parsed config")
+	}
+	
+	// Without a prefix targets are unchanged
+	targets = expandTargets("source", SymlinkConfig{Targets: []Target{{Path: "app.key"}}})
+	if targets[0].Path != "app.key" {
+		t.Errorf("Expected unchanged target, got %s", targets[0].Path)
+	}
+}
+
+// Test naming links in directory targets after the source, with -link-prefix
+// and the manifest-level link_prefix
+func TestExpandTargetsLinkPrefix(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	sourcePath := filepath.Join("app_secret", "db.key")
+	fileTarget := filepath.Join("shared", "custom.key")
+	tests := []struct {
+		name       string
+		flagPrefix string
+		config     string
+		wantDir    string
+	}{
+		{name: "no_prefix", wantDir: "db.key"},
+		{name: "flag_prefix", flagPrefix: "app1-", wantDir: "app1-db.key"},
+		{name: "manifest_prefix_wins", flagPrefix: "app1-", config: "app2-", wantDir: "app2-db.key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.LinkPrefix = tt.flagPrefix
+			config := SymlinkConfig{
+				LinkPrefix: tt.config,
+				Targets: []Target{
+					{Path: "shared/"},
+					{Path: fileTarget},
+				},
+			}
+
+			targets := expandTargets(sourcePath, config)
+			if len(targets) != 2 {
+				t.Fatalf("Expected 2 targets, got %d", len(targets))
+			}
+			if want := filepath.Join("shared", tt.wantDir); targets[0].Path != want {
+				t.Errorf("Directory target: expected %s, got %s", want, targets[0].Path)
+			}
+			// A fully specified file target keeps its name
+			if targets[1].Path != fileTarget {
+				t.Errorf("File target: expected %s, got %s", fileTarget, targets[1].Path)
+			}
+		})
+	}
+
+	// The directory style survives target_prefix
+	opts.LinkPrefix = "app1-"
+	targets := expandTargets(sourcePath, SymlinkConfig{TargetPrefix: "config", Targets: []Target{{Path: "shared/"}}})
+	if want := filepath.Join("config", "shared", "app1-db.key"); targets[0].Path != want {
+		t.Errorf("Expected %s, got %s", want, targets[0].Path)
+	}
+}
+
+// Test resolving sources and targets against -source-root and -target-root
+func TestSourceAndTargetRoots(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	secretDir := filepath.Join("app", "secret")
+	createFile(t, filepath.Join(tempDir, secretDir, "api.key.symlink.json"), `{"targets":[]}`)
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	
+	sourceRoot := filepath.Join(tempDir, "generated")
+	targetRoot := filepath.Join(tempDir, "deploy")
+	opts.SourceRoot = sourceRoot
+	opts.TargetRoot = targetRoot
+	
+	manifests, err := listManifests(secretDir)
+	if err != nil {
+		t.Fatalf("listManifests() error = %v", err)
+	}
+	if want := filepath.Join(sourceRoot, "app", "secret", "api.key"); len(manifests) != 1 || manifests[0].sourcePath != want {
+		t.Errorf("Expected source %s, got %+v", want, manifests)
+	}
+	if want := filepath.Join(secretDir, "api.key.symlink.json"); manifests[0].configPath != want {
+		t.Errorf("Expected manifest to stay at %s, got %s", want, manifests[0].configPath)
+	}
+	
+	absTarget := filepath.Join(tempDir, "abs", "api.key")
+	config := SymlinkConfig{
+		TargetPrefix: "conf",
+		Targets: []Target{
+			{Path: "api.key"},
+			{Path: absTarget},
+		},
+	}
+	targets := expandTargets(manifests[0].sourcePath, config)
+	expected := []string{
+		filepath.Join(targetRoot, "conf", "api.key"),
+		absTarget,
+	}
+	for i, want := range expected {
+		if targets[i].Path != want {
+			t.Errorf("Target %d: expected %s, got %s", i, want, targets[i].Path)
+		}
+	}
+}
+
+// Test tag filtering in expandTargets
+func TestExpandTargetsTags(t *testing.T) {
+	config := SymlinkConfig{
+		Targets: []Target{
+			{Path: "tls.crt", Tags: []string{"tls"}},
+			{Path: "db.ini", Tags: []string{"db", "prod"}},
+			{Path: "untagged.txt"},
+		},
+	}
+	
+	tests := []struct {
+		name        string
+		tags        []string
+		excludeTags []string
+		requireTags bool
+		expected    []string
+	}{
+		{
+			name:     "no_filters",
+			expected: []string{"tls.crt", "db.ini", "untagged.txt"},
+		},
+		{
+			name:     "include_tag",
+			tags:     []string{"tls"},
+			expected: []string{"tls.crt", "untagged.txt"},
+		},
+		{
+			name:     "include_any_of_tags",
+			tags:     []string{"tls", "prod"},
+			expected: []string{"tls.crt", "db.ini", "untagged.txt"},
+		},
+		{
+			name:        "exclude_tag",
+			excludeTags: []string{"prod"},
+			expected:    []string{"tls.crt", "untagged.txt"},
+		},
+		{
+			name:        "exclude_wins_over_include",
+			tags:        []string{"db"},
+			excludeTags: []string{"prod"},
+			expected:    []string{"untagged.txt"},
+		},
+		{
+			name:        "require_tags",
+			tags:        []string{"tls"},
+			requireTags: true,
+			expected:    []string{"tls.crt"},
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			opts.Tags = tt.tags
+			opts.ExcludeTags = tt.excludeTags
+			opts.RequireTags = tt.requireTags
+			defer func() { opts = originalOpts }()
+			
+			var paths []string
+			for _, target := range expandTargets("source", config) {
+				paths = append(paths, target.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected targets %v, got %v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+// Test runtime skipping of targets in expandTargets
+func TestExpandTargetsSkipTarget(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	config := SymlinkConfig{
+		Targets: []Target{
+			{Path: filepath.Join("app", "db.ini")},
+			{Path: filepath.Join(tempDir, "app", "api.key")},
+			{Path: filepath.Join("cache", "tmp.key")},
+			{Path: filepath.Join("cache", "keep.txt")},
+		},
+	}
+	
+	tests := []struct {
+		name     string
+		skips    []string
+		globs    []string
+		expected []string
+	}{
+		{
+			name:     "no_skips",
+			expected: []string{filepath.Join("app", "db.ini"), filepath.Join(tempDir, "app", "api.key"), filepath.Join("cache", "tmp.key"), filepath.Join("cache", "keep.txt")},
+		},
+		{
+			name:     "exact_relative_path",
+			skips:    []string{filepath.Join(".", "app", "..", "app", "db.ini")},
+			expected: []string{filepath.Join(tempDir, "app", "api.key"), filepath.Join("cache", "tmp.key"), filepath.Join("cache", "keep.txt")},
+		},
+		{
+			name:     "exact_path_canonicalized",
+			skips:    []string{filepath.Join("app", "api.key")},
+			expected: []string{filepath.Join("app", "db.ini"), filepath.Join("cache", "tmp.key"), filepath.Join("cache", "keep.txt")},
+		},
+		{
+			name:     "glob",
+			globs:    []string{filepath.Join("cache", "*.key")},
+			expected: []string{filepath.Join("app", "db.ini"), filepath.Join(tempDir, "app", "api.key"), filepath.Join("cache", "keep.txt")},
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalSummary := runSummary
+			opts.SkipTargets = tt.skips
+			opts.SkipTargetGlobs = tt.globs
+			runSummary = &RunSummary{}
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+			}()
+			
+			var paths []string
+			output := captureStdout(t, func() {
+				for _, target := range expandTargets("source", config) {
+					paths = append(paths, target.Path)
+				}
+			})
+			if strings.Join(paths, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected targets %v, got %v", tt.expected, paths)
+			}
+			
+			skipped := len(config.Targets) - len(tt.expected)
+			if runSummary.count(actionSkipped) != skipped {
+				t.Errorf("Expected %d skipped results, got %d", skipped, runSummary.count(actionSkipped))
+			}
+			if skipped > 0 && !strings.Contains(output, "skipped by flag") {
+				t.Errorf("Expected skip reason in output, got: %s", output)
+			}
+		})
+	}
+}
+
+// Test parsing of comma-separated list flags
+func TestCommaListFlag(t *testing.T) {
+	var list commaList
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&list, "tags", "")
+	if err := fs.Parse([]string{"-tags", "a, b", "-tags", "c,,"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if list.String() != "a,b,c" {
+		t.Errorf("Expected a,b,c, got %s", list.String())
+	}
+}
+
+// Test createSymlink function
+func TestCreateSymlink(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func() (string, Target)
+		mockSetup func()
+		mockTeardown func()
+		wantErr   bool
+		errMsg    string
+	}{
+		{
+			name: "successful_creation",
+			setup: func() (string, Target) {
+				tempDir := setupTestDir(t)
+				sourcePath := filepath.Join(tempDir, "source.txt")
+				createFile(t, sourcePath, "content")
+				// Create the target directory first
+				os.MkdirAll(filepath.Join(tempDir, "link"), 0755)
+				target := Target{
+					Path:        filepath.Join(tempDir, "link", "target.txt"),
+					Description: "Test link",
+				}
+				return sourcePath, target
+			},
+			wantErr: false,
+		},
+		{
+			name: "directory_not_exist",
+			setup: func() (string, Target) {
+				tempDir := setupTestDir(t)
+				sourcePath := filepath.Join(tempDir, "source.txt")
+				createFile(t, sourcePath, "content")
+				// Target in non-existent directory
+				target := Target{
+					Path:        filepath.Join(tempDir, "nonexistent", "file.txt"),
+					Description: "Test",
+				}
+				return sourcePath, target
+			},
+			wantErr: false, // Now returns nil instead of error
+		},
+		{
+			name: "remove_existing_error",
+			setup: func() (string, Target) {
+				tempDir := setupTestDir(t)
+				sourcePath := filepath.Join(tempDir, "source.txt")
+				createFile(t, sourcePath, "content")
+				target := Target{
+					Path:        filepath.Join(tempDir, "target.txt"),
+					Description: "Test",
+				}
+				return sourcePath, target
+			},
+			mockSetup: func() {
+				originalLstat := lstatFunc
+				originalRemove := removeFunc
+				lstatFunc = func(name string) (os.FileInfo, error) {
+					return nil, nil // File exists
+				}
+				removeFunc = func(name string) error {
+					return errors.New("permission denied")
+				}
+				t.Cleanup(func() {
+					lstatFunc = originalLstat
+					removeFunc = originalRemove
+				})
+			},
+			wantErr: true,
+			errMsg:  "failed to remove existing symlink: permission denied",
+		},
+		{
+			name: "symlink_creation_error",
+			setup: func() (string, Target) {
+				tempDir := setupTestDir(t)
+				sourcePath := filepath.Join(tempDir, "source.txt")
+				createFile(t, sourcePath, "content")
+				target := Target{
+					Path:        filepath.Join(tempDir, "target.txt"),
+					Description: "Test",
+				}
+				return sourcePath, target
+			},
+			mockSetup: func() {
+				originalSymlink := symlinkFunc
+				originalLstat := lstatFunc
+				// Make Lstat return error so Remove is not called
+				lstatFunc = func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				}
+				symlinkFunc = func(oldname, newname string) error {
+					return errors.New("symlink failed")
+				}
+				t.Cleanup(func() {
+					symlinkFunc = originalSymlink
+					lstatFunc = originalLstat
+				})
+			},
+			wantErr: true,
+			errMsg:  "failed to create symlink: symlink failed",
+		},
+		{
+			name: "replace_existing_file",
+			setup: func() (string, Target) {
+				tempDir := setupTestDir(t)
+				sourcePath := filepath.Join(tempDir, "source.txt")
+				targetPath := filepath.Join(tempDir, "existing.txt")
+				createFile(t, sourcePath, "source content")
+				createFile(t, targetPath, "existing content")
+				target := Target{Path: targetPath, Description: "Replace"}
+				return sourcePath, target
+			},
+			mockSetup: func() {
+				// Reset to use default mockSymlink
+				originalSymlink := symlinkFunc
+				symlinkFunc = mockSymlink
+				t.Cleanup(func() {
+					symlinkFunc = originalSymlink
+				})
+			},
+			wantErr: false,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourcePath, target := tt.setup()
+			defer func() {
+				if dir := filepath.Dir(sourcePath); dir != "" {
+					os.RemoveAll(dir)
+				}
+			}()
+			
+			if tt.mockSetup != nil {
+				tt.mockSetup()
+			}
+			
+			err := createSymlink(sourcePath, target)
+			
+			if (err != nil) != tt.wantErr {
+				t.Errorf("createSymlink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			
+			if tt.errMsg != "" && err != nil && err.Error() != tt.errMsg {
+				t.Errorf("Expected error %q, got %q", tt.errMsg, err.Error())
+			}
+		})
+	}
+}
+
+// Test that dry-run reports the link without touching the filesystem
+func TestCreateSymlinkDryRun(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	sourcePath := filepath.Join(tempDir, "source.txt")
+	targetPath := filepath.Join(tempDir, "existing.txt")
+	createFile(t, sourcePath, "source content")
+	createFile(t, targetPath, "existing content")
+	
+	originalOpts := opts
+	opts.DryRun = true
+	defer func() { opts = originalOpts }()
+	
+	output := captureStdout(t, func() {
+		if err := createSymlink(sourcePath, Target{Path: targetPath, Description: "Dry"}); err != nil {
+			t.Errorf("createSymlink() error = %v", err)
+		}
+	})
+	
+	if !strings.Contains(output, "Would create symlink: "+targetPath) {
+		t.Errorf("Expected dry-run message, got: %s", output)
+	}
+	data, _ := os.ReadFile(targetPath)
+	if string(data) != "existing content" {
+		t.Errorf("Existing target should be untouched in dry-run, got %q", string(data))
+	}
+}
+
+// Test creation of missing target directories with -mkdir and -dir-perm
+func TestCreateSymlinkMkdir(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirPerm  string
+		mkdirErr error
+		wantErr  string
+		wantMode os.FileMode
+	}{
+		{
+			name:     "private_directory",
+			dirPerm:  "0700",
+			wantMode: 0700,
+		},
+		{
+			name:     "default_mode",
+			dirPerm:  "",
+			wantMode: 0755,
+		},
+		{
+			name:    "invalid_mode",
+			dirPerm: "0999",
+			wantErr: "invalid -dir-perm",
+		},
+		{
+			name:     "mkdir_error",
+			dirPerm:  "0700",
+			mkdirErr: errors.New("read-only file system"),
+			wantErr:  "failed to create target directory",
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.txt")
+			createFile(t, sourcePath, "content")
+			targetDir := filepath.Join(tempDir, "new", "private")
+			
+			originalOpts := opts
+			originalMkdirAll := mkdirAllFunc
+			opts.Mkdir = true
+			opts.DirPerm = tt.dirPerm
+			if tt.mkdirErr != nil {
+				mkdirAllFunc = func(path string, perm os.FileMode) error {
+					return tt.mkdirErr
+				}
+			}
+			defer func() {
+				opts = originalOpts
+				mkdirAllFunc = originalMkdirAll
+			}()
+			
+			err := createSymlink(sourcePath, Target{Path: filepath.Join(targetDir, "link.txt")})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createSymlink() error = %v", err)
+			}
+			
+			info, err := os.Stat(targetDir)
+			if err != nil {
+				t.Fatalf("Expected target directory to be created: %v", err)
+			}
+			if runtime.GOOS != "windows" && info.Mode().Perm() != tt.wantMode {
+				t.Errorf("Expected mode %o, got %o", tt.wantMode, info.Mode().Perm())
+			}
+			if _, err := os.Stat(filepath.Join(targetDir, "link.txt")); err != nil {
+				t.Errorf("Expected link to be created: %v", err)
+			}
+		})
+	}
+}
+
+// Test that an invalid -dir-perm is rejected before any work is done
+func TestMainInvalidDirPerm(t *testing.T) {
+	originalExit := exitFunc
+	originalOpts := opts
+	originalFindSecretDirs := findSecretDirs
+	
+	exitCode := -1
+	exitFunc = func(code int) {
+		exitCode = code
+	}
+	findSecretDirs = func(root string) ([]string, error) {
+		t.Error("Scan should not run with invalid options")
+		return nil, nil
+	}
+	opts.DirPerm = "rwx"
+	
+	defer func() {
+		exitFunc = originalExit
+		opts = originalOpts
+		findSecretDirs = originalFindSecretDirs
+	}()
+	
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	
+	main()
+	
+	w.Close()
+	os.Stderr = originalStderr
+	output, _ := io.ReadAll(r)
+	
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(string(output), "invalid -dir-perm") {
+		t.Errorf("Expected invalid -dir-perm error, got: %s", string(output))
+	}
+}
+
+// Test processing a single directory with -only
+// Test that -no-chdir scans the working directory and leaves it unchanged
+func TestMainNoChdir(t *testing.T) {
+	workDir := setupTestDir(t)
+	defer os.RemoveAll(workDir)
+	exeDir := setupTestDir(t)
+	defer os.RemoveAll(exeDir)
+	
+	// Each tree links its own secret, so the scanned tree can be told apart
+	for _, dir := range []string{workDir, exeDir} {
+		createFile(t, filepath.Join(dir, "app_secret", "api.key"), "secret")
+		config := fmt.Sprintf(`{"targets":[{"path":%q}]}`, filepath.Join(dir, "api.link"))
+		createFile(t, filepath.Join(dir, "app_secret", "api.key.symlink.json"), config)
+	}
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(workDir)
+	defer os.Chdir(originalWd)
+	wantWd, _ := os.Getwd()
+	
+	originalExit := exitFunc
+	originalOpts := opts
+	originalExeDir := executableDir
+	defer func() {
+		exitFunc = originalExit
+		opts = originalOpts
+		executableDir = originalExeDir
+	}()
+	
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return exeDir, nil }
+	opts.NoOwnerCheck = true
+	opts.NoChdir = true
+	
+	captureStdout(t, main)
+	
+	if exitCode != -1 {
+		t.Errorf("Expected no exit, got %d", exitCode)
+	}
+	if wd, _ := os.Getwd(); wd != wantWd {
+		t.Errorf("Expected working directory %s to be unchanged, got %s", wantWd, wd)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "api.link")); err != nil {
+		t.Errorf("Expected the working directory tree to be scanned: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(exeDir, "api.link")); !os.IsNotExist(err) {
+		t.Errorf("Expected the executable directory not to be scanned, got %v", err)
+	}
+}
+
+func TestMainOnly(t *testing.T) {
+	tests := []struct {
+		name        string
+		dir         string
+		manifest    bool
+		dirManifest bool
+		wantErr     string
+	}{
+		{
+			name:     "valid_directory",
+			dir:      "service",
+			manifest: true,
+		},
+		{
+			name:        "directory_manifest_only",
+			dir:         "service",
+			dirManifest: true,
+		},
+		{
+			name:    "nonexistent_directory",
+			dir:     "missing",
+			wantErr: "does not exist",
+		},
+		{
+			name:    "no_manifests",
+			dir:     "service",
+			wantErr: "contains no *.symlink.json or *.symlink.json5 or secret_manager.json manifests",
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			serviceDir := filepath.Join(tempDir, "service")
+			os.MkdirAll(serviceDir, 0755)
+			targetPath := filepath.Join(tempDir, "app.key")
+			if tt.manifest {
+				createFile(t, filepath.Join(serviceDir, "app.key"), "secret")
+				config := fmt.Sprintf(`{"targets":[{"path":%q,"description":"key"}]}`, targetPath)
+				createFile(t, filepath.Join(serviceDir, "app.key.symlink.json"), config)
+			}
+			if tt.dirManifest {
+				createFile(t, filepath.Join(serviceDir, "app.key"), "secret")
+				config := fmt.Sprintf(`{"entries":[{"source":"app.key","targets":[{"path":%q}]}]}`, targetPath)
+				createFile(t, filepath.Join(serviceDir, directoryManifest), config)
+			}
+			
+			originalWd, _ := os.Getwd()
+			os.Chdir(tempDir)
+			defer os.Chdir(originalWd)
+			
+			originalExit := exitFunc
+			originalOpts := opts
+			originalExeDir := executableDir
+			originalFindSecretDirs := findSecretDirs
+			defer func() {
+				exitFunc = originalExit
+				opts = originalOpts
+				executableDir = originalExeDir
+				findSecretDirs = originalFindSecretDirs
+			}()
+			
+			exitCode := -1
+			exitFunc = func(code int) {
+				exitCode = code
+			}
+			executableDir = func() (string, error) {
+				return t.TempDir(), nil
+			}
+			findSecretDirs = func(root string) ([]string, error) {
+				t.Error("Scan should not run with -only")
+				return nil, nil
+			}
+			opts.NoOwnerCheck = true
+			opts.Only = tt.dir
+			
+			r, w, _ := os.Pipe()
+			originalStderr := os.Stderr
+			os.Stderr = w
+			
+			captureStdout(t, main)
+			
+			w.Close()
+			os.Stderr = originalStderr
+			output, _ := io.ReadAll(r)
+			
+			if tt.wantErr != "" {
+				if exitCode != 1 {
+					t.Errorf("Expected exit code 1, got %d", exitCode)
+				}
+				if !strings.Contains(string(output), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %s", tt.wantErr, string(output))
+				}
+				return
+			}
+			
+			if exitCode != -1 {
+				t.Errorf("Expected no exit, got code %d: %s", exitCode, string(output))
+			}
+			if _, err := os.Stat(targetPath); err != nil {
+				t.Errorf("Expected target to be created: %v", err)
+			}
+		})
+	}
+}
+
+// Test waiting for a target directory that appears after start
+func TestCreateSymlinkWaitForTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		appearAt   int // Sleep call that creates the directory, 0 for never
+		wantAction string
+	}{
+		{
+			name:       "appears_mid_wait",
+			appearAt:   3,
+			wantAction: actionCreated,
+		},
+		{
+			name:       "never_appears",
+			wantAction: actionFailed,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.txt")
+			createFile(t, sourcePath, "content")
+			mountDir := filepath.Join(tempDir, "mount")
+			
+			originalOpts := opts
+			originalNow := nowFunc
+			originalSleep := sleepFunc
+			originalSummary := runSummary
+			defer func() {
+				opts = originalOpts
+				nowFunc = originalNow
+				sleepFunc = originalSleep
+				runSummary = originalSummary
+			}()
+			
+			opts.NoOwnerCheck = true
+			opts.WaitForTarget = 5 * time.Second
+			runSummary = &RunSummary{}
+			
+			clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			start := clock
+			sleeps := 0
+			nowFunc = func() time.Time { return clock }
+			sleepFunc = func(d time.Duration) {
+				sleeps++
+				clock = clock.Add(d)
+				if sleeps == tt.appearAt {
+					os.MkdirAll(mountDir, 0755)
+				}
+			}
+			
+			captureStdout(t, func() {
+				createSymlink(sourcePath, Target{Path: filepath.Join(mountDir, "link.txt")})
+			})
+			
+			if got := runSummary.Results[0].Action; got != tt.wantAction {
+				t.Errorf("Expected action %s, got %s", tt.wantAction, got)
+			}
+			if tt.appearAt != 0 && sleeps != tt.appearAt {
+				t.Errorf("Expected to stop polling once the directory appeared, got %d sleeps", sleeps)
+			}
+			if tt.appearAt == 0 && clock.Sub(start) < opts.WaitForTarget {
+				t.Errorf("Expected to wait the full %s, waited %s", opts.WaitForTarget, clock.Sub(start))
+			}
+		})
+	}
+}
+
+// Test retrying transient link failures
+func TestCreateSymlinkRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     []error
+		linkRetries  int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{
+			name:         "transient_then_success",
+			failures:     []error{syscall.EAGAIN, syscall.EBUSY},
+			retries:      3,
+			wantAttempts: 3,
+		},
+		{
+			name:         "global_default",
+			failures:     []error{syscall.EBUSY},
+			linkRetries:  1,
+			wantAttempts: 2,
+		},
+		{
+			name:         "retries_exhausted",
+			failures:     []error{syscall.EBUSY, syscall.EBUSY, syscall.EBUSY},
+			retries:      2,
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+		{
+			name:         "permanent_failure",
+			failures:     []error{syscall.EACCES},
+			retries:      3,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.txt")
+			targetPath := filepath.Join(tempDir, "link.txt")
+			createFile(t, sourcePath, "content")
+			
+			originalOpts := opts
+			originalSymlink := symlinkFunc
+			originalSleep := sleepFunc
+			defer func() {
+				opts = originalOpts
+				symlinkFunc = originalSymlink
+				sleepFunc = originalSleep
+			}()
+			
+			opts.NoOwnerCheck = true
+			opts.LinkRetries = tt.linkRetries
+			attempts := 0
+			symlinkFunc = func(oldname, newname string) error {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: tt.failures[attempts-1]}
+				}
+				return mockSymlink(oldname, newname)
+			}
+			var backoffs []time.Duration
+			sleepFunc = func(d time.Duration) {
+				backoffs = append(backoffs, d)
+			}
+			
+			var err error
+			captureStdout(t, func() {
+				err = createSymlink(sourcePath, Target{Path: targetPath, Retries: tt.retries})
+			})
+			
+			if (err != nil) != tt.wantErr {
+				t.Errorf("createSymlink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			for i, d := range backoffs {
+				if want := linkRetryBackoff << i; d != want {
+					t.Errorf("Expected backoff %s before retry %d, got %s", want, i+1, d)
+				}
+			}
+		})
+	}
+}
+
+// Test resolving a symlinked source before linking
+func TestCreateSymlinkResolveSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		flag        bool
+		perTarget   bool
+		resolveErr  error
+		expectedOld string
+		wantErr     string
+	}{
+		{
+			name:        "without_resolution",
+			expectedOld: "alias.txt",
+		},
+		{
+			name:        "resolve_source_flag",
+			flag:        true,
+			expectedOld: "real.txt",
+		},
+		{
+			name:        "per_target_resolve_source",
+			perTarget:   true,
+			expectedOld: "real.txt",
+		},
+		{
+			name:       "resolve_error",
+			flag:       true,
+			resolveErr: errors.New("too many links"),
+			wantErr:    "failed to resolve source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			realPath := filepath.Join(tempDir, "real.txt")
+			aliasPath := filepath.Join(tempDir, "alias.txt")
+			createFile(t, realPath, "content")
+
+			var linkedTo string
+			originalSymlink := symlinkFunc
+			originalEval := evalSymlinks
+			originalOpts := opts
+			symlinkFunc = func(oldname, newname string) error {
+				linkedTo = oldname
+				return mockSymlink(oldname, newname)
+			}
+			evalSymlinks = func(path string) (string, error) {
+				if path != aliasPath {
+					t.Errorf("Expected to resolve %s, got %s", aliasPath, path)
+				}
+				return realPath, tt.resolveErr
+			}
+			opts.ResolveSource = tt.flag
+			defer func() {
+				symlinkFunc = originalSymlink
+				evalSymlinks = originalEval
+				opts = originalOpts
+			}()
+
+			target := Target{Path: filepath.Join(tempDir, "link.txt"), ResolveSource: tt.perTarget}
+			err := createSymlink(aliasPath, target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createSymlink() error = %v", err)
+			}
+			if linkedTo != filepath.Join(tempDir, tt.expectedOld) {
+				t.Errorf("Expected link to %s, got %s", tt.expectedOld, linkedTo)
+			}
+		})
+	}
+}
+
+// Test reading back a newly created link
+func TestCreateSymlinkReadback(t *testing.T) {
+	tests := []struct {
+		name     string
+		readlink func(string) (string, error)
+		wantErr  string
+	}{
+		{
+			name:     "matching_readback",
+			readlink: mockReadlink,
+		},
+		{
+			name: "relative_readback",
+			readlink: func(name string) (string, error) {
+				return "source.txt", nil
+			},
+		},
+		{
+			name: "mismatching_readback",
+			readlink: func(name string) (string, error) {
+				return "/somewhere/else.txt", nil
+			},
+			wantErr: "symlink verification failed",
+		},
+		{
+			name: "readlink_error",
+			readlink: func(name string) (string, error) {
+				return "", errors.New("not a symlink")
+			},
+			wantErr: "failed to verify symlink",
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			
+			sourcePath := filepath.Join(tempDir, "source.txt")
+			createFile(t, sourcePath, "content")
+			
+			originalReadlink := readlinkFunc
+			readlinkFunc = tt.readlink
+			defer func() { readlinkFunc = originalReadlink }()
+			
+			err := createSymlink(sourcePath, Target{Path: filepath.Join(tempDir, "link.txt")})
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("createSymlink() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Test error handling with symlink creation continues on error
+func TestSymlinkCreationContinuesOnError(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	sourceFile := filepath.Join(tempDir, "source.txt")
+	createFile(t, sourceFile, "content")
+	
+	errorCount := 0
+	originalSymlink := symlinkFunc
+	symlinkFunc = func(oldname, newname string) error {
+		errorCount++
+		return errors.New("mock error")
+	}
+	defer func() { symlinkFunc = originalSymlink }()
+	
+	config := SymlinkConfig{
+		Targets: []Target{
+			{Path: filepath.Join(tempDir, "link1.txt"), Description: "Link 1"},
+			{Path: filepath.Join(tempDir, "link2.txt"), Description: "Link 2"},
+			{Path: filepath.Join(tempDir, "link3.txt"), Description: "Link 3"},
+		},
+	}
+	
+	configData, _ := json.Marshal(config)
+	configFile := filepath.Join(tempDir, "config.json")
+	createFile(t, configFile, string(configData))
+	
+	err := processSymlinkConfig(sourceFile, configFile)
+	if err != nil {
+		t.Errorf("processSymlinkConfig should not return error: %v", err)
+	}
+	
+	if errorCount != 3 {
+		t.Errorf("Expected 3 symlink attempts, got %d", errorCount)
+	}
+}
+
+// Integration test with multiple files
+func TestFullIntegration(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	secretDir := filepath.Join(tempDir, "secret")
+	os.MkdirAll(secretDir, 0755)
+	
+	// Create target directories
+	os.MkdirAll(filepath.Join(tempDir, "app"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "backup"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "keys"), 0755)
+	
+	// Create multiple files with configs
+	files := []struct {
+		name    string
+		content string
+		targets []Target
+	}{
+		{
+			name:    "config.ini",
+			content: "[database]\nhost=localhost",
+			targets: []Target{
+				{Path: filepath.Join(tempDir, "app", "config.ini"), Description: "App config"},
+				{Path: filepath.Join(tempDir, "backup", "config.ini"), Description: "Backup"},
+			},
+		},
+		{
+			name:    "secret.key",
+			content: "supersecretkey123",
+			targets: []Target{
+				{Path: filepath.Join(tempDir, "keys", "app.key"), Description: "App key"},
+			},
+		},
+	}
+	
+	for _, file := range files {
+		filePath := filepath.Join(secretDir, file.name)
+		createFile(t, filePath, file.content)
+		
+		config := SymlinkConfig{Targets: file.targets}
+		configData, _ := json.Marshal(config)
+		configPath := filepath.Join(secretDir, file.name+".symlink.json")
+		createFile(t, configPath, string(configData))
+	}
+	
+	err := processSecretDirectory(secretDir)
+	if err != nil {
+		t.Errorf("processSecretDirectory failed: %v", err)
+	}
+	
+	// Verify all symlinks were created
+	expectedLinks := []string{
+		filepath.Join(tempDir, "app", "config.ini"),
+		filepath.Join(tempDir, "backup", "config.ini"),
+		filepath.Join(tempDir, "keys", "app.key"),
+	}
+	
+	for _, link := range expectedLinks {
+		if _, err := os.Stat(link); err != nil {
+			t.Errorf("Expected symlink not created: %s", link)
+		}
+	}
+}
+
+// Test findSecretDirectories function
+func TestFindSecretDirectories(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	// Create test directory structure
+	os.MkdirAll(filepath.Join(tempDir, "project1", "secret"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "project2", "my_secrets"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "no_match", "config"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "secret_data"), 0755)
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	dirs, err := findSecretDirectories(".")
+	if err != nil {
+		t.Errorf("findSecretDirectories() error = %v", err)
+	}
+	
+	expected := 3 // "project1/secret", "project2/my_secrets", "secret_data"
+	if len(dirs) != expected {
+		t.Errorf("Expected %d directories, got %d: %v", expected, len(dirs), dirs)
+	}
+}
+
+// Test that -skip-hidden prunes hidden directories from the scan
+func TestFindSecretDirectoriesSkipHidden(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	os.MkdirAll(filepath.Join(tempDir, "app", "secret"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, ".secret_backup"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, ".git", "secret"), 0755)
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	
+	opts.SkipHidden = false
+	dirs, err := findSecretDirectories(".")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	if len(dirs) != 3 {
+		t.Errorf("Expected 3 directories without -skip-hidden, got %d: %v", len(dirs), dirs)
+	}
+	
+	opts.SkipHidden = true
+	dirs, err = findSecretDirectories(".")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	if len(dirs) != 1 || dirs[0] != filepath.Join("app", "secret") {
+		t.Errorf("Expected only app/secret with -skip-hidden, got %v", dirs)
+	}
+	
+	// A hidden root is still scanned
+	os.MkdirAll(filepath.Join(".secret_backup", "secret"), 0755)
+	dirs, err = findSecretDirectories(".secret_backup")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	if len(dirs) != 2 {
+		t.Errorf("Expected hidden root and its secret directory, got %v", dirs)
+	}
+}
+
+// Test matching directories against -scan-keyword
+func TestFindSecretDirectoriesScanKeyword(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	for _, dir := range []string{"app_secrets", "Vault", "team/credentials", "team/keys", "logs"} {
+		os.MkdirAll(filepath.Join(tempDir, dir), 0755)
+	}
+	
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	
+	tests := []struct {
+		name     string
+		keywords []string
+		want     []string
+	}{
+		{"default", nil, []string{"app_secrets"}},
+		{"custom_case_insensitive", []string{"VAULT"}, []string{"Vault"}},
+		{"multiple", []string{"credentials", "keys"}, []string{filepath.Join("team", "credentials"), filepath.Join("team", "keys")}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.ScanKeywords = tt.keywords
+			dirs, err := findSecretDirectories(".")
+			if err != nil {
+				t.Fatalf("findSecretDirectories() error = %v", err)
+			}
+			if strings.Join(dirs, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, dirs)
+			}
+		})
+	}
+}
+
+// Test findSecretDirectories with walk error
+func TestFindSecretDirectoriesWalkError(t *testing.T) {
+	// On Windows, filepath.Walk doesn't return error for non-existent paths
+	// Instead, let's test with an invalid path pattern
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	// Create a file (not directory) to trigger different behavior
+	testFile := filepath.Join(tempDir, "testfile")
+	createFile(t, testFile, "content")
+	
+	// Try to walk a file as if it were a directory
+	dirs, err := findSecretDirectories(testFile)
+	// This might not error on all platforms, but should return empty
+	if err != nil {
+		// Some platforms may error
+		if dirs != nil {
+			t.Error("Expected nil directories on error")
+		}
+	} else {
+		// Other platforms may just return empty
+		if len(dirs) != 0 {
+			t.Error("Expected no directories when walking a file")
+		}
+	}
+}
+
+// Test findSecretDirectories with permission error (tests line 42-44)
+func TestFindSecretDirectoriesPermissionError(t *testing.T) {
+	// Test that Walk callback continues on error (line 42-47)
+	originalWalk := filepathWalk
+	callbackCalled := false
+	errorReturned := false
+	
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		// First call with valid directory
+		walkFn(".", &mockFileInfo{name: ".", isDir: true}, nil)
+		
+		// Then call with an error to test error handling path
+		result := walkFn("./badfile", nil, errors.New("permission denied"))
+		if result != nil {
+			errorReturned = true
+		}
+		callbackCalled = true
+		
+		// Continue with a secret directory after the error
+		walkFn("./my_secret", &mockFileInfo{name: "my_secret", isDir: true}, nil)
+		
+		return nil
+	}
+	
+	defer func() {
+		filepathWalk = originalWalk
+	}()
+	
+	dirs, err := findSecretDirectories(".")
+	
+	if err != nil {
+		t.Errorf("findSecretDirectories() error = %v", err)
+	}
+	
+	if !callbackCalled {
+		t.Error("Walk callback was not called")
+	}
+	
+	if errorReturned {
+		t.Error("Callback should return nil on error, not propagate it")
+	}
+	
+	// Should find the secret directory despite the error
+	if len(dirs) != 1 || dirs[0] != "./my_secret" {
+		t.Errorf("Expected to find ./my_secret, got %v", dirs)
+	}
+}
+
+// mockFileInfo implements os.FileInfo for testing
+type mockFileInfo struct {
+	name  string
+	isDir bool
+}
+
+func (m *mockFileInfo) Name() string       { return m.name }
+func (m *mockFileInfo) Size() int64        { return 0 }
+func (m *mockFileInfo) Mode() os.FileMode  { return 0755 }
+func (m *mockFileInfo) ModTime() time.Time { return time.Now() }
+func (m *mockFileInfo) IsDir() bool        { return m.isDir }
+func (m *mockFileInfo) Sys() interface{}   { return nil }
+
+// Test findSecretDirectories with filepath.Walk returning error
+func TestFindSecretDirectoriesWalkReturnsError(t *testing.T) {
+	// Mock filepathWalk to return an error
+	originalWalk := filepathWalk
+	mockError := errors.New("walk error")
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		return mockError
+	}
+	defer func() {
+		filepathWalk = originalWalk
+	}()
+	
+	dirs, err := findSecretDirectories(".")
+	if err == nil {
+		t.Error("Expected error from findSecretDirectories")
+	}
+	if err != mockError {
+		t.Errorf("Expected error %v, got %v", mockError, err)
+	}
+	if dirs != nil {
+		t.Error("Expected nil directories on error")
+	}
+}
+
+// Test main function with no secret directories found
+func TestMainWithNoSecretDirectories(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalWalk := filepathWalk
+	originalOpts := opts
+	
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	// Change to temp dir first
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalWd)
+	
+	exitCalled := false
+	exitCode := 0
+	exitFunc = func(code int) {
+		exitCalled = true
+		exitCode = code
+	}
+	
+	executableDir = func() (string, error) {
+		return tempDir, nil
+	}
+	
+	// Mock filepathWalk to return empty list without error
+	// This simulates the behavior when Walk completes but finds no directories
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		// Return nil to simulate successful walk with no results
+		return nil
+	}
+	
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		filepathWalk = originalWalk
+		opts = originalOpts
+	}()
+	
+	// Capture stdout (message goes to stdout, not stderr)
+	r, w, _ := os.Pipe()
+	originalStdout := os.Stdout
+	os.Stdout = w
+	
+	main()
+	
+	w.Close()
+	os.Stdout = originalStdout
+	output := make([]byte, 1024)
+	n, _ := r.Read(output)
+	output = output[:n]
+	
+	if !exitCalled {
+		t.Error("Expected exit to be called")
+	}
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+	if !strings.Contains(string(output), "No directories containing 'secret' found") {
+		t.Errorf("Expected message about no secret directories found, got: %s", string(output))
+	}
+}
+
+// Test main function with actual findSecretDirectories error  
+func TestMainWithFindDirectoriesActualError(t *testing.T) {
+	// Save originals
+	originalExitFunc := exitFunc
+	originalExecutableDir := executableDir
+	originalFindSecretDirs := findSecretDirs
+	originalParseFlags := parseFlags
+	originalOpts := opts
+	defer func() {
+		exitFunc = originalExitFunc
+		executableDir = originalExecutableDir
+		findSecretDirs = originalFindSecretDirs
+		parseFlags = originalParseFlags
+		opts = originalOpts
+	}()
+
+	// Track exit calls
+	exitCalled := false
+	exitCode := 0
+	exitFunc = func(code int) {
+		exitCalled = true
+		exitCode = code
+		// Don't actually exit, just panic to stop execution
+		panic("test exit")
+	}
+
+	// Mock parseFlags to return no flags
+	parseFlags = func() (*bool, *bool) {
+		versionFlag := false
+		updateFlag := false
+		return &versionFlag, &updateFlag
+	}
+
+	// Mock executable directory
+	executableDir = func() (string, error) {
+		return ".", nil
+	}
+
+	// Mock findSecretDirs to return an error
+	findSecretDirs = func(root string) ([]string, error) {
+		return nil, errors.New("mock find secret dirs error")
+	}
+
+	// Capture stderr
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	// Run main in a goroutine so we can capture the panic
+	done := make(chan bool)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				// Expected panic from exit mock
+				if r == "test exit" {
+					done <- true
+				}
+			}
+		}()
+		main()
+		done <- true
+	}()
+
+	// Read output in background
+	outputChan := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := r.Read(buf)
+		if n > 0 {
+			outputChan <- string(buf[:n])
+		}
+		close(outputChan)
+	}()
+
+	// Wait for main to complete
+	<-done
+	
+	// Give a moment for output to be written
+	time.Sleep(10 * time.Millisecond)
+	
+	// Get output
+	var output string
+	select {
+	case out := <-outputChan:
+		output = out
+	default:
+		// Try one more read if nothing yet
+		buf := make([]byte, 4096)
+		n, _ := r.Read(buf)
+		if n > 0 {
+			output = string(buf[:n])
+		}
+	}
+
+	// Close writer and restore stderr
+	w.Close()
+	os.Stderr = oldStderr
+
+	// Verify behavior
+	if !exitCalled {
+		t.Error("Expected exitFunc to be called")
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(output, "Error finding secret directories") {
+		t.Error("Expected error message about finding secret directories")
+	}
+	if !strings.Contains(output, "mock find secret dirs error") {
+		t.Error("Expected mock error message")
+	}
+}
+
+// Test processSecretDirectory returning error in main
+func TestMainWithProcessDirectoryError(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalReadDir := readDirFunc
+	originalOpts := opts
+	
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	
+	// Create a secret directory
+	secretDir := filepath.Join(tempDir, "my_secret")
+	os.MkdirAll(secretDir, 0755)
+	
+	exitCalled := false
+	exitFunc = func(code int) {
+		exitCalled = true
+	}
+	
+	executableDir = func() (string, error) {
+		return tempDir, nil
+	}
+	
+	// Make ReadDir fail for the secret directory
+	readDirFunc = func(name string) ([]os.DirEntry, error) {
+		if strings.Contains(name, "my_secret") {
+			return nil, errors.New("read error")
+		}
+		return originalReadDir(name)
+	}
+	
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		readDirFunc = originalReadDir
+		opts = originalOpts
+	}()
+	
+	// Capture stderr
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	
+	main()
+	
+	w.Close()
+	os.Stderr = originalStderr
+	output := make([]byte, 1024)
+	n, _ := r.Read(output)
+	output = output[:n]
+	
+	// Should not exit on process directory error
+	if exitCalled {
+		t.Error("Should not exit on process directory error")
+	}
+	
+	if !strings.Contains(string(output), "Error processing") {
+		t.Error("Expected error message about processing directory")
+	}
+}
+
+// Test getExecutableDir function
+func TestGetExecutableDir(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir, err := getExecutableDir()
+		if err != nil {
+			t.Errorf("getExecutableDir() error = %v", err)
+		}
+		if dir == "" {
+			t.Error("getExecutableDir() returned empty string")
+		}
+	})
+	
+	t.Run("error", func(t *testing.T) {
+		// Mock os.Executable to return error
+		originalOsExecutable := osExecutable
+		osExecutable = func() (string, error) {
+			return "", errors.New("mock error")
+		}
+		defer func() {
+			osExecutable = originalOsExecutable
+		}()
+		
+		_, err := getExecutableDir()
+		if err == nil {
+			t.Error("Expected error from getExecutableDir")
+		}
+	})
+}
+
+// =============================================================================
+// VERSION AND FLAG TESTS
+// =============================================================================
+// Tests for command line flag handling and version display
+// =============================================================================
+
+// Test version flag
+func TestMainVersionFlag(t *testing.T) {
+	originalExit := exitFunc
+	originalParseFlags := parseFlags
+	originalOpts := opts
+	originalWd, _ := os.Getwd()
+	
+	exitCalled := false
+	exitCode := 0
+	exitFunc = func(code int) {
+		exitCalled = true
+		exitCode = code
+	}
+	
+	// Mock parseFlags to return version flag
+	parseFlags = func() (*bool, *bool) {
+		versionFlag := true
+		updateFlag := false
+		return &versionFlag, &updateFlag
+	}
+	
+	defer func() {
+		exitFunc = originalExit
+		parseFlags = originalParseFlags
+		opts = originalOpts
+		os.Chdir(originalWd)
+	}()
+	
+	// Capture stdout
+	r, w, _ := os.Pipe()
+	originalStdout := os.Stdout
+	os.Stdout = w
+	
+	main()
+	
+	w.Close()
+	os.Stdout = originalStdout
+	output := make([]byte, 1024)
+	n, _ := r.Read(output)
+	output = output[:n]
+	
+	if !exitCalled {
+		t.Error("Expected exit to be called")
+	}
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+	
+	// Check output contains version info
+	outputStr := string(output)
+	if len(outputStr) == 0 {
+		t.Error("Expected version output")
+	}
+}
+
+// Test update flag
+func TestMainUpdateFlag(t *testing.T) {
+	originalExit := exitFunc
+	originalParseFlags := parseFlags
+	originalOpts := opts
+	originalWd, _ := os.Getwd()
+	originalCheckAndUpdate := checkAndUpdateFunc
+	
+	exitCalled := false
+	exitCode := 0
+	exitFunc = func(code int) {
+		exitCalled = true
+		exitCode = code
+	}
+	
+	// Mock parseFlags to return update flag
+	parseFlags = func() (*bool, *bool) {
+		versionFlag := false
+		updateFlag := true
+		return &versionFlag, &updateFlag
+	}
+	
+	// Mock checkAndUpdate
+	checkAndUpdateCalled := false
+	checkAndUpdateFunc = func() error {
+		checkAndUpdateCalled = true
+		return nil
+	}
+	
+	defer func() {
+		exitFunc = originalExit
+		parseFlags = originalParseFlags
+		opts = originalOpts
+		os.Chdir(originalWd)
+		checkAndUpdateFunc = originalCheckAndUpdate
+	}()
+	
+	main()
+	
+	if !exitCalled {
+		t.Error("Expected exit to be called")
+	}
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+	if !checkAndUpdateCalled {
+		t.Error("Expected checkAndUpdate to be called")
+	}
+}
+
+// Test update flag with error
+func TestMainUpdateFlagError(t *testing.T) {
+	originalExit := exitFunc
+	originalParseFlags := parseFlags
+	originalCheckAndUpdate := checkAndUpdateFunc
+	originalExeDir := executableDir
+	
+	exitCalled := false
+	exitCode := 0
+	exitFunc = func(code int) {
+		exitCalled = true
+		exitCode = code
+		// Panic to prevent continuing execution
+		panic("exit called")
+	}
+	
+	// Mock parseFlags to return update flag
+	parseFlags = func() (*bool, *bool) {
+		versionFlag := false
+		updateFlag := true
+		return &versionFlag, &updateFlag
+	}
+	
+	// Mock checkAndUpdate to return error
+	checkAndUpdateFunc = func() error {
+		return os.ErrNotExist
+	}
+	
+	// Mock executableDir (in case it continues)
+	executableDir = func() (string, error) {
+		return ".", nil
+	}
+	
+	defer func() {
+		// Recover from panic
+		if r := recover(); r != nil {
+			// Expected panic from exitFunc
+		}
+		exitFunc = originalExit
+		parseFlags = originalParseFlags
+		checkAndUpdateFunc = originalCheckAndUpdate
+		executableDir = originalExeDir
+	}()
+	
+	// Capture stderr
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	
+	// Wrap main() call to handle panic
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				// Expected panic
+			}
+		}()
+		main()
+	}()
+	
+	w.Close()
+	os.Stderr = originalStderr
+	output := make([]byte, 1024)
+	n, _ := r.Read(output)
+	output = output[:n]
+	
+	if !exitCalled {
+		t.Error("Expected exit to be called")
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", exitCode)
+	}
+	
+	outputStr := string(output)
+	if len(outputStr) == 0 {
+		t.Error("Expected error output")
+	}
+}
+
+// =============================================================================
+// FLAG PARSING TESTS
+// =============================================================================
+
+// Test the actual parseFlags function
+func TestParseFlags(t *testing.T) {
+	// Save original command line args
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	
+	// Reset flag.CommandLine to avoid flag redefined errors
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	
+	tests := []struct {
+		name           string
+		args           []string
+		expectVersion  bool
+		expectUpdate   bool
+	}{
+		{
+			name:          "no flags",
+			args:          []string{"secret_manager"},
+			expectVersion: false,
+			expectUpdate:  false,
+		},
+		{
+			name:          "version flag",
+			args:          []string{"secret_manager", "-version"},
+			expectVersion: true,
+			expectUpdate:  false,
+		},
+		{
+			name:          "update flag",
+			args:          []string{"secret_manager", "-update"},
+			expectVersion: false,
+			expectUpdate:  true,
+		},
+		{
+			name:          "both flags",
+			args:          []string{"secret_manager", "-version", "-update"},
+			expectVersion: true,
+			expectUpdate:  true,
+		},
+	}
+	
+	// Save original parseFlags
+	originalParseFlags := parseFlags
+	defer func() { parseFlags = originalParseFlags }()
+	
+	// Use the real parseFlags implementation
+	parseFlags = func() (*bool, *bool) {
+		// Reset flags for each test
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		versionFlag := flag.Bool("version", false, "Show version information")
+		updateFlag := flag.Bool("update", false, "Check for updates and install if available")
+		flag.Parse()
+		return versionFlag, updateFlag
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set command line args
+			os.Args = tt.args
+			
+			versionFlag, updateFlag := parseFlags()
+			
+			if *versionFlag != tt.expectVersion {
+				t.Errorf("Expected version flag %v, got %v", tt.expectVersion, *versionFlag)
+			}
+			if *updateFlag != tt.expectUpdate {
+				t.Errorf("Expected update flag %v, got %v", tt.expectUpdate, *updateFlag)
+			}
+		})
+	}
+}
+
+// TestDefaultParseFlags tests the defaultParseFlags function
+func TestDefaultParseFlags(t *testing.T) {
+	// Save original state
+	oldArgs := os.Args
+	oldCommandLine := flag.CommandLine
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = oldCommandLine
+	}()
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectVersion bool
+		expectUpdate  bool
+	}{
+		{
+			name:          "no flags",
+			args:          []string{"secret_manager"},
+			expectVersion: false,
+			expectUpdate:  false,
+		},
+		{
+			name:          "version flag",
+			args:          []string{"secret_manager", "-version"},
+			expectVersion: true,
+			expectUpdate:  false,
+		},
+		{
+			name:          "update flag",
+			args:          []string{"secret_manager", "-update"},
+			expectVersion: false,
+			expectUpdate:  true,
+		},
+		{
+			name:          "both flags",
+			args:          []string{"secret_manager", "-version", "-update"},
+			expectVersion: true,
+			expectUpdate:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set command line args
+			os.Args = tt.args
+			// Reset flag.CommandLine for each test
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+			versionFlag, updateFlag := defaultParseFlags()
+
+			if *versionFlag != tt.expectVersion {
+				t.Errorf("Expected version flag %v, got %v", tt.expectVersion, *versionFlag)
+			}
+			if *updateFlag != tt.expectUpdate {
+				t.Errorf("Expected update flag %v, got %v", tt.expectUpdate, *updateFlag)
+			}
+		})
+	}
+}
\ No newline at end of file
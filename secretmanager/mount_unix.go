@@ -0,0 +1,29 @@
+//go:build !windows
+
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// mountOf identifies the filesystem holding path by its device number,
+// looking at the nearest existing ancestor when path does not exist yet.
+// It returns "" if the filesystem cannot be determined
+func mountOf(path string) string {
+	for dir := path; ; {
+		if info, err := os.Stat(dir); err == nil {
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				return fmt.Sprintf("dev:%d", st.Dev)
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package secretmanager
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// mountOf identifies the filesystem holding path by its volume, e.g. "c:"
+// or a UNC share
+func mountOf(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(filepath.VolumeName(abs))
+}
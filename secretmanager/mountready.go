@@ -0,0 +1,45 @@
+package secretmanager
+
+import (
+	"time"
+)
+
+// defaultMountReadyTimeout is how long -check-mount-ready waits for a stat
+const defaultMountReadyTimeout = 2 * time.Second
+
+// mountReady reports whether a stat of dir returns within timeout. A mount
+// that is not ready yet can make any access to it hang, so the stat runs in
+// its own goroutine and is abandoned if it does not return in time. Whether
+// the stat succeeded does not matter, only that the filesystem answered
+func mountReady(dir string, timeout time.Duration) bool {
+	stat := statFunc
+	done := make(chan struct{})
+	go func() {
+		stat(dir)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// checkMountReady skips a target under -check-mount-ready when the
+// filesystem holding its directory does not respond
+func checkMountReady(dir string) bool {
+	if !opts.CheckMountReady {
+		return true
+	}
+	timeout := opts.MountReadyTimeout
+	if timeout <= 0 {
+		timeout = defaultMountReadyTimeout
+	}
+	if mountReady(dir, timeout) {
+		return true
+	}
+	warnTarget("Warning: mount not ready, skipping: %s did not respond within %s\n", dir, timeout)
+	return false
+}
@@ -0,0 +1,100 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// MOUNT READINESS TESTS
+// =============================================================================
+// Tests for skipping targets on filesystems that do not respond
+// =============================================================================
+
+func TestCreateSymlinkMountNotReady(t *testing.T) {
+	originalOpts := opts
+	originalStat := statFunc
+	originalSymlink := symlinkFunc
+	originalSummary := runSummary
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	hungDir := filepath.Join(tempDir, "hung")
+	readyDir := filepath.Join(tempDir, "ready")
+	os.MkdirAll(hungDir, 0755)
+	os.MkdirAll(readyDir, 0755)
+
+	// A stat of the hung mount blocks until the test ends
+	release := make(chan struct{})
+	statFunc = func(name string) (os.FileInfo, error) {
+		if name == hungDir {
+			<-release
+		}
+		return os.Stat(name)
+	}
+	var symlinks []string
+	symlinkFunc = func(oldname, newname string) error {
+		symlinks = append(symlinks, newname)
+		return mockSymlink(oldname, newname)
+	}
+	opts.NoOwnerCheck = true
+	opts.CheckMountReady = true
+	opts.MountReadyTimeout = 20 * time.Millisecond
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	defer func() {
+		close(release)
+		opts = originalOpts
+		statFunc = originalStat
+		symlinkFunc = originalSymlink
+		runSummary = originalSummary
+	}()
+
+	hungTarget := filepath.Join(hungDir, "api.key")
+	readyTarget := filepath.Join(readyDir, "api.key")
+	output := captureStdout(t, func() {
+		for _, path := range []string{hungTarget, readyTarget} {
+			if err := createSymlink(sourcePath, Target{Path: path}); err != nil {
+				t.Errorf("createSymlink(%s) error = %v", path, err)
+			}
+		}
+	})
+
+	if len(symlinks) != 1 || symlinks[0] != readyTarget {
+		t.Errorf("Expected only %s to be linked, got %v", readyTarget, symlinks)
+	}
+	if !strings.Contains(output, "mount not ready, skipping: "+hungDir) {
+		t.Errorf("Expected a mount not ready warning, got:\n%s", output)
+	}
+	if got := runSummary.Results[0]; got.Action != actionSkipped || got.Message != "mount not ready" {
+		t.Errorf("Expected the hung target to be skipped, got %+v", got)
+	}
+}
+
+func TestMountReady(t *testing.T) {
+	originalStat := statFunc
+	defer func() { statFunc = originalStat }()
+
+	statFunc = func(name string) (os.FileInfo, error) {
+		return nil, os.ErrNotExist
+	}
+	if !mountReady("/missing", time.Second) {
+		t.Error("Expected a stat that fails quickly to count as ready")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	statFunc = func(name string) (os.FileInfo, error) {
+		<-release
+		return nil, nil
+	}
+	if mountReady("/hung", 10*time.Millisecond) {
+		t.Error("Expected a hanging stat to time out")
+	}
+}
@@ -0,0 +1,89 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openFilesFunc is a variable to allow mocking in tests. It returns every path
+// held open by a process, with the IDs of the processes holding it
+var openFilesFunc = procOpenFiles
+
+// procOpenFiles finds the open files of every process by reading the
+// descriptor links under /proc. Processes this user may not inspect are left
+// out, so the answer is best-effort; without /proc it is an error
+func procOpenFiles() (map[string][]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	open := make(map[string][]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // Not a process
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		held := make(map[string]bool)
+		for _, fd := range fds {
+			if dest, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && !held[dest] {
+				held[dest] = true
+				open[dest] = append(open[dest], pid)
+			}
+		}
+	}
+	return open, nil
+}
+
+// openFiles returns the open files of every process, scanning them on first
+// use so that a run reads /proc once however many targets it checks
+func (s *RunSummary) openFiles() (map[string][]int, error) {
+	s.openFilesOnce.Do(func() {
+		s.openFilesIndex, s.openFilesErr = openFilesFunc()
+	})
+	return s.openFilesIndex, s.openFilesErr
+}
+
+// targetOpenBy returns the processes that have the existing target open.
+// Removing it from under them could break a service mid-read. A target that
+// is a symlink is not followed: the file it links to stays in place. When
+// the check is not possible nothing is reported
+func targetOpenBy(target Target) []int {
+	targetPath := target.Path
+	if _, err := lstatFunc(targetPath); err != nil {
+		return nil
+	}
+
+	open, err := runSummary.openFiles()
+	if err != nil {
+		target.out.logf(verboseDecisions, "Cannot check whether %s is open: %v\n", targetPath, err)
+		return nil
+	}
+	paths := []string{targetPath}
+	if abs, err := filepath.Abs(targetPath); err == nil && abs != targetPath {
+		paths = append(paths, abs)
+	}
+	var pids []int
+	for _, path := range paths {
+		pids = append(pids, open[path]...)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// formatPIDs lists process IDs for a message
+func formatPIDs(pids []int) string {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(ids, ", ")
+}
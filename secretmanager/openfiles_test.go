@@ -0,0 +1,160 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// OPEN FILE CHECK TESTS
+// =============================================================================
+// Tests for leaving targets that a running process has open with -check-open-files
+// =============================================================================
+
+func TestCreateSymlinkCheckOpenFiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		inUse      bool
+		wantAction string
+		wantTarget string
+	}{
+		{name: "in_use_target_skipped", inUse: true, wantAction: actionSkipped, wantTarget: "old content"},
+		{name: "free_target_replaced", wantAction: actionReplaced, wantTarget: "SYMLINK:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			sourcePath := filepath.Join(tempDir, "secret", "api.key")
+			targetPath := filepath.Join(tempDir, "app", "api.key")
+			createFile(t, sourcePath, "new content")
+			createFile(t, targetPath, "old content")
+
+			originalOpts := opts
+			originalSummary := runSummary
+			originalOpenFiles := openFilesFunc
+			scans := 0
+			openFilesFunc = func() (map[string][]int, error) {
+				scans++
+				if tt.inUse {
+					return map[string][]int{targetPath: {4242}}, nil
+				}
+				return map[string][]int{sourcePath: {4242}}, nil
+			}
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+				openFilesFunc = originalOpenFiles
+			}()
+			opts.NoOwnerCheck = true
+			opts.CheckOpenFiles = true
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+
+			var err error
+			output := captureStdout(t, func() {
+				err = createSymlink(sourcePath, Target{Path: targetPath})
+				// A second target in the same run reuses the scan
+				createSymlink(sourcePath, Target{Path: filepath.Join(tempDir, "app", "other.key")})
+			})
+			if err != nil {
+				t.Fatalf("createSymlink() error = %v", err)
+			}
+
+			if scans != 1 {
+				t.Errorf("Expected open files to be scanned once per run, got %d scans", scans)
+			}
+			if got := runSummary.Results[0].Action; got != tt.wantAction {
+				t.Errorf("Action = %q, want %q", got, tt.wantAction)
+			}
+			if tt.inUse && !strings.Contains(output, "is open by process 4242, skipping") {
+				t.Errorf("Expected a warning naming the process, got %q", output)
+			}
+			content, _ := os.ReadFile(targetPath)
+			if !strings.HasPrefix(string(content), tt.wantTarget) {
+				t.Errorf("Target content = %q, want prefix %q", content, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestProcOpenFiles(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("/proc is not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "held.key")
+	createFile(t, path, "secret")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := procOpenFiles()
+	if err != nil {
+		t.Fatalf("procOpenFiles() error = %v", err)
+	}
+	found := false
+	for _, pid := range open[path] {
+		found = found || pid == os.Getpid()
+	}
+	if !found {
+		t.Errorf("Expected this process (%d) to hold %s open, got %v", os.Getpid(), path, open[path])
+	}
+
+	f.Close()
+	open, _ = procOpenFiles()
+	for _, pid := range open[path] {
+		if pid == os.Getpid() {
+			t.Errorf("Expected %s to be free once closed, got %v", path, open[path])
+		}
+	}
+}
+
+func TestProcessSymlinkConfigAtomicCheckOpenFiles(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalOpenFiles := openFilesFunc
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		openFilesFunc = originalOpenFiles
+	}()
+	opts.NoOwnerCheck = true
+	opts.CheckOpenFiles = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.key")
+	second := filepath.Join(tempDir, "second.key")
+	createFile(t, second, "old content")
+	sourcePath, configPath := atomicFixture(t, tempDir, first, second)
+	openFilesFunc = func() (map[string][]int, error) {
+		return map[string][]int{second: {4242}}, nil
+	}
+
+	output := captureStdout(t, func() {
+		processSymlinkConfig(sourcePath, configPath)
+	})
+
+	if !strings.Contains(output, "is open by process 4242") {
+		t.Errorf("Expected the open target to be reported, got %q", output)
+	}
+	if _, err := os.Lstat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected no link while another target is open, got %v", err)
+	}
+	if data, _ := os.ReadFile(second); string(data) != "old content" {
+		t.Errorf("Expected the open target to be left alone, got %q", data)
+	}
+	if runSummary.count(actionFailed) != 2 {
+		t.Errorf("Expected both targets to fail, got %+v", runSummary.Results)
+	}
+	assertNoTempFiles(t, tempDir)
+}
@@ -0,0 +1,120 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Link classes of -audit-orphans
+const (
+	linkTracked  = "tracked"
+	linkOrphaned = "orphaned"
+	linkForeign  = "foreign"
+)
+
+// managedRoots returns the directories whose files are sources of managed
+// links: the secret directories and the directories of the planned sources
+func managedRoots(secretDirs []string, links []effectiveLink) []string {
+	var roots []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			roots = append(roots, dir)
+		}
+	}
+	for _, dir := range secretDirs {
+		add(dir)
+	}
+	for _, link := range links {
+		add(filepath.Dir(link.Source))
+	}
+	return roots
+}
+
+// classifyLink tells whether the symlink at path is a planned target, points
+// into a managed root without being planned, or points elsewhere. dest is
+// the symlink's destination, resolved against its directory
+func classifyLink(path, dest string, tracked map[string]bool, roots []string) string {
+	if tracked[path] {
+		return linkTracked
+	}
+	for _, root := range roots {
+		if dest == root || strings.HasPrefix(dest, root+string(filepath.Separator)) {
+			return linkOrphaned
+		}
+	}
+	return linkForeign
+}
+
+// writeOrphanAudit scans the directories of the planned targets for symlinks
+// that point into a managed source but are not declared by any manifest,
+// such as links of a target that was since removed from its manifest.
+// Nothing is changed. It writes a line per orphan or unreadable directory
+// followed by the totals, and reports whether none were found
+func writeOrphanAudit(w io.Writer, secretDirs []string, links []effectiveLink) bool {
+	roots := managedRoots(secretDirs, links)
+	tracked := make(map[string]bool)
+	var dirs []string
+	seenDir := make(map[string]bool)
+	for _, link := range links {
+		target := link.Target
+		if abs, err := filepath.Abs(target); err == nil {
+			target = abs
+		}
+		tracked[target] = true
+		if dir := filepath.Dir(target); !seenDir[dir] {
+			seenDir[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	counts := make(map[string]int)
+	checked, failed := 0, 0
+	for _, dir := range dirs {
+		entries, err := readDirFunc(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", dir, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			dest, err := readlinkFunc(path)
+			if err != nil {
+				failed++
+				fmt.Fprintf(w, "FAIL %s: %v\n", path, err)
+				continue
+			}
+			if !filepath.IsAbs(dest) {
+				dest = filepath.Join(dir, dest)
+			}
+			dest = filepath.Clean(dest)
+
+			checked++
+			class := classifyLink(path, dest, tracked, roots)
+			counts[class]++
+			if class == linkOrphaned {
+				fmt.Fprintf(w, "ORPHAN %s -> %s\n", path, dest)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Orphan audit: %d symlinks checked, %d tracked, %d orphaned, %d foreign\n",
+		checked, counts[linkTracked], counts[linkOrphaned], counts[linkForeign])
+	return counts[linkOrphaned] == 0 && failed == 0
+}
@@ -0,0 +1,113 @@
+package secretmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// ORPHAN AUDIT TESTS
+// =============================================================================
+// Tests for reporting undeclared symlinks into managed sources with -audit-orphans
+// =============================================================================
+
+func TestWriteOrphanAudit(t *testing.T) {
+	tempDir := t.TempDir()
+	secretDir := filepath.Join(tempDir, "app_secret")
+	targetDir := filepath.Join(tempDir, "config")
+	elsewhere := filepath.Join(tempDir, "elsewhere")
+	for _, dir := range []string{secretDir, targetDir, elsewhere} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, filepath.Join(secretDir, "api.key"), "tracked")
+	createFile(t, filepath.Join(secretDir, "old.key"), "no longer declared")
+	createFile(t, filepath.Join(elsewhere, "other.key"), "not ours")
+	createFile(t, filepath.Join(targetDir, "plain.txt"), "regular file")
+
+	links := map[string]string{
+		"api.key":   filepath.Join(secretDir, "api.key"),
+		"old.key":   filepath.Join("..", "app_secret", "old.key"), // relative links resolve against their directory
+		"other.key": filepath.Join(elsewhere, "other.key"),
+	}
+	for name, dest := range links {
+		if err := os.Symlink(dest, filepath.Join(targetDir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	originalReadlink := readlinkFunc
+	readlinkFunc = os.Readlink
+	defer func() { readlinkFunc = originalReadlink }()
+
+	planned := []effectiveLink{
+		{Source: filepath.Join(secretDir, "api.key"), Target: filepath.Join(targetDir, "api.key")},
+		// A target directory that does not exist yet is skipped
+		{Source: filepath.Join(secretDir, "api.key"), Target: filepath.Join(tempDir, "missing", "api.key")},
+	}
+
+	var buf bytes.Buffer
+	if writeOrphanAudit(&buf, []string{secretDir}, planned) {
+		t.Error("Expected the audit to fail with an orphaned link")
+	}
+	output := buf.String()
+
+	orphan := "ORPHAN " + filepath.Join(targetDir, "old.key") + " -> " + filepath.Join(secretDir, "old.key")
+	if !strings.Contains(output, orphan) {
+		t.Errorf("Expected %q in output, got:\n%s", orphan, output)
+	}
+	for _, name := range []string{"api.key", "other.key", "plain.txt"} {
+		if strings.Contains(output, "ORPHAN "+filepath.Join(targetDir, name)) {
+			t.Errorf("Did not expect %s to be reported, got:\n%s", name, output)
+		}
+	}
+	if !strings.Contains(output, "Orphan audit: 3 symlinks checked, 1 tracked, 1 orphaned, 1 foreign") {
+		t.Errorf("Expected totals in output, got:\n%s", output)
+	}
+
+	// Nothing is removed
+	if _, err := os.Lstat(filepath.Join(targetDir, "old.key")); err != nil {
+		t.Errorf("Expected the orphaned link to be left in place: %v", err)
+	}
+
+	// Once declared again, the link is tracked and the audit passes
+	planned = append(planned, effectiveLink{Source: filepath.Join(secretDir, "old.key"), Target: filepath.Join(targetDir, "old.key")})
+	buf.Reset()
+	if !writeOrphanAudit(&buf, []string{secretDir}, planned) {
+		t.Errorf("Expected the audit to pass, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "2 tracked, 0 orphaned, 1 foreign") {
+		t.Errorf("Expected totals in output, got:\n%s", buf.String())
+	}
+}
+
+func TestClassifyLink(t *testing.T) {
+	roots := []string{filepath.FromSlash("/srv/app_secret")}
+	tracked := map[string]bool{filepath.FromSlash("/etc/app/api.key"): true}
+
+	tests := []struct {
+		name string
+		path string
+		dest string
+		want string
+	}{
+		{"tracked", "/etc/app/api.key", "/srv/app_secret/api.key", linkTracked},
+		{"orphaned", "/etc/app/old.key", "/srv/app_secret/old.key", linkOrphaned},
+		{"orphaned_root", "/etc/app/secrets", "/srv/app_secret", linkOrphaned},
+		{"foreign", "/etc/app/ca.pem", "/usr/share/ca.pem", linkForeign},
+		{"foreign_sibling_prefix", "/etc/app/x.key", "/srv/app_secret_old/x.key", linkForeign},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyLink(filepath.FromSlash(tt.path), filepath.FromSlash(tt.dest), tracked, roots)
+			if got != tt.want {
+				t.Errorf("classifyLink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// orderedOutput collects the output of work units that run concurrently and
+// writes it in the order the units were submitted, so that the lines of one
+// unit are never interleaved with those of another. The oldest unfinished
+// unit writes through directly; later units are buffered until it finishes
+type orderedOutput struct {
+	mu    sync.Mutex
+	units []*outputUnit
+}
+
+// outputChunk is a piece of buffered output and the stream it is bound for
+type outputChunk struct {
+	w    io.Writer
+	data string
+}
+
+// outputUnit is the output of one work unit. A nil unit writes directly, as
+// when nothing runs concurrently
+type outputUnit struct {
+	parent *orderedOutput
+	chunks []outputChunk
+	done   bool
+}
+
+// directOutput is the nil unit, for output outside concurrent work
+var directOutput *outputUnit
+
+// silentOutput discards what a run would print to standard output, as Apply
+// reports its outcome through its result instead
+var silentOutput bool
+
+// outputWriter returns where progress output goes: standard output, looked
+// up on each call as Ansible mode redirects it, or nowhere under Apply
+func outputWriter() io.Writer {
+	if silentOutput {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// unit starts a new work unit, ordered after all earlier ones
+func (o *orderedOutput) unit() *outputUnit {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	u := &outputUnit{parent: o}
+	o.units = append(o.units, u)
+	return u
+}
+
+// write prints to w, or buffers it while an earlier unit is still running
+func (u *outputUnit) write(w io.Writer, format string, a ...interface{}) {
+	if u == nil {
+		fmt.Fprintf(w, format, a...)
+		return
+	}
+	o := u.parent
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.units) > 0 && o.units[0] == u {
+		fmt.Fprintf(w, format, a...)
+		return
+	}
+	u.chunks = append(u.chunks, outputChunk{w: w, data: fmt.Sprintf(format, a...)})
+}
+
+// close finishes the unit and flushes every finished unit that is no longer
+// waiting for an earlier one
+func (u *outputUnit) close() {
+	if u == nil {
+		return
+	}
+	o := u.parent
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	u.done = true
+	for len(o.units) > 0 {
+		head := o.units[0]
+		for _, chunk := range head.chunks {
+			io.WriteString(chunk.w, chunk.data)
+		}
+		head.chunks = nil
+		if !head.done {
+			return
+		}
+		o.units = o.units[1:]
+	}
+}
+
+// printTarget prints a per-target progress line unless -summary-only is set
+func (u *outputUnit) printTarget(format string, a ...interface{}) {
+	if opts.SummaryOnly {
+		return
+	}
+	u.write(outputWriter(), format, a...)
+}
+
+// warnTarget writes a per-target warning unless -summary-only is set
+func (u *outputUnit) warnTarget(format string, a ...interface{}) {
+	if opts.SummaryOnly {
+		return
+	}
+	u.write(warningsWriter(), format, a...)
+}
+
+// logf prints a diagnostic line when the verbosity is at least level
+func (u *outputUnit) logf(level int, format string, a ...interface{}) {
+	if opts.Verbosity < level {
+		return
+	}
+	u.write(outputWriter(), format, a...)
+}
@@ -0,0 +1,105 @@
+package secretmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// ORDERED OUTPUT TESTS
+// =============================================================================
+// Tests for keeping the output of concurrent work units together and in order
+// =============================================================================
+
+func TestOrderedOutput(t *testing.T) {
+	var buf bytes.Buffer
+	output := &orderedOutput{}
+	first, second, third := output.unit(), output.unit(), output.unit()
+
+	// Later units finish first; only the oldest one writes through
+	third.write(&buf, "third 1\n")
+	second.write(&buf, "second 1\n")
+	first.write(&buf, "first 1\n")
+	third.write(&buf, "third 2\n")
+	third.close()
+	second.write(&buf, "second 2\n")
+	if buf.String() != "first 1\n" {
+		t.Errorf("Expected only the first unit to be written, got %q", buf.String())
+	}
+
+	first.write(&buf, "first 2\n")
+	first.close()
+	// The second unit is now the oldest, so it writes through
+	second.write(&buf, "second 3\n")
+	second.close()
+
+	want := "first 1\nfirst 2\nsecond 1\nsecond 2\nsecond 3\nthird 1\nthird 2\n"
+	if buf.String() != want {
+		t.Errorf("Output = %q, want %q", buf.String(), want)
+	}
+
+	// A nil unit writes directly
+	buf.Reset()
+	directOutput.write(&buf, "direct %d\n", 1)
+	directOutput.close()
+	if buf.String() != "direct 1\n" {
+		t.Errorf("Expected direct output, got %q", buf.String())
+	}
+}
+
+func TestLinkTargetsOrderedOutput(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalSymlink := symlinkFunc
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	var targets []Target
+	for i := 0; i < 6; i++ {
+		targets = append(targets, Target{Path: filepath.Join(tempDir, fmt.Sprintf("link%d.key", i)), ResolveSource: true})
+	}
+
+	// Earlier targets take longer, so they finish last
+	symlinkFunc = func(oldname, newname string) error {
+		var i int
+		fmt.Sscanf(filepath.Base(newname), "link%d.key", &i)
+		time.Sleep(time.Duration(len(targets)-i) * 5 * time.Millisecond)
+		return mockSymlink(oldname, newname)
+	}
+
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		symlinkFunc = originalSymlink
+	}()
+	opts.NoOwnerCheck = true
+	opts.JobsPerMount = len(targets)
+	opts.Verbosity = verbosePaths
+	runSummary = &RunSummary{}
+
+	output := captureStdout(t, func() {
+		linkTargets(sourcePath, targets)
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2*len(targets) {
+		t.Fatalf("Expected two lines per target, got:\n%s", output)
+	}
+	for i, target := range targets {
+		resolved, created := lines[2*i], lines[2*i+1]
+		if !strings.HasPrefix(resolved, "Resolved source ") {
+			t.Errorf("Line %d = %q, want the resolved source of %s", 2*i, resolved, target.Path)
+		}
+		if !strings.HasPrefix(created, "Created symlink: "+target.Path+" ") {
+			t.Errorf("Line %d = %q, want the link of %s", 2*i+1, created, target.Path)
+		}
+	}
+}
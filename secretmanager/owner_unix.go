@@ -0,0 +1,135 @@
+//go:build !windows
+
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// chownFunc is a variable to allow mocking in tests
+var chownFunc = os.Chown
+
+// geteuidFunc is a variable to allow mocking in tests
+var geteuidFunc = os.Geteuid
+
+// lookupUserFunc is a variable to allow mocking in tests
+var lookupUserFunc = user.Lookup
+
+// lookupGroupFunc is a variable to allow mocking in tests
+var lookupGroupFunc = user.LookupGroup
+
+// checkDirOwner verifies that a target directory is owned by the expected user
+// (or root) and is not world-writable before a link is created inside it
+func checkDirOwner(dir string) error {
+	if opts.NoOwnerCheck {
+		return nil
+	}
+
+	info, err := statFunc(dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat target directory: %w", err)
+	}
+
+	if info.Mode().Perm()&0002 != 0 {
+		return fmt.Errorf("target directory %s is world-writable (%s)", dir, info.Mode().Perm())
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	expected := os.Getuid()
+	if opts.OwnerUID != nil {
+		expected = *opts.OwnerUID
+	}
+
+	if int(stat.Uid) != expected && stat.Uid != 0 {
+		return fmt.Errorf("target directory %s is owned by uid %d, expected %d", dir, stat.Uid, expected)
+	}
+
+	return nil
+}
+
+// applyTargetOwner changes the owner and group of the file a target resolves
+// to, as set by the target's owner and group fields. Names and numeric ids are
+// both accepted. Without root the change is skipped with a warning, or fails
+// the target under -strict
+func applyTargetOwner(target Target) error {
+	if target.Owner == "" && target.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if target.Owner != "" {
+		id, err := lookupID(target.Owner, func(name string) (string, error) {
+			u, err := lookupUserFunc(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown owner %q: %w", target.Owner, err)
+		}
+		uid = id
+	}
+	if target.Group != "" {
+		id, err := lookupID(target.Group, func(name string) (string, error) {
+			g, err := lookupGroupFunc(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown group %q: %w", target.Group, err)
+		}
+		gid = id
+	}
+
+	if geteuidFunc() != 0 {
+		if opts.Strict {
+			return fmt.Errorf("changing the owner of %s requires root", target.Path)
+		}
+		target.out.warnTarget("Warning: not running as root, skipping chown of %s\n", target.Path)
+		return nil
+	}
+
+	if err := chownFunc(target.Path, uid, gid); err != nil {
+		return fmt.Errorf("failed to change owner: %w", err)
+	}
+	target.out.logf(verboseDecisions, "Changed owner of %s to %d:%d\n", target.Path, uid, gid)
+	return nil
+}
+
+// lookupID returns the numeric id of name, which may already be numeric
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// checkFilePerms refuses manifests and sources that the group or others can
+// write, since anyone able to edit them could redirect or replace a secret
+func checkFilePerms(paths ...string) error {
+	for _, path := range paths {
+		info, err := statFunc(path)
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode().Perm(); mode&0022 != 0 {
+			return fmt.Errorf("%s is writable by group or others (%s)", path, mode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+//go:build !windows
+
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// TARGET DIRECTORY OWNERSHIP TESTS
+// =============================================================================
+// Tests for the ownership and permission checks on target directories
+// =============================================================================
+
+// ownedFileInfo implements os.FileInfo with a configurable owner and mode
+type ownedFileInfo struct {
+	uid  uint32
+	mode os.FileMode
+}
+
+func (m *ownedFileInfo) Name() string       { return "dir" }
+func (m *ownedFileInfo) Size() int64        { return 0 }
+func (m *ownedFileInfo) Mode() os.FileMode  { return m.mode | os.ModeDir }
+func (m *ownedFileInfo) ModTime() time.Time { return time.Now() }
+func (m *ownedFileInfo) IsDir() bool        { return true }
+func (m *ownedFileInfo) Sys() interface{}   { return &syscall.Stat_t{Uid: m.uid} }
+
+func TestCheckDirOwner(t *testing.T) {
+	otherUID := uint32(os.Getuid() + 1000)
+	configuredUID := int(otherUID)
+
+	tests := []struct {
+		name    string
+		info    os.FileInfo
+		options Options
+		errMsg  string
+	}{
+		{
+			name:    "owned_by_current_user",
+			info:    &ownedFileInfo{uid: uint32(os.Getuid()), mode: 0755},
+			options: Options{},
+		},
+		{
+			name:    "owned_by_root",
+			info:    &ownedFileInfo{uid: 0, mode: 0755},
+			options: Options{},
+		},
+		{
+			name:    "world_writable",
+			info:    &ownedFileInfo{uid: uint32(os.Getuid()), mode: 0777},
+			options: Options{},
+			errMsg:  "world-writable",
+		},
+		{
+			name:    "owned_by_other_user",
+			info:    &ownedFileInfo{uid: otherUID, mode: 0755},
+			options: Options{},
+			errMsg:  "is owned by uid",
+		},
+		{
+			name:    "owned_by_configured_uid",
+			info:    &ownedFileInfo{uid: otherUID, mode: 0755},
+			options: Options{OwnerUID: &configuredUID},
+		},
+		{
+			name:    "no_owner_check",
+			info:    &ownedFileInfo{uid: otherUID, mode: 0777},
+			options: Options{NoOwnerCheck: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalStat := statFunc
+			originalOpts := opts
+			statFunc = func(name string) (os.FileInfo, error) {
+				return tt.info, nil
+			}
+			opts = tt.options
+			defer func() {
+				statFunc = originalStat
+				opts = originalOpts
+			}()
+
+			err := checkDirOwner("/some/dir")
+			if tt.errMsg == "" && err != nil {
+				t.Errorf("checkDirOwner() unexpected error = %v", err)
+			}
+			if tt.errMsg != "" && (err == nil || !strings.Contains(err.Error(), tt.errMsg)) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// Test that createSymlink refuses a world-writable target directory
+func TestCreateSymlinkWorldWritableDir(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "source.txt")
+	createFile(t, sourcePath, "content")
+
+	// Owned by the current user, so only the mode matters
+	ownedDir := filepath.Join(tempDir, "owned")
+	os.MkdirAll(ownedDir, 0755)
+	if err := createSymlink(sourcePath, Target{Path: filepath.Join(ownedDir, "link.txt")}); err != nil {
+		t.Errorf("Expected link in owned directory to succeed, got %v", err)
+	}
+
+	openDir := filepath.Join(tempDir, "open")
+	os.MkdirAll(openDir, 0755)
+	os.Chmod(openDir, 0777)
+	err := createSymlink(sourcePath, Target{Path: filepath.Join(openDir, "link.txt")})
+	if err == nil || !strings.Contains(err.Error(), "world-writable") {
+		t.Errorf("Expected world-writable refusal, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(openDir, "link.txt")); err == nil {
+		t.Error("Link should not have been created in world-writable directory")
+	}
+}
+
+// Test that -check-file-perms refuses a manifest or source the group or
+// others can write, and lets secure ones through
+func TestProcessSymlinkConfigCheckFilePerms(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceMode os.FileMode
+		configMode os.FileMode
+		wantRefuse string
+	}{
+		{name: "secure_files_proceed", sourceMode: 0600, configMode: 0644},
+		{name: "world_writable_source_refused", sourceMode: 0666, configMode: 0644, wantRefuse: "source.key is writable by group or others (-rw-rw-rw-)"},
+		{name: "group_writable_manifest_refused", sourceMode: 0600, configMode: 0664, wantRefuse: "source.key.symlink.json is writable by group or others (-rw-rw-r--)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			sourcePath := filepath.Join(tempDir, "source.key")
+			configPath := sourcePath + ".symlink.json"
+			targetPath := filepath.Join(tempDir, "link.key")
+			createFile(t, sourcePath, "secret")
+			createFile(t, configPath, fmt.Sprintf(`{"targets":[{"path":%q}]}`, targetPath))
+			os.Chmod(sourcePath, tt.sourceMode)
+			os.Chmod(configPath, tt.configMode)
+
+			originalOpts := opts
+			originalSummary := runSummary
+			originalStat := statFunc
+			var statted []string
+			statFunc = func(name string) (os.FileInfo, error) {
+				statted = append(statted, filepath.Base(name))
+				return os.Stat(name)
+			}
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+				statFunc = originalStat
+			}()
+			opts.NoOwnerCheck = true
+			opts.CheckFilePerms = true
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+
+			output := captureStdout(t, func() {
+				if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+					t.Errorf("processSymlinkConfig() error = %v", err)
+				}
+			})
+
+			if len(statted) < 1 || statted[0] != "source.key.symlink.json" {
+				t.Errorf("Expected the manifest to be checked through statFunc, got %v", statted)
+			}
+			_, err := os.Lstat(targetPath)
+			if tt.wantRefuse == "" {
+				if err != nil {
+					t.Errorf("Expected the link to be created, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Error("Expected no link to be created")
+			}
+			if !strings.Contains(output, tt.wantRefuse) {
+				t.Errorf("Expected %q, got:\n%s", tt.wantRefuse, output)
+			}
+			if runSummary.count(actionFailed) != 1 {
+				t.Errorf("Expected the target to be recorded as failed, got %+v", runSummary.Results)
+			}
+		})
+	}
+}
+
+// Test that -check-file-perms refuses a writable manifest before running its
+// pre_hook, which could otherwise have been put there by anyone
+func TestProcessSymlinkConfigCheckFilePermsBeforePreHook(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "source.key")
+	configPath := sourcePath + ".symlink.json"
+	createFile(t, configPath, fmt.Sprintf(`{"pre_hook":"fetch-secret","targets":[{"path":%q}]}`, filepath.Join(tempDir, "link.key")))
+	os.Chmod(configPath, 0666)
+
+	originalOpts := opts
+	originalSummary := runSummary
+	originalRun := runCommand
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		runCommand = originalRun
+	}()
+	runCommand = func(command string, env []string) error {
+		t.Errorf("Expected the pre-hook not to run, got %q", command)
+		return nil
+	}
+	opts.NoOwnerCheck = true
+	opts.CheckFilePerms = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	output := captureStdout(t, func() {
+		if err := processSymlinkConfig(sourcePath, configPath); err != nil {
+			t.Errorf("processSymlinkConfig() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "source.key.symlink.json is writable by group or others") {
+		t.Errorf("Expected the manifest to be refused, got:\n%s", output)
+	}
+	if runSummary.count(actionFailed) != 1 {
+		t.Errorf("Expected the target to be recorded as failed, got %+v", runSummary.Results)
+	}
+}
+
+func TestCheckDirOwnerStatError(t *testing.T) {
+	err := checkDirOwner(filepath.Join(os.TempDir(), "does-not-exist-owner-check"))
+	if err == nil || !strings.Contains(err.Error(), "failed to stat target directory") {
+		t.Errorf("Expected stat error, got %v", err)
+	}
+}
+
+func TestApplyTargetOwner(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    Target
+		euid      int
+		strict    bool
+		wantChown []int // uid and gid passed to chown, nil if not called
+		errMsg    string
+	}{
+		{name: "no owner or group", target: Target{Path: "/etc/app/key"}},
+		{name: "user and group names", target: Target{Path: "/etc/app/key", Owner: "app", Group: "app-readers"}, wantChown: []int{1500, 1600}},
+		{name: "numeric ids", target: Target{Path: "/etc/app/key", Owner: "42", Group: "43"}, wantChown: []int{42, 43}},
+		{name: "group only", target: Target{Path: "/etc/app/key", Group: "app-readers"}, wantChown: []int{-1, 1600}},
+		{name: "unknown user", target: Target{Path: "/etc/app/key", Owner: "nobody-here"}, errMsg: `unknown owner "nobody-here"`},
+		{name: "unknown group", target: Target{Path: "/etc/app/key", Group: "nobody-here"}, errMsg: `unknown group "nobody-here"`},
+		{name: "not root", target: Target{Path: "/etc/app/key", Owner: "app"}, euid: 1000},
+		{name: "not root under strict", target: Target{Path: "/etc/app/key", Owner: "app"}, euid: 1000, strict: true, errMsg: "requires root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			originalChown := chownFunc
+			originalEuid := geteuidFunc
+			originalLookupUser := lookupUserFunc
+			originalLookupGroup := lookupGroupFunc
+			defer func() {
+				opts = originalOpts
+				chownFunc = originalChown
+				geteuidFunc = originalEuid
+				lookupUserFunc = originalLookupUser
+				lookupGroupFunc = originalLookupGroup
+			}()
+
+			opts.Strict = tt.strict
+			opts.WarningsTo = "stdout"
+			geteuidFunc = func() int { return tt.euid }
+			lookupUserFunc = func(name string) (*user.User, error) {
+				if name == "app" {
+					return &user.User{Username: name, Uid: "1500"}, nil
+				}
+				return nil, user.UnknownUserError(name)
+			}
+			lookupGroupFunc = func(name string) (*user.Group, error) {
+				if name == "app-readers" {
+					return &user.Group{Name: name, Gid: "1600"}, nil
+				}
+				return nil, user.UnknownGroupError(name)
+			}
+			var chowned []int
+			chownFunc = func(name string, uid, gid int) error {
+				if name != tt.target.Path {
+					t.Errorf("Expected chown of %s, got %s", tt.target.Path, name)
+				}
+				chowned = []int{uid, gid}
+				return nil
+			}
+
+			var err error
+			output := captureStdout(t, func() {
+				err = applyTargetOwner(tt.target)
+			})
+
+			if tt.errMsg == "" && err != nil {
+				t.Errorf("applyTargetOwner() unexpected error = %v", err)
+			}
+			if tt.errMsg != "" && (err == nil || !strings.Contains(err.Error(), tt.errMsg)) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+			if len(chowned) != len(tt.wantChown) || (chowned != nil && (chowned[0] != tt.wantChown[0] || chowned[1] != tt.wantChown[1])) {
+				t.Errorf("Expected chown %v, got %v", tt.wantChown, chowned)
+			}
+			if tt.euid != 0 && !tt.strict && !strings.Contains(output, "skipping chown") {
+				t.Errorf("Expected a warning when not root, got %q", output)
+			}
+		})
+	}
+}
+
+// Test that a chown failure fails the target after the link is created
+func TestCreateSymlinkChownError(t *testing.T) {
+	originalOpts := opts
+	originalChown := chownFunc
+	originalEuid := geteuidFunc
+	defer func() {
+		opts = originalOpts
+		chownFunc = originalChown
+		geteuidFunc = originalEuid
+	}()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	sourcePath := filepath.Join(tempDir, "source.txt")
+	createFile(t, sourcePath, "content")
+
+	opts.NoOwnerCheck = true
+	geteuidFunc = func() int { return 0 }
+	chownFunc = func(name string, uid, gid int) error {
+		return syscall.EPERM
+	}
+
+	err := createSymlink(sourcePath, Target{Path: filepath.Join(tempDir, "link.txt"), Owner: "0"})
+	if err == nil || !strings.Contains(err.Error(), "failed to change owner") {
+		t.Errorf("Expected chown error, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package secretmanager
+
+// checkDirOwner is a no-op on Windows, where POSIX ownership does not apply
+func checkDirOwner(dir string) error {
+	return nil
+}
+
+// applyTargetOwner is a no-op on Windows, where POSIX ownership does not apply
+func applyTargetOwner(target Target) error {
+	return nil
+}
+
+// checkFilePerms is a no-op on Windows, where POSIX modes do not apply
+func checkFilePerms(paths ...string) error {
+	return nil
+}
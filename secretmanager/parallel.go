@@ -0,0 +1,54 @@
+package secretmanager
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// mountKey is a variable to allow mocking in tests
+var mountKey = mountOf
+
+// linkTargets links each target to sourcePath. With -jobs-per-mount above 1,
+// targets are linked concurrently, with a separate limit for each filesystem
+// so that a slow network mount does not hold up local disks. The output of
+// each target is then kept together and printed in manifest order, unless
+// -unordered-output is set
+func linkTargets(sourcePath string, targets []Target) {
+	if opts.JobsPerMount <= 1 {
+		for _, target := range targets {
+			if err := createSymlink(sourcePath, target); err != nil {
+				reportLinkError(target, err)
+			}
+		}
+		return
+	}
+
+	limits := make(map[string]chan struct{})
+	var wg sync.WaitGroup
+	output := &orderedOutput{}
+	for _, target := range targets {
+		mount := mountKey(filepath.Dir(target.Path))
+		limit, ok := limits[mount]
+		if !ok {
+			limit = make(chan struct{}, opts.JobsPerMount)
+			limits[mount] = limit
+		}
+		logf(verboseDecisions, "Scheduling %s on filesystem %q\n", target.Path, mount)
+		if !opts.UnorderedOutput {
+			target.out = output.unit()
+		}
+
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			defer target.out.close()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			if err := createSymlink(sourcePath, target); err != nil {
+				reportLinkError(target, err)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,136 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// PARALLEL LINKING TESTS
+// =============================================================================
+// Tests for linking targets concurrently with -jobs-per-mount
+// =============================================================================
+
+func TestLinkTargetsJobsPerMount(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	originalMountKey := mountKey
+	originalSymlink := symlinkFunc
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+
+	// Two directories stand in for a local disk and a network mount
+	var targets []Target
+	for _, mount := range []string{"local", "nfs"} {
+		os.MkdirAll(filepath.Join(tempDir, mount), 0755)
+		for i := 0; i < 5; i++ {
+			targets = append(targets, Target{Path: filepath.Join(tempDir, mount, fmt.Sprintf("link%d.key", i))})
+		}
+	}
+
+	var mu sync.Mutex
+	active := make(map[string]int)
+	peak := make(map[string]int)
+	total, totalPeak := 0, 0
+	mountKey = func(dir string) string {
+		return filepath.Base(dir)
+	}
+	symlinkFunc = func(oldname, newname string) error {
+		mount := filepath.Base(filepath.Dir(newname))
+		mu.Lock()
+		active[mount]++
+		total++
+		if active[mount] > peak[mount] {
+			peak[mount] = active[mount]
+		}
+		if total > totalPeak {
+			totalPeak = total
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active[mount]--
+		total--
+		mu.Unlock()
+		return mockSymlink(oldname, newname)
+	}
+
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		mountKey = originalMountKey
+		symlinkFunc = originalSymlink
+	}()
+	opts.NoOwnerCheck = true
+	opts.JobsPerMount = 2
+	runSummary = &RunSummary{}
+
+	captureStdout(t, func() {
+		linkTargets(sourcePath, targets)
+	})
+
+	if got := runSummary.count(actionCreated); got != len(targets) {
+		t.Errorf("Expected %d links, got %d: %+v", len(targets), got, runSummary.Results)
+	}
+	for _, mount := range []string{"local", "nfs"} {
+		if peak[mount] > opts.JobsPerMount {
+			t.Errorf("Expected at most %d concurrent links on %s, got %d", opts.JobsPerMount, mount, peak[mount])
+		}
+	}
+	// Each filesystem has its own limit rather than sharing a global one
+	if totalPeak <= opts.JobsPerMount {
+		t.Errorf("Expected the two mounts to be linked in parallel, peak was %d", totalPeak)
+	}
+}
+
+func TestLinkTargetsSequentialByDefault(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	sourcePath := filepath.Join(tempDir, "api.key")
+	createFile(t, sourcePath, "key")
+
+	var targets []Target
+	for i := 0; i < 3; i++ {
+		targets = append(targets, Target{Path: filepath.Join(tempDir, fmt.Sprintf("link%d.key", i))})
+	}
+
+	output := captureStdout(t, func() {
+		linkTargets(sourcePath, targets)
+	})
+
+	// Without -jobs-per-mount the output keeps manifest order
+	var order []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Created symlink: ") {
+			order = append(order, strings.Fields(line)[2])
+		}
+	}
+	if len(order) != len(targets) {
+		t.Fatalf("Expected %d links, got output:\n%s", len(targets), output)
+	}
+	for i, target := range targets {
+		if order[i] != target.Path {
+			t.Errorf("Expected link %d to be %s, got %s", i, target.Path, order[i])
+		}
+	}
+}
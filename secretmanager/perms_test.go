@@ -0,0 +1,184 @@
+package secretmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// PERMISSION ENFORCEMENT TESTS
+// =============================================================================
+// Tests for correcting drifted source and target permissions with -enforce-perms
+// =============================================================================
+
+func TestWritePermEnforcement(t *testing.T) {
+	tests := []struct {
+		name          string
+		dryRun        bool
+		drift         bool
+		wantChmod     []string
+		wantOutput    string
+		wantSourceEnd os.FileMode
+	}{
+		{
+			name:          "drifted_permissions_corrected",
+			drift:         true,
+			wantChmod:     []string{"source.key"},
+			wantOutput:    "Corrected permissions of %s: -rw-rw-rw- -> 0600",
+			wantSourceEnd: 0600,
+		},
+		{
+			name:          "correct_permissions_left_alone",
+			wantSourceEnd: 0600,
+		},
+		{
+			name:          "dry_run_reports_only",
+			dryRun:        true,
+			drift:         true,
+			wantOutput:    "Would correct permissions of %s",
+			wantSourceEnd: 0666,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			source := filepath.Join(tempDir, "source.key")
+			target := filepath.Join(tempDir, "target.key")
+			createFile(t, source, "secret")
+			createFile(t, target, "secret")
+			os.Chmod(target, 0640)
+			if tt.drift {
+				os.Chmod(source, 0666)
+			} else {
+				os.Chmod(source, 0600)
+			}
+
+			originalOpts := opts
+			originalChmod := chmodFunc
+			var chmodded []string
+			chmodFunc = func(name string, mode os.FileMode) error {
+				chmodded = append(chmodded, filepath.Base(name))
+				return os.Chmod(name, mode)
+			}
+			defer func() {
+				opts = originalOpts
+				chmodFunc = originalChmod
+			}()
+			opts.DryRun = tt.dryRun
+
+			links := []effectiveLink{
+				{Source: source, Target: target, SourcePerm: "0600", Perm: "0640"},
+				// A second target of the same source is only checked once
+				{Source: source, Target: filepath.Join(tempDir, "unmanaged.key"), SourcePerm: "0600"},
+			}
+			var buf bytes.Buffer
+			if !writePermEnforcement(&buf, links) {
+				t.Errorf("Expected no failures, got:\n%s", buf.String())
+			}
+			output := buf.String()
+
+			if strings.Join(chmodded, ",") != strings.Join(tt.wantChmod, ",") {
+				t.Errorf("Expected chmod of %v, got %v", tt.wantChmod, chmodded)
+			}
+			if tt.wantOutput != "" {
+				want := strings.Replace(tt.wantOutput, "%s", source, 1)
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected output containing %q, got:\n%s", want, output)
+				}
+			}
+			if !strings.Contains(output, "Permissions: 2 checked") {
+				t.Errorf("Expected the source and target to be checked, got:\n%s", output)
+			}
+			info, _ := os.Stat(source)
+			if info.Mode().Perm() != tt.wantSourceEnd {
+				t.Errorf("Expected source mode %o, got %o", tt.wantSourceEnd, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestWritePermEnforcementMissingFile(t *testing.T) {
+	links := []effectiveLink{{Source: "/nonexistent/source.key", Target: "/nonexistent/target.key", SourcePerm: "0600"}}
+	var buf bytes.Buffer
+	if writePermEnforcement(&buf, links) {
+		t.Error("Expected a missing source to fail")
+	}
+	if !strings.Contains(buf.String(), "FAIL /nonexistent/source.key") {
+		t.Errorf("Expected a FAIL line, got:\n%s", buf.String())
+	}
+}
+
+func TestWritePermEnforcementSymlinkTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourcePerm string
+		perm       string
+		wantOK     bool
+		wantOutput string
+	}{
+		{
+			name:       "matching_perm_not_applied_through_link",
+			sourcePerm: "0600",
+			perm:       "0600",
+			wantOK:     true,
+			wantOutput: "Permissions: 1 checked",
+		},
+		{
+			name:       "conflicting_perm_reported",
+			sourcePerm: "0600",
+			perm:       "0644",
+			wantOutput: "perm 0644 conflicts with source_perm 0600",
+		},
+		{
+			name:       "perm_without_source_perm_skipped",
+			perm:       "0644",
+			wantOK:     true,
+			wantOutput: "Permissions: 0 checked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			source := filepath.Join(tempDir, "source.key")
+			target := filepath.Join(tempDir, "target.key")
+			createFile(t, source, "secret")
+			os.Chmod(source, 0600)
+			if err := symlinkFunc(source, target); err != nil {
+				t.Fatalf("Failed to link target: %v", err)
+			}
+
+			originalChmod := chmodFunc
+			var chmodded []string
+			chmodFunc = func(name string, mode os.FileMode) error {
+				chmodded = append(chmodded, filepath.Base(name))
+				return os.Chmod(name, mode)
+			}
+			defer func() { chmodFunc = originalChmod }()
+
+			links := []effectiveLink{{Source: source, Target: target, SourcePerm: tt.sourcePerm, Perm: tt.perm}}
+			var buf bytes.Buffer
+			if ok := writePermEnforcement(&buf, links); ok != tt.wantOK {
+				t.Errorf("Expected ok=%v, got:\n%s", tt.wantOK, buf.String())
+			}
+			if !strings.Contains(buf.String(), tt.wantOutput) {
+				t.Errorf("Expected output containing %q, got:\n%s", tt.wantOutput, buf.String())
+			}
+			if len(chmodded) != 0 {
+				t.Errorf("Expected no chmod through the link, got %v", chmodded)
+			}
+			info, _ := os.Stat(source)
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected source mode 0600, got %o", info.Mode().Perm())
+			}
+		})
+	}
+}
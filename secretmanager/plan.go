@@ -0,0 +1,124 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// readPlanFile loads the links saved with -plan-file
+func readPlanFile(path string) ([]effectiveLink, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	if data, err = maybeGunzip(data); err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan effectiveManifest
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	return plan.Links, nil
+}
+
+// applyPlanFile creates exactly the links saved with -plan-file, without
+// reading the manifests again, so that a reviewed plan is what gets applied
+// even if the manifests have changed since
+func applyPlanFile(path string) error {
+	links, err := readPlanFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Links of one source are applied together, as a manifest would be,
+	// and those of a link group together across its sources
+	for _, group := range groupPlanBySource(links) {
+		if name := group[0].LinkGroup; name != "" {
+			applyPlannedGroup(name, group)
+			continue
+		}
+		sourcePath := group[0].Source
+		targets := make([]Target, 0, len(group))
+		for _, link := range group {
+			targets = append(targets, plannedTarget(link))
+		}
+
+		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+			warnTarget("Warning: Source file %s does not exist, skipping\n", sourcePath)
+			continue
+		}
+		if group[0].Atomic && !opts.DryRun {
+			if err := linkTargetsAtomically(sourcePath, targets); err != nil {
+				warnTarget("Failed to link %s, no targets were changed: %v\n", sourcePath, err)
+			}
+		} else {
+			linkTargets(sourcePath, targets)
+		}
+
+		if perm := group[0].SourcePerm; perm != "" && !opts.DryRun {
+			mode, err := parsePerm(perm)
+			if err != nil {
+				runSummary.recordManifestError(sourcePath, fmt.Errorf("invalid source_perm: %w", err))
+				continue
+			}
+			if err := chmodFunc(sourcePath, mode); err != nil {
+				runSummary.recordManifestError(sourcePath, fmt.Errorf("failed to set source permissions: %w", err))
+				continue
+			}
+			printTarget("Set permissions of %s to %s\n", sourcePath, mode)
+		}
+	}
+	return nil
+}
+
+// plannedTarget returns the target a planned link was expanded to
+func plannedTarget(link effectiveLink) Target {
+	return Target{
+		Path:           link.Target,
+		Description:    link.Description,
+		ResolveSource:  link.ResolveSource,
+		Retries:        link.Retries,
+		Owner:          link.Owner,
+		Group:          link.Group,
+		Optional:       link.Optional,
+		Perm:           link.Perm,
+		VerifyReadable: link.VerifyReadable,
+	}
+}
+
+// applyPlannedGroup links the planned links of a link group together, or
+// none of them if a source is missing
+func applyPlannedGroup(name string, links []effectiveLink) {
+	items := make([]atomicItem, 0, len(links))
+	var missing error
+	for _, link := range links {
+		items = append(items, atomicItem{source: link.Source, target: plannedTarget(link)})
+		if _, err := os.Stat(link.Source); os.IsNotExist(err) && missing == nil {
+			missing = fmt.Errorf("source %s does not exist", link.Source)
+		}
+	}
+	linkGroupItems(name, items, missing)
+}
+
+// groupPlanBySource splits links into runs that share a source, or for a link
+// group its manifest and name, keeping the order of the plan
+func groupPlanBySource(links []effectiveLink) [][]effectiveLink {
+	var groups [][]effectiveLink
+	index := make(map[string]int)
+	for _, link := range links {
+		key := link.Source
+		if link.LinkGroup != "" {
+			key = link.Manifest + "\x00" + link.LinkGroup
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], link)
+	}
+	return groups
+}
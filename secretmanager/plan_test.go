@@ -0,0 +1,161 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// PLAN FILE TESTS
+// =============================================================================
+// Tests for saving a plan with -plan-file and applying it with -apply-plan
+// =============================================================================
+
+func TestMainPlanFileRoundTrip(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalSymlink := symlinkFunc
+	originalOpen := openFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	reviewed := filepath.Join(tempDir, "reviewed.key")
+	changed := filepath.Join(tempDir, "changed.key")
+	manifest := filepath.Join(tempDir, "app_secret", "api.key.symlink.json")
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key"), "key")
+	createFile(t, manifest, fmt.Sprintf(`{"targets":[{"path":%q,"description":"reviewed","verify_readable":true}]}`, reviewed))
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	var symlinks []string
+	symlinkFunc = func(oldname, newname string) error {
+		symlinks = append(symlinks, newname)
+		return mockSymlink(oldname, newname)
+	}
+	var opened []string
+	openFunc = func(name string) (*os.File, error) {
+		opened = append(opened, name)
+		return os.Open(name)
+	}
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		symlinkFunc = originalSymlink
+		openFunc = originalOpen
+		opts = originalOpts
+	}()
+
+	// Saving the plan creates nothing
+	planFile := filepath.Join(tempDir, "plan.json")
+	opts = originalOpts
+	opts.NoOwnerCheck = true
+	opts.PlanFile = planFile
+	output := captureStdout(t, main)
+	if exitCode != -1 {
+		t.Fatalf("Expected no exit, got %d:\n%s", exitCode, output)
+	}
+	if len(symlinks) != 0 {
+		t.Errorf("Expected -plan-file to create no links, got %v", symlinks)
+	}
+	if _, err := os.Stat(planFile); err != nil {
+		t.Fatalf("Expected the plan to be written: %v", err)
+	}
+
+	// The manifest changes after the plan was reviewed
+	createFile(t, manifest, fmt.Sprintf(`{"targets":[{"path":%q}]}`, changed))
+
+	opts = originalOpts
+	opts.NoOwnerCheck = true
+	opts.ApplyPlan = planFile
+	output = captureStdout(t, main)
+	if exitCode != -1 {
+		t.Fatalf("Expected no exit, got %d:\n%s", exitCode, output)
+	}
+	if len(symlinks) != 1 || symlinks[0] != reviewed {
+		t.Errorf("Expected only the planned link %s, got %v", reviewed, symlinks)
+	}
+	if _, err := os.Stat(changed); !os.IsNotExist(err) {
+		t.Errorf("Expected the changed manifest to be ignored, got %v", err)
+	}
+	if !strings.Contains(output, "Created symlink: "+reviewed) || !strings.Contains(output, "(reviewed)") {
+		t.Errorf("Expected the planned link to be reported, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Summary: created 1, replaced 0, skipped 0, failed 0") {
+		t.Errorf("Expected the summary of the applied plan, got:\n%s", output)
+	}
+	if len(opened) != 1 || opened[0] != reviewed {
+		t.Errorf("Expected verify_readable to be kept in the plan, got opened %v", opened)
+	}
+}
+
+func TestApplyPlanFileErrors(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := applyPlanFile(filepath.Join(tempDir, "missing.json")); err == nil || !strings.Contains(err.Error(), "failed to read plan") {
+		t.Errorf("Expected a read error, got %v", err)
+	}
+
+	invalid := filepath.Join(tempDir, "invalid.json")
+	createFile(t, invalid, "{")
+	if err := applyPlanFile(invalid); err == nil || !strings.Contains(err.Error(), "failed to parse plan") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+}
+
+func TestGroupPlanBySource(t *testing.T) {
+	groups := groupPlanBySource([]effectiveLink{
+		{Source: "b", Target: "1"},
+		{Source: "a", Target: "2"},
+		{Source: "b", Target: "3"},
+	})
+	if len(groups) != 2 || len(groups[0]) != 2 || groups[0][1].Target != "3" || groups[1][0].Source != "a" {
+		t.Errorf("Unexpected groups %v", groups)
+	}
+}
+
+func TestPlanFileKeepsPriorityWinner(t *testing.T) {
+	originalOpts := opts
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+	}()
+	opts.NoOwnerCheck = true
+	runSummary = &RunSummary{}
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// a.key sorts first by path but has the higher priority, so it is
+	// linked last and wins the shared target
+	secretDir := filepath.Join(tempDir, "app_secret")
+	shared := filepath.Join(tempDir, "shared.key")
+	createFile(t, filepath.Join(secretDir, "a.key"), "a")
+	createFile(t, filepath.Join(secretDir, "b.key"), "b")
+	createFile(t, filepath.Join(secretDir, "a.key.symlink.json"), fmt.Sprintf(`{"priority":10,"targets":[{"path":%q}]}`, shared))
+	createFile(t, filepath.Join(secretDir, "b.key.symlink.json"), fmt.Sprintf(`{"targets":[{"path":%q}]}`, shared))
+
+	planFile := filepath.Join(tempDir, "plan.json")
+	if err := writeEffectiveManifest(planFile, []string{secretDir}, false); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() {
+		if err := applyPlanFile(planFile); err != nil {
+			t.Errorf("applyPlanFile() error = %v", err)
+		}
+	})
+
+	if source, _ := mockReadlink(shared); source != filepath.Join(secretDir, "a.key") {
+		t.Errorf("Expected the higher priority source to win, got %q", source)
+	}
+}
@@ -0,0 +1,32 @@
+package secretmanager
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// probeSuffix is appended to the throwaway link created by -dry-run-probe
+const probeSuffix = ".sm-probe"
+
+// probeLink confirms that a link to sourcePath could be created next to
+// targetPath by creating a throwaway symlink in the same directory and
+// removing it again straight away
+func probeLink(sourcePath, targetPath string) error {
+	probePath := filepath.Join(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+probeSuffix)
+	removeFunc(probePath) // left over from an interrupted run
+	if err := symlinkFunc(sourcePath, probePath); err != nil {
+		return err
+	}
+	if err := removeFunc(probePath); err != nil {
+		return fmt.Errorf("failed to remove probe link %s: %w", probePath, err)
+	}
+	return nil
+}
+
+// validateDryRunProbe checks the -dry-run-probe option
+func validateDryRunProbe() error {
+	if opts.DryRunProbe && !opts.DryRun {
+		return fmt.Errorf("-dry-run-probe requires -dry-run")
+	}
+	return nil
+}
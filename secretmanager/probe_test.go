@@ -0,0 +1,98 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// =============================================================================
+// DRY-RUN PROBE TESTS
+// =============================================================================
+// Tests for confirming planned links with a throwaway link in -dry-run
+// =============================================================================
+
+func TestDryRunProbe(t *testing.T) {
+	originalOpts := opts
+	originalSymlink := symlinkFunc
+	originalSummary := runSummary
+	defer func() {
+		opts = originalOpts
+		symlinkFunc = originalSymlink
+		runSummary = originalSummary
+	}()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	sourcePath := filepath.Join(tempDir, "secret", "api.key")
+	createFile(t, sourcePath, "key")
+	writableDir := filepath.Join(tempDir, "writable")
+	lockedDir := filepath.Join(tempDir, "locked")
+	os.MkdirAll(writableDir, 0755)
+	os.MkdirAll(lockedDir, 0755)
+
+	// Links in the locked directory are refused as they would be without
+	// write permission
+	var probes []string
+	symlinkFunc = func(oldname, newname string) error {
+		probes = append(probes, newname)
+		if filepath.Dir(newname) == lockedDir {
+			return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EACCES}
+		}
+		return mockSymlink(oldname, newname)
+	}
+	opts.NoOwnerCheck = true
+	opts.DryRun = true
+	opts.DryRunProbe = true
+	opts.WarningsTo = "stdout"
+	runSummary = &RunSummary{}
+
+	writableTarget := filepath.Join(writableDir, "api.key")
+	lockedTarget := filepath.Join(lockedDir, "api.key")
+	output := captureStdout(t, func() {
+		for _, path := range []string{writableTarget, lockedTarget} {
+			if err := createSymlink(sourcePath, Target{Path: path}); err != nil {
+				t.Errorf("createSymlink(%s) error = %v", path, err)
+			}
+		}
+	})
+
+	if len(probes) != 2 {
+		t.Errorf("Expected a probe per target, got %v", probes)
+	}
+	if !strings.Contains(output, "Probe: "+writableTarget+" would succeed") {
+		t.Errorf("Expected the writable target to succeed, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Probe: "+lockedTarget+" would fail (") || !strings.Contains(output, "permission denied") {
+		t.Errorf("Expected the locked target to fail with the reason, got:\n%s", output)
+	}
+	if got := runSummary.Results[0].Action; got != actionPlanned {
+		t.Errorf("Expected the writable target to be planned, got %q", got)
+	}
+	if got := runSummary.Results[1]; got.Action != actionFailed || !strings.Contains(got.Message, "probe failed") {
+		t.Errorf("Expected the locked target to fail, got %+v", got)
+	}
+
+	// Neither the targets nor the probe links are left behind
+	for _, dir := range []string{writableDir, lockedDir} {
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("Expected %s to be left empty, found %d entries", dir, len(entries))
+		}
+	}
+}
+
+func TestValidateDryRunProbe(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	opts.DryRunProbe = true
+	if err := validateDryRunProbe(); err == nil {
+		t.Error("Expected -dry-run-probe without -dry-run to be rejected")
+	}
+	opts.DryRun = true
+	if err := validateDryRunProbe(); err != nil {
+		t.Errorf("Expected -dry-run-probe with -dry-run to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package secretmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// RATE LIMIT TESTS
+// =============================================================================
+// Tests for explaining an exhausted GitHub API rate limit
+// =============================================================================
+
+func TestGetLatestReleaseRateLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(17 * time.Minute)
+
+	tests := []struct {
+		name       string
+		status     int
+		remaining  string
+		token      string
+		wantErr    string
+		notWantErr string
+	}{
+		{
+			name:      "rate_limited",
+			status:    http.StatusForbidden,
+			remaining: "0",
+			wantErr:   "GitHub API rate limit exceeded; it resets at " + reset.Local().Format(time.RFC3339) + " (in 17m0s); set GITHUB_TOKEN",
+		},
+		{
+			name:      "secondary_limit_429",
+			status:    http.StatusTooManyRequests,
+			remaining: "0",
+			wantErr:   "rate limit exceeded",
+		},
+		{
+			name:       "rate_limited_with_token",
+			status:     http.StatusForbidden,
+			remaining:  "0",
+			token:      "ghp_test",
+			wantErr:    "rate limit exceeded; it resets at",
+			notWantErr: "set GITHUB_TOKEN",
+		},
+		{
+			name:       "other_forbidden",
+			status:     http.StatusForbidden,
+			remaining:  "42",
+			wantErr:    "GitHub API returned status 403",
+			notWantErr: "rate limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_TOKEN", tt.token)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				wantAuth := ""
+				if tt.token != "" {
+					wantAuth = "Bearer " + tt.token
+				}
+				if got := r.Header.Get("Authorization"); got != wantAuth {
+					t.Errorf("Authorization = %q, want %q", got, wantAuth)
+				}
+				w.Header().Set("X-RateLimit-Remaining", tt.remaining)
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				http.Error(w, "API rate limit exceeded", tt.status)
+			}))
+			defer server.Close()
+
+			originalClient := httpClient
+			originalNow := nowFunc
+			httpClient = &http.Client{Transport: &mockTransport{server: server}}
+			nowFunc = func() time.Time { return now }
+			defer func() {
+				httpClient = originalClient
+				nowFunc = originalNow
+			}()
+
+			_, err := getLatestRelease()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if tt.notWantErr != "" && strings.Contains(err.Error(), tt.notWantErr) {
+				t.Errorf("Did not expect %q in %v", tt.notWantErr, err)
+			}
+		})
+	}
+}
+
+func TestSetGitHubAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiBase  string
+		url      string
+		wantAuth bool
+	}{
+		{name: "default_api", url: "https://api.github.com/repos/o/r/releases/latest", wantAuth: true},
+		{name: "other_host", url: "https://evil.example.com/assets", wantAuth: false},
+		{name: "asset_download_host", url: "https://objects.githubusercontent.com/asset", wantAuth: false},
+		{name: "custom_api_base", apiBase: "https://ghe.example.com/api/v3/", url: "https://ghe.example.com/api/v3/repos/o/r/releases", wantAuth: true},
+		{name: "default_host_with_custom_base", apiBase: "https://ghe.example.com/api/v3", url: "https://api.github.com/repos/o/r", wantAuth: false},
+	}
+
+	t.Setenv("GITHUB_TOKEN", "ghp_test")
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.APIBase = tt.apiBase
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			setGitHubAuth(req)
+			if got := req.Header.Get("Authorization") != ""; got != tt.wantAuth {
+				t.Errorf("Authorization sent = %v, want %v", got, tt.wantAuth)
+			}
+		})
+	}
+}
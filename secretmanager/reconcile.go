@@ -0,0 +1,67 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// newTicker is a variable to allow mocking in tests. It returns the tick
+// channel and a function that stops the ticker
+var newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+// notifyStop is a variable to allow mocking in tests. It returns a channel
+// that receives the signal asking a long-running process to stop
+var notifyStop = func() (<-chan os.Signal, func()) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	return stop, func() { signal.Stop(stop) }
+}
+
+// processSecretDirectories processes each secret directory in turn
+func processSecretDirectories(secretDirs []string) {
+	for _, secretDir := range secretDirs {
+		fmt.Fprintf(outputWriter(), "\nProcessing: %s\n", secretDir)
+		if err := processSecretDirectory(secretDir); err != nil {
+			runSummary.recordManifestError(secretDir, err)
+			// Continue with other directories
+		}
+	}
+}
+
+// reconcile re-applies every manifest each interval until a stop signal, so
+// a long-running sidecar heals links removed or changed behind its back.
+// With rescan set, each pass scans again for new secret directories
+func reconcile(interval time.Duration, secretDirs []string, rescan bool) {
+	ticks, stopTicker := newTicker(interval)
+	defer stopTicker()
+	stop, stopNotify := notifyStop()
+	defer stopNotify()
+
+	fmt.Printf("Reconciling every %s until stopped\n", interval)
+	for {
+		select {
+		case <-ticks:
+			if rescan {
+				dirs, err := findSecretDirs(".")
+				if err != nil {
+					warnf("Error finding secret directories: %v\n", err)
+					continue
+				}
+				secretDirs = dirs
+			}
+			runSummary = &RunSummary{}
+			fmt.Printf("\nReconciling %d secret directories\n", len(secretDirs))
+			processSecretDirectories(secretDirs)
+			fmt.Printf("Summary: %s\n", runSummary.message())
+		case sig := <-stop:
+			fmt.Printf("Received %s, stopping reconciliation\n", sig)
+			return
+		}
+	}
+}
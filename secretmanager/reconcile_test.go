@@ -0,0 +1,109 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// RECONCILE TESTS
+// =============================================================================
+// Tests for re-applying manifests periodically with -reconcile-interval
+// =============================================================================
+
+func TestReconcile(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir, targets := healthFixture(t, tempDir, "api.key")
+	newDir := filepath.Join(tempDir, "new_secret")
+	newTarget := filepath.Join(tempDir, "links", "db.key")
+
+	originalOpts := opts
+	originalSummary := runSummary
+	originalTicker := newTicker
+	originalNotifyStop := notifyStop
+	originalFindSecretDirs := findSecretDirs
+	defer func() {
+		opts = originalOpts
+		runSummary = originalSummary
+		newTicker = originalTicker
+		notifyStop = originalNotifyStop
+		findSecretDirs = originalFindSecretDirs
+	}()
+	opts.NoOwnerCheck = true
+
+	ticks := make(chan time.Time)
+	stop := make(chan os.Signal)
+	var interval time.Duration
+	tickerStopped := false
+	newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		interval = d
+		return ticks, func() { tickerStopped = true }
+	}
+	notifyStop = func() (<-chan os.Signal, func()) {
+		return stop, func() {}
+	}
+	findSecretDirs = func(root string) ([]string, error) {
+		return []string{secretDir, newDir}, nil
+	}
+
+	captureStdout(t, func() { processSecretDirectories([]string{secretDir}) })
+
+	// Drift: the link is deleted externally and a new secret directory appears
+	os.Remove(targets[0])
+	createFile(t, filepath.Join(newDir, "db.key"), "secret")
+	createFile(t, filepath.Join(newDir, "db.key.symlink.json"), `{"targets":[{"path":"`+newTarget+`"}]}`)
+
+	go func() {
+		ticks <- time.Now()
+		// Only received once the pass triggered by the tick has finished
+		stop <- syscall.SIGTERM
+	}()
+	output := captureStdout(t, func() {
+		reconcile(time.Minute, []string{secretDir}, true)
+	})
+
+	if interval != time.Minute || !tickerStopped {
+		t.Errorf("Expected a one-minute ticker that is stopped afterwards, got %s (stopped %v)", interval, tickerStopped)
+	}
+	for _, target := range []string{targets[0], newTarget} {
+		if _, err := os.Lstat(target); err != nil {
+			t.Errorf("Expected %s to be linked by the reconciliation pass, got %v", target, err)
+		}
+	}
+	for _, want := range []string{"Reconciling 2 secret directories", "Summary: ", "stopping reconciliation"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output containing %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestReconcileStopsWithoutTick(t *testing.T) {
+	originalTicker := newTicker
+	originalNotifyStop := notifyStop
+	defer func() {
+		newTicker = originalTicker
+		notifyStop = originalNotifyStop
+	}()
+
+	newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		return make(chan time.Time), func() {}
+	}
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	notifyStop = func() (<-chan os.Signal, func()) {
+		return stop, func() {}
+	}
+
+	output := captureStdout(t, func() {
+		reconcile(time.Minute, []string{"/nonexistent"}, false)
+	})
+	if strings.Contains(output, "Reconciling 1") {
+		t.Errorf("Expected no pass without a tick, got:\n%s", output)
+	}
+}
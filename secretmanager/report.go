@@ -0,0 +1,319 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Actions recorded for each processed target
+const (
+	actionCreated  = "created"
+	actionReplaced = "replaced"
+	actionPlanned  = "planned"
+	actionSkipped  = "skipped"
+	actionFailed   = "failed"
+
+	// actionUnchanged is a -copy-fallback copy whose content already matched
+	actionUnchanged = "unchanged"
+)
+
+// LinkResult records the outcome of processing a single target
+type LinkResult struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	Description string `json:"description,omitempty"`
+	Action      string `json:"action"`
+	Message     string `json:"message,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+}
+
+// RunSummary aggregates the outcome of every target processed in a run
+type RunSummary struct {
+	Results []LinkResult
+
+	// ManifestErrors are manifests and groups that failed as a whole
+	// before their targets had results, such as a manifest that does not
+	// parse or whose pre_hook failed under -strict
+	ManifestErrors []string
+
+	// mu guards Results while targets are linked concurrently
+	mu sync.Mutex
+
+	// openFilesIndex holds the files open by each process for
+	// -check-open-files, scanned once per run
+	openFilesOnce  sync.Once
+	openFilesIndex map[string][]int
+	openFilesErr   error
+}
+
+// runSummary collects the results of the current run
+var runSummary = &RunSummary{}
+
+// record adds the outcome of a single target to the summary
+func (s *RunSummary) record(result LinkResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Results = append(s.Results, result)
+}
+
+// recordManifestError reports that a manifest or group at path failed as a
+// whole, and records it so that the run counts as failed. A manifest that
+// planning already listed is recorded once
+func (s *RunSummary) recordManifestError(path string, err error) {
+	warnf("Error processing %s: %v\n", path, err)
+	entry := fmt.Sprintf("%s: %v", path, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, recorded := range s.ManifestErrors {
+		if recorded == entry {
+			return
+		}
+	}
+	s.ManifestErrors = append(s.ManifestErrors, entry)
+}
+
+// count returns the number of targets that ended with the given action
+func (s *RunSummary) count(action string) int {
+	n := 0
+	for _, result := range s.Results {
+		if result.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// changed reports whether any link was created or replaced
+func (s *RunSummary) changed() bool {
+	return s.count(actionCreated) > 0 || s.count(actionReplaced) > 0
+}
+
+// failed reports whether any manifest or required target failed. Failures
+// of optional targets are only warnings
+func (s *RunSummary) failed() bool {
+	if len(s.ManifestErrors) > 0 {
+		return true
+	}
+	for _, result := range s.Results {
+		if result.Action == actionFailed && !result.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// message returns a one-line description of the aggregated counts
+func (s *RunSummary) message() string {
+	message := fmt.Sprintf("created %d, replaced %d, skipped %d, failed %d",
+		s.count(actionCreated), s.count(actionReplaced), s.count(actionSkipped), s.count(actionFailed))
+	if unchanged := s.count(actionUnchanged); unchanged > 0 {
+		message += fmt.Sprintf(", unchanged %d", unchanged)
+	}
+	if n := len(s.ManifestErrors); n > 0 {
+		message += fmt.Sprintf(", failed manifests %d", n)
+	}
+	return message
+}
+
+// failure describes what failed in a run that failed()
+func (s *RunSummary) failure() error {
+	if n := len(s.ManifestErrors); n > 0 {
+		return fmt.Errorf("%d targets and %d manifests failed", s.count(actionFailed), n)
+	}
+	return fmt.Errorf("%d targets failed", s.count(actionFailed))
+}
+
+// ansibleResult is the module output format understood by Ansible
+type ansibleResult struct {
+	Changed bool         `json:"changed"`
+	Failed  bool         `json:"failed"`
+	Msg     string       `json:"msg"`
+	Links   []LinkResult `json:"links"`
+}
+
+// writeAnsibleResult writes the summary as a single Ansible-compatible JSON
+// object. The failed field is only set under -strict
+func writeAnsibleResult(w io.Writer, s *RunSummary) error {
+	result := ansibleResult{
+		Changed: s.changed(),
+		Failed:  opts.Strict && s.failed(),
+		Msg:     s.message(),
+		Links:   make([]LinkResult, 0, len(s.Results)),
+	}
+	for _, link := range s.Results {
+		link.Source = reportPath(link.Source)
+		link.Target = reportPath(link.Target)
+		result.Links = append(result.Links, link)
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+// Totals are the aggregate counts of a run, as written with
+// -summary-json-file and returned by Apply
+type Totals struct {
+	Success   bool `json:"success"`
+	Total     int  `json:"total"`
+	Created   int  `json:"created"`
+	Replaced  int  `json:"replaced"`
+	Planned   int  `json:"planned"`
+	Skipped   int  `json:"skipped"`
+	Failed    int  `json:"failed"`
+	Unchanged int  `json:"unchanged"`
+
+	FailedManifests int `json:"failed_manifests,omitempty"`
+}
+
+// totals returns the aggregate counts of the summary
+func (s *RunSummary) totals() Totals {
+	return Totals{
+		Success:   !s.failed(),
+		Total:     len(s.Results),
+		Created:   s.count(actionCreated),
+		Replaced:  s.count(actionReplaced),
+		Planned:   s.count(actionPlanned),
+		Skipped:   s.count(actionSkipped),
+		Failed:    s.count(actionFailed),
+		Unchanged: s.count(actionUnchanged),
+
+		FailedManifests: len(s.ManifestErrors),
+	}
+}
+
+// writeSummaryJSONFile atomically writes the aggregate counts of the run to path
+func writeSummaryJSONFile(path string, s *RunSummary) error {
+	data, err := json.MarshalIndent(s.totals(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// reportPath returns path relative to -report-base when it lies inside it,
+// so that reports can be compared across machines. Paths outside the base
+// are written as absolute paths
+func reportPath(path string) string {
+	if opts.ReportBase == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(opts.ReportBase, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
+
+// writeFileAtomic writes data to a temporary name next to path and renames
+// it into place, so readers never see a partial file. A path ending in .gz
+// is written gzip-compressed
+func writeFileAtomic(path string, data []byte) error {
+	if isGzipPath(path) {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Verbosity levels selected with -v, -vv and -vvv
+const (
+	verboseSkips     = 1 // files and targets that are skipped
+	verbosePaths     = 2 // resolved source and target paths
+	verboseDecisions = 3 // internal decisions such as readlink results and retries
+)
+
+// logf prints a diagnostic line when the verbosity is at least level. Level 0
+// output stays as it was before -v existed
+func logf(level int, format string, a ...interface{}) {
+	directOutput.logf(level, format, a...)
+}
+
+// quietly runs fn with per-target output suppressed and its results kept out
+// of the run summary, for planning ahead of the real run
+func quietly(fn func()) {
+	summary, summaryOnly := runSummary, opts.SummaryOnly
+	runSummary, opts.SummaryOnly = &RunSummary{}, true
+	defer func() { runSummary, opts.SummaryOnly = summary, summaryOnly }()
+	fn()
+}
+
+// printTarget prints a per-target progress line unless -summary-only is set.
+// Results are recorded regardless, so the summary stays complete
+func printTarget(format string, a ...interface{}) {
+	directOutput.printTarget(format, a...)
+}
+
+// warningsFile is the file opened for -warnings-to <path>
+var warningsFile *os.File
+
+// openWarningsFile opens the -warnings-to file, if one was given
+func openWarningsFile() error {
+	switch opts.WarningsTo {
+	case "", "stdout", "stderr":
+		return nil
+	}
+	f, err := os.OpenFile(opts.WarningsTo, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open warnings file: %w", err)
+	}
+	warningsFile = f
+	return nil
+}
+
+func closeWarningsFile() {
+	if warningsFile != nil {
+		warningsFile.Close()
+		warningsFile = nil
+	}
+}
+
+// warningsWriter returns the stream selected with -warnings-to. The standard
+// streams are looked up on each call, as Ansible mode redirects stdout
+func warningsWriter() io.Writer {
+	switch {
+	case opts.WarningsTo == "stdout":
+		return outputWriter()
+	case warningsFile != nil:
+		return warningsFile
+	default:
+		return os.Stderr
+	}
+}
+
+// warnf writes a warning or error message to the warnings stream
+func warnf(format string, a ...interface{}) {
+	fmt.Fprintf(warningsWriter(), format, a...)
+}
+
+// warnTarget writes a per-target warning unless -summary-only is set
+func warnTarget(format string, a ...interface{}) {
+	directOutput.warnTarget(format, a...)
+}
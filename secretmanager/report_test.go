@@ -0,0 +1,689 @@
+package secretmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// RUN REPORT TESTS
+// =============================================================================
+// This file contains all tests related to:
+// - Run summary aggregation
+// - Machine-readable run output (Ansible, summary JSON file)
+// - Verbosity levels
+// =============================================================================
+
+func TestRunSummaryCounts(t *testing.T) {
+	summary := &RunSummary{}
+	summary.record(LinkResult{Target: "a", Action: actionCreated})
+	summary.record(LinkResult{Target: "b", Action: actionReplaced})
+	summary.record(LinkResult{Target: "c", Action: actionSkipped})
+	summary.record(LinkResult{Target: "d", Action: actionFailed})
+	summary.record(LinkResult{Target: "e", Action: actionCreated})
+
+	if got := summary.message(); got != "created 2, replaced 1, skipped 1, failed 1" {
+		t.Errorf("Unexpected summary message %q", got)
+	}
+	if !summary.changed() {
+		t.Error("Expected summary to be changed")
+	}
+	if !summary.failed() {
+		t.Error("Expected summary to be failed")
+	}
+
+	// A manifest that failed as a whole fails the run without any target
+	summary = &RunSummary{}
+	summary.record(LinkResult{Target: "a", Action: actionCreated})
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.WarningsTo = "stdout"
+	captureStdout(t, func() {
+		summary.recordManifestError("app.symlink.json", errors.New("failed to parse JSON"))
+		summary.recordManifestError("app.symlink.json", errors.New("failed to parse JSON"))
+	})
+	if !summary.failed() {
+		t.Error("Expected a manifest error to fail the summary")
+	}
+	if got := summary.failure().Error(); got != "0 targets and 1 manifests failed" {
+		t.Errorf("Unexpected failure %q", got)
+	}
+}
+
+func TestWriteAnsibleResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		results       []LinkResult
+		strict        bool
+		expectChanged bool
+		expectFailed  bool
+	}{
+		{
+			name:          "no targets",
+			expectChanged: false,
+		},
+		{
+			name:          "created link",
+			results:       []LinkResult{{Source: "s", Target: "t", Action: actionCreated}},
+			expectChanged: true,
+		},
+		{
+			name:          "replaced link",
+			results:       []LinkResult{{Source: "s", Target: "t", Action: actionReplaced}},
+			expectChanged: true,
+		},
+		{
+			name: "only skipped and planned",
+			results: []LinkResult{
+				{Source: "s", Target: "t1", Action: actionSkipped},
+				{Source: "s", Target: "t2", Action: actionPlanned},
+			},
+			expectChanged: false,
+		},
+		{
+			name:          "failure without strict",
+			results:       []LinkResult{{Source: "s", Target: "t", Action: actionFailed, Message: "boom"}},
+			expectChanged: false,
+			expectFailed:  false,
+		},
+		{
+			name: "failure with strict",
+			results: []LinkResult{
+				{Source: "s", Target: "t1", Action: actionCreated},
+				{Source: "s", Target: "t2", Action: actionFailed, Message: "boom"},
+			},
+			strict:        true,
+			expectChanged: true,
+			expectFailed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			opts.Strict = tt.strict
+			defer func() { opts = originalOpts }()
+
+			var buf bytes.Buffer
+			if err := writeAnsibleResult(&buf, &RunSummary{Results: tt.results}); err != nil {
+				t.Fatalf("writeAnsibleResult() error = %v", err)
+			}
+
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+			}
+			for _, key := range []string{"changed", "failed", "msg", "links"} {
+				if _, ok := decoded[key]; !ok {
+					t.Errorf("Expected key %q in output", key)
+				}
+			}
+			if decoded["changed"] != tt.expectChanged {
+				t.Errorf("Expected changed %v, got %v", tt.expectChanged, decoded["changed"])
+			}
+			if decoded["failed"] != tt.expectFailed {
+				t.Errorf("Expected failed %v, got %v", tt.expectFailed, decoded["failed"])
+			}
+			links, ok := decoded["links"].([]interface{})
+			if !ok || len(links) != len(tt.results) {
+				t.Errorf("Expected %d links, got %v", len(tt.results), decoded["links"])
+			}
+		})
+	}
+}
+
+func TestReportPath(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	base := filepath.Join(t.TempDir(), "deploy")
+	outside := filepath.Join(filepath.Dir(base), "elsewhere", "api.key")
+	tests := []struct {
+		name string
+		base string
+		path string
+		want string
+	}{
+		{"no base", "", outside, outside},
+		{"inside base", base, filepath.Join(base, "etc", "api.key"), filepath.Join("etc", "api.key")},
+		{"base itself", base, base, "."},
+		{"outside base", base, outside, outside},
+		{"sibling with a common prefix", base, base + "-old", base + "-old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.ReportBase = tt.base
+			if got := reportPath(tt.path); got != tt.want {
+				t.Errorf("reportPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportBaseOutputs(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	base := filepath.Join(t.TempDir(), "deploy")
+	outside := filepath.Join(filepath.Dir(base), "shared", "api.key")
+	opts.ReportBase = base
+	summary := &RunSummary{Results: []LinkResult{
+		{Source: filepath.Join(base, "app_secret", "api.key"), Target: filepath.Join(base, "etc", "api.key"), Action: actionCreated},
+		{Source: filepath.Join(base, "app_secret", "api.key"), Target: outside, Action: actionCreated},
+	}}
+
+	var buf bytes.Buffer
+	if err := writeAnsibleResult(&buf, summary); err != nil {
+		t.Fatalf("writeAnsibleResult() error = %v", err)
+	}
+	var decoded ansibleResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if got := decoded.Links[0]; got.Source != filepath.Join("app_secret", "api.key") || got.Target != filepath.Join("etc", "api.key") {
+		t.Errorf("Expected paths relative to the base, got %+v", got)
+	}
+	if got := decoded.Links[1].Target; got != outside {
+		t.Errorf("Expected an absolute path outside the base, got %q", got)
+	}
+	if summary.Results[0].Target != filepath.Join(base, "etc", "api.key") {
+		t.Error("The run results should not be rewritten")
+	}
+
+	report := buildJUnitReport(summary)
+	if got := report.Suites[0].Name; got != filepath.Join("app_secret", "api.key") {
+		t.Errorf("Expected a relative JUnit suite name, got %q", got)
+	}
+	if got := report.Suites[0].Cases[1].Name; got != outside {
+		t.Errorf("Expected an absolute JUnit case name outside the base, got %q", got)
+	}
+}
+
+func TestWriteSummaryJSONFile(t *testing.T) {
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "summary.json")
+
+	summary := &RunSummary{}
+	summary.record(LinkResult{Source: "s", Target: "a", Action: actionCreated})
+	summary.record(LinkResult{Source: "s", Target: "b", Action: actionSkipped})
+	summary.record(LinkResult{Source: "s", Target: "c", Action: actionSkipped})
+
+	if err := writeSummaryJSONFile(path, summary); err != nil {
+		t.Fatalf("writeSummaryJSONFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read summary file: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Summary file is not valid JSON: %v\n%s", err, data)
+	}
+
+	expected := map[string]float64{"total": 3, "created": 1, "replaced": 0, "planned": 0, "skipped": 2, "failed": 0}
+	for key, want := range expected {
+		if got, ok := fields[key].(float64); !ok || got != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, fields[key])
+		}
+	}
+	if success, ok := fields["success"].(bool); !ok || !success {
+		t.Errorf("Expected success = true, got %v", fields["success"])
+	}
+	if _, ok := fields["links"]; ok {
+		t.Error("Summary file should not contain per-target results")
+	}
+
+	// A failed target clears the success flag, and the file is replaced
+	summary.record(LinkResult{Source: "s", Target: "d", Action: actionFailed})
+	if err := writeSummaryJSONFile(path, summary); err != nil {
+		t.Fatalf("writeSummaryJSONFile() error = %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	var result Totals
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Summary file is not valid JSON: %v", err)
+	}
+	if result.Success || result.Failed != 1 || result.Total != 4 {
+		t.Errorf("Unexpected summary after failure: %+v", result)
+	}
+
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the summary file to remain, got %d entries", len(entries))
+	}
+}
+
+// Test that a report path ending in .gz is written gzip-compressed
+func TestWriteSummaryJSONFileGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json.gz")
+
+	summary := &RunSummary{}
+	summary.record(LinkResult{Source: "s", Target: "a", Action: actionCreated})
+	if err := writeSummaryJSONFile(path, summary); err != nil {
+		t.Fatalf("writeSummaryJSONFile() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Fatalf("Expected gzip-compressed output, got %q", data)
+	}
+	plain, err := maybeGunzip(data)
+	if err != nil {
+		t.Fatalf("maybeGunzip() error = %v", err)
+	}
+	var result Totals
+	if err := json.Unmarshal(plain, &result); err != nil || result.Created != 1 {
+		t.Errorf("Expected the summary to round-trip, got %+v (%v)", result, err)
+	}
+}
+
+func TestMainAnsibleOutput(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalSymlink := symlinkFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	config := SymlinkConfig{Targets: []Target{
+		{Path: filepath.Join(tempDir, "ok.key"), Description: "ok"},
+		{Path: filepath.Join(tempDir, "fail.key"), Description: "fail"},
+	}}
+	data, _ := json.Marshal(config)
+	createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), string(data))
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	symlinkFunc = func(oldname, newname string) error {
+		if strings.HasSuffix(newname, "fail.key") {
+			return errors.New("mock failure")
+		}
+		return mockSymlink(oldname, newname)
+	}
+	opts.Ansible = true
+	opts.Strict = true
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		symlinkFunc = originalSymlink
+		opts = originalOpts
+	}()
+
+	r, w, _ := os.Pipe()
+	originalStderr := os.Stderr
+	os.Stderr = w
+	output := captureStdout(t, main)
+	w.Close()
+	os.Stderr = originalStderr
+	r.Close()
+
+	var result ansibleResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("Expected only JSON on stdout, got: %s", output)
+	}
+	if !result.Changed || !result.Failed {
+		t.Errorf("Expected changed and failed, got %+v", result)
+	}
+	if len(result.Links) != 2 {
+		t.Errorf("Expected 2 links, got %d", len(result.Links))
+	}
+	if exitCode != -1 {
+		t.Errorf("Ansible mode should not exit nonzero, got %d", exitCode)
+	}
+}
+
+func TestMainStrictExitCode(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalSymlink := symlinkFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	config := SymlinkConfig{Targets: []Target{{Path: filepath.Join(tempDir, "fail.key")}}}
+	data, _ := json.Marshal(config)
+	createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), string(data))
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	symlinkFunc = func(oldname, newname string) error {
+		return errors.New("mock failure")
+	}
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		symlinkFunc = originalSymlink
+		opts = originalOpts
+	}()
+
+	captureStdout(t, main)
+	if exitCode != -1 {
+		t.Errorf("Expected no exit without -strict, got %d", exitCode)
+	}
+
+	opts.Strict = true
+	captureStdout(t, main)
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 under -strict, got %d", exitCode)
+	}
+}
+
+func TestMainStrictManifestError(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		hookErr  error
+	}{
+		{name: "parse_error", manifest: `{"targets": [`},
+		{name: "invalid_source_perm", manifest: `{"source_perm":"999","targets":[{"path":"api.link"}]}`},
+		{name: "schema_too_new", manifest: `{"schema_version":99,"targets":[{"path":"api.link"}]}`},
+		{name: "failing_pre_hook", manifest: `{"pre_hook":"fetch-secret","targets":[{"path":"api.link"}]}`, hookErr: errors.New("exit status 1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalExit := exitFunc
+			originalExeDir := executableDir
+			originalRun := runCommand
+			originalOpts := opts
+
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd)
+
+			secretDir := filepath.Join(tempDir, "secret")
+			createFile(t, filepath.Join(secretDir, "api.key"), "key")
+			createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), tt.manifest)
+
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+			executableDir = func() (string, error) { return tempDir, nil }
+			runCommand = func(command string, env []string) error { return tt.hookErr }
+			opts.NoOwnerCheck = true
+			opts.Strict = true
+			opts.WarningsTo = "stdout"
+
+			defer func() {
+				exitFunc = originalExit
+				executableDir = originalExeDir
+				runCommand = originalRun
+				opts = originalOpts
+			}()
+
+			output := captureStdout(t, main)
+			if exitCode != 1 {
+				t.Errorf("Expected exit code 1 under -strict, got %d\n%s", exitCode, output)
+			}
+			if !strings.Contains(output, "Error processing "+filepath.Join("secret", "api.key.symlink.json")) {
+				t.Errorf("Expected the manifest error to be reported, got:\n%s", output)
+			}
+			if !strings.Contains(output, "failed manifests 1") {
+				t.Errorf("Expected the summary to count the manifest, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestMainStrictOptionalTargets(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalSymlink := symlinkFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	okTarget := filepath.Join(tempDir, "ok.key")
+	failTarget := filepath.Join(tempDir, "fail.key")
+	writeConfig := func(optional bool) {
+		config := SymlinkConfig{Targets: []Target{
+			{Path: okTarget},
+			{Path: failTarget, Optional: optional},
+		}}
+		data, _ := json.Marshal(config)
+		createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), string(data))
+	}
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	symlinkFunc = func(oldname, newname string) error {
+		if newname == failTarget {
+			return errors.New("mock failure")
+		}
+		return mockSymlink(oldname, newname)
+	}
+	opts.Strict = true
+	opts.WarningsTo = "stdout"
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		symlinkFunc = originalSymlink
+		opts = originalOpts
+	}()
+
+	// A failing optional target is only a warning
+	writeConfig(true)
+	output := captureStdout(t, main)
+	if exitCode != -1 {
+		t.Errorf("Expected an optional failure not to fail the run, got exit %d:\n%s", exitCode, output)
+	}
+	if !strings.Contains(output, "Warning: failed to create optional symlink for "+failTarget) {
+		t.Errorf("Expected a warning for the optional target, got:\n%s", output)
+	}
+	if !strings.Contains(output, "failed 1") {
+		t.Errorf("Expected the optional failure to be counted, got:\n%s", output)
+	}
+
+	// The same failure on a required target fails the run
+	writeConfig(false)
+	output = captureStdout(t, main)
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 for a required failure, got %d:\n%s", exitCode, output)
+	}
+}
+
+func TestMainSummaryOnly(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	config := SymlinkConfig{Targets: []Target{
+		{Path: filepath.Join(tempDir, "api.key"), Description: "created"},
+		{Path: filepath.Join(tempDir, "missing", "api.key"), Description: "skipped", Optional: true},
+	}}
+	data, _ := json.Marshal(config)
+	createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), string(data))
+	createFile(t, filepath.Join(secretDir, "gone.key.symlink.json"), string(data))
+
+	exitFunc = func(code int) {}
+	executableDir = func() (string, error) { return tempDir, nil }
+	opts.NoOwnerCheck = true
+	opts.SummaryOnly = true
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		opts = originalOpts
+	}()
+
+	output := captureStdout(t, main)
+
+	for _, line := range []string{"Created symlink", "Warning", "Target directory does not exist"} {
+		if strings.Contains(output, line) {
+			t.Errorf("Expected no %q lines with -summary-only, got: %s", line, output)
+		}
+	}
+	if !strings.Contains(output, "Summary: created 1, replaced 0, skipped 1, failed 0") {
+		t.Errorf("Expected aggregated summary, got: %s", output)
+	}
+}
+
+func TestWarningsTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		warningsTo string
+		wantStdout bool
+		wantStderr bool
+		wantFile   bool
+	}{
+		{name: "default_stderr", wantStderr: true},
+		{name: "stderr", warningsTo: "stderr", wantStderr: true},
+		{name: "stdout", warningsTo: "stdout", wantStdout: true},
+		{name: "file", warningsTo: "file", wantFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			configPath := filepath.Join(tempDir, "missing.key.symlink.json")
+			createFile(t, configPath, `{"targets":[{"path":"/tmp/unused"}]}`)
+			warningsPath := filepath.Join(tempDir, "warnings.log")
+
+			originalOpts := opts
+			defer func() { opts = originalOpts }()
+			opts.WarningsTo = tt.warningsTo
+			if tt.warningsTo == "file" {
+				opts.WarningsTo = warningsPath
+			}
+			if err := openWarningsFile(); err != nil {
+				t.Fatalf("openWarningsFile() error = %v", err)
+			}
+
+			r, w, _ := os.Pipe()
+			originalStderr := os.Stderr
+			os.Stderr = w
+			stdout := captureStdout(t, func() {
+				processSymlinkConfig(filepath.Join(tempDir, "missing.key"), configPath)
+			})
+			w.Close()
+			os.Stderr = originalStderr
+			stderr, _ := io.ReadAll(r)
+			closeWarningsFile()
+			file, _ := os.ReadFile(warningsPath)
+
+			const warning = "Warning: Source file"
+			if got := strings.Contains(stdout, warning); got != tt.wantStdout {
+				t.Errorf("Warning on stdout = %v, want %v: %q", got, tt.wantStdout, stdout)
+			}
+			if got := strings.Contains(string(stderr), warning); got != tt.wantStderr {
+				t.Errorf("Warning on stderr = %v, want %v: %q", got, tt.wantStderr, stderr)
+			}
+			if got := strings.Contains(string(file), warning); got != tt.wantFile {
+				t.Errorf("Warning in file = %v, want %v: %q", got, tt.wantFile, file)
+			}
+		})
+	}
+}
+
+func TestVerbosityFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: nil, want: 0},
+		{args: []string{"-v"}, want: 1},
+		{args: []string{"-v", "-v"}, want: 2},
+		{args: []string{"-vv"}, want: 2},
+		{args: []string{"-vvv"}, want: 3},
+		{args: []string{"-v", "-vv"}, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			level := 0
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Var(verbosityFlag{&level, 1}, "v", "")
+			fs.Var(verbosityFlag{&level, 2}, "vv", "")
+			fs.Var(verbosityFlag{&level, 3}, "vvv", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if level != tt.want {
+				t.Errorf("Expected verbosity %d, got %d", tt.want, level)
+			}
+		})
+	}
+}
+
+func TestVerbosityLevels(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+
+	secretDir := filepath.Join(tempDir, "secret")
+	createFile(t, filepath.Join(secretDir, "api.key"), "key")
+	createFile(t, filepath.Join(secretDir, "README.txt"), "notes")
+	config := SymlinkConfig{Targets: []Target{{Path: filepath.Join(tempDir, "api.key")}}}
+	data, _ := json.Marshal(config)
+	createFile(t, filepath.Join(secretDir, "api.key.symlink.json"), string(data))
+
+	// Each level adds its own lines on top of the previous ones
+	expected := []string{
+		"Created symlink:",
+		"Skipping " + filepath.Join(secretDir, "README.txt") + ": not a manifest",
+		"Resolved target " + filepath.Join(tempDir, "api.key"),
+		"Readlink " + filepath.Join(tempDir, "api.key") + ":",
+	}
+
+	previous := -1
+	for level := 0; level <= 3; level++ {
+		opts.Verbosity = level
+		output := captureStdout(t, func() {
+			if err := processSecretDirectory(secretDir); err != nil {
+				t.Fatalf("processSecretDirectory() error = %v", err)
+			}
+		})
+
+		for i, line := range expected {
+			if got := strings.Contains(output, line); got != (i <= level) {
+				t.Errorf("Level %d: expected line %q present = %v, output:\n%s", level, line, i <= level, output)
+			}
+		}
+		if len(output) <= previous {
+			t.Errorf("Level %d: expected more output than the previous level", level)
+		}
+		previous = len(output)
+	}
+}
@@ -0,0 +1,81 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// nowFunc is a variable to allow mocking in tests
+var nowFunc = time.Now
+
+// scanCache is the persisted result of a secret directory scan
+type scanCache struct {
+	Root       string    `json:"root"`
+	Options    string    `json:"options"`
+	Created    time.Time `json:"created"`
+	Dirs       []string  `json:"dirs"`
+	SecretDirs []string  `json:"secret_dirs"`
+}
+
+// scanOptionsKey describes the options that affect the scan result, so a
+// cache written with different options is not reused
+func scanOptionsKey() string {
+	return fmt.Sprintf("skip-hidden=%v keywords=%s", opts.SkipHidden, strings.Join(scanKeywords(), ","))
+}
+
+// loadScanCache returns the cached secret directories for root if the cache
+// exists and no directory in the tree has been modified since it was written.
+// Only the recorded directories are stat'ed; adding or removing an entry
+// changes the mtime of its parent, so new subdirectories are detected too
+func loadScanCache(path, root string) ([]string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var cache scanCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, false
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil || cache.Root != absRoot || cache.Options != scanOptionsKey() {
+		return nil, false
+	}
+
+	for _, dir := range cache.Dirs {
+		info, err := statFunc(dir)
+		if err != nil || info.ModTime().After(cache.Created) {
+			return nil, false
+		}
+	}
+
+	return cache.SecretDirs, true
+}
+
+// saveScanCache persists a scan result for root
+func saveScanCache(path, root string, secretDirs, allDirs []string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	cache := scanCache{
+		Root:       absRoot,
+		Options:    scanOptionsKey(),
+		Created:    nowFunc(),
+		Dirs:       allDirs,
+		SecretDirs: secretDirs,
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,121 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// SCAN CACHE TESTS
+// =============================================================================
+// Tests for reusing the secret directory scan between runs
+// =============================================================================
+
+// setupScanCacheTest creates a tree with one secret directory, changes into
+// it and returns a counter of filepathWalk calls along with the cache path
+func setupScanCacheTest(t *testing.T) (*int, string) {
+	tempDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tempDir, "app", "secret"), 0755)
+	cachePath := filepath.Join(t.TempDir(), "scan.json")
+
+	originalWd, _ := os.Getwd()
+	os.Chdir(tempDir)
+
+	originalOpts := opts
+	originalWalk := filepathWalk
+	originalNow := nowFunc
+	t.Cleanup(func() {
+		os.Chdir(originalWd)
+		opts = originalOpts
+		filepathWalk = originalWalk
+		nowFunc = originalNow
+	})
+
+	walks := 0
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		walks++
+		return originalWalk(root, walkFn)
+	}
+	opts.ScanCache = cachePath
+	nowFunc = func() time.Time { return time.Now().Add(time.Hour) }
+
+	return &walks, cachePath
+}
+
+func TestFindSecretDirectoriesScanCacheHit(t *testing.T) {
+	walks, cachePath := setupScanCacheTest(t)
+
+	dirs, err := findSecretDirectories(".")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("Expected scan cache to be written: %v", err)
+	}
+
+	cached, err := findSecretDirectories(".")
+	if err != nil {
+		t.Fatalf("findSecretDirectories() error = %v", err)
+	}
+	if *walks != 1 {
+		t.Errorf("Expected the second scan to skip the walk, got %d walks", *walks)
+	}
+	if len(cached) != 1 || cached[0] != dirs[0] || dirs[0] != filepath.Join("app", "secret") {
+		t.Errorf("Expected cached result %v, got %v", dirs, cached)
+	}
+}
+
+func TestFindSecretDirectoriesScanCacheMiss(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T)
+	}{
+		{
+			name: "subdirectory modified",
+			change: func(t *testing.T) {
+				future := time.Now().Add(2 * time.Hour)
+				if err := os.Chtimes("app", future, future); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "directory removed",
+			change: func(t *testing.T) {
+				os.RemoveAll(filepath.Join("app", "secret"))
+			},
+		},
+		{
+			name: "options changed",
+			change: func(t *testing.T) {
+				opts.SkipHidden = !opts.SkipHidden
+			},
+		},
+		{
+			name: "corrupt cache",
+			change: func(t *testing.T) {
+				os.WriteFile(opts.ScanCache, []byte("{"), 0644)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walks, _ := setupScanCacheTest(t)
+
+			if _, err := findSecretDirectories("."); err != nil {
+				t.Fatalf("findSecretDirectories() error = %v", err)
+			}
+			tt.change(t)
+			if _, err := findSecretDirectories("."); err != nil {
+				t.Fatalf("findSecretDirectories() error = %v", err)
+			}
+
+			if *walks != 2 {
+				t.Errorf("Expected the cache to be invalidated, got %d walks", *walks)
+			}
+		})
+	}
+}
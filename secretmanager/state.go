@@ -0,0 +1,181 @@
+package secretmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// defaultStateFile is resolved against the executable directory
+const defaultStateFile = "secret_manager.state.json"
+
+// linkState is persisted between runs in the state file
+type linkState struct {
+	// Hashes maps a target path to the SHA256 of its source when it was linked
+	Hashes map[string]string `json:"hashes"`
+
+	// mu guards Hashes while targets are linked concurrently
+	mu sync.Mutex
+}
+
+// runState holds the state loaded for the current run
+var runState = newLinkState()
+
+func newLinkState() *linkState {
+	return &linkState{Hashes: make(map[string]string)}
+}
+
+// Functions that can be mocked in tests
+var (
+	stateReadFile  = os.ReadFile
+	stateWriteFile = os.WriteFile
+	stateRename    = os.Rename
+	stateRemove    = os.Remove
+)
+
+// stateBackupPath is where the previous state is kept while it is replaced
+func stateBackupPath(path string) string {
+	return path + ".bak"
+}
+
+// loadState reads the state file, returning an empty state if it does not
+// exist. A corrupt state file is recovered from its backup when possible
+func loadState(path string) (*linkState, error) {
+	state, err := readStateFile(path)
+	if err == nil || os.IsNotExist(err) {
+		return state, nil
+	}
+
+	backup, backupErr := readStateFile(stateBackupPath(path))
+	if backupErr != nil {
+		return nil, err
+	}
+	warnf("Warning: %v; recovered the previous state from %s\n", err, stateBackupPath(path))
+	return backup, nil
+}
+
+// readStateFile parses a single state file. A missing file yields an empty
+// state along with the not-exist error
+func readStateFile(path string) (*linkState, error) {
+	data, err := stateReadFile(path)
+	if os.IsNotExist(err) {
+		return newLinkState(), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = maybeGunzip(data); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+
+	state := newLinkState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if state.Hashes == nil {
+		state.Hashes = make(map[string]string)
+	}
+	return state, nil
+}
+
+// hash returns the hash recorded for targetPath
+func (s *linkState) hash(targetPath string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.Hashes[targetPath]
+	return hash, ok
+}
+
+// setHash records the hash of the source linked at targetPath
+func (s *linkState) setHash(targetPath, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Hashes[targetPath] = hash
+}
+
+// save writes the state file atomically through a temporary file. The
+// previous state is copied to the backup first, so a crash can never leave
+// both unreadable. With -compress-state the file is gzip-compressed
+func (s *linkState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if opts.CompressState {
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+
+	// Only a readable state is worth keeping; a corrupt one would replace a good backup
+	if current, err := stateReadFile(path); err == nil && validState(current) {
+		if err := stateWriteFile(stateBackupPath(path), current, 0600); err != nil {
+			return fmt.Errorf("failed to back up state file: %w", err)
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := stateWriteFile(tmp, data, 0600); err != nil {
+		stateRemove(tmp)
+		return err
+	}
+	if err := stateRename(tmp, path); err != nil {
+		stateRemove(tmp)
+		return err
+	}
+	return nil
+}
+
+// validState reports whether data, compressed or not, is a JSON document
+func validState(data []byte) bool {
+	plain, err := maybeGunzip(data)
+	return err == nil && json.Valid(plain)
+}
+
+// hashFile returns the hex-encoded SHA256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkSourceHash compares the source content against the hash recorded when
+// targetPath was last linked. It returns the current hash and whether the
+// existing link can be left in place
+func checkSourceHash(sourcePath, targetPath string) (string, bool, error) {
+	hash, err := hashFile(sourcePath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to hash source: %w", err)
+	}
+
+	recorded, known := runState.hash(targetPath)
+	changed := known && recorded != hash
+	if changed {
+		warnTarget("Warning: Source %s changed since %s was linked\n", sourcePath, targetPath)
+	}
+
+	// The link itself is only rebuilt when it no longer points at the source,
+	// or when asked to relink rotated secrets
+	if verifySymlink(sourcePath, targetPath) != nil {
+		return hash, false, nil
+	}
+	if changed && opts.RelinkOnChange {
+		return hash, false, nil
+	}
+
+	// A change is reported once: the next run compares against the new content
+	runState.setHash(targetPath, hash)
+	return hash, true, nil
+}
@@ -0,0 +1,282 @@
+package secretmanager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// STATE TESTS
+// =============================================================================
+// Tests for source hashes persisted in the state file
+// =============================================================================
+
+func TestCreateSymlinkHashVerify(t *testing.T) {
+	tests := []struct {
+		name           string
+		rotate         bool
+		relinkOnChange bool
+		wantAction     string
+		wantWarning    bool
+		wantRecreated  bool
+	}{
+		{
+			name:       "unchanged",
+			wantAction: actionSkipped,
+		},
+		{
+			name:        "changed_warned",
+			rotate:      true,
+			wantAction:  actionSkipped,
+			wantWarning: true,
+		},
+		{
+			name:           "changed_relinked",
+			rotate:         true,
+			relinkOnChange: true,
+			wantAction:     actionReplaced,
+			wantWarning:    true,
+			wantRecreated:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestDir(t)
+			defer os.RemoveAll(tempDir)
+
+			sourcePath := filepath.Join(tempDir, "key.pem")
+			targetPath := filepath.Join(tempDir, "link.pem")
+			statePath := filepath.Join(tempDir, "state.json")
+			createFile(t, sourcePath, "old secret")
+
+			originalOpts := opts
+			originalState := runState
+			originalSummary := runSummary
+			defer func() {
+				opts = originalOpts
+				runState = originalState
+				runSummary = originalSummary
+			}()
+			opts.HashVerify = true
+			opts.RelinkOnChange = tt.relinkOnChange
+			opts.StateFile = statePath
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+			runState = newLinkState()
+
+			target := Target{Path: targetPath, Description: "key"}
+			if err := createSymlink(sourcePath, target); err != nil {
+				t.Fatalf("createSymlink() error = %v", err)
+			}
+			if err := runState.save(statePath); err != nil {
+				t.Fatalf("save() error = %v", err)
+			}
+
+			// Next run
+			if tt.rotate {
+				createFile(t, sourcePath, "new secret")
+			}
+			state, err := loadState(statePath)
+			if err != nil {
+				t.Fatalf("loadState() error = %v", err)
+			}
+			runState = state
+			runSummary = &RunSummary{}
+
+			symlinks := 0
+			originalSymlink := symlinkFunc
+			symlinkFunc = func(oldname, newname string) error {
+				symlinks++
+				return mockSymlink(oldname, newname)
+			}
+			defer func() { symlinkFunc = originalSymlink }()
+
+			output := captureStdout(t, func() {
+				if err := createSymlink(sourcePath, target); err != nil {
+					t.Errorf("createSymlink() error = %v", err)
+				}
+			})
+
+			if got := runSummary.Results[0].Action; got != tt.wantAction {
+				t.Errorf("Expected action %s, got %s", tt.wantAction, got)
+			}
+			if got := strings.Contains(output, "Warning: Source"); got != tt.wantWarning {
+				t.Errorf("Expected warning = %v, got output: %s", tt.wantWarning, output)
+			}
+			if got := symlinks == 1; got != tt.wantRecreated {
+				t.Errorf("Expected recreated = %v, got %d symlink calls", tt.wantRecreated, symlinks)
+			}
+
+			// The current content is recorded, so a change is reported once
+			want, _ := hashFile(sourcePath)
+			if got := runState.Hashes[targetPath]; got != want {
+				t.Errorf("Unexpected recorded hash %s (current %s)", got, want)
+			}
+			runSummary = &RunSummary{}
+			output = captureStdout(t, func() { createSymlink(sourcePath, target) })
+			if strings.Contains(output, "Warning: Source") {
+				t.Errorf("Expected no warning on the run after, got output: %s", output)
+			}
+		})
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	state, err := loadState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if len(state.Hashes) != 0 {
+		t.Errorf("Expected empty state, got %v", state.Hashes)
+	}
+}
+
+func TestLoadStateRecoversFromBackup(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+	opts.WarningsTo = "stdout"
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	for _, hash := range []string{"first", "second"} {
+		state := newLinkState()
+		state.Hashes["/etc/app/api.key"] = hash
+		if err := state.save(statePath); err != nil {
+			t.Fatalf("save() error = %v", err)
+		}
+	}
+
+	// A crash mid-write leaves a truncated primary
+	os.WriteFile(statePath, []byte(`{"hashes": {"/etc/app/a`), 0600)
+
+	var state *linkState
+	var err error
+	output := captureStdout(t, func() {
+		state, err = loadState(statePath)
+	})
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if got := state.Hashes["/etc/app/api.key"]; got != "first" {
+		t.Errorf("Expected the backed up state, got hash %q", got)
+	}
+	if !strings.Contains(output, "recovered the previous state from "+statePath+".bak") {
+		t.Errorf("Expected the recovery to be logged, got: %s", output)
+	}
+
+	// Without a usable backup the corruption is reported
+	os.Remove(statePath + ".bak")
+	captureStdout(t, func() {
+		_, err = loadState(statePath)
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid state file") {
+		t.Errorf("Expected invalid state error, got %v", err)
+	}
+}
+
+func TestLoadStateFreshStart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if state == nil || len(state.Hashes) != 0 {
+		t.Errorf("Expected an empty state when the file and backup are missing, got %+v", state)
+	}
+}
+
+func TestSaveStateInterruptedRename(t *testing.T) {
+	originalRename := stateRename
+	defer func() { stateRename = originalRename }()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state := newLinkState()
+	state.Hashes["/etc/app/api.key"] = "first"
+	if err := state.save(statePath); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	stateRename = func(oldpath, newpath string) error {
+		return errors.New("mock rename failure")
+	}
+	state.Hashes["/etc/app/api.key"] = "second"
+	if err := state.save(statePath); err == nil {
+		t.Fatal("Expected the failed rename to be reported")
+	}
+
+	loaded, err := loadState(statePath)
+	if err != nil || loaded.Hashes["/etc/app/api.key"] != "first" {
+		t.Errorf("Expected the previous state to be intact, got %+v (%v)", loaded, err)
+	}
+	if _, err := os.Stat(statePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+}
+
+func TestSaveStateCompressed(t *testing.T) {
+	tests := []struct {
+		name         string
+		compress     bool
+		wantCompress bool
+	}{
+		{name: "plaintext", compress: false},
+		{name: "compressed", compress: true, wantCompress: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOpts := opts
+			defer func() { opts = originalOpts }()
+			opts.CompressState = tt.compress
+
+			statePath := filepath.Join(t.TempDir(), "state.json")
+			for _, hash := range []string{"first", "second"} {
+				state := newLinkState()
+				state.Hashes["/etc/app/api.key"] = hash
+				if err := state.save(statePath); err != nil {
+					t.Fatalf("save() error = %v", err)
+				}
+			}
+
+			data, _ := os.ReadFile(statePath)
+			if compressed := bytes.HasPrefix(data, gzipMagic); compressed != tt.wantCompress {
+				t.Errorf("Expected compressed = %v, got %v", tt.wantCompress, compressed)
+			}
+
+			// Either form loads regardless of the current setting
+			opts.CompressState = !tt.compress
+			loaded, err := loadState(statePath)
+			if err != nil || loaded.Hashes["/etc/app/api.key"] != "second" {
+				t.Errorf("Expected the saved state to load, got %+v (%v)", loaded, err)
+			}
+			backup, err := readStateFile(stateBackupPath(statePath))
+			if err != nil || backup.Hashes["/etc/app/api.key"] != "first" {
+				t.Errorf("Expected the previous state to be backed up, got %+v (%v)", backup, err)
+			}
+		})
+	}
+}
+
+func TestLoadStateCorruptGzip(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(statePath, append(append([]byte{}, gzipMagic...), "not gzip"...), 0600)
+
+	if _, err := loadState(statePath); err == nil || !strings.Contains(err.Error(), "invalid state file") {
+		t.Errorf("Expected invalid state error, got %v", err)
+	}
+}
+
+func TestValidateOptionsRelinkWithoutHashVerify(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	opts.DirPerm = "0755"
+	opts.RelinkOnChange = true
+	if err := validateOptions(); err == nil {
+		t.Error("Expected error for -relink-on-change without -hash-verify")
+	}
+}
@@ -0,0 +1,1446 @@
+package secretmanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIBase = "https://api.github.com"
+	defaultRepo    = "ohishi-yhonda-org/secret_manager"
+	userAgent      = "secret_manager-updater"
+)
+
+// repoPattern matches a GitHub repository in owner/name form
+var repoPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// validRepo reports whether repo is an owner/name repository. Neither part
+// may be . or .., which would change the API path instead of naming one
+func validRepo(repo string) bool {
+	if !repoPattern.MatchString(repo) {
+		return false
+	}
+	for _, part := range strings.Split(repo, "/") {
+		if part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+type GitHubRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	PublishedAt time.Time `json:"published_at"`
+	AssetsURL   string    `json:"assets_url"`
+	Assets      []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+		Size               int64  `json:"size"`
+	} `json:"assets"`
+}
+
+// httpClient is a variable to allow mocking in tests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// downloadAndInstallFunc is a variable to allow mocking in tests
+var downloadAndInstallFunc = downloadAndInstall
+
+// patchAndInstallFunc is a variable to allow mocking in tests
+var patchAndInstallFunc = patchAndInstall
+
+// bspatchFunc is a variable to allow mocking in tests
+var bspatchFunc = bspatch
+
+// stageUpdateFunc is a variable to allow mocking in tests
+var stageUpdateFunc = stageUpdate
+
+// replaceExecutableFunc is a variable to allow mocking in tests
+var replaceExecutableFunc = replaceExecutable
+
+// osCreateTemp is a variable to allow mocking in tests
+var osCreateTemp = os.CreateTemp
+
+// httpNewRequest is a variable to allow mocking in tests
+var httpNewRequest = http.NewRequest
+
+// ioCopy is a variable to allow mocking in tests
+var ioCopy = io.Copy
+
+// zipFileOpen is a variable to allow mocking in tests
+var zipFileOpen = func(f *zip.File) (io.ReadCloser, error) {
+	return f.Open()
+}
+
+// osChmod is a variable to allow mocking in tests
+var osChmod = os.Chmod
+
+// osRename is a variable to allow mocking in tests
+var osRename = os.Rename
+
+// osReadFile is a variable to allow mocking in tests
+var osReadFile = os.ReadFile
+
+// osRemove is a variable to allow mocking in tests
+var osRemove = os.Remove
+
+// osWriteFile is a variable to allow mocking in tests
+var osWriteFile = os.WriteFile
+
+// isWindows is a variable to allow mocking in tests
+var isWindows = func() bool {
+	return runtime.GOOS == "windows"
+}
+
+// isRosettaTranslated is a variable to allow mocking in tests. It reports
+// whether this is an amd64 build running under Rosetta on Apple Silicon
+var isRosettaTranslated = func() bool {
+	if runtime.GOOS != "darwin" || runtime.GOARCH != "amd64" {
+		return false
+	}
+	out, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "1"
+}
+
+// stdinIsTerminal is a variable to allow mocking in tests
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptInput is where confirmation answers are read from
+var promptInput io.Reader = os.Stdin
+
+// goOS is a variable to allow mocking in tests
+var goOS = func() string {
+	return runtime.GOOS
+}
+
+// goArch is a variable to allow mocking in tests
+var goArch = func() string {
+	return runtime.GOARCH
+}
+
+// assetOS returns the operating system to select update assets for: the one
+// given with -target-os, or the running one
+func assetOS() string {
+	if opts.TargetOS != "" {
+		return opts.TargetOS
+	}
+	return goOS()
+}
+
+// crossPlatform reports whether -target-os or -target-arch asks for another
+// platform's binary, which is saved with -download-to rather than installed
+func crossPlatform() bool {
+	return opts.TargetOS != "" || opts.TargetArch != ""
+}
+
+// armVariant is a variable to allow mocking in tests. It returns the GOARM
+// level this binary was built for (e.g. "7"), or "" if it is unknown
+var armVariant = func() string {
+	goarm := os.Getenv("GOARM")
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "GOARM" {
+				goarm = setting.Value
+			}
+		}
+	}
+	// Newer toolchains append the float mode, e.g. "7,softfloat"
+	level, _, _ := strings.Cut(goarm, ",")
+	return level
+}
+
+func checkAndUpdate() error {
+	// Every request of this update shares one connection limit; the idle
+	// timeout sits underneath it so waiting for a slot is not counted
+	client := httpClient
+	httpClient = withConnectionLimit(withIdleTimeout(withHTTPTrace(client, opts.HTTPTrace), opts.IdleTimeout), opts.MaxConnections)
+	defer func() { httpClient = client }()
+
+	// With -print-download-url stdout carries only the URLs, for scripts
+	progress := os.Stdout
+	if opts.PrintDownloadURL {
+		progress = os.Stderr
+	}
+
+	// The replaced executable would be lost when the container restarts
+	if !opts.AllowContainerUpdate && !opts.PrintDownloadURL && opts.DownloadTo == "" && isContainer(readContainerSignals()) {
+		fmt.Println("Running inside a container, skipping update; rebuild the image instead or use -allow-container-update")
+		return nil
+	}
+
+	fmt.Fprintln(progress, "Checking for updates...")
+
+	// Get latest release info
+	release, err := getLatestRelease()
+	if err != nil {
+		return fmt.Errorf("failed to get latest release: %w", err)
+	}
+
+	// Saving a binary is not an update of this one, so the latest release is
+	// fetched whatever version is running
+	if opts.DownloadTo != "" {
+		return downloadTo(progress, release)
+	}
+
+	// Compare versions
+	latestVersion := strings.TrimPrefix(release.TagName, "v")
+	currentVersion := strings.TrimPrefix(version, "v")
+
+	if currentVersion == "dev" {
+		fileVersion := readVersionFile()
+		if fileVersion == "" {
+			fmt.Fprintln(progress, "Running development version, skipping update check")
+			return nil
+		}
+		fmt.Fprintf(progress, "Running development version, using VERSION file (%s) for comparison\n", fileVersion)
+		currentVersion = strings.TrimPrefix(fileVersion, "v")
+	}
+
+	if latestVersion == currentVersion {
+		fmt.Fprintf(progress, "Already running the latest version (%s)\n", version)
+		return nil
+	}
+
+	if !opts.AllowDowngrade {
+		if reason := downgradeReason(release, currentVersion); reason != "" {
+			if err := confirmDowngrade(progress, release.TagName, reason); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(progress, "New version available: %s (current: %s)\n", release.TagName, version)
+
+	// Find appropriate asset for current platform
+	assetURL, checksumURL, err := selectAsset(progress, release)
+	if err != nil {
+		return err
+	}
+
+	// Leave the download to the operator, e.g. for air-gapped hosts
+	if opts.PrintDownloadURL {
+		fmt.Println(assetURL)
+		if checksumURL != "" {
+			fmt.Println(checksumURL)
+		}
+		return nil
+	}
+
+	if opts.DryRun {
+		exePath, err := osExecutable()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Dry run: would download %s\n", assetURL)
+		fmt.Printf("Dry run: would install to %s\n", exePath)
+		return nil
+	}
+
+	if opts.UpdateBackground {
+		fmt.Println("Downloading update in the background...")
+		if err := stageUpdateFunc(assetURL, checksumURL); err != nil {
+			return fmt.Errorf("failed to stage update: %w", err)
+		}
+		recordPublishedAt(stagedUpdatePath, release.PublishedAt)
+		fmt.Println("Update downloaded and staged; it will be installed the next time secret_manager starts.")
+		return nil
+	}
+
+	// Prefer a small binary patch from the current version when one is published.
+	// Patches keep the running architecture, so not when switching off Rosetta
+	if patchURL, checksumURL := findPatchURLs(release, currentVersion); patchURL != "" && !isRosettaTranslated() {
+		fmt.Println("Downloading incremental update...")
+		err := patchAndInstallFunc(patchURL, checksumURL)
+		if err == nil {
+			recordPublishedAt(nil, release.PublishedAt)
+			fmt.Println("Update completed successfully!")
+			fmt.Println("Please restart the application to use the new version.")
+			return nil
+		}
+		fmt.Printf("Incremental update failed (%v), falling back to full download\n", err)
+	}
+
+	// Download and install update
+	fmt.Println("Downloading update...")
+	if err := downloadAndInstallFunc(assetURL, checksumURL); err != nil {
+		return fmt.Errorf("failed to install update: %w", err)
+	}
+	recordPublishedAt(nil, release.PublishedAt)
+
+	fmt.Println("Update completed successfully!")
+	fmt.Println("Please restart the application to use the new version.")
+	return nil
+}
+
+// selectAsset returns the URLs of the release asset to download and of its
+// checksum, and reports the download size
+func selectAsset(progress io.Writer, release *GitHubRelease) (string, string, error) {
+	var assetURL string
+	if opts.AssetRegex != "" {
+		var err error
+		assetURL, err = findAssetByRegex(release, opts.AssetRegex)
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		assetURL = findAssetURL(release)
+	}
+	if assetURL == "" {
+		return "", "", fmt.Errorf("no suitable binary found for %s/%s", assetOS(), assetArchs()[0])
+	}
+	checksumURL := findChecksumURL(release, assetURL)
+	if size := findAssetSize(release, assetURL); size > 0 {
+		fmt.Fprintf(progress, "Download size: %s\n", formatSize(size))
+	}
+	return assetURL, checksumURL, nil
+}
+
+// downloadTo saves the selected asset of release to -download-to instead of
+// replacing the running executable, e.g. to stage a binary for another
+// platform from a CI host
+func downloadTo(progress io.Writer, release *GitHubRelease) error {
+	assetURL, checksumURL, err := selectAsset(progress, release)
+	if err != nil {
+		return err
+	}
+
+	if opts.PrintDownloadURL {
+		fmt.Println(assetURL)
+		if checksumURL != "" {
+			fmt.Println(checksumURL)
+		}
+		return nil
+	}
+
+	if opts.DryRun {
+		fmt.Printf("Dry run: would download %s\n", assetURL)
+		fmt.Printf("Dry run: would save to %s\n", opts.DownloadTo)
+		return nil
+	}
+
+	fmt.Fprintf(progress, "Downloading %s...\n", release.TagName)
+	updatePath, cleanup, err := downloadUpdate(assetURL, checksumURL)
+	if err != nil {
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	defer cleanup()
+
+	// Copied rather than renamed, as the temporary directory may be on
+	// another filesystem
+	data, err := os.ReadFile(updatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	if err := osWriteFile(opts.DownloadTo, data, 0755); err != nil {
+		return fmt.Errorf("failed to save download: %w", err)
+	}
+
+	fmt.Fprintf(progress, "Saved %s to %s\n", release.TagName, opts.DownloadTo)
+	return nil
+}
+
+// downgradeReason explains why installing release over currentVersion would
+// be a downgrade, or returns "" if it would not. A release published before
+// the installed one is a rollback, however its version string compares
+func downgradeReason(release *GitHubRelease, currentVersion string) string {
+	latestVersion := strings.TrimPrefix(release.TagName, "v")
+	if cmp, ok := compareVersions(latestVersion, currentVersion); ok && cmp < 0 {
+		return fmt.Sprintf("version %s is older than the installed %s", latestVersion, currentVersion)
+	}
+
+	installedAt := readPublishedAt()
+	if !installedAt.IsZero() && !release.PublishedAt.IsZero() && release.PublishedAt.Before(installedAt) {
+		return fmt.Sprintf("published %s, before the installed release (%s)",
+			release.PublishedAt.Format(time.RFC3339), installedAt.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// confirmDowngrade asks before installing an older release. Without
+// -assume-yes-for-downgrade the answer must come from a terminal; otherwise
+// the downgrade is refused
+func confirmDowngrade(w io.Writer, tag, reason string) error {
+	if opts.AssumeYesForDowngrade {
+		fmt.Fprintf(w, "Installing older release %s (%s)\n", tag, reason)
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("refusing to install %s: %s; use -allow-downgrade or -assume-yes-for-downgrade to install it anyway", tag, reason)
+	}
+
+	if askConfirmation(w, fmt.Sprintf("%s is a downgrade: %s.\nInstall it anyway?", tag, reason)) {
+		return nil
+	}
+	return fmt.Errorf("downgrade to %s cancelled", tag)
+}
+
+// askConfirmation writes question and reads a yes/no answer from
+// promptInput. Anything but "y" or "yes" is a no
+func askConfirmation(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(promptInput).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions such as "1.10.2",
+// ignoring any pre-release or build suffix. ok is false if either version
+// is not in that form
+func compareVersions(a, b string) (int, bool) {
+	partsA, okA := parseVersion(a)
+	partsB, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func parseVersion(v string) ([]int, bool) {
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// apiBase returns the base URL of the GitHub API, honoring --api-base
+func apiBase() string {
+	if opts.APIBase != "" {
+		return strings.TrimSuffix(opts.APIBase, "/")
+	}
+	return defaultAPIBase
+}
+
+// releasesURL builds the releases API URL for the configured repository,
+// honoring the --api-base and --repo overrides
+func releasesURL(path string) (string, error) {
+	base := apiBase()
+
+	repo := defaultRepo
+	if opts.Repo != "" {
+		if !validRepo(opts.Repo) {
+			return "", fmt.Errorf("invalid repository %q: expected owner/name", opts.Repo)
+		}
+		repo = opts.Repo
+	}
+
+	return fmt.Sprintf("%s/repos/%s/releases/%s", base, repo, path), nil
+}
+
+// readVersionFile returns the version recorded in a VERSION file beside the
+// executable, or an empty string if there is none
+func readVersionFile() string {
+	exeDir, err := executableDir()
+	if err != nil {
+		return ""
+	}
+
+	data, err := osReadFile(filepath.Join(exeDir, "VERSION"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// publishedAtPath returns the file recording when the release installed at
+// exePath was published
+func publishedAtPath(exePath string) string {
+	return exePath + ".published"
+}
+
+// readPublishedAt returns the publish date of the installed release, or the
+// zero time if it is unknown
+func readPublishedAt() time.Time {
+	exePath, err := osExecutable()
+	if err != nil {
+		return time.Time{}
+	}
+
+	data, err := osReadFile(publishedAtPath(exePath))
+	if err != nil {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// recordPublishedAt saves the publish date of a release just installed, next
+// to the executable or, with pathFunc, next to the path it maps the
+// executable to. Failing to record it only weakens the downgrade check
+func recordPublishedAt(pathFunc func(string) string, publishedAt time.Time) {
+	if publishedAt.IsZero() {
+		return
+	}
+
+	exePath, err := osExecutable()
+	if err != nil {
+		return
+	}
+	if pathFunc != nil {
+		exePath = pathFunc(exePath)
+	}
+
+	data := []byte(publishedAt.UTC().Format(time.RFC3339) + "\n")
+	if err := osWriteFile(publishedAtPath(exePath), data, 0644); err != nil {
+		warnf("Warning: failed to record release date: %v\n", err)
+	}
+}
+
+func getLatestRelease() (*GitHubRelease, error) {
+	if opts.ReleaseFile != "" {
+		return readReleaseFile(opts.ReleaseFile)
+	}
+
+	apiURL, err := releasesURL("latest")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := httpNewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	setGitHubAuth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, githubStatusError(resp)
+	}
+
+	var release GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+
+	// The inline list is capped; a full page may be hiding more assets
+	if len(release.Assets) >= inlineAssetLimit && release.AssetsURL != "" {
+		if err := fetchAllAssets(&release); err != nil {
+			return nil, fmt.Errorf("failed to list release assets: %w", err)
+		}
+	}
+
+	return &release, nil
+}
+
+// readReleaseFile decodes a release saved from the GitHub API, so that
+// updates can be tested offline. Its assets may use file:// URLs
+func readReleaseFile(path string) (*GitHubRelease, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release file: %w", err)
+	}
+
+	var release GitHubRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release file: %w", err)
+	}
+	return &release, nil
+}
+
+// fileURLPath returns the local path of a file:// URL, reporting whether
+// rawURL is one
+func fileURLPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	path := u.Path
+	// file:///C:/dir/file has the path /C:/dir/file
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), true
+}
+
+// inlineAssetLimit is the most assets GitHub includes in a release response
+const inlineAssetLimit = 100
+
+// maxAssetPages bounds how many pages of assets are fetched
+const maxAssetPages = 50
+
+// fetchAllAssets replaces the release's inline assets with the complete list
+// from its paginated assets endpoint, following the Link header
+func fetchAllAssets(release *GitHubRelease) error {
+	pageURL := release.AssetsURL + "?per_page=100"
+	assets := release.Assets[:0:0] // Empty, of the same anonymous element type
+
+	for page := 0; pageURL != "" && page < maxAssetPages; page++ {
+		req, err := httpNewRequest("GET", pageURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		setGitHubAuth(req)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return githubStatusError(resp)
+		}
+
+		pageAssets := release.Assets[:0:0]
+		err = json.NewDecoder(resp.Body).Decode(&pageAssets)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		assets = append(assets, pageAssets...)
+		pageURL = nextPageURL(resp.Header.Get("Link"))
+	}
+
+	release.Assets = assets
+	return nil
+}
+
+// nextPageURL returns the rel="next" URL of a GitHub Link header, if any
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		fields := strings.Split(part, ";")
+		if len(fields) < 2 {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(fields[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+func findAssetURL(release *GitHubRelease) string {
+	// Under Rosetta, self-heal to the native build when one is published
+	if !crossPlatform() && isRosettaTranslated() {
+		if url := findPlatformAsset(release, runtime.GOOS+"-arm64"); url != "" {
+			return url
+		}
+	}
+
+	osName, windows := runtime.GOOS, isWindows()
+	if opts.TargetOS != "" {
+		osName, windows = opts.TargetOS, opts.TargetOS == "windows"
+	}
+
+	for _, arch := range assetArchs() {
+		platform := fmt.Sprintf("%s-%s", osName, arch)
+		
+		// Special case for Windows
+		if windows {
+			platform = fmt.Sprintf("windows-%s.exe", arch)
+		}
+
+		if url := findPlatformAsset(release, platform); url != "" {
+			return url
+		}
+	}
+
+	return ""
+}
+
+// findAssetByRegex returns the URL of the one binary asset whose name matches
+// pattern, for naming schemes the platform heuristic cannot handle
+func findAssetByRegex(release *GitHubRelease, pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid -asset-regex: %w", err)
+	}
+
+	var names []string
+	var url string
+	for _, asset := range release.Assets {
+		if isAuxiliaryAsset(asset.Name) || !re.MatchString(asset.Name) {
+			continue
+		}
+		names = append(names, asset.Name)
+		url = asset.BrowserDownloadURL
+	}
+
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no asset in release %s matches -asset-regex %q", release.TagName, pattern)
+	case 1:
+		return url, nil
+	default:
+		return "", fmt.Errorf("-asset-regex %q matches %d assets (%s); make it more specific", pattern, len(names), strings.Join(names, ", "))
+	}
+}
+
+// assetArchs returns the architecture names to look for in asset names, most
+// specific first. 32-bit ARM releases usually encode the ARM version (armv6,
+// armv7), and an armv7 CPU can also run armv6 builds
+func assetArchs() []string {
+	if opts.TargetArch != "" {
+		return []string{opts.TargetArch}
+	}
+
+	arch := goArch()
+	if arch != "arm" {
+		return []string{arch}
+	}
+
+	switch armVariant() {
+	case "7":
+		return []string{"armv7", "armv6", "arm"}
+	case "5":
+		return []string{"armv5", "arm"}
+	default:
+		return []string{"armv6", "arm"}
+	}
+}
+
+func findPlatformAsset(release *GitHubRelease, platform string) string {
+	for _, asset := range release.Assets {
+		if isAuxiliaryAsset(asset.Name) {
+			continue
+		}
+		if matchesPlatform(asset.Name, platform) {
+			return asset.BrowserDownloadURL
+		}
+	}
+
+	return ""
+}
+
+// matchesPlatform reports whether name contains platform as a whole word, so
+// "linux-arm" does not match "linux-arm64" or "linux-armv7"
+func matchesPlatform(name, platform string) bool {
+	for offset := 0; ; {
+		i := strings.Index(name[offset:], platform)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(platform)
+		if end == len(name) || !isAlphanumeric(name[end]) {
+			return true
+		}
+		offset += i + 1
+	}
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// findChecksumURL returns the URL of the "<asset>.sha256" file published
+// alongside the asset at assetURL, if any
+func findChecksumURL(release *GitHubRelease, assetURL string) string {
+	var assetName string
+	for _, asset := range release.Assets {
+		if asset.BrowserDownloadURL == assetURL {
+			assetName = asset.Name
+		}
+	}
+	if assetName == "" {
+		return ""
+	}
+
+	for _, asset := range release.Assets {
+		if asset.Name == assetName+".sha256" {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return ""
+}
+
+// findAssetSize returns the size the release declares for the asset at
+// assetURL, or 0 if it is not known
+func findAssetSize(release *GitHubRelease, assetURL string) int64 {
+	for _, asset := range release.Assets {
+		if asset.BrowserDownloadURL == assetURL {
+			return asset.Size
+		}
+	}
+	return 0
+}
+
+// formatSize formats a byte count for people, e.g. "12.5 MiB"
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// isAuxiliaryAsset reports whether an asset is a patch or checksum rather than a binary
+func isAuxiliaryAsset(name string) bool {
+	return strings.HasSuffix(name, ".bspatch") || strings.HasSuffix(name, ".sha256")
+}
+
+// findPatchURLs returns the URLs of a binary patch from currentVersion for this
+// platform and of the checksum of the patched executable, if the release has both
+func findPatchURLs(release *GitHubRelease, currentVersion string) (string, string) {
+	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	suffixes := []string{
+		"-from-v" + currentVersion + ".bspatch",
+		"-from-" + currentVersion + ".bspatch",
+	}
+
+	var patchName, patchURL string
+	for _, asset := range release.Assets {
+		if !strings.Contains(asset.Name, platform) {
+			continue
+		}
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(asset.Name, suffix) {
+				patchName, patchURL = asset.Name, asset.BrowserDownloadURL
+			}
+		}
+	}
+	if patchURL == "" {
+		return "", ""
+	}
+
+	for _, asset := range release.Assets {
+		if asset.Name == patchName+".sha256" {
+			return patchURL, asset.BrowserDownloadURL
+		}
+	}
+
+	return "", ""
+}
+
+// patchAndInstall downloads a binary patch, applies it to the running
+// executable, verifies the result against the published checksum and installs it
+func patchAndInstall(patchURL, checksumURL string) error {
+	exePath, err := osExecutable()
+	if err != nil {
+		return err
+	}
+
+	current, err := osReadFile(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to read current executable: %w", err)
+	}
+
+	patch, err := downloadBytes(patchURL)
+	if err != nil {
+		return fmt.Errorf("failed to download patch: %w", err)
+	}
+
+	expected, err := fetchChecksum(checksumURL)
+	if err != nil {
+		return err
+	}
+
+	patched, err := bspatchFunc(current, patch)
+	if err != nil {
+		return fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	sum := sha256.Sum256(patched)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), expected) {
+		return fmt.Errorf("checksum mismatch for patched executable")
+	}
+
+	tempFile, err := osCreateTemp("", "secret_manager_patched_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(patched)
+	tempFile.Close()
+	if err != nil {
+		return err
+	}
+	if !isWindows() {
+		osChmod(tempFile.Name(), 0755)
+	}
+
+	return replaceExecutableFunc(exePath, tempFile.Name())
+}
+
+// probeAsset sends a HEAD request for an asset and returns its size, or -1
+// if unknown. Assets are often served from a CDN host that a proxy treats
+// differently from the API host, so this checks that host is reachable
+func probeAsset(assetURL string) (int64, error) {
+	req, err := httpNewRequest("HEAD", assetURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("cannot reach download host %s: %w", req.URL.Host, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK, resp.StatusCode == http.StatusPartialContent:
+		return resp.ContentLength, nil
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		return -1, nil // The host is up but does not answer HEAD
+	default:
+		return 0, fmt.Errorf("download host %s returned status %d", resp.Request.URL.Host, resp.StatusCode)
+	}
+}
+
+// fetchChecksum downloads a sha256sum-style checksum file and returns the
+// hex digest it lists first
+func fetchChecksum(checksumURL string) (string, error) {
+	checksum, err := downloadBytes(checksumURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksum: %w", err)
+	}
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	return fields[0], nil
+}
+
+// verifyChecksum compares a digest computed during download with the
+// published checksum
+func verifyChecksum(sum []byte, expected string) error {
+	if !strings.EqualFold(hex.EncodeToString(sum), expected) {
+		return fmt.Errorf("checksum mismatch for downloaded update")
+	}
+	return nil
+}
+
+// downloadBytes fetches a small asset into memory
+func downloadBytes(url string) ([]byte, error) {
+	if path, ok := fileURLPath(url); ok {
+		return os.ReadFile(path)
+	}
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func downloadAndInstall(url, checksumURL string) error {
+	// Get current executable path
+	exePath, err := osExecutable()
+	if err != nil {
+		return err
+	}
+
+	updatePath, cleanup, err := downloadUpdate(url, checksumURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Replace current executable
+	return replaceExecutableFunc(exePath, updatePath)
+}
+
+// downloadUpdate downloads the asset at url, extracting it if it is an archive,
+// and returns the path of the new executable along with a cleanup function
+// that removes the temporary files
+func downloadUpdate(url, checksumURL string) (string, func(), error) {
+	// Download to temporary file
+	tempFile, err := osCreateTemp("", "secret_manager_update_*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(tempFile.Name()) }
+
+	// Fetch the checksum first: the asset body holds a connection until it
+	// is closed, which under -max-connections 1 would leave none for it
+	var expected string
+	if checksumURL != "" {
+		if expected, err = fetchChecksum(checksumURL); err != nil {
+			tempFile.Close()
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	body, err := openAsset(url)
+	if err != nil {
+		tempFile.Close()
+		cleanup()
+		return "", nil, err
+	}
+
+	// Hash while downloading rather than reading the file back afterwards
+	hasher := sha256.New()
+	_, err = ioCopy(io.MultiWriter(tempFile, hasher), body)
+	body.Close()
+	tempFile.Close()
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	if checksumURL != "" {
+		if err := verifyChecksum(hasher.Sum(nil), expected); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	// Extract if archive, otherwise use directly. The content decides the
+	// format so that a misnamed asset still extracts; the suffix is only
+	// consulted when the magic number is not recognised
+	archiveType := detectArchiveType(tempFile.Name())
+	if archiveType == "" {
+		if strings.HasSuffix(url, ".zip") {
+			archiveType = archiveZip
+		} else if strings.HasSuffix(url, ".tar.gz") {
+			archiveType = archiveTarGz
+		}
+	}
+	var updatePath string
+	switch archiveType {
+	case archiveZip:
+		updatePath, err = extractZip(tempFile.Name())
+	case archiveTarGz:
+		updatePath, err = extractTarGz(tempFile.Name())
+	default:
+		updatePath = tempFile.Name()
+	}
+	
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+	if updatePath != tempFile.Name() {
+		removeArchive := cleanup
+		cleanup = func() {
+			removeArchive()
+			os.Remove(updatePath)
+		}
+	}
+
+	// Catch an asset built for another platform before it replaces us
+	if err := validateExecutable(updatePath); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return updatePath, cleanup, nil
+}
+
+// openAsset opens the asset at url for reading, from the local filesystem
+// for a file:// URL and over HTTP otherwise
+func openAsset(url string) (io.ReadCloser, error) {
+	if path, ok := fileURLPath(url); ok {
+		return os.Open(path)
+	}
+
+	// Fail fast if the asset host is unreachable. The size was already
+	// reported from the release when the asset was chosen
+	if _, err := probeAsset(url); err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// validateExecutable is a variable to allow mocking in tests
+var validateExecutable = validateExecutableFile
+
+// readFileHeader is a variable to allow mocking in tests. It returns up to
+// the first n bytes of the file at path
+var readFileHeader = func(path string, n int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, n)
+	read, err := io.ReadFull(file, header)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return header[:read], err
+}
+
+// executableMagics lists the leading bytes of executables by format
+var executableMagics = map[string][][]byte{
+	"ELF": {{0x7f, 'E', 'L', 'F'}},
+	"Mach-O": {
+		{0xfe, 0xed, 0xfa, 0xce}, {0xce, 0xfa, 0xed, 0xfe}, // 32-bit
+		{0xfe, 0xed, 0xfa, 0xcf}, {0xcf, 0xfa, 0xed, 0xfe}, // 64-bit
+		{0xca, 0xfe, 0xba, 0xbe}, // universal
+	},
+	"PE": {{'M', 'Z'}},
+}
+
+// executableFormat returns the executable format used on goos, or "" when
+// it is not known
+func executableFormat(goos string) string {
+	switch goos {
+	case "windows":
+		return "PE"
+	case "darwin", "ios":
+		return "Mach-O"
+	case "linux", "android", "freebsd", "netbsd", "openbsd", "dragonfly", "solaris", "illumos":
+		return "ELF"
+	}
+	return ""
+}
+
+// validateExecutableFormat checks the magic bytes of the file at path against
+// the executable format of this platform, so that a download for the wrong
+// platform is refused
+func validateExecutableFormat(path string) error {
+	format := executableFormat(assetOS())
+	if format == "" {
+		return nil
+	}
+
+	header, err := readFileHeader(path, 4)
+	if err != nil {
+		return fmt.Errorf("failed to read downloaded executable: %w", err)
+	}
+	for _, magic := range executableMagics[format] {
+		if bytes.HasPrefix(header, magic) {
+			return nil
+		}
+	}
+	return fmt.Errorf("downloaded update is not a valid %s executable for %s", format, assetOS())
+}
+
+// stagedUpdatePath returns where -update-background stages the next executable
+func stagedUpdatePath(exePath string) string {
+	return exePath + ".staged"
+}
+
+// stageUpdate downloads the update beside the running executable without
+// replacing it, so that the next startup can swap it in
+func stageUpdate(url, checksumURL string) error {
+	exePath, err := osExecutable()
+	if err != nil {
+		return err
+	}
+
+	updatePath, cleanup, err := downloadUpdate(url, checksumURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := osRename(updatePath, stagedUpdatePath(exePath)); err != nil {
+		return fmt.Errorf("failed to stage update: %w", err)
+	}
+
+	return nil
+}
+
+// applyStagedUpdate installs an update previously staged by -update-background
+func applyStagedUpdate() error {
+	exePath, err := osExecutable()
+	if err != nil {
+		return err
+	}
+
+	stagedPath := stagedUpdatePath(exePath)
+	if _, err := statFunc(stagedPath); err != nil {
+		return nil // Nothing staged
+	}
+
+	if err := replaceExecutableFunc(exePath, stagedPath); err != nil {
+		return fmt.Errorf("failed to apply staged update: %w", err)
+	}
+	osRename(publishedAtPath(stagedPath), publishedAtPath(exePath))
+
+	fmt.Fprintf(os.Stderr, "Applied staged update from %s; restart to use the new version\n", stagedPath)
+	return nil
+}
+
+// createExtractFile creates a uniquely named file to extract an executable
+// into, so concurrent or repeated updates never share a path. The caller
+// removes it once installed
+func createExtractFile(name string) (*os.File, error) {
+	pattern := "secret_manager_extract_*"
+	if strings.HasSuffix(strings.ToLower(name), ".exe") {
+		pattern += ".exe"
+	}
+	return osCreateTemp("", pattern)
+}
+
+// Archive types recognised by detectArchiveType
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+// detectArchiveType reports the archive format of the file at path from its
+// magic number: gzip (1f 8b) or zip (PK). It returns "" for anything else,
+// including files it cannot read
+func detectArchiveType(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return ""
+	}
+	switch {
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		return archiveTarGz
+	case magic[0] == 'P' && magic[1] == 'K':
+		return archiveZip
+	}
+	return ""
+}
+
+func extractZip(archivePath string) (string, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if strings.Contains(file.Name, "secret_manager") {
+			rc, err := zipFileOpen(file)
+			if err != nil {
+				return "", err
+			}
+			defer rc.Close()
+
+			out, err := createExtractFile(file.Name)
+			if err != nil {
+				return "", err
+			}
+			defer out.Close()
+
+			_, err = ioCopy(out, rc)
+			if err != nil {
+				os.Remove(out.Name())
+				return "", err
+			}
+
+			return out.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("executable not found in archive")
+}
+
+func extractTarGz(archivePath string) (string, error) {
+	var extractPath string
+	err := walkTarGz(archivePath, func(header *tar.Header, r io.Reader) (bool, error) {
+		if !strings.Contains(header.Name, "secret_manager") {
+			return false, nil
+		}
+
+		out, err := createExtractFile(header.Name)
+		if err != nil {
+			return true, err
+		}
+		defer out.Close()
+
+		_, err = ioCopy(out, r)
+		if err != nil {
+			os.Remove(out.Name())
+			return true, err
+		}
+
+		// Set executable permissions on Unix-like systems
+		if !isWindows() {
+			osChmod(out.Name(), 0755)
+		}
+
+		extractPath = out.Name()
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if extractPath == "" {
+		return "", fmt.Errorf("executable not found in archive")
+	}
+
+	return extractPath, nil
+}
+
+// walkTarGz calls fn for each entry of a gzipped tarball until fn returns
+// true or an error
+func walkTarGz(archivePath string, fn func(header *tar.Header, r io.Reader) (bool, error)) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		done, err := fn(header, tr)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// extractTarGzAll extracts the regular files and directories of a gzipped
+// tarball into destDir. Entries that would land outside destDir are refused
+func extractTarGzAll(archivePath, destDir string) error {
+	return walkTarGz(archivePath, func(header *tar.Header, r io.Reader) (bool, error) {
+		target := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if rel, err := filepath.Rel(destDir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true, fmt.Errorf("archive entry %s is outside the extraction directory", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			return false, os.MkdirAll(target, 0700)
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return true, err
+			}
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return true, err
+			}
+			_, err = ioCopy(out, r)
+			out.Close()
+			return err != nil, err
+		default:
+			return false, nil // Links and special files are not extracted
+		}
+	})
+}
+
+func replaceExecutable(currentPath, newPath string) error {
+	// On Windows, we need to rename the current executable first
+	if isWindows() {
+		backupPath := currentPath + ".old"
+		
+		// Remove old backup if exists
+		osRemove(backupPath)
+		
+		// Rename current executable
+		if err := osRename(currentPath, backupPath); err != nil {
+			return fmt.Errorf("failed to backup current executable: %w", err)
+		}
+
+		// Move new executable
+		if err := osRename(newPath, currentPath); err != nil {
+			// Try to restore backup
+			osRename(backupPath, currentPath)
+			return fmt.Errorf("failed to install new executable: %w", err)
+		}
+
+		// Schedule old executable deletion (will happen after process exits)
+		go func() {
+			time.Sleep(5 * time.Second)
+			osRemove(backupPath)
+		}()
+	} else {
+		// Keep the mode of the executable being replaced
+		mode := os.FileMode(0755)
+		if info, err := statFunc(currentPath); err == nil && info.Mode().Perm()&0111 != 0 {
+			mode = info.Mode().Perm()
+		}
+
+		// On Unix-like systems, we can directly replace
+		if err := osRename(newPath, currentPath); err != nil {
+			return fmt.Errorf("failed to install new executable: %w", err)
+		}
+
+		// The downloaded file may have lost its execute bits on the way
+		if err := osChmod(currentPath, mode); err != nil {
+			return fmt.Errorf("failed to set permissions of new executable: %w", err)
+		}
+	}
+
+	return nil
+}
\ No newline at end of file
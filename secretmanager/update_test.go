@@ -0,0 +1,3702 @@
+package secretmanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// UPDATE FUNCTIONALITY TESTS
+// =============================================================================
+// This file contains all tests related to:
+// - Update checking and downloading
+// - GitHub API integration
+// - Archive extraction (ZIP, TAR.GZ)
+// - HTTP operations
+// - File replacement
+// - Platform-specific functionality
+// =============================================================================
+
+// =============================================================================
+// CORE UPDATE TESTS
+// =============================================================================
+// Tests for main update flow and version checking
+// =============================================================================
+
+func TestCheckAndUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		currentVersion string
+		latestVersion  string
+		expectUpdate   bool
+		wantErr        bool
+	}{
+		{
+			name:           "dev version",
+			currentVersion: "dev",
+			latestVersion:  "v1.0.0",
+			expectUpdate:   false,
+			wantErr:        false,
+		},
+		{
+			name:           "same version",
+			currentVersion: "v1.0.0",
+			latestVersion:  "v1.0.0",
+			expectUpdate:   false,
+			wantErr:        false,
+		},
+		{
+			name:           "update available",
+			currentVersion: "v1.0.0",
+			latestVersion:  "v1.1.0",
+			expectUpdate:   true,
+			wantErr:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Save original version
+			originalVersion := version
+			version = tt.currentVersion
+			defer func() { version = originalVersion }()
+
+			// Mock HTTP server
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				release := GitHubRelease{
+					TagName: tt.latestVersion,
+					Name:    "Test Release",
+				}
+				
+				if tt.expectUpdate {
+					// Add mock asset
+					assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+					if runtime.GOOS == "windows" {
+						assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+					}
+					release.Assets = []struct {
+						Name               string `json:"name"`
+						BrowserDownloadURL string `json:"browser_download_url"`
+						Size               int64  `json:"size"`
+					}{
+						{
+							Name:               assetName,
+							BrowserDownloadURL: "http://example.com/download",
+						},
+					}
+				}
+
+				json.NewEncoder(w).Encode(release)
+			}))
+			defer server.Close()
+
+			// Mock HTTP client
+			originalClient := httpClient
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			
+			// Mock downloadAndInstall for update available case
+			originalDownload := downloadAndInstallFunc
+			if tt.expectUpdate {
+				downloadAndInstallFunc = func(url, checksumURL string) error {
+					return nil
+				}
+			}
+			
+			defer func() { 
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+			}()
+
+			err := checkAndUpdate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkAndUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type mockTransport struct {
+	server *httptest.Server
+}
+
+func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Redirect to test server
+	req.URL.Scheme = "http"
+	req.URL.Host = m.server.Listener.Addr().String()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// =============================================================================
+// GITHUB API TESTS
+// =============================================================================
+// Tests for GitHub API integration and release fetching
+// =============================================================================
+
+func TestGetLatestRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("Expected User-Agent %s, got %s", userAgent, r.Header.Get("User-Agent"))
+		}
+
+		release := GitHubRelease{
+			TagName: "v1.0.0",
+			Name:    "Test Release",
+			Assets: []struct {
+				Name               string `json:"name"`
+				BrowserDownloadURL string `json:"browser_download_url"`
+				Size               int64  `json:"size"`
+			}{
+				{
+					Name:               "secret_manager-linux-amd64",
+					BrowserDownloadURL: "http://example.com/linux",
+				},
+				{
+					Name:               "secret_manager-windows-amd64.exe",
+					BrowserDownloadURL: "http://example.com/windows",
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(release)
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+	defer func() { httpClient = originalClient }()
+
+	release, err := getLatestRelease()
+	if err != nil {
+		t.Fatalf("getLatestRelease() error = %v", err)
+	}
+
+	if release.TagName != "v1.0.0" {
+		t.Errorf("Expected tag v1.0.0, got %s", release.TagName)
+	}
+
+	if len(release.Assets) != 2 {
+		t.Errorf("Expected 2 assets, got %d", len(release.Assets))
+	}
+}
+
+func TestGetLatestReleaseErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverFunc    func(w http.ResponseWriter, r *http.Request)
+		expectedError string
+	}{
+		{
+			name: "HTTP error",
+			serverFunc: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Not Found", http.StatusNotFound)
+			},
+			expectedError: "GitHub API returned status 404",
+		},
+		{
+			name: "Invalid JSON",
+			serverFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("invalid json"))
+			},
+			expectedError: "invalid character",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tt.serverFunc))
+			defer server.Close()
+
+			originalClient := httpClient
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			defer func() { httpClient = originalClient }()
+
+			_, err := getLatestRelease()
+			if err == nil {
+				t.Error("Expected error, got nil")
+			} else if !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGetLatestReleaseNetworkError(t *testing.T) {
+	originalClient := httpClient
+	httpClient = &http.Client{
+		Timeout: 1, // 1 nanosecond timeout
+	}
+	defer func() {
+		httpClient = originalClient
+	}()
+	
+	_, err := getLatestRelease()
+	if err == nil {
+		t.Error("Expected error for network timeout")
+	}
+}
+
+func TestGetLatestReleaseWithMockedNewRequest(t *testing.T) {
+	originalHttpNewRequest := httpNewRequest
+	httpNewRequest = func(method, url string, body io.Reader) (*http.Request, error) {
+		return nil, errors.New("mock http.NewRequest error")
+	}
+	defer func() {
+		httpNewRequest = originalHttpNewRequest
+	}()
+	
+	_, err := getLatestRelease()
+	if err == nil || !strings.Contains(err.Error(), "mock http.NewRequest error") {
+		t.Errorf("Expected NewRequest error, got %v", err)
+	}
+}
+
+func TestGetLatestReleasePaginatedAssets(t *testing.T) {
+	originalClient := httpClient
+	originalIsWindows := isWindows
+	defer func() {
+		httpClient = originalClient
+		isWindows = originalIsWindows
+	}()
+	isWindows = func() bool { return false }
+
+	filler := func(from, n int) string {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = fmt.Sprintf(`{"name": "extra-%d.txt", "browser_download_url": "http://example.com/extra-%d"}`, from+i, from+i)
+		}
+		return strings.Join(names, ",")
+	}
+	platformAsset := fmt.Sprintf(`{"name": "secret_manager-%s-%s", "browser_download_url": "http://example.com/binary"}`, runtime.GOOS, runtime.GOARCH)
+
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/releases/latest"):
+			fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets_url": "https://api.github.com/repos/o/r/releases/1/assets", "assets": [%s]}`, filler(0, 100))
+		case strings.HasSuffix(r.URL.Path, "/releases/1/assets"):
+			pages = append(pages, r.URL.RawQuery)
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", `<https://api.github.com/repos/o/r/releases/1/assets?per_page=100&page=2>; rel="next", <https://api.github.com/repos/o/r/releases/1/assets?per_page=100&page=2>; rel="last"`)
+				fmt.Fprintf(w, "[%s]", filler(0, 100))
+				return
+			}
+			fmt.Fprintf(w, "[%s, %s]", filler(100, 20), platformAsset)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+
+	release, err := getLatestRelease()
+	if err != nil {
+		t.Fatalf("getLatestRelease() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("Expected 2 asset pages to be fetched, got %v", pages)
+	}
+	if len(release.Assets) != 121 {
+		t.Errorf("Expected 121 assets, got %d", len(release.Assets))
+	}
+	if url := findAssetURL(release); url != "http://example.com/binary" {
+		t.Errorf("Expected asset from the second page, got %q", url)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{`<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=5>; rel="last"`, "https://api.github.com/x?page=2"},
+		{`<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=1>; rel="first"`, ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := nextPageURL(tt.link); got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestGetLatestReleaseRepoOverride(t *testing.T) {
+	tests := []struct {
+		name          string
+		repo          string
+		apiBase       string
+		expectedPath  string
+		expectedError string
+	}{
+		{
+			name:         "default repository",
+			expectedPath: "/repos/" + defaultRepo + "/releases/latest",
+		},
+		{
+			name:         "repo flag",
+			repo:         "someone/fork",
+			expectedPath: "/repos/someone/fork/releases/latest",
+		},
+		{
+			name:         "repo flag with api base",
+			repo:         "someone/fork",
+			apiBase:      "https://ghe.example.com/api/v3/",
+			expectedPath: "/api/v3/repos/someone/fork/releases/latest",
+		},
+		{
+			name:          "invalid repo",
+			repo:          "not-a-repo",
+			expectedError: "expected owner/name",
+		},
+		{
+			name:          "dot dot owner",
+			repo:          "../secret_manager",
+			expectedError: "expected owner/name",
+		},
+		{
+			name:          "dot name",
+			repo:          "someone/.",
+			expectedError: "expected owner/name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestedPath = r.URL.Path
+				json.NewEncoder(w).Encode(GitHubRelease{TagName: "v1.0.0"})
+			}))
+			defer server.Close()
+
+			originalClient := httpClient
+			originalOpts := opts
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			opts.Repo = tt.repo
+			opts.APIBase = tt.apiBase
+			defer func() {
+				httpClient = originalClient
+				opts = originalOpts
+			}()
+
+			_, err := getLatestRelease()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+				}
+				if requestedPath != "" {
+					t.Errorf("Expected no request, got %s", requestedPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getLatestRelease() error = %v", err)
+			}
+			if requestedPath != tt.expectedPath {
+				t.Errorf("Expected request path %s, got %s", tt.expectedPath, requestedPath)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// ASSET FINDING TESTS
+// =============================================================================
+// Tests for platform-specific asset selection
+// =============================================================================
+
+func TestFindAssetURL(t *testing.T) {
+	release := &GitHubRelease{
+		Assets: []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+			Size               int64  `json:"size"`
+		}{
+			{
+				Name:               "secret_manager-linux-amd64",
+				BrowserDownloadURL: "http://example.com/linux-amd64",
+			},
+			{
+				Name:               "secret_manager-windows-amd64.exe",
+				BrowserDownloadURL: "http://example.com/windows-amd64",
+			},
+			{
+				Name:               "secret_manager-darwin-amd64",
+				BrowserDownloadURL: "http://example.com/darwin-amd64",
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		goos     string
+		goarch   string
+		expected string
+	}{
+		{
+			name:     "linux amd64",
+			goos:     "linux",
+			goarch:   "amd64",
+			expected: "http://example.com/linux-amd64",
+		},
+		{
+			name:     "windows amd64",
+			goos:     "windows",
+			goarch:   "amd64",
+			expected: "http://example.com/windows-amd64",
+		},
+		{
+			name:     "darwin amd64",
+			goos:     "darwin",
+			goarch:   "amd64",
+			expected: "http://example.com/darwin-amd64",
+		},
+		{
+			name:     "unsupported platform",
+			goos:     "freebsd",
+			goarch:   "amd64",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// We can't mock runtime.GOOS and runtime.GOARCH directly
+			// So we'll test with the current platform
+			if tt.goos == runtime.GOOS && tt.goarch == runtime.GOARCH {
+				url := findAssetURL(release)
+				if url != tt.expected {
+					t.Errorf("Expected URL %s, got %s", tt.expected, url)
+				}
+			} else {
+				t.Skip("Skipping test for different platform")
+			}
+		})
+	}
+}
+
+func TestCheckAndUpdateVersionFile(t *testing.T) {
+	tests := []struct {
+		name           string
+		versionFile    string
+		fileExists     bool
+		expectDownload bool
+		expectedOutput string
+	}{
+		{
+			name:           "VERSION file with older version",
+			versionFile:    "v1.0.0\n",
+			fileExists:     true,
+			expectDownload: true,
+			expectedOutput: "New version available: v1.1.0 (current: dev)",
+		},
+		{
+			name:           "VERSION file with latest version",
+			versionFile:    "1.1.0",
+			fileExists:     true,
+			expectDownload: false,
+			expectedOutput: "Already running the latest version (dev)",
+		},
+		{
+			name:           "VERSION file absent",
+			fileExists:     false,
+			expectDownload: false,
+			expectedOutput: "skipping update check",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalVersion := version
+			originalClient := httpClient
+			originalDownload := downloadAndInstallFunc
+			originalReadFile := osReadFile
+			originalExeDir := executableDir
+
+			version = "dev"
+
+			assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+			if runtime.GOOS == "windows" {
+				assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets": [{"name": "%s", "browser_download_url": "http://example.com/asset"}]}`, assetName)
+			}))
+			defer server.Close()
+
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			downloadCalled := false
+			downloadAndInstallFunc = func(url, checksumURL string) error {
+				downloadCalled = true
+				return nil
+			}
+			executableDir = func() (string, error) {
+				return "/opt/bin", nil
+			}
+			osReadFile = func(name string) ([]byte, error) {
+				if strings.HasSuffix(name, ".published") {
+					return nil, os.ErrNotExist // No recorded release date
+				}
+				if name != filepath.Join("/opt/bin", "VERSION") {
+					t.Errorf("Unexpected VERSION path %s", name)
+				}
+				if !tt.fileExists {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.versionFile), nil
+			}
+
+			defer func() {
+				version = originalVersion
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+				osReadFile = originalReadFile
+				executableDir = originalExeDir
+			}()
+
+			var err error
+			output := captureStdout(t, func() {
+				err = checkAndUpdate()
+			})
+			if err != nil {
+				t.Fatalf("checkAndUpdate() error = %v", err)
+			}
+			if downloadCalled != tt.expectDownload {
+				t.Errorf("Expected download called = %v, got %v", tt.expectDownload, downloadCalled)
+			}
+			if !strings.Contains(output, tt.expectedOutput) {
+				t.Errorf("Expected output containing %q, got: %s", tt.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestReadVersionFileExecutableDirError(t *testing.T) {
+	originalExeDir := executableDir
+	executableDir = func() (string, error) {
+		return "", errors.New("mock error")
+	}
+	defer func() { executableDir = originalExeDir }()
+
+	if v := readVersionFile(); v != "" {
+		t.Errorf("Expected empty version, got %q", v)
+	}
+}
+
+func TestCheckAndUpdateDryRun(t *testing.T) {
+	originalVersion := version
+	originalClient := httpClient
+	originalDownload := downloadAndInstallFunc
+	originalOsExecutable := osExecutable
+	originalOpts := opts
+
+	version = "v1.0.0"
+	opts.DryRun = true
+
+	assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets": [{"name": "%s", "browser_download_url": "http://example.com/dry-run-asset"}]}`, assetName)
+	}))
+	defer server.Close()
+
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+	downloadCalled := false
+	downloadAndInstallFunc = func(url, checksumURL string) error {
+		downloadCalled = true
+		return nil
+	}
+	osExecutable = func() (string, error) {
+		return "/opt/bin/secret_manager", nil
+	}
+
+	defer func() {
+		version = originalVersion
+		httpClient = originalClient
+		downloadAndInstallFunc = originalDownload
+		osExecutable = originalOsExecutable
+		opts = originalOpts
+	}()
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	if downloadCalled {
+		t.Error("downloadAndInstall should not be called in dry-run")
+	}
+	if !strings.Contains(output, "would download http://example.com/dry-run-asset") {
+		t.Errorf("Expected asset URL in output, got: %s", output)
+	}
+	if !strings.Contains(output, "would install to /opt/bin/secret_manager") {
+		t.Errorf("Expected install path in output, got: %s", output)
+	}
+
+	// An executable lookup failure is still reported in dry-run
+	osExecutable = func() (string, error) {
+		return "", errors.New("no executable")
+	}
+	captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err == nil || !strings.Contains(err.Error(), "no executable") {
+		t.Errorf("Expected executable error, got %v", err)
+	}
+}
+
+func TestCheckAndUpdateReleaseFile(t *testing.T) {
+	originalVersion := version
+	originalClient := httpClient
+	originalReplace := replaceExecutableFunc
+	originalOsExecutable := osExecutable
+	originalOpts := opts
+
+	dir := t.TempDir()
+	assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+	}
+	assetPath := filepath.Join(dir, assetName+".tar.gz")
+	data := archiveBytes(t, archiveTarGz, "offline binary")
+	if err := os.WriteFile(assetPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	checksumPath := assetPath + ".sha256"
+	if err := os.WriteFile(checksumPath, []byte(hex.EncodeToString(sum[:])+"  "+assetName+".tar.gz\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileURL := func(path string) string {
+		path = filepath.ToSlash(path)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return "file://" + path
+	}
+	release := fmt.Sprintf(`{"tag_name": "v1.1.0", "assets": [{"name": %q, "browser_download_url": %q}, {"name": %q, "browser_download_url": %q}]}`,
+		assetName+".tar.gz", fileURL(assetPath), assetName+".tar.gz.sha256", fileURL(checksumPath))
+	releaseFile := filepath.Join(dir, "release.json")
+	if err := os.WriteFile(releaseFile, []byte(release), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	version = "v1.0.0"
+	opts.ReleaseFile = releaseFile
+	// Any network access fails the test
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("Unexpected request to %s", req.URL)
+		return nil, errors.New("offline")
+	})}
+	osExecutable = func() (string, error) {
+		return filepath.Join(dir, "secret_manager"), nil
+	}
+	var installed string
+	replaceExecutableFunc = func(current, new string) error {
+		content, err := os.ReadFile(new)
+		installed = string(content)
+		return err
+	}
+
+	defer func() {
+		version = originalVersion
+		httpClient = originalClient
+		replaceExecutableFunc = originalReplace
+		osExecutable = originalOsExecutable
+		opts = originalOpts
+	}()
+
+	var err error
+	captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	if installed != "offline binary" {
+		t.Errorf("Expected the asset from the release file to be installed, got %q", installed)
+	}
+
+	// A missing or malformed release file is reported
+	opts.ReleaseFile = filepath.Join(dir, "missing.json")
+	if _, err := getLatestRelease(); err == nil || !strings.Contains(err.Error(), "failed to read release file") {
+		t.Errorf("Expected a read error, got %v", err)
+	}
+	os.WriteFile(releaseFile, []byte("{"), 0644)
+	opts.ReleaseFile = releaseFile
+	if _, err := getLatestRelease(); err == nil || !strings.Contains(err.Error(), "failed to parse release file") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+}
+
+func TestFileURLPath(t *testing.T) {
+	tests := []struct {
+		url    string
+		path   string
+		isFile bool
+	}{
+		{"file:///opt/releases/secret_manager", filepath.FromSlash("/opt/releases/secret_manager"), true},
+		{"file:///C:/releases/secret_manager.exe", filepath.FromSlash("C:/releases/secret_manager.exe"), true},
+		{"https://github.com/releases/secret_manager", "", false},
+	}
+
+	for _, tt := range tests {
+		path, isFile := fileURLPath(tt.url)
+		if path != tt.path || isFile != tt.isFile {
+			t.Errorf("fileURLPath(%q) = %q, %v; want %q, %v", tt.url, path, isFile, tt.path, tt.isFile)
+		}
+	}
+}
+
+func TestCheckAndUpdatePrintDownloadURL(t *testing.T) {
+	originalVersion := version
+	originalClient := httpClient
+	originalDownload := downloadAndInstallFunc
+	originalPatch := patchAndInstallFunc
+	originalStage := stageUpdateFunc
+	originalOpts := opts
+
+	version = "v1.0.0"
+	opts.PrintDownloadURL = true
+
+	assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets": [
+			{"name": "%[1]s", "browser_download_url": "http://example.com/asset"},
+			{"name": "%[1]s.sha256", "browser_download_url": "http://example.com/asset.sha256"}
+		]}`, assetName)
+	}))
+	defer server.Close()
+
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+	downloadCalled := false
+	downloadAndInstallFunc = func(url, checksumURL string) error {
+		downloadCalled = true
+		return nil
+	}
+	patchAndInstallFunc = func(patchURL, checksumURL string) error {
+		downloadCalled = true
+		return nil
+	}
+	stageUpdateFunc = func(url, checksumURL string) error {
+		downloadCalled = true
+		return nil
+	}
+
+	defer func() {
+		version = originalVersion
+		httpClient = originalClient
+		downloadAndInstallFunc = originalDownload
+		patchAndInstallFunc = originalPatch
+		stageUpdateFunc = originalStage
+		opts = originalOpts
+	}()
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	if downloadCalled {
+		t.Error("Nothing should be downloaded with -print-download-url")
+	}
+	// Progress messages go to stderr, so stdout can be fed to curl
+	if output != "http://example.com/asset\nhttp://example.com/asset.sha256\n" {
+		t.Errorf("Expected only the asset and checksum URLs on stdout, got: %q", output)
+	}
+}
+
+// =============================================================================
+// UPDATE ERROR TESTS
+// =============================================================================
+// Tests for various error scenarios in the update process
+// =============================================================================
+
+func TestCheckAndUpdatePublishedAt(t *testing.T) {
+	tests := []struct {
+		name           string
+		installedAt    string
+		publishedAt    string
+		allowDowngrade bool
+		wantErr        string
+		wantInstall    bool
+		wantRecorded   string
+	}{
+		{
+			name:         "newer_release",
+			installedAt:  "2024-05-01T00:00:00Z",
+			publishedAt:  "2024-06-01T00:00:00Z",
+			wantInstall:  true,
+			wantRecorded: "2024-06-01T00:00:00Z",
+		},
+		{
+			name:         "older_release_refused",
+			installedAt:  "2024-05-01T00:00:00Z",
+			publishedAt:  "2024-04-01T00:00:00Z",
+			wantErr:      "use -allow-downgrade",
+			wantRecorded: "2024-05-01T00:00:00Z",
+		},
+		{
+			name:           "older_release_allowed",
+			installedAt:    "2024-05-01T00:00:00Z",
+			publishedAt:    "2024-04-01T00:00:00Z",
+			allowDowngrade: true,
+			wantInstall:    true,
+			wantRecorded:   "2024-04-01T00:00:00Z",
+		},
+		{
+			name:         "unknown_installed_date",
+			publishedAt:  "2024-04-01T00:00:00Z",
+			wantInstall:  true,
+			wantRecorded: "2024-04-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalVersion := version
+			originalClient := httpClient
+			originalDownload := downloadAndInstallFunc
+			originalOsExecutable := osExecutable
+			originalOpts := opts
+
+			tempDir := t.TempDir()
+			exePath := filepath.Join(tempDir, "secret_manager")
+			if tt.installedAt != "" {
+				os.WriteFile(publishedAtPath(exePath), []byte(tt.installedAt+"\n"), 0644)
+			}
+
+			version = "v1.0.0"
+			opts.AllowDowngrade = tt.allowDowngrade
+
+			assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+			if runtime.GOOS == "windows" {
+				assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"tag_name": "v1.1.0", "published_at": %q, "assets": [{"name": "%s", "browser_download_url": "http://example.com/asset"}]}`, tt.publishedAt, assetName)
+			}))
+			defer server.Close()
+
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			installed := false
+			downloadAndInstallFunc = func(url, checksumURL string) error {
+				installed = true
+				return nil
+			}
+			osExecutable = func() (string, error) {
+				return exePath, nil
+			}
+
+			defer func() {
+				version = originalVersion
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+				osExecutable = originalOsExecutable
+				opts = originalOpts
+			}()
+
+			var err error
+			captureStdout(t, func() {
+				err = checkAndUpdate()
+			})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("checkAndUpdate() error = %v", err)
+			}
+			if installed != tt.wantInstall {
+				t.Errorf("Expected install = %v, got %v", tt.wantInstall, installed)
+			}
+			if got := readPublishedAt().Format(time.RFC3339); got != tt.wantRecorded {
+				t.Errorf("Expected recorded publish date %s, got %s", tt.wantRecorded, got)
+			}
+		})
+	}
+}
+
+func TestCheckAndUpdateDowngradeConfirmation(t *testing.T) {
+	tests := []struct {
+		name        string
+		assumeYes   bool
+		terminal    bool
+		answer      string
+		wantErr     string
+		wantInstall bool
+	}{
+		{
+			name:    "refused_non_interactively",
+			wantErr: "use -allow-downgrade or -assume-yes-for-downgrade",
+		},
+		{
+			name:        "assume_yes",
+			assumeYes:   true,
+			wantInstall: true,
+		},
+		{
+			name:        "confirmed_at_prompt",
+			terminal:    true,
+			answer:      "y\n",
+			wantInstall: true,
+		},
+		{
+			name:     "declined_at_prompt",
+			terminal: true,
+			answer:   "\n",
+			wantErr:  "downgrade to v1.1.0 cancelled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalVersion := version
+			originalClient := httpClient
+			originalDownload := downloadAndInstallFunc
+			originalOsExecutable := osExecutable
+			originalTerminal := stdinIsTerminal
+			originalInput := promptInput
+			originalOpts := opts
+
+			version = "v1.2.0"
+			opts.AssumeYesForDowngrade = tt.assumeYes
+			stdinIsTerminal = func() bool { return tt.terminal }
+			promptInput = strings.NewReader(tt.answer)
+
+			assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+			if runtime.GOOS == "windows" {
+				assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets": [{"name": "%s", "browser_download_url": "http://example.com/asset"}]}`, assetName)
+			}))
+			defer server.Close()
+
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			installed := false
+			downloadAndInstallFunc = func(url, checksumURL string) error {
+				installed = true
+				return nil
+			}
+			osExecutable = func() (string, error) {
+				return filepath.Join(t.TempDir(), "secret_manager"), nil
+			}
+
+			defer func() {
+				version = originalVersion
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+				osExecutable = originalOsExecutable
+				stdinIsTerminal = originalTerminal
+				promptInput = originalInput
+				opts = originalOpts
+			}()
+
+			var err error
+			captureStdout(t, func() {
+				err = checkAndUpdate()
+			})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("checkAndUpdate() error = %v", err)
+			}
+			if installed != tt.wantInstall {
+				t.Errorf("Expected install = %v, got %v", tt.wantInstall, installed)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{"1.2.0", "1.1.9", 1, true},
+		{"1.1.0", "1.10.0", -1, true},
+		{"1.2", "1.2.0", 0, true},
+		{"2.0.0-rc1", "2.0.0", 0, true},
+		{"dev", "1.0.0", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := compareVersions(tt.a, tt.b)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("compareVersions(%q, %q) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestIsContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals containerSignals
+		want    bool
+	}{
+		{"host", containerSignals{Cgroup: "0::/init.scope\n"}, false},
+		{"dockerenv", containerSignals{DockerEnv: true}, true},
+		{"podman", containerSignals{ContainerEnv: true}, true},
+		{"kubernetes_cgroup", containerSignals{Cgroup: "0::/kubepods/besteffort/pod1234/abcd\n"}, true},
+		{"docker_cgroup_v1", containerSignals{Cgroup: "12:pids:/docker/3f2a\n"}, true},
+		{"container_env", containerSignals{EnvContainer: "lxc"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isContainer(tt.signals); got != tt.want {
+				t.Errorf("isContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAndUpdateInContainer(t *testing.T) {
+	originalSignals := readContainerSignals
+	originalClient := httpClient
+	originalOpts := opts
+	defer func() {
+		readContainerSignals = originalSignals
+		httpClient = originalClient
+		opts = originalOpts
+	}()
+
+	readContainerSignals = func() containerSignals {
+		return containerSignals{DockerEnv: true}
+	}
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Errorf("Expected container skip without error, got %v", err)
+	}
+	if requested {
+		t.Error("Expected no release lookup inside a container")
+	}
+	if !strings.Contains(output, "-allow-container-update") {
+		t.Errorf("Expected explanatory message, got: %s", output)
+	}
+
+	// With the override the update check goes ahead
+	opts.AllowContainerUpdate = true
+	captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if !requested {
+		t.Error("Expected release lookup with -allow-container-update")
+	}
+	if err == nil {
+		t.Error("Expected the mocked release lookup failure to be reported")
+	}
+}
+
+func TestCheckAndUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func()
+		expectedError string
+	}{
+		{
+			name: "getLatestRelease error",
+			setupMock: func() {
+				// Mock HTTP server returns error
+			},
+			expectedError: "failed to get latest release",
+		},
+		{
+			name: "no suitable binary",
+			setupMock: func() {
+				// Mock returns release without matching assets
+			},
+			expectedError: "no suitable binary found",
+		},
+		{
+			name: "download error",
+			setupMock: func() {
+				// Mock download failure
+			},
+			expectedError: "failed to install update",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Save originals
+			originalVersion := version
+			originalClient := httpClient
+			originalDownload := downloadAndInstallFunc
+			originalIsWindows := isWindows
+
+			// Set current version
+			version = "v1.0.0"
+
+			// The mock asset uses the Windows naming scheme
+			isWindows = func() bool { return true }
+
+			// Create mock server
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.name == "getLatestRelease error" {
+					http.Error(w, "Server error", http.StatusInternalServerError)
+					return
+				}
+
+				release := GitHubRelease{
+					TagName: "v1.1.0",
+					Name:    "Test Release",
+				}
+
+				if tt.name != "no suitable binary" {
+					assetName := fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+					release.Assets = []struct {
+						Name               string `json:"name"`
+						BrowserDownloadURL string `json:"browser_download_url"`
+						Size               int64  `json:"size"`
+					}{
+						{
+							Name:               assetName,
+							BrowserDownloadURL: "http://example.com/download",
+						},
+					}
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"tag_name": "%s", "name": "%s", "assets": [`, release.TagName, release.Name)
+				for i, asset := range release.Assets {
+					if i > 0 {
+						fmt.Fprint(w, ",")
+					}
+					fmt.Fprintf(w, `{"name": "%s", "browser_download_url": "%s"}`, asset.Name, asset.BrowserDownloadURL)
+				}
+				fmt.Fprint(w, "]}")
+			}))
+			defer server.Close()
+
+			// Mock HTTP client
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+
+			// Mock downloadAndInstall
+			if tt.name == "download error" {
+				downloadAndInstallFunc = func(url, checksumURL string) error {
+					return errors.New("download failed")
+				}
+			}
+
+			defer func() {
+				version = originalVersion
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+				isWindows = originalIsWindows
+			}()
+
+			err := checkAndUpdate()
+			if err == nil && tt.expectedError != "" {
+				t.Errorf("Expected error containing %q, got nil", tt.expectedError)
+			} else if err != nil && !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// ARCHIVE EXTRACTION TESTS
+// =============================================================================
+// Tests for ZIP and TAR.GZ archive extraction
+// =============================================================================
+
+func TestExtractZip(t *testing.T) {
+	// Create test zip file
+	tempFile, err := os.CreateTemp("", "test*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	zipWriter := zip.NewWriter(tempFile)
+	
+	// Add test file
+	writer, err := zipWriter.Create("secret_manager.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("test binary content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	zipWriter.Close()
+	tempFile.Close()
+
+	// Test extraction
+	extractedPath, err := extractZip(tempFile.Name())
+	if err != nil {
+		t.Fatalf("extractZip() error = %v", err)
+	}
+	defer os.Remove(extractedPath)
+
+	// Verify extracted file
+	content, err := os.ReadFile(extractedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "test binary content" {
+		t.Errorf("Expected content 'test binary content', got %s", string(content))
+	}
+}
+
+func TestExtractTarGz(t *testing.T) {
+	// Create test tar.gz file
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	
+	// Add test file
+	content := []byte("test binary content")
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0755,
+		Size: int64(len(content)),
+	}
+	
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	// Test extraction
+	extractedPath, err := extractTarGz(tempFile.Name())
+	if err != nil {
+		t.Fatalf("extractTarGz() error = %v", err)
+	}
+	defer os.Remove(extractedPath)
+
+	// Verify extracted file
+	readContent, err := os.ReadFile(extractedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(readContent) != "test binary content" {
+		t.Errorf("Expected content 'test binary content', got %s", string(readContent))
+	}
+}
+
+// archiveBytes returns a zip or a gzipped tarball holding a single
+// secret_manager executable with the given content
+func archiveBytes(t *testing.T, archiveType, content string) []byte {
+	var buf strings.Builder
+	switch archiveType {
+	case archiveZip:
+		zipWriter := zip.NewWriter(&buf)
+		writer, err := zipWriter.Create("secret_manager")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(content))
+		zipWriter.Close()
+	case archiveTarGz:
+		gzWriter := gzip.NewWriter(&buf)
+		tarWriter := tar.NewWriter(gzWriter)
+		if err := tarWriter.WriteHeader(&tar.Header{Name: "secret_manager", Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write([]byte(content))
+		tarWriter.Close()
+		gzWriter.Close()
+	}
+	return []byte(buf.String())
+}
+
+func TestDetectArchiveType(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		expected string
+	}{
+		{"gzip named as zip", "update.zip", archiveBytes(t, archiveTarGz, "binary"), archiveTarGz},
+		{"zip named as tar.gz", "update.tar.gz", archiveBytes(t, archiveZip, "binary"), archiveZip},
+		{"plain executable", "update.zip", []byte("\x7fELF binary"), ""},
+		{"empty file", "update.tar.gz", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.fileName)
+			if err := os.WriteFile(path, tt.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if got := detectArchiveType(path); got != tt.expected {
+				t.Errorf("detectArchiveType() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got := detectArchiveType(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("Expected no type for a missing file, got %q", got)
+	}
+}
+
+func TestDownloadUpdateMisnamedArchive(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{}
+
+	for _, tt := range []struct {
+		archiveType string
+		urlPath     string
+	}{
+		{archiveZip, "/secret_manager_linux_amd64.tar.gz"},
+		{archiveTarGz, "/secret_manager_linux_amd64.zip"},
+	} {
+		t.Run(tt.archiveType+" at "+tt.urlPath, func(t *testing.T) {
+			data := archiveBytes(t, tt.archiveType, "new binary")
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(data)
+			}))
+			defer server.Close()
+
+			updatePath, cleanup, err := downloadUpdate(server.URL+tt.urlPath, "")
+			if err != nil {
+				t.Fatalf("downloadUpdate() error = %v", err)
+			}
+			defer cleanup()
+
+			content, err := os.ReadFile(updatePath)
+			if err != nil || string(content) != "new binary" {
+				t.Errorf("Expected the extracted executable, got %q (%v)", content, err)
+			}
+		})
+	}
+}
+
+func TestValidateExecutableFormat(t *testing.T) {
+	dir := t.TempDir()
+	elf := []byte("\x7fELF\x02\x01\x01")
+	machO := []byte{0xcf, 0xfa, 0xed, 0xfe, 0x07}
+	pe := []byte("MZ\x90\x00")
+
+	tests := []struct {
+		name    string
+		goos    string
+		content []byte
+		wantErr string
+	}{
+		{name: "ELF on linux", goos: "linux", content: elf},
+		{name: "Mach-O on darwin", goos: "darwin", content: machO},
+		{name: "universal Mach-O on darwin", goos: "darwin", content: []byte{0xca, 0xfe, 0xba, 0xbe}},
+		{name: "PE on windows", goos: "windows", content: pe},
+		{name: "PE on linux", goos: "linux", content: pe, wantErr: "not a valid ELF executable for linux"},
+		{name: "ELF on darwin", goos: "darwin", content: elf, wantErr: "not a valid Mach-O executable for darwin"},
+		{name: "ELF on windows", goos: "windows", content: elf, wantErr: "not a valid PE executable for windows"},
+		{name: "text file", goos: "linux", content: []byte("mock binary content"), wantErr: "not a valid ELF executable"},
+		{name: "empty file", goos: "linux", wantErr: "not a valid ELF executable"},
+		{name: "unknown platform", goos: "plan9", content: []byte("anything")},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalGOOS := goOS
+			goOS = func() string { return tt.goos }
+			defer func() { goOS = originalGOOS }()
+
+			path := filepath.Join(dir, fmt.Sprintf("binary%d", i))
+			if err := os.WriteFile(path, tt.content, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := validateExecutableFormat(path)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateExecutableFormat() unexpected error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		originalGOOS := goOS
+		originalRead := readFileHeader
+		goOS = func() string { return "linux" }
+		readFileHeader = func(path string, n int) ([]byte, error) {
+			return nil, errors.New("read failed")
+		}
+		defer func() {
+			goOS = originalGOOS
+			readFileHeader = originalRead
+		}()
+
+		if err := validateExecutableFormat("binary"); err == nil || !strings.Contains(err.Error(), "read failed") {
+			t.Errorf("Expected the read error, got %v", err)
+		}
+	})
+}
+
+func TestDownloadUpdateWrongPlatform(t *testing.T) {
+	originalClient := httpClient
+	originalValidate := validateExecutable
+	originalGOOS := goOS
+	defer func() {
+		httpClient = originalClient
+		validateExecutable = originalValidate
+		goOS = originalGOOS
+	}()
+	httpClient = &http.Client{}
+	validateExecutable = validateExecutableFormat
+	goOS = func() string { return "linux" }
+
+	for _, tt := range []struct {
+		content string
+		wantErr bool
+	}{
+		{content: "\x7fELF linux binary"},
+		{content: "MZ windows binary", wantErr: true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(archiveBytes(t, archiveTarGz, tt.content))
+		}))
+
+		updatePath, cleanup, err := downloadUpdate(server.URL+"/secret_manager-linux-amd64.tar.gz", "")
+		server.Close()
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "not a valid ELF executable") {
+				t.Errorf("Expected a wrong platform error for %q, got %v", tt.content, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("downloadUpdate() error = %v", err)
+			continue
+		}
+		if _, statErr := os.Stat(updatePath); statErr != nil {
+			t.Errorf("Expected the extracted executable to exist: %v", statErr)
+		}
+		cleanup()
+	}
+}
+
+func TestExtractTarGzConcurrentUniquePaths(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "update.tar.gz")
+	tempFile, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	content := []byte("test binary content")
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "secret_manager", Mode: 0755, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	tarWriter.Write(content)
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	paths := make([]string, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = extractTarGz(archivePath)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Fatalf("extractTarGz() error = %v", errs[i])
+		}
+		defer os.Remove(path)
+		if !strings.HasPrefix(filepath.Base(path), "secret_manager_extract_") {
+			t.Errorf("Expected a secret_manager_extract_* file, got %s", path)
+		}
+		if data, _ := os.ReadFile(path); string(data) != string(content) {
+			t.Errorf("Expected extracted content %q in %s, got %q", content, path, data)
+		}
+	}
+	if paths[0] == paths[1] {
+		t.Errorf("Expected distinct extraction paths, both got %s", paths[0])
+	}
+}
+
+// =============================================================================
+// ARCHIVE EXTRACTION ERROR TESTS
+// =============================================================================
+// Tests for error scenarios in archive extraction
+// =============================================================================
+
+func TestExtractErrors(t *testing.T) {
+	t.Run("zip error", func(t *testing.T) {
+		// Create invalid zip
+		tempFile, err := os.CreateTemp("", "bad*.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tempFile.Write([]byte("not a zip file"))
+		tempFile.Close()
+		defer os.Remove(tempFile.Name())
+
+		_, err = extractZip(tempFile.Name())
+		if err == nil {
+			t.Error("Expected error for invalid zip")
+		}
+	})
+
+	t.Run("tar.gz error", func(t *testing.T) {
+		// Create invalid tar.gz
+		tempFile, err := os.CreateTemp("", "bad*.tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tempFile.Write([]byte("not a tar.gz file"))
+		tempFile.Close()
+		defer os.Remove(tempFile.Name())
+
+		_, err = extractTarGz(tempFile.Name())
+		if err == nil {
+			t.Error("Expected error for invalid tar.gz")
+		}
+	})
+}
+
+func TestExtractZipErrors(t *testing.T) {
+	t.Run("no executable in zip", func(t *testing.T) {
+		// Create zip without secret_manager
+		tempFile, err := os.CreateTemp("", "test*.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tempFile.Name())
+
+		zipWriter := zip.NewWriter(tempFile)
+		// Add a file that's not secret_manager
+		writer, err := zipWriter.Create("other.exe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write([]byte("other content"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zipWriter.Close()
+		tempFile.Close()
+
+		_, err = extractZip(tempFile.Name())
+		if err == nil || !strings.Contains(err.Error(), "executable not found") {
+			t.Errorf("Expected 'executable not found' error, got %v", err)
+		}
+	})
+
+	t.Run("file open error in zip", func(t *testing.T) {
+		// Test with non-existent file
+		_, err := extractZip("/nonexistent/file.zip")
+		if err == nil {
+			t.Error("Expected error for non-existent file")
+		}
+	})
+}
+
+func TestExtractTarGzErrors(t *testing.T) {
+	t.Run("no executable in tar.gz", func(t *testing.T) {
+		// Create tar.gz without secret_manager
+		tempFile, err := os.CreateTemp("", "test*.tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tempFile.Name())
+
+		gzWriter := gzip.NewWriter(tempFile)
+		tarWriter := tar.NewWriter(gzWriter)
+		
+		// Add a file that's not secret_manager
+		content := []byte("other content")
+		header := &tar.Header{
+			Name: "other",
+			Mode: 0755,
+			Size: int64(len(content)),
+		}
+		
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		
+		tarWriter.Close()
+		gzWriter.Close()
+		tempFile.Close()
+
+		_, err = extractTarGz(tempFile.Name())
+		if err == nil || !strings.Contains(err.Error(), "executable not found") {
+			t.Errorf("Expected 'executable not found' error, got %v", err)
+		}
+	})
+
+	t.Run("file open error", func(t *testing.T) {
+		_, err := extractTarGz("/nonexistent/file.tar.gz")
+		if err == nil {
+			t.Error("Expected error for non-existent file")
+		}
+	})
+}
+
+func TestExtractTarGzNextError(t *testing.T) {
+	// Create an invalid tar.gz that will cause Next() to fail
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	// Write gzip header but invalid tar data
+	gzWriter := gzip.NewWriter(tempFile)
+	gzWriter.Write([]byte("invalid tar data"))
+	gzWriter.Close()
+	tempFile.Close()
+
+	_, err = extractTarGz(tempFile.Name())
+	if err == nil {
+		t.Error("Expected error for invalid tar data")
+	}
+}
+
+// =============================================================================
+// MOCK-BASED EXTRACTION TESTS
+// =============================================================================
+// Tests using mocked functions for edge cases
+// =============================================================================
+
+func TestExtractZipFileOpenErrorMock(t *testing.T) {
+	// Create a temporary zip file
+	tempFile, err := os.CreateTemp("", "test_*.zip")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	zipWriter := zip.NewWriter(tempFile)
+	writer, err := zipWriter.Create("secret_manager.exe")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	if _, err := writer.Write([]byte("test")); err != nil {
+		t.Fatalf("Failed to write to zip: %v", err)
+	}
+	zipWriter.Close()
+	tempFile.Close()
+
+	// Mock zipFileOpen to fail
+	originalZipFileOpen := zipFileOpen
+	zipFileOpen = func(f *zip.File) (io.ReadCloser, error) {
+		return nil, errors.New("mock open error")
+	}
+	defer func() { zipFileOpen = originalZipFileOpen }()
+
+	_, err = extractZip(tempFile.Name())
+	if err == nil || err.Error() != "mock open error" {
+		t.Errorf("Expected 'mock open error', got %v", err)
+	}
+}
+
+func TestExtractZipWithMockedCreate(t *testing.T) {
+	// Create a valid zip file
+	tempFile, err := os.CreateTemp("", "test*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	zipWriter := zip.NewWriter(tempFile)
+	writer, err := zipWriter.Create("secret_manager.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter.Close()
+	tempFile.Close()
+
+	// Mock os.CreateTemp to fail
+	originalOsCreateTemp := osCreateTemp
+	osCreateTemp = func(dir, pattern string) (*os.File, error) {
+		return nil, errors.New("mock Create error")
+	}
+	defer func() {
+		osCreateTemp = originalOsCreateTemp
+	}()
+	
+	_, err = extractZip(tempFile.Name())
+	if err == nil || !strings.Contains(err.Error(), "mock Create error") {
+		t.Errorf("Expected Create error, got %v", err)
+	}
+}
+
+func TestExtractTarGzWithMockedCreate(t *testing.T) {
+	// Create a valid tar.gz file
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	
+	content := []byte("test")
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0755,
+		Size: int64(len(content)),
+	}
+	
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	// Mock os.CreateTemp to fail
+	originalOsCreateTemp := osCreateTemp
+	osCreateTemp = func(dir, pattern string) (*os.File, error) {
+		return nil, errors.New("mock Create error")
+	}
+	defer func() {
+		osCreateTemp = originalOsCreateTemp
+	}()
+	
+	_, err = extractTarGz(tempFile.Name())
+	if err == nil || !strings.Contains(err.Error(), "mock Create error") {
+		t.Errorf("Expected Create error, got %v", err)
+	}
+}
+
+func TestExtractZipWithMockedIOCopy(t *testing.T) {
+	// Create a valid zip file
+	tempFile, err := os.CreateTemp("", "test*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	zipWriter := zip.NewWriter(tempFile)
+	writer, err := zipWriter.Create("secret_manager.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("test content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter.Close()
+	tempFile.Close()
+
+	// Mock io.Copy to fail
+	originalIOCopy := ioCopy
+	callCount := 0
+	ioCopy = func(dst io.Writer, src io.Reader) (int64, error) {
+		callCount++
+		return 0, errors.New("mock io.Copy error")
+	}
+	defer func() {
+		ioCopy = originalIOCopy
+	}()
+	
+	_, err = extractZip(tempFile.Name())
+	if err == nil || !strings.Contains(err.Error(), "mock io.Copy error") {
+		t.Errorf("Expected io.Copy error, got %v", err)
+	}
+}
+
+func TestExtractTarGzWithMockedIOCopy(t *testing.T) {
+	// Create a valid tar.gz file
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	
+	content := []byte("test content")
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0755,
+		Size: int64(len(content)),
+	}
+	
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	// Mock io.Copy to fail
+	originalIOCopy := ioCopy
+	ioCopy = func(dst io.Writer, src io.Reader) (int64, error) {
+		return 0, errors.New("mock io.Copy error")
+	}
+	defer func() {
+		ioCopy = originalIOCopy
+	}()
+	
+	_, err = extractTarGz(tempFile.Name())
+	if err == nil || !strings.Contains(err.Error(), "mock io.Copy error") {
+		t.Errorf("Expected io.Copy error, got %v", err)
+	}
+}
+
+// =============================================================================
+// CHMOD AND PLATFORM-SPECIFIC TESTS
+// =============================================================================
+// Tests for platform-specific operations like chmod
+// =============================================================================
+
+func TestExtractTarGzWithChmodCoverage(t *testing.T) {
+	// Save originals
+	originalIsWindows := isWindows
+	originalOsChmod := osChmod
+	originalOsCreateTemp := osCreateTemp
+	originalIOCopy := ioCopy
+	defer func() {
+		isWindows = originalIsWindows
+		osChmod = originalOsChmod
+		osCreateTemp = originalOsCreateTemp
+		ioCopy = originalIOCopy
+	}()
+
+	// Mock as Unix system
+	isWindows = func() bool { return false }
+
+	// Track chmod call
+	chmodCalled := false
+	osChmod = func(name string, mode os.FileMode) error {
+		chmodCalled = true
+		if mode != 0755 {
+			t.Errorf("Expected mode 0755, got %o", mode)
+		}
+		return nil
+	}
+
+	// Create a temporary tar.gz file
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	// Create tar.gz with secret_manager file
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	// Add a file named "secret_manager"
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0644,
+		Size: 13,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write([]byte("test content\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	// Mock osCreateTemp to succeed
+	tempExtractFile, err := os.CreateTemp("", "extract*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempExtractFile.Name())
+	tempExtractFile.Close()
+
+	osCreateTemp = func(dir, pattern string) (*os.File, error) {
+		return os.Create(tempExtractFile.Name())
+	}
+
+	// Mock ioCopy to succeed
+	ioCopy = func(dst io.Writer, src io.Reader) (int64, error) {
+		return 13, nil
+	}
+
+	// Extract the tar.gz
+	extractPath, err := extractTarGz(tempFile.Name())
+	if err != nil {
+		t.Fatalf("extractTarGz failed: %v", err)
+	}
+
+	// Verify chmod was called
+	if !chmodCalled {
+		t.Error("Expected osChmod to be called on Unix systems")
+	}
+
+	// Clean up
+	if extractPath != "" && extractPath != tempExtractFile.Name() {
+		os.Remove(extractPath)
+	}
+}
+
+func TestExtractTarGzUnixChmod(t *testing.T) {
+	// Save originals
+	originalIsWindows := isWindows
+	originalOsChmod := osChmod
+	defer func() {
+		isWindows = originalIsWindows
+		osChmod = originalOsChmod
+	}()
+
+	// Mock as Unix system
+	isWindows = func() bool { return false }
+
+	// Track chmod call
+	osChmod = func(name string, mode os.FileMode) error {
+		if mode != 0755 {
+			t.Errorf("Expected mode 0755, got %o", mode)
+		}
+		return nil
+	}
+
+	// We need to create a proper tar.gz with secret_manager file
+	// For now, just verify the mock works
+	if !isWindows() {
+		// This confirms our mock is working
+		t.Log("Successfully mocked Unix environment")
+	}
+}
+
+func TestExtractTarGzWindowsChmod(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Skipping Windows-specific test on non-Windows")
+	}
+	
+	// Create a valid tar.gz file
+	tempFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	gzWriter := gzip.NewWriter(tempFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	
+	content := []byte("test")
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0755,
+		Size: int64(len(content)),
+	}
+	
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	
+	tarWriter.Close()
+	gzWriter.Close()
+	tempFile.Close()
+
+	// Extract - should work without chmod on Windows
+	extractedPath, err := extractTarGz(tempFile.Name())
+	if err != nil {
+		t.Fatalf("extractTarGz() error = %v", err)
+	}
+	defer os.Remove(extractedPath)
+}
+
+// =============================================================================
+// DOWNLOAD AND INSTALL TESTS
+// =============================================================================
+// Tests for file download and installation process
+// =============================================================================
+
+func TestDownloadAndInstall(t *testing.T) {
+	// Create a test server that serves a mock binary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("mock binary content"))
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	originalOsExecutable := osExecutable
+	originalReplaceFunc := replaceExecutableFunc
+
+	// Create temp executable path
+	tempFile, err := os.CreateTemp("", "test_exe_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	osExecutable = func() (string, error) {
+		return tempFile.Name(), nil
+	}
+
+	replaceExecutableFunc = func(current, new string) error {
+		// Just verify the paths
+		if current != tempFile.Name() {
+			t.Errorf("Expected current path %s, got %s", tempFile.Name(), current)
+		}
+		return nil
+	}
+
+	httpClient = &http.Client{}
+
+	defer func() {
+		httpClient = originalClient
+		osExecutable = originalOsExecutable
+		replaceExecutableFunc = originalReplaceFunc
+	}()
+
+	err = downloadAndInstall(server.URL, "")
+	if err != nil {
+		t.Errorf("downloadAndInstall() error = %v", err)
+	}
+}
+
+func TestDownloadAndInstallZip(t *testing.T) {
+	// Create a test zip file
+	zipFile, err := os.CreateTemp("", "test*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(zipFile.Name())
+
+	zipWriter := zip.NewWriter(zipFile)
+	writer, err := zipWriter.Create("secret_manager.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("test binary content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter.Close()
+	zipFile.Close()
+
+	// Read the zip content
+	zipContent, err := os.ReadFile(zipFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a test server that serves the zip
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipContent)
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	originalOsExecutable := osExecutable
+	originalReplaceFunc := replaceExecutableFunc
+
+	// Create temp executable path
+	tempFile, err := os.CreateTemp("", "test_exe_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	osExecutable = func() (string, error) {
+		return tempFile.Name(), nil
+	}
+
+	replaceExecutableFunc = func(current, new string) error {
+		return nil
+	}
+
+	httpClient = &http.Client{}
+
+	defer func() {
+		httpClient = originalClient
+		osExecutable = originalOsExecutable
+		replaceExecutableFunc = originalReplaceFunc
+	}()
+
+	err = downloadAndInstall(server.URL + "/test.zip", "")
+	if err != nil {
+		t.Errorf("downloadAndInstall() error = %v", err)
+	}
+}
+
+func TestDownloadAndInstallTarGz(t *testing.T) {
+	// Create a test tar.gz file
+	tarFile, err := os.CreateTemp("", "test*.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tarFile.Name())
+
+	gzWriter := gzip.NewWriter(tarFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	
+	content := []byte("test binary content")
+	header := &tar.Header{
+		Name: "secret_manager",
+		Mode: 0755,
+		Size: int64(len(content)),
+	}
+	
+	if err := tarWriter.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	
+	tarWriter.Close()
+	gzWriter.Close()
+	tarFile.Close()
+
+	// Read the tar.gz content
+	tarContent, err := os.ReadFile(tarFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a test server that serves the tar.gz
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarContent)
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	originalOsExecutable := osExecutable
+	originalReplaceFunc := replaceExecutableFunc
+
+	// Create temp executable path
+	tempFile, err := os.CreateTemp("", "test_exe_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	osExecutable = func() (string, error) {
+		return tempFile.Name(), nil
+	}
+
+	replaceExecutableFunc = func(current, new string) error {
+		return nil
+	}
+
+	httpClient = &http.Client{}
+
+	defer func() {
+		httpClient = originalClient
+		osExecutable = originalOsExecutable
+		replaceExecutableFunc = originalReplaceFunc
+	}()
+
+	err = downloadAndInstall(server.URL + "/test.tar.gz", "")
+	if err != nil {
+		t.Errorf("downloadAndInstall() error = %v", err)
+	}
+}
+
+// =============================================================================
+// BACKGROUND UPDATE TESTS
+// =============================================================================
+// Tests for staging an update and applying it on the next start
+// =============================================================================
+
+func TestStageUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new binary content"))
+	}))
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "stage_update_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	exePath := filepath.Join(tempDir, "secret_manager")
+	os.WriteFile(exePath, []byte("old binary content"), 0755)
+
+	originalClient := httpClient
+	originalOsExecutable := osExecutable
+	originalReplaceFunc := replaceExecutableFunc
+	osExecutable = func() (string, error) {
+		return exePath, nil
+	}
+	replaceCalled := false
+	replaceExecutableFunc = func(current, new string) error {
+		replaceCalled = true
+		return nil
+	}
+	httpClient = &http.Client{}
+	defer func() {
+		httpClient = originalClient
+		osExecutable = originalOsExecutable
+		replaceExecutableFunc = originalReplaceFunc
+	}()
+
+	if err := stageUpdate(server.URL, ""); err != nil {
+		t.Fatalf("stageUpdate() error = %v", err)
+	}
+	if replaceCalled {
+		t.Error("stageUpdate should not replace the running executable")
+	}
+
+	staged, err := os.ReadFile(stagedUpdatePath(exePath))
+	if err != nil {
+		t.Fatalf("Expected staged update: %v", err)
+	}
+	if string(staged) != "new binary content" {
+		t.Errorf("Unexpected staged content %q", string(staged))
+	}
+	current, _ := os.ReadFile(exePath)
+	if string(current) != "old binary content" {
+		t.Errorf("Running executable should be untouched, got %q", string(current))
+	}
+}
+
+func TestDownloadUpdateChecksum(t *testing.T) {
+	payload := []byte("secret_manager binary payload")
+	sum := sha256.Sum256(payload)
+	digest := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  string
+	}{
+		{"matching_digest", digest + "  secret_manager-linux-amd64\n", ""},
+		{"uppercase_digest", strings.ToUpper(digest), ""},
+		{"mismatched_digest", strings.Repeat("0", 64), "checksum mismatch"},
+		{"empty_checksum_file", "", "empty checksum file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, ".sha256") {
+					w.Write([]byte(tt.checksum))
+					return
+				}
+				w.Write(payload)
+			}))
+			defer server.Close()
+
+			path, cleanup, err := downloadUpdate(server.URL+"/asset", server.URL+"/asset.sha256")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadUpdate() error = %v", err)
+			}
+			defer cleanup()
+
+			data, _ := os.ReadFile(path)
+			if string(data) != string(payload) {
+				t.Errorf("Expected downloaded payload, got %q", data)
+			}
+		})
+	}
+}
+
+func TestDownloadUpdateConnectionLimit(t *testing.T) {
+	payload := []byte("secret_manager binary payload")
+	sum := sha256.Sum256(payload)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			w.Write([]byte(hex.EncodeToString(sum[:])))
+			return
+		}
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	// One connection must be enough for the asset, its probe and its checksum
+	originalClient := httpClient
+	httpClient = withConnectionLimit(&http.Client{Timeout: 5 * time.Second}, 1)
+	defer func() { httpClient = originalClient }()
+
+	path, cleanup, err := downloadUpdate(server.URL+"/asset", server.URL+"/asset.sha256")
+	if err != nil {
+		t.Fatalf("downloadUpdate() error = %v", err)
+	}
+	defer cleanup()
+	if data, _ := os.ReadFile(path); string(data) != string(payload) {
+		t.Errorf("Expected downloaded payload, got %q", data)
+	}
+	if n := httpClient.Transport.(*limitedTransport).inFlight(); n != 0 {
+		t.Errorf("Expected every connection to be released, %d still held", n)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestProbeAsset(t *testing.T) {
+	payload := []byte("secret_manager binary payload")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/asset":
+			w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+			if r.Method != http.MethodHead {
+				w.Write(payload)
+			}
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	t.Run("reachable", func(t *testing.T) {
+		size, err := probeAsset(server.URL + "/asset")
+		if err != nil {
+			t.Fatalf("probeAsset() error = %v", err)
+		}
+		if size != int64(len(payload)) {
+			t.Errorf("Expected size %d, got %d", len(payload), size)
+		}
+	})
+
+	t.Run("head_not_allowed", func(t *testing.T) {
+		if _, err := probeAsset(server.URL + "/no-head"); err != nil {
+			t.Errorf("Expected a host without HEAD support to pass, got %v", err)
+		}
+	})
+
+	t.Run("missing_asset", func(t *testing.T) {
+		_, err := probeAsset(server.URL + "/missing")
+		if err == nil || !strings.Contains(err.Error(), "returned status 404") {
+			t.Errorf("Expected status error, got %v", err)
+		}
+	})
+
+	t.Run("unreachable_host", func(t *testing.T) {
+		gets := 0
+		httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodHead {
+				gets++
+			}
+			return nil, errors.New("proxy refused connection")
+		})}
+		defer func() { httpClient = originalClient }()
+
+		_, _, err := downloadUpdate("https://objects.githubusercontent.com/asset", "")
+		if err == nil || !strings.Contains(err.Error(), "cannot reach download host objects.githubusercontent.com") {
+			t.Errorf("Expected unreachable host error, got %v", err)
+		}
+		if gets != 0 {
+			t.Errorf("Expected the download to be skipped, got %d GET requests", gets)
+		}
+	})
+}
+
+func TestFindChecksumURL(t *testing.T) {
+	release := &GitHubRelease{
+		Assets: []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+			Size               int64  `json:"size"`
+		}{
+			{Name: "secret_manager-linux-amd64", BrowserDownloadURL: "http://example.com/binary"},
+			{Name: "secret_manager-linux-amd64.sha256", BrowserDownloadURL: "http://example.com/binary.sha256"},
+			{Name: "secret_manager-darwin-arm64", BrowserDownloadURL: "http://example.com/darwin"},
+		},
+	}
+
+	if got := findChecksumURL(release, "http://example.com/binary"); got != "http://example.com/binary.sha256" {
+		t.Errorf("Expected checksum URL, got %q", got)
+	}
+	if got := findChecksumURL(release, "http://example.com/darwin"); got != "" {
+		t.Errorf("Expected no checksum URL without a published checksum, got %q", got)
+	}
+}
+
+func TestFindAssetByRegex(t *testing.T) {
+	release := &GitHubRelease{
+		TagName: "v2.0.0",
+		Assets: []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+			Size               int64  `json:"size"`
+		}{
+			{Name: "sm_2.0.0_Linux_x86_64", BrowserDownloadURL: "http://example.com/linux-x86_64"},
+			{Name: "sm_2.0.0_Linux_x86_64.sha256", BrowserDownloadURL: "http://example.com/linux-x86_64.sha256"},
+			{Name: "sm_2.0.0_Linux_arm64", BrowserDownloadURL: "http://example.com/linux-arm64"},
+			{Name: "sm_2.0.0_Darwin_universal", BrowserDownloadURL: "http://example.com/darwin"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{name: "single match", pattern: `_Linux_x86_64$`, want: "http://example.com/linux-x86_64"},
+		{name: "checksum ignored", pattern: `Linux_x86_64`, want: "http://example.com/linux-x86_64"},
+		{name: "no match", pattern: `Windows`, wantErr: "no asset in release v2.0.0 matches"},
+		{name: "multiple matches", pattern: `_Linux_`, wantErr: "matches 2 assets (sm_2.0.0_Linux_x86_64, sm_2.0.0_Linux_arm64)"},
+		{name: "invalid pattern", pattern: `Linux(`, wantErr: "invalid -asset-regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findAssetByRegex(release, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findAssetByRegex() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckAndUpdateDownloadSize(t *testing.T) {
+	originalVersion := version
+	originalOsExecutable := osExecutable
+	originalOpts := opts
+	defer func() {
+		version = originalVersion
+		osExecutable = originalOsExecutable
+		opts = originalOpts
+	}()
+
+	assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+	}
+	release := fmt.Sprintf(`{"tag_name": "v1.1.0", "assets": [{"name": %q, "browser_download_url": "http://example.com/binary", "size": 13107200}]}`, assetName)
+	releaseFile := filepath.Join(t.TempDir(), "release.json")
+	if err := os.WriteFile(releaseFile, []byte(release), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	version = "v1.0.0"
+	opts.ReleaseFile = releaseFile
+	opts.DryRun = true
+	osExecutable = func() (string, error) {
+		return "/usr/local/bin/secret_manager", nil
+	}
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	if !strings.Contains(output, "Download size: 12.5 MiB") {
+		t.Errorf("Expected the declared asset size, got:\n%s", output)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{13107200, "12.5 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestStageUpdateErrors(t *testing.T) {
+	originalClient := httpClient
+	originalOsExecutable := osExecutable
+	originalRename := osRename
+	defer func() {
+		httpClient = originalClient
+		osExecutable = originalOsExecutable
+		osRename = originalRename
+	}()
+
+	osExecutable = func() (string, error) {
+		return "", errors.New("mock executable error")
+	}
+	if err := stageUpdate("http://example.com", ""); err == nil || !strings.Contains(err.Error(), "mock executable error") {
+		t.Errorf("Expected executable error, got %v", err)
+	}
+
+	osExecutable = func() (string, error) {
+		return filepath.Join(os.TempDir(), "secret_manager"), nil
+	}
+	httpClient = &http.Client{Timeout: 1}
+	if err := stageUpdate("http://example.com", ""); err == nil {
+		t.Error("Expected download error")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("binary"))
+	}))
+	defer server.Close()
+	httpClient = &http.Client{}
+	osRename = func(oldpath, newpath string) error {
+		return errors.New("mock rename error")
+	}
+	if err := stageUpdate(server.URL, ""); err == nil || !strings.Contains(err.Error(), "failed to stage update") {
+		t.Errorf("Expected stage error, got %v", err)
+	}
+}
+
+func TestApplyStagedUpdate(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "apply_staged_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	exePath := filepath.Join(tempDir, "secret_manager")
+
+	originalOsExecutable := osExecutable
+	originalReplaceFunc := replaceExecutableFunc
+	osExecutable = func() (string, error) {
+		return exePath, nil
+	}
+	var replaced []string
+	var replaceErr error
+	replaceExecutableFunc = func(current, new string) error {
+		replaced = []string{current, new}
+		return replaceErr
+	}
+	defer func() {
+		osExecutable = originalOsExecutable
+		replaceExecutableFunc = originalReplaceFunc
+	}()
+
+	// Nothing staged
+	if err := applyStagedUpdate(); err != nil {
+		t.Errorf("applyStagedUpdate() error = %v", err)
+	}
+	if replaced != nil {
+		t.Error("Expected no replacement without a staged update")
+	}
+
+	// Staged update is swapped in
+	os.WriteFile(stagedUpdatePath(exePath), []byte("new"), 0755)
+	if err := applyStagedUpdate(); err != nil {
+		t.Errorf("applyStagedUpdate() error = %v", err)
+	}
+	if len(replaced) != 2 || replaced[0] != exePath || replaced[1] != stagedUpdatePath(exePath) {
+		t.Errorf("Expected replacement of %s with staged update, got %v", exePath, replaced)
+	}
+
+	// Replacement failure is reported
+	replaceErr = errors.New("mock replace error")
+	if err := applyStagedUpdate(); err == nil || !strings.Contains(err.Error(), "failed to apply staged update") {
+		t.Errorf("Expected apply error, got %v", err)
+	}
+
+	osExecutable = func() (string, error) {
+		return "", errors.New("mock executable error")
+	}
+	if err := applyStagedUpdate(); err == nil {
+		t.Error("Expected executable error")
+	}
+}
+
+func TestCheckAndUpdateBackground(t *testing.T) {
+	originalVersion := version
+	originalClient := httpClient
+	originalDownload := downloadAndInstallFunc
+	originalStage := stageUpdateFunc
+	originalOpts := opts
+
+	version = "v1.0.0"
+	opts.UpdateBackground = true
+
+	assetName := fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName = fmt.Sprintf("secret_manager-windows-%s.exe", runtime.GOARCH)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.1.0", "assets": [{"name": "%s", "browser_download_url": "http://example.com/asset"}]}`, assetName)
+	}))
+	defer server.Close()
+
+	httpClient = &http.Client{
+		Transport: &mockTransport{server: server},
+	}
+	downloadAndInstallFunc = func(url, checksumURL string) error {
+		t.Error("downloadAndInstall should not be called in background mode")
+		return nil
+	}
+	var stagedURL string
+	var stageErr error
+	stageUpdateFunc = func(url, checksumURL string) error {
+		stagedURL = url
+		return stageErr
+	}
+	defer func() {
+		version = originalVersion
+		httpClient = originalClient
+		downloadAndInstallFunc = originalDownload
+		stageUpdateFunc = originalStage
+		opts = originalOpts
+	}()
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	if stagedURL != "http://example.com/asset" {
+		t.Errorf("Expected staged asset URL, got %q", stagedURL)
+	}
+	if !strings.Contains(output, "staged") {
+		t.Errorf("Expected staging notification, got: %s", output)
+	}
+
+	stageErr = errors.New("mock stage error")
+	captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to stage update") {
+		t.Errorf("Expected stage error, got %v", err)
+	}
+}
+
+// =============================================================================
+// INCREMENTAL UPDATE TESTS
+// =============================================================================
+// Tests for binary patch updates and the fallback to a full download
+// =============================================================================
+
+func TestCheckAndUpdatePatch(t *testing.T) {
+	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	patchName := fmt.Sprintf("secret_manager-%s-from-v1.0.0.bspatch", platform)
+
+	tests := []struct {
+		name           string
+		assets         []string
+		patchErr       error
+		expectPatch    bool
+		expectDownload bool
+	}{
+		{
+			name:        "patch success",
+			assets:      []string{patchName, patchName + ".sha256"},
+			expectPatch: true,
+		},
+		{
+			name:           "patch failure falls back",
+			assets:         []string{patchName, patchName + ".sha256"},
+			patchErr:       errors.New("checksum mismatch"),
+			expectPatch:    true,
+			expectDownload: true,
+		},
+		{
+			name:           "patch without checksum is ignored",
+			assets:         []string{patchName},
+			expectDownload: true,
+		},
+		{
+			name:           "patch for another version is ignored",
+			assets:         []string{fmt.Sprintf("secret_manager-%s-from-v0.9.0.bspatch", platform)},
+			expectDownload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalVersion := version
+			originalClient := httpClient
+			originalDownload := downloadAndInstallFunc
+			originalPatch := patchAndInstallFunc
+			originalIsWindows := isWindows
+
+			version = "v1.0.0"
+			isWindows = func() bool { return false }
+
+			assets := append([]string{"secret_manager-" + platform}, tt.assets...)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"tag_name": "v1.1.0", "assets": [`)
+				for i, name := range assets {
+					if i > 0 {
+						fmt.Fprint(w, ",")
+					}
+					fmt.Fprintf(w, `{"name": "%s", "browser_download_url": "http://example.com/%s"}`, name, name)
+				}
+				fmt.Fprint(w, "]}")
+			}))
+			defer server.Close()
+
+			httpClient = &http.Client{
+				Transport: &mockTransport{server: server},
+			}
+			var downloadedURL string
+			downloadAndInstallFunc = func(url, checksumURL string) error {
+				downloadedURL = url
+				return nil
+			}
+			var patchURL, checksumURL string
+			patchAndInstallFunc = func(p, c string) error {
+				patchURL, checksumURL = p, c
+				return tt.patchErr
+			}
+
+			defer func() {
+				version = originalVersion
+				httpClient = originalClient
+				downloadAndInstallFunc = originalDownload
+				patchAndInstallFunc = originalPatch
+				isWindows = originalIsWindows
+			}()
+
+			var err error
+			captureStdout(t, func() {
+				err = checkAndUpdate()
+			})
+			if err != nil {
+				t.Fatalf("checkAndUpdate() error = %v", err)
+			}
+			if (patchURL != "") != tt.expectPatch {
+				t.Errorf("Expected patch attempted = %v, got %q", tt.expectPatch, patchURL)
+			}
+			if tt.expectPatch && (patchURL != "http://example.com/"+patchName || checksumURL != "http://example.com/"+patchName+".sha256") {
+				t.Errorf("Unexpected patch URLs %s, %s", patchURL, checksumURL)
+			}
+			if (downloadedURL != "") != tt.expectDownload {
+				t.Errorf("Expected full download = %v, got %q", tt.expectDownload, downloadedURL)
+			}
+			if tt.expectDownload && downloadedURL != "http://example.com/secret_manager-"+platform {
+				t.Errorf("Full download should use the binary asset, got %s", downloadedURL)
+			}
+		})
+	}
+}
+
+func TestPatchAndInstall(t *testing.T) {
+	newSum := sha256.Sum256([]byte(testPatchNew))
+	patch := testPatch(t)
+
+	tests := []struct {
+		name      string
+		checksum  string
+		patchErr  error
+		patchCode int
+		wantErr   string
+	}{
+		{
+			name:     "patched and verified",
+			checksum: hex.EncodeToString(newSum[:]) + "  secret_manager\n",
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: strings.Repeat("0", 64),
+			wantErr:  "checksum mismatch",
+		},
+		{
+			name:     "empty checksum",
+			checksum: "",
+			wantErr:  "empty checksum",
+		},
+		{
+			name:     "patch error",
+			checksum: hex.EncodeToString(newSum[:]),
+			patchErr: errors.New("mock patch error"),
+			wantErr:  "failed to apply patch",
+		},
+		{
+			name:      "patch download error",
+			patchCode: http.StatusNotFound,
+			wantErr:   "failed to download patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, ".sha256") {
+					w.Write([]byte(tt.checksum))
+					return
+				}
+				if tt.patchCode != 0 {
+					w.WriteHeader(tt.patchCode)
+					return
+				}
+				w.Write(patch)
+			}))
+			defer server.Close()
+
+			tempDir, err := os.MkdirTemp("", "patch_install_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			exePath := filepath.Join(tempDir, "secret_manager")
+			os.WriteFile(exePath, []byte(testPatchOld), 0755)
+
+			originalClient := httpClient
+			originalOsExecutable := osExecutable
+			originalReplaceFunc := replaceExecutableFunc
+			originalBspatch := bspatchFunc
+			httpClient = &http.Client{}
+			osExecutable = func() (string, error) {
+				return exePath, nil
+			}
+			var installed []byte
+			replaceExecutableFunc = func(current, new string) error {
+				installed, _ = os.ReadFile(new)
+				return nil
+			}
+			if tt.patchErr != nil {
+				bspatchFunc = func(old, patch []byte) ([]byte, error) {
+					return nil, tt.patchErr
+				}
+			}
+			defer func() {
+				httpClient = originalClient
+				osExecutable = originalOsExecutable
+				replaceExecutableFunc = originalReplaceFunc
+				bspatchFunc = originalBspatch
+			}()
+
+			err = patchAndInstall(server.URL+"/app.bspatch", server.URL+"/app.bspatch.sha256")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if installed != nil {
+					t.Error("Nothing should be installed on failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("patchAndInstall() error = %v", err)
+			}
+			if string(installed) != testPatchNew {
+				t.Errorf("Expected patched executable %q, got %q", testPatchNew, string(installed))
+			}
+		})
+	}
+}
+
+func TestFindAssetURLSkipsAuxiliaryAssets(t *testing.T) {
+	originalIsWindows := isWindows
+	isWindows = func() bool { return false }
+	defer func() { isWindows = originalIsWindows }()
+
+	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	release := &GitHubRelease{
+		Assets: []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+			Size               int64  `json:"size"`
+		}{
+			{Name: "secret_manager-" + platform + "-from-v1.0.0.bspatch", BrowserDownloadURL: "http://example.com/patch"},
+			{Name: "secret_manager-" + platform + ".sha256", BrowserDownloadURL: "http://example.com/sum"},
+			{Name: "secret_manager-" + platform, BrowserDownloadURL: "http://example.com/binary"},
+		},
+	}
+
+	if url := findAssetURL(release); url != "http://example.com/binary" {
+		t.Errorf("Expected binary asset, got %s", url)
+	}
+}
+
+func TestFindAssetURLRosetta(t *testing.T) {
+	tests := []struct {
+		name       string
+		translated bool
+		arm64Asset bool
+		want       string
+	}{
+		{
+			name:       "translated_prefers_arm64",
+			translated: true,
+			arm64Asset: true,
+			want:       "http://example.com/arm64",
+		},
+		{
+			name:       "translated_without_arm64_asset",
+			translated: true,
+			want:       "http://example.com/amd64",
+		},
+		{
+			name:       "native_keeps_amd64",
+			arm64Asset: true,
+			want:       "http://example.com/amd64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalIsWindows := isWindows
+			originalTranslated := isRosettaTranslated
+			isWindows = func() bool { return false }
+			isRosettaTranslated = func() bool { return tt.translated }
+			defer func() {
+				isWindows = originalIsWindows
+				isRosettaTranslated = originalTranslated
+			}()
+
+			// The running build's asset stands in for amd64 on any test host
+			release := &GitHubRelease{
+				Assets: []struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+					Size               int64  `json:"size"`
+				}{
+					{Name: fmt.Sprintf("secret_manager-%s-%s", runtime.GOOS, runtime.GOARCH), BrowserDownloadURL: "http://example.com/amd64"},
+				},
+			}
+			if tt.arm64Asset && runtime.GOARCH != "arm64" {
+				release.Assets = append(release.Assets, struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+					Size               int64  `json:"size"`
+				}{Name: "secret_manager-" + runtime.GOOS + "-arm64", BrowserDownloadURL: "http://example.com/arm64"})
+			}
+			if runtime.GOARCH == "arm64" && tt.translated {
+				t.Skip("the native and translated assets coincide on arm64 hosts")
+			}
+
+			if url := findAssetURL(release); url != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, url)
+			}
+		})
+	}
+}
+
+func TestFindAssetURLARMVariants(t *testing.T) {
+	names := map[string]string{
+		"arm64": "secret_manager-" + runtime.GOOS + "-arm64.tar.gz",
+		"armv7": "secret_manager-" + runtime.GOOS + "-armv7.tar.gz",
+		"armv6": "secret_manager-" + runtime.GOOS + "-armv6.tar.gz",
+		"arm":   "secret_manager-" + runtime.GOOS + "-arm.tar.gz",
+	}
+
+	tests := []struct {
+		name    string
+		arch    string
+		variant string
+		assets  []string
+		want    string
+	}{
+		{name: "armv7", arch: "arm", variant: "7", assets: []string{"arm64", "armv6", "armv7", "arm"}, want: "armv7"},
+		{name: "armv7_falls_back_to_armv6", arch: "arm", variant: "7", assets: []string{"arm64", "armv6", "arm"}, want: "armv6"},
+		{name: "armv6_skips_armv7", arch: "arm", variant: "6", assets: []string{"arm64", "armv7", "arm"}, want: "arm"},
+		{name: "bare_arm_does_not_match_arm64", arch: "arm", variant: "", assets: []string{"arm64", "armv7"}, want: ""},
+		{name: "arm64", arch: "arm64", variant: "", assets: []string{"armv7", "armv6", "arm64"}, want: "arm64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalIsWindows := isWindows
+			originalTranslated := isRosettaTranslated
+			originalArch := goArch
+			originalVariant := armVariant
+			isWindows = func() bool { return false }
+			isRosettaTranslated = func() bool { return false }
+			goArch = func() string { return tt.arch }
+			armVariant = func() string { return tt.variant }
+			defer func() {
+				isWindows = originalIsWindows
+				isRosettaTranslated = originalTranslated
+				goArch = originalArch
+				armVariant = originalVariant
+			}()
+
+			release := &GitHubRelease{}
+			for _, arch := range tt.assets {
+				release.Assets = append(release.Assets, struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+					Size               int64  `json:"size"`
+				}{Name: names[arch], BrowserDownloadURL: "http://example.com/" + arch})
+			}
+
+			want := ""
+			if tt.want != "" {
+				want = "http://example.com/" + tt.want
+			}
+			if url := findAssetURL(release); url != want {
+				t.Errorf("Expected %q, got %q", want, url)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// DOWNLOAD AND INSTALL ERROR TESTS
+// =============================================================================
+// Tests for various error scenarios in download and install
+// =============================================================================
+
+func TestDownloadAndInstallErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func()
+		expectedError string
+	}{
+		{
+			name: "executable error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "", errors.New("exe error")
+				}
+			},
+			expectedError: "exe error",
+		},
+		{
+			name: "HTTP error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "test.exe", nil
+				}
+			},
+			expectedError: "",
+		},
+		{
+			name: "temp file creation error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "test.exe", nil
+				}
+			},
+			expectedError: "",
+		},
+		{
+			name: "io copy error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "test.exe", nil
+				}
+			},
+			expectedError: "",
+		},
+		{
+			name: "extract error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "test.exe", nil
+				}
+			},
+			expectedError: "failed to extract archive",
+		},
+		{
+			name: "replace error",
+			setupMock: func() {
+				osExecutable = func() (string, error) {
+					return "test.exe", nil
+				}
+				replaceExecutableFunc = func(current, new string) error {
+					return errors.New("replace failed")
+				}
+			},
+			expectedError: "replace failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalOsExecutable := osExecutable
+			originalClient := httpClient
+			originalReplaceFunc := replaceExecutableFunc
+
+			// Create a failing server
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.name == "HTTP error" {
+					http.Error(w, "Server Error", http.StatusInternalServerError)
+				} else if tt.name == "io copy error" {
+					// Start writing but don't finish
+					w.Header().Set("Content-Length", "1000")
+					w.Write([]byte("partial"))
+					// Close connection abruptly
+					if hijacker, ok := w.(http.Hijacker); ok {
+						if conn, _, err := hijacker.Hijack(); err == nil {
+							conn.Close()
+						}
+					}
+				} else if tt.name == "extract error" {
+					// Return invalid archive data for .zip URL
+					w.Write([]byte("invalid archive data"))
+				} else {
+					w.Write([]byte("mock binary content"))
+				}
+			}))
+			defer server.Close()
+
+			httpClient = &http.Client{}
+			replaceExecutableFunc = func(current, new string) error {
+				return nil
+			}
+			tt.setupMock()
+
+			defer func() {
+				osExecutable = originalOsExecutable
+				httpClient = originalClient
+				replaceExecutableFunc = originalReplaceFunc
+			}()
+
+			url := server.URL
+			if tt.name == "extract error" {
+				url = server.URL + "/test.zip"
+			}
+			
+			err := downloadAndInstall(url, "")
+			if tt.expectedError == "" && err == nil {
+				// Expected no error
+			} else if err == nil && tt.expectedError != "" {
+				t.Errorf("Expected error containing %q, got nil", tt.expectedError)
+			} else if tt.expectedError != "" && err != nil && !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestDownloadAndInstallWithMockedCreateTemp(t *testing.T) {
+	originalOsCreateTemp := osCreateTemp
+	originalOsExecutable := osExecutable
+	
+	osExecutable = func() (string, error) {
+		return "test.exe", nil
+	}
+	
+	osCreateTemp = func(dir, pattern string) (*os.File, error) {
+		return nil, errors.New("mock CreateTemp error")
+	}
+	
+	defer func() {
+		osCreateTemp = originalOsCreateTemp
+		osExecutable = originalOsExecutable
+	}()
+	
+	err := downloadAndInstall("http://example.com/test", "")
+	if err == nil || !strings.Contains(err.Error(), "mock CreateTemp error") {
+		t.Errorf("Expected CreateTemp error, got %v", err)
+	}
+}
+
+func TestDownloadAndInstallAdditionalErrors(t *testing.T) {
+	t.Run("http get error", func(t *testing.T) {
+		originalClient := httpClient
+		originalOsExecutable := osExecutable
+		
+		osExecutable = func() (string, error) {
+			return "test.exe", nil
+		}
+		
+		// Set invalid HTTP client
+		httpClient = &http.Client{
+			Timeout: 1, // 1 nanosecond timeout to force error
+		}
+		
+		defer func() {
+			httpClient = originalClient
+			osExecutable = originalOsExecutable
+		}()
+		
+		err := downloadAndInstall("http://invalid.local/test", "")
+		if err == nil {
+			t.Error("Expected error for invalid URL")
+		}
+	})
+}
+
+// =============================================================================
+// EXECUTABLE REPLACEMENT TESTS
+// =============================================================================
+// Tests for executable file replacement on different platforms
+// =============================================================================
+
+func TestReplaceExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Run("windows", func(t *testing.T) {
+			// Create temp files
+			currentFile, err := os.CreateTemp("", "current_*.exe")
+			if err != nil {
+				t.Fatal(err)
+			}
+			currentFile.Write([]byte("current"))
+			currentFile.Close()
+			defer os.Remove(currentFile.Name())
+
+			newFile, err := os.CreateTemp("", "new_*.exe")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newFile.Write([]byte("new"))
+			newFile.Close()
+			defer os.Remove(newFile.Name())
+
+			// Test replace
+			err = replaceExecutable(currentFile.Name(), newFile.Name())
+			if err != nil {
+				t.Errorf("replaceExecutable() error = %v", err)
+			}
+
+			// Check that backup was created
+			backupPath := currentFile.Name() + ".old"
+			if _, err := os.Stat(backupPath); err == nil {
+				// Backup exists, clean it up
+				os.Remove(backupPath)
+			}
+		})
+	} else {
+		t.Run("unix", func(t *testing.T) {
+			// Create temp files
+			currentFile, err := os.CreateTemp("", "current_*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			currentFile.Write([]byte("current"))
+			currentFile.Close()
+			defer os.Remove(currentFile.Name())
+
+			newFile, err := os.CreateTemp("", "new_*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newFile.Write([]byte("new"))
+			newFile.Close()
+
+			// Test replace
+			err = replaceExecutable(currentFile.Name(), newFile.Name())
+			if err != nil {
+				t.Errorf("replaceExecutable() error = %v", err)
+			}
+
+			// Check content
+			content, err := os.ReadFile(currentFile.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "new" {
+				t.Errorf("Expected content 'new', got %s", string(content))
+			}
+		})
+	}
+}
+
+func TestReplaceExecutableKeepsExecuteBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("execute bits are a Unix concept")
+	}
+
+	tests := []struct {
+		name        string
+		currentMode os.FileMode
+		wantMode    os.FileMode
+	}{
+		{"preserves_original_mode", 0750, 0750},
+		{"restores_execute_bits", 0644, 0755},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			currentPath := filepath.Join(tempDir, "secret_manager")
+			newPath := filepath.Join(tempDir, "secret_manager_extract_1")
+			os.WriteFile(currentPath, []byte("current"), tt.currentMode)
+			os.Chmod(currentPath, tt.currentMode)
+			// The extracted binary lost its execute bits
+			os.WriteFile(newPath, []byte("new"), 0600)
+
+			if err := replaceExecutable(currentPath, newPath); err != nil {
+				t.Fatalf("replaceExecutable() error = %v", err)
+			}
+
+			info, err := os.Stat(currentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.wantMode {
+				t.Errorf("Expected mode %o, got %o", tt.wantMode, info.Mode().Perm())
+			}
+		})
+	}
+
+	t.Run("chmod_error", func(t *testing.T) {
+		originalOsChmod := osChmod
+		osChmod = func(name string, mode os.FileMode) error {
+			return errors.New("mock chmod error")
+		}
+		defer func() { osChmod = originalOsChmod }()
+
+		tempDir := t.TempDir()
+		currentPath := filepath.Join(tempDir, "secret_manager")
+		newPath := filepath.Join(tempDir, "new")
+		os.WriteFile(currentPath, []byte("current"), 0755)
+		os.WriteFile(newPath, []byte("new"), 0600)
+
+		err := replaceExecutable(currentPath, newPath)
+		if err == nil || !strings.Contains(err.Error(), "mock chmod error") {
+			t.Errorf("Expected chmod error, got %v", err)
+		}
+	})
+}
+
+func TestReplaceExecutableErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Run("windows rename error", func(t *testing.T) {
+			// Test when we can't rename the current executable
+			err := replaceExecutable("/nonexistent/path/exe.exe", "new.exe")
+			if err == nil {
+				t.Error("Expected error for nonexistent path")
+			}
+		})
+		
+		t.Run("windows install error", func(t *testing.T) {
+			// Create a read-only directory to cause rename failure
+			tempDir, err := os.MkdirTemp("", "readonly*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+			
+			// Create current file
+			currentPath := tempDir + "\\current.exe"
+			if err := os.WriteFile(currentPath, []byte("current"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			
+			// Test with nonexistent new file
+			err = replaceExecutable(currentPath, "/nonexistent/new.exe")
+			if err == nil {
+				t.Error("Expected error for nonexistent new file")
+			}
+		})
+	} else {
+		t.Run("unix rename error", func(t *testing.T) {
+			// Test when we can't rename
+			err := replaceExecutable("/nonexistent/current", "/nonexistent/new")
+			if err == nil {
+				t.Error("Expected error for nonexistent files")
+			}
+		})
+	}
+}
+
+func TestReplaceExecutableUnixPath(t *testing.T) {
+	// Save originals
+	originalIsWindows := isWindows
+	originalOsRename := osRename
+	originalOsChmod := osChmod
+	defer func() {
+		isWindows = originalIsWindows
+		osRename = originalOsRename
+		osChmod = originalOsChmod
+	}()
+
+	// Mock as Unix system
+	isWindows = func() bool { return false }
+	osChmod = func(name string, mode os.FileMode) error {
+		if name != "/tmp/current" || mode != 0755 {
+			t.Errorf("Unexpected chmod %s %o", name, mode)
+		}
+		return nil
+	}
+
+	// Test successful rename
+	renameCalled := false
+	osRename = func(oldpath, newpath string) error {
+		renameCalled = true
+		if oldpath != "/tmp/new" || newpath != "/tmp/current" {
+			t.Errorf("Unexpected rename paths: %s -> %s", oldpath, newpath)
+		}
+		return nil
+	}
+
+	err := replaceExecutable("/tmp/current", "/tmp/new")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if !renameCalled {
+		t.Error("Expected osRename to be called")
+	}
+
+	// Test rename failure
+	osRename = func(oldpath, newpath string) error {
+		return errors.New("rename failed")
+	}
+
+	err = replaceExecutable("/tmp/current", "/tmp/new")
+	if err == nil {
+		t.Error("Expected error when rename fails")
+	}
+}
+
+func TestReplaceExecutableUnixPaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping Unix-specific test on Windows")
+	}
+	
+	// Create temp files
+	currentFile, err := os.CreateTemp("", "current_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentFile.Write([]byte("current"))
+	currentFile.Close()
+	defer os.Remove(currentFile.Name())
+
+	newFile, err := os.CreateTemp("", "new_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile.Write([]byte("new"))
+	newFile.Close()
+
+	// Test replace
+	err = replaceExecutable(currentFile.Name(), newFile.Name())
+	if err != nil {
+		t.Errorf("replaceExecutable() error = %v", err)
+	}
+
+	// Check content
+	content, err := os.ReadFile(currentFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "new" {
+		t.Errorf("Expected content 'new', got %s", string(content))
+	}
+}
+func TestFindAssetURLTargetOverride(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	release := &GitHubRelease{
+		Assets: []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+			Size               int64  `json:"size"`
+		}{
+			{Name: "secret_manager-linux-amd64", BrowserDownloadURL: "http://example.com/linux-amd64"},
+			{Name: "secret_manager-darwin-arm64", BrowserDownloadURL: "http://example.com/darwin-arm64"},
+			{Name: "secret_manager-windows-amd64.exe", BrowserDownloadURL: "http://example.com/windows-amd64.exe"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		targetOS   string
+		targetArch string
+		want       string
+	}{
+		{name: "windows", targetOS: "windows", targetArch: "amd64", want: "http://example.com/windows-amd64.exe"},
+		{name: "darwin", targetOS: "darwin", targetArch: "arm64", want: "http://example.com/darwin-arm64"},
+		{name: "not published", targetOS: "freebsd", targetArch: "amd64", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.TargetOS = tt.targetOS
+			opts.TargetArch = tt.targetArch
+			if got := findAssetURL(release); got != tt.want {
+				t.Errorf("findAssetURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAndUpdateDownloadTo(t *testing.T) {
+	originalVersion := version
+	originalClient := httpClient
+	originalReplace := replaceExecutableFunc
+	originalOpts := opts
+
+	dir := t.TempDir()
+	fileURL := func(path string) string {
+		path = filepath.ToSlash(path)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return "file://" + path
+	}
+	writeAsset := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, archiveBytes(t, archiveTarGz, content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fileURL(path)
+	}
+	nativeName := fmt.Sprintf("secret_manager-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	release := fmt.Sprintf(`{"tag_name": "v1.1.0", "assets": [{"name": %q, "browser_download_url": %q}, {"name": %q, "browser_download_url": %q}]}`,
+		nativeName, writeAsset(nativeName, "native binary"),
+		"secret_manager-windows-arm64.exe.tar.gz", writeAsset("windows.tar.gz", "windows binary"))
+	releaseFile := filepath.Join(dir, "release.json")
+	if err := os.WriteFile(releaseFile, []byte(release), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The running version is already the latest; a download is still made
+	version = "v1.1.0"
+	opts.ReleaseFile = releaseFile
+	opts.TargetOS = "windows"
+	opts.TargetArch = "arm64"
+	opts.DownloadTo = filepath.Join(dir, "secret_manager.exe")
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("Unexpected request to %s", req.URL)
+		return nil, errors.New("offline")
+	})}
+	replaceExecutableFunc = func(current, new string) error {
+		t.Error("The running executable must not be replaced with -download-to")
+		return nil
+	}
+
+	defer func() {
+		version = originalVersion
+		httpClient = originalClient
+		replaceExecutableFunc = originalReplace
+		opts = originalOpts
+	}()
+
+	var err error
+	output := captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err != nil {
+		t.Fatalf("checkAndUpdate() error = %v", err)
+	}
+	saved, err := os.ReadFile(opts.DownloadTo)
+	if err != nil {
+		t.Fatalf("Expected the download to be saved: %v", err)
+	}
+	if string(saved) != "windows binary" {
+		t.Errorf("Expected the windows asset to be saved, got %q", saved)
+	}
+	if !strings.Contains(output, "Saved v1.1.0 to "+opts.DownloadTo) {
+		t.Errorf("Expected the saved path to be reported, got %q", output)
+	}
+
+	// No asset for the requested platform
+	opts.TargetOS = "plan9"
+	captureStdout(t, func() {
+		err = checkAndUpdate()
+	})
+	if err == nil || !strings.Contains(err.Error(), "no suitable binary found for plan9/arm64") {
+		t.Errorf("Expected a missing asset error, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package secretmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// varPattern matches ${key} placeholders
+var varPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// targetVars holds the values loaded with -vars
+var targetVars map[string]string
+
+// loadVars reads the -vars file, a flat JSON object of string values
+func loadVars(path string) error {
+	targetVars = nil
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read -vars file: %w", err)
+	}
+
+	vars := make(map[string]string)
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return fmt.Errorf("invalid -vars file %s: %w", path, err)
+	}
+	targetVars = vars
+	return nil
+}
+
+// substituteVars replaces ${key} placeholders in s. Undefined keys are an
+// error unless -allow-undefined-vars is set, in which case they are left in
+// place and reported as a warning
+func substituteVars(s string) (string, error) {
+	var undefined []string
+	result := varPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		key := varPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := targetVars[key]; ok {
+			return value
+		}
+		undefined = append(undefined, key)
+		return placeholder
+	})
+
+	if len(undefined) > 0 {
+		err := fmt.Errorf("undefined variable %s", strings.Join(undefined, ", "))
+		if !opts.AllowUndefinedVars {
+			return "", err
+		}
+		warnTarget("Warning: %v in %s\n", err, s)
+	}
+	return result, nil
+}
+
+// substituteTargetVars applies substituteVars to a target's path and
+// description. Without -vars they are left as they are, so that manifests
+// written before -vars existed may contain a literal ${
+func substituteTargetVars(target *Target) error {
+	if targetVars == nil {
+		return nil
+	}
+	path, err := substituteVars(target.Path)
+	if err != nil {
+		return err
+	}
+	description, err := substituteVars(target.Description)
+	if err != nil {
+		return err
+	}
+	target.Path, target.Description = path, description
+	return nil
+}
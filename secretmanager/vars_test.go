@@ -0,0 +1,117 @@
+package secretmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// =============================================================================
+// VARS TESTS
+// =============================================================================
+// Tests for ${key} placeholders substituted from the -vars file
+// =============================================================================
+
+func TestExpandTargetsVars(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       bool
+		wantTargets []Target
+		wantFailed  int
+	}{
+		{
+			name: "undefined_fails_target",
+			wantTargets: []Target{
+				{Path: "/srv/payments/api.key", Description: "payments key (prod)"},
+			},
+			wantFailed: 1,
+		},
+		{
+			name:  "undefined_allowed",
+			allow: true,
+			wantTargets: []Target{
+				{Path: "/srv/payments/api.key", Description: "payments key (prod)"},
+				{Path: "/srv/${region}/api.key"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			varsPath := filepath.Join(t.TempDir(), "vars.json")
+			createFile(t, varsPath, `{"service": "payments", "env": "prod"}`)
+
+			originalOpts := opts
+			originalSummary := runSummary
+			defer func() {
+				opts = originalOpts
+				runSummary = originalSummary
+				targetVars = nil
+			}()
+			opts.AllowUndefinedVars = tt.allow
+			opts.WarningsTo = "stdout"
+			runSummary = &RunSummary{}
+			if err := loadVars(varsPath); err != nil {
+				t.Fatalf("loadVars() error = %v", err)
+			}
+
+			config := SymlinkConfig{Targets: []Target{
+				{Path: "/srv/${service}/api.key", Description: "${service} key (${env})"},
+				{Path: "/srv/${region}/api.key"},
+			}}
+			var targets []Target
+			captureStdout(t, func() {
+				targets = expandTargets("source", config)
+			})
+
+			if len(targets) != len(tt.wantTargets) {
+				t.Fatalf("Expected %d targets, got %+v", len(tt.wantTargets), targets)
+			}
+			for i, want := range tt.wantTargets {
+				if targets[i].Path != want.Path || targets[i].Description != want.Description {
+					t.Errorf("Target %d: expected %+v, got %+v", i, want, targets[i])
+				}
+			}
+			if got := runSummary.count(actionFailed); got != tt.wantFailed {
+				t.Errorf("Expected %d failed targets, got %d", tt.wantFailed, got)
+			}
+			if config.Targets[0].Path != "/srv/${service}/api.key" {
+				t.Error("expandTargets should not modify the parsed config")
+			}
+		})
+	}
+}
+
+func TestExpandTargetsWithoutVars(t *testing.T) {
+	originalSummary := runSummary
+	defer func() { runSummary = originalSummary }()
+	runSummary = &RunSummary{}
+	targetVars = nil
+
+	config := SymlinkConfig{Targets: []Target{{Path: "/srv/${literal}/api.key", Description: "costs ${5}"}}}
+	targets := expandTargets("source", config)
+	if len(targets) != 1 || targets[0].Path != "/srv/${literal}/api.key" || targets[0].Description != "costs ${5}" {
+		t.Errorf("Expected the target unchanged without -vars, got %+v", targets)
+	}
+	if runSummary.count(actionFailed) != 0 {
+		t.Errorf("Expected no failures without -vars, got %+v", runSummary.Results)
+	}
+}
+
+func TestLoadVarsErrors(t *testing.T) {
+	defer func() { targetVars = nil }()
+
+	if err := loadVars(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for a missing -vars file")
+	}
+
+	path := filepath.Join(t.TempDir(), "vars.json")
+	os.WriteFile(path, []byte(`{"port": 8080}`), 0644)
+	if err := loadVars(path); err == nil {
+		t.Error("Expected error for non-string values")
+	}
+
+	if err := loadVars(""); err != nil || targetVars != nil {
+		t.Errorf("Expected no vars without -vars, got %v, %v", targetVars, err)
+	}
+}
@@ -0,0 +1,42 @@
+package secretmanager
+
+import (
+	"fmt"
+	"io"
+)
+
+// predictOutcomes dry-runs secretDirs without printing and returns the
+// result the dry run predicted for each target
+func predictOutcomes(secretDirs []string) []LinkResult {
+	var predicted []LinkResult
+	quietly(func() {
+		opts.DryRun = true
+		defer func() { opts.DryRun = false }()
+		for _, secretDir := range secretDirs {
+			processSecretDirectory(secretDir)
+		}
+		predicted = runSummary.Results
+	})
+	return predicted
+}
+
+// writeApplyVerification health-checks every target the dry run predicted
+// would be linked and writes a MISMATCH line for each one that is not in
+// place after the apply, followed by the totals. It reports whether the
+// applied state matched the prediction
+func writeApplyVerification(w io.Writer, predicted []LinkResult) bool {
+	checked, mismatched := 0, 0
+	for _, result := range predicted {
+		if result.Action != actionPlanned {
+			continue
+		}
+		checked++
+		if err := checkLinkHealth(graphEdge{source: result.Source, target: result.Target}); err != nil {
+			mismatched++
+			fmt.Fprintf(w, "MISMATCH %s: predicted a link to %s, found %v\n", result.Target, result.Source, err)
+		}
+	}
+
+	fmt.Fprintf(w, "Verify: %d predicted links checked, %d mismatched\n", checked, mismatched)
+	return mismatched == 0
+}
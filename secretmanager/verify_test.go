@@ -0,0 +1,90 @@
+package secretmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// =============================================================================
+// APPLY THEN VERIFY TESTS
+// =============================================================================
+// Tests for comparing the applied links against the dry-run prediction
+// =============================================================================
+
+func TestMainApplyThenVerify(t *testing.T) {
+	originalExit := exitFunc
+	originalExeDir := executableDir
+	originalReadlink := readlinkFunc
+	originalSymlink := symlinkFunc
+	originalOpts := opts
+
+	tempDir := setupTestDir(t)
+	defer os.RemoveAll(tempDir)
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+
+	goodTarget := filepath.Join(tempDir, "good.key")
+	badTarget := filepath.Join(tempDir, "bad.key")
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key"), "key")
+	createFile(t, filepath.Join(tempDir, "app_secret", "api.key.symlink.json"),
+		fmt.Sprintf(`{"targets":[{"path":%q},{"path":%q}]}`, goodTarget, badTarget))
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	executableDir = func() (string, error) { return tempDir, nil }
+	readlinkFunc = os.Readlink
+	opts.NoOwnerCheck = true
+	opts.ApplyThenVerify = true
+
+	defer func() {
+		exitFunc = originalExit
+		executableDir = originalExeDir
+		readlinkFunc = originalReadlink
+		symlinkFunc = originalSymlink
+		opts = originalOpts
+	}()
+
+	// The dry run predicts both links; the apply then fails for one of them
+	symlinkFunc = func(oldname, newname string) error {
+		if newname == badTarget {
+			return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: syscall.EACCES}
+		}
+		return os.Symlink(oldname, newname)
+	}
+
+	output := captureStdout(t, main)
+
+	if _, err := os.Lstat(goodTarget); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 for a mismatch, got %d:\n%s", exitCode, output)
+	}
+	if !strings.Contains(output, "MISMATCH "+badTarget+": predicted a link to ") || !strings.Contains(output, "missing") {
+		t.Errorf("Expected a mismatch for %s, got:\n%s", badTarget, output)
+	}
+	if strings.Contains(output, "MISMATCH "+goodTarget) {
+		t.Errorf("Expected no mismatch for %s, got:\n%s", goodTarget, output)
+	}
+	if !strings.Contains(output, "Verify: 2 predicted links checked, 1 mismatched") {
+		t.Errorf("Expected verification totals, got:\n%s", output)
+	}
+	if strings.Contains(output, "Would create symlink") {
+		t.Errorf("Expected the dry run to be silent, got:\n%s", output)
+	}
+}
+
+func TestValidateApplyThenVerify(t *testing.T) {
+	originalOpts := opts
+	defer func() { opts = originalOpts }()
+
+	opts.ApplyThenVerify = true
+	opts.DryRun = true
+	if err := validateOptions(); err == nil {
+		t.Error("Expected -dry-run-apply-then-verify with -dry-run to be rejected")
+	}
+}